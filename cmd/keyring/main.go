@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+const usage = `Manage the PASETO keyring used to sign access and refresh tokens.
+
+Usage:
+  keyring [-file path] list
+  keyring [-file path] generate [local|public]
+  keyring [-file path] promote <key-id>
+  keyring [-file path] retire <key-id>
+
+Local keys encrypt v4.local tokens and public keys sign v4.public ones; pick
+the format with APP_TOKEN_FORMAT. The first key of each purpose is current
+right away. Later keys only verify tokens until they're promoted: roll the
+keyring out to every instance before promoting, and retire a replaced key
+once the tokens it signed have expired.
+`
+
+func main() {
+	file := flag.String("file", config.App.TokenKeyringFile, "keyring file")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*file, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(file string, args []string) (err error) {
+	var keyring *token.Keyring
+
+	if len(file) == 0 {
+		return errors.New("missing keyring file, set -file or APP_TOKEN_KEYRING_FILE")
+	}
+
+	if len(args) == 0 {
+		flag.Usage()
+		return errors.New("missing command")
+	}
+
+	if keyring, err = token.LoadKeyring(file); err != nil {
+		if !errors.Is(err, os.ErrNotExist) || args[0] != "generate" {
+			return err
+		}
+
+		keyring = &token.Keyring{}
+	}
+
+	switch args[0] {
+	case "list":
+		return list(keyring)
+	case "generate":
+		var key *token.Key
+
+		purpose := token.PurposeLocal
+
+		if len(args) > 1 {
+			purpose = args[1]
+		}
+
+		if key, err = keyring.Generate(purpose); err != nil {
+			return err
+		}
+
+		fmt.Printf("generated %s key %s (%s)\n", purpose, key.ID, key.Status)
+	case "promote", "retire":
+		if len(args) < 2 {
+			return fmt.Errorf("%s: missing key id", args[0])
+		}
+
+		if args[0] == "promote" {
+			err = keyring.Promote(args[1])
+		} else {
+			err = keyring.Retire(args[1])
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%sd key %s\n", args[0], args[1])
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	return keyring.Save(file)
+}
+
+func list(keyring *token.Keyring) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPURPOSE\tSTATUS\tCREATED AT")
+
+	for _, key := range keyring.Keys {
+		purpose := key.Purpose
+
+		if len(purpose) == 0 {
+			purpose = token.PurposeLocal
+		}
+
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\n",
+			key.ID,
+			purpose,
+			key.Status,
+			key.CreatedAt.Format("2006-01-02 15:04:05"),
+		)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dharmavagabond/simple-bank/internal/worker"
+)
+
+const usage = `Inspect and manage the task processor's queues in Redis.
+
+Usage:
+  tasks queues
+  tasks schedules
+  tasks list <queue> [pending|active|scheduled|retry|archived] [page]
+  tasks show <queue> <task-id>
+  tasks run <queue> <task-id>
+  tasks delete <queue> <task-id>
+  tasks run-all <queue> retry|archived
+  tasks delete-all <queue> retry|archived
+
+Queues are critical and default. list shows archived (dead) tasks unless
+given another state. run requeues a scheduled, retry or archived task to run
+right away. schedules shows the periodic tasks and their last run. This talks to Redis directly, with the APP_REDIS_* settings: for
+admin users without Redis access, the REST API offers the same under
+/admin/queues.
+`
+
+const pageSize = 20
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	admin := worker.NewRedisTaskAdmin()
+	err := run(admin, flag.Args())
+	_ = admin.Close()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(admin *worker.TaskAdmin, args []string) (err error) {
+	if len(args) == 0 {
+		flag.Usage()
+		return errors.New("missing command")
+	}
+
+	switch args[0] {
+	case "queues":
+		return listQueues(admin)
+	case "schedules":
+		return listSchedules(admin)
+	case "list":
+		if len(args) < 2 {
+			return errors.New("list: missing queue")
+		}
+
+		state := worker.TaskStateArchived
+		page := 1
+
+		if len(args) > 2 {
+			state = args[2]
+		}
+
+		if len(args) > 3 {
+			if page, err = strconv.Atoi(args[3]); err != nil || page < 1 {
+				return fmt.Errorf("list: invalid page %q", args[3])
+			}
+		}
+
+		return listTasks(admin, args[1], state, page)
+	case "show", "run", "delete":
+		if len(args) < 3 {
+			return fmt.Errorf("%s: missing queue or task id", args[0])
+		}
+
+		switch args[0] {
+		case "show":
+			return showTask(admin, args[1], args[2])
+		case "run":
+			err = admin.RunTask(args[1], args[2])
+		default:
+			err = admin.DeleteTask(args[1], args[2])
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s task %s\n", pastTense(args[0]), args[2])
+	case "run-all", "delete-all":
+		var count int
+
+		if len(args) < 3 {
+			return fmt.Errorf("%s: missing queue or state", args[0])
+		}
+
+		if args[0] == "run-all" {
+			count, err = admin.RunAllTasks(args[1], args[2])
+		} else {
+			count, err = admin.DeleteAllTasks(args[1], args[2])
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s %d %s tasks\n", pastTense(strings.TrimSuffix(args[0], "-all")), count, args[2])
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	return nil
+}
+
+func listQueues(admin *worker.TaskAdmin) error {
+	queues, err := admin.Queues()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "QUEUE\tSIZE\tPENDING\tACTIVE\tSCHEDULED\tRETRY\tARCHIVED\tLATENCY\tPAUSED")
+
+	for _, queue := range queues {
+		fmt.Fprintf(
+			w,
+			"%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%t\n",
+			queue.Queue,
+			queue.Size,
+			queue.Pending,
+			queue.Active,
+			queue.Scheduled,
+			queue.Retry,
+			queue.Archived,
+			time.Duration(queue.LatencySeconds*float64(time.Second)).Round(time.Millisecond),
+			queue.Paused,
+		)
+	}
+
+	return w.Flush()
+}
+
+func listSchedules(admin *worker.TaskAdmin) error {
+	schedules, err := admin.Schedules(context.Background())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tCRON\tSTATUS\tENQUEUED AT\tFINISHED AT\tERROR")
+
+	for _, schedule := range schedules {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%s\n",
+			schedule.Type,
+			valueOrDash(schedule.Cron),
+			valueOrDash(schedule.Status),
+			formatTime(schedule.EnqueuedAt),
+			formatTime(schedule.FinishedAt),
+			schedule.Error,
+		)
+	}
+
+	return w.Flush()
+}
+
+func listTasks(admin *worker.TaskAdmin, queue, state string, page int) error {
+	tasks, err := admin.ListTasks(queue, state, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPE\tRETRIED\tLAST FAILED AT\tLAST ERROR")
+
+	for _, task := range tasks {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%d/%d\t%s\t%s\n",
+			task.ID,
+			task.Type,
+			task.Retried,
+			task.MaxRetry,
+			formatTime(task.LastFailedAt),
+			task.LastErr,
+		)
+	}
+
+	return w.Flush()
+}
+
+func showTask(admin *worker.TaskAdmin, queue, id string) error {
+	task, err := admin.GetTask(queue, id)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(task)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func valueOrDash(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+
+	return value
+}
+
+func pastTense(command string) string {
+	if command == "run" {
+		return "requeued"
+	}
+
+	return command + "d"
+}
@@ -0,0 +1,162 @@
+package activity
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+const (
+	subscriptionBuffer = 64
+	reconnectDelay     = time.Second
+)
+
+var (
+	ERR_SLOW_SUBSCRIBER = errors.New("[Err]: The subscriber fell behind the account activity")
+	ERR_HUB_CLOSED      = errors.New("[Err]: The account activity hub is closed")
+)
+
+// Hub fans the account activity notified by Postgres out to the subscribers
+// of each account. Activity notified while the hub reconnects is lost, so
+// subscribers should treat it as a live feed, not a ledger.
+type Hub struct {
+	subscribers map[int64]map[*Subscription]struct{}
+	mu          sync.Mutex
+	closed      bool
+}
+
+type Subscription struct {
+	err        error
+	hub        *Hub
+	ch         chan db.AccountActivity
+	accountIDs []int64
+	closed     bool
+}
+
+// C receives the subscribed accounts' activity. It's closed when the
+// subscription is.
+func (sub *Subscription) C() <-chan db.AccountActivity {
+	return sub.ch
+}
+
+// Err is ERR_SLOW_SUBSCRIBER when the hub closed the subscription because its
+// buffer was full, and ERR_HUB_CLOSED when the hub itself was closed.
+func (sub *Subscription) Err() error {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+	return sub.err
+}
+
+func (sub *Subscription) Close() {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+	sub.hub.remove(sub)
+}
+
+func (hub *Hub) Subscribe(accountIDs ...int64) *Subscription {
+	sub := &Subscription{
+		hub:        hub,
+		ch:         make(chan db.AccountActivity, subscriptionBuffer),
+		accountIDs: accountIDs,
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.closed {
+		sub.err = ERR_HUB_CLOSED
+		close(sub.ch)
+		sub.closed = true
+
+		return sub
+	}
+
+	for _, id := range accountIDs {
+		if hub.subscribers[id] == nil {
+			hub.subscribers[id] = map[*Subscription]struct{}{}
+		}
+
+		hub.subscribers[id][sub] = struct{}{}
+	}
+
+	return sub
+}
+
+// Publish never blocks: a subscriber whose buffer is full is closed instead.
+func (hub *Hub) Publish(activity db.AccountActivity) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for sub := range hub.subscribers[activity.AccountID] {
+		select {
+		case sub.ch <- activity:
+		default:
+			sub.err = ERR_SLOW_SUBSCRIBER
+			hub.remove(sub)
+		}
+	}
+}
+
+// Close closes every subscription, and those made afterwards, so the
+// subscribers stop on shutdown.
+func (hub *Hub) Close() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.closed = true
+
+	for _, subs := range hub.subscribers {
+		for sub := range subs {
+			sub.err = ERR_HUB_CLOSED
+			hub.remove(sub)
+		}
+	}
+}
+
+// Run listens to the store's account activity until ctx is done,
+// reconnecting when the listener fails.
+func (hub *Hub) Run(ctx context.Context, store db.Store) error {
+	for {
+		err := store.ListenAccountActivity(ctx, hub.Publish)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		log.Error().Err(err).Msg("[account-activity] listener failed, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// remove must be called with the hub locked.
+func (hub *Hub) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+
+	for _, id := range sub.accountIDs {
+		delete(hub.subscribers[id], sub)
+
+		if len(hub.subscribers[id]) == 0 {
+			delete(hub.subscribers, id)
+		}
+	}
+
+	close(sub.ch)
+	sub.closed = true
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: map[int64]map[*Subscription]struct{}{},
+	}
+}
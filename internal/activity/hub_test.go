@@ -0,0 +1,71 @@
+package activity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(1, 2)
+	other := hub.Subscribe(3)
+
+	hub.Publish(db.AccountActivity{AccountID: 2, EntryID: 10})
+	hub.Publish(db.AccountActivity{AccountID: 3, EntryID: 11})
+	hub.Publish(db.AccountActivity{AccountID: 4, EntryID: 12})
+
+	require.Equal(t, db.AccountActivity{AccountID: 2, EntryID: 10}, <-sub.C())
+	require.Equal(t, db.AccountActivity{AccountID: 3, EntryID: 11}, <-other.C())
+	require.Empty(t, sub.C())
+
+	sub.Close()
+	sub.Close()
+
+	_, ok := <-sub.C()
+	require.False(t, ok)
+	require.NoError(t, sub.Err())
+
+	hub.Publish(db.AccountActivity{AccountID: 1})
+	require.NotContains(t, hub.subscribers, int64(1))
+	require.Contains(t, hub.subscribers, int64(3))
+}
+
+func TestHubSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(1)
+
+	for i := 0; i <= subscriptionBuffer; i++ {
+		hub.Publish(db.AccountActivity{AccountID: 1, EntryID: int64(i)})
+	}
+
+	received := 0
+
+	for range sub.C() {
+		received++
+	}
+
+	require.Equal(t, subscriptionBuffer, received)
+	require.ErrorIs(t, sub.Err(), ERR_SLOW_SUBSCRIBER)
+	require.Empty(t, hub.subscribers)
+}
+
+func TestHubClose(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(1, 2)
+
+	hub.Close()
+
+	_, ok := <-sub.C()
+	require.False(t, ok)
+	require.ErrorIs(t, sub.Err(), ERR_HUB_CLOSED)
+	require.Empty(t, hub.subscribers)
+
+	late := hub.Subscribe(1)
+	_, ok = <-late.C()
+	require.False(t, ok)
+	require.ErrorIs(t, late.Err(), ERR_HUB_CLOSED)
+	late.Close()
+}
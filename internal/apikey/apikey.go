@@ -0,0 +1,133 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+const (
+	keyPrefix  = "sbk"
+	secretSize = 32
+)
+
+var (
+	ERR_MALFORMED_KEY = errors.New("[Err]: Malformed API key")
+	ERR_INVALID_KEY   = errors.New("[Err]: Invalid API key")
+	ERR_EXPIRED_KEY   = errors.New("[Err]: API key has expired")
+	ERR_REVOKED_KEY   = errors.New("[Err]: API key has been revoked")
+)
+
+// Generate returns a new key ID, the plaintext key, which is shown to the
+// client only once, and the hash of its secret, which is what gets stored.
+func Generate() (id uuid.UUID, key string, hashedSecret string, err error) {
+	secret := make([]byte, secretSize)
+
+	if _, err = rand.Read(secret); err != nil {
+		return uuid.Nil, "", "", err
+	}
+
+	id = uuid.New()
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+	key = strings.Join([]string{keyPrefix, hex.EncodeToString(id[:]), encodedSecret}, "_")
+
+	return id, key, HashSecret(encodedSecret), nil
+}
+
+// Parse splits a key of the form sbk_<hex id>_<secret>.
+func Parse(key string) (id uuid.UUID, secret string, err error) {
+	parts := strings.SplitN(key, "_", 3)
+
+	if len(parts) != 3 || parts[0] != keyPrefix || len(parts[2]) == 0 {
+		return uuid.Nil, "", ERR_MALFORMED_KEY
+	}
+
+	rawID, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return uuid.Nil, "", ERR_MALFORMED_KEY
+	}
+
+	if id, err = uuid.FromBytes(rawID); err != nil {
+		return uuid.Nil, "", ERR_MALFORMED_KEY
+	}
+
+	return id, parts[2], nil
+}
+
+// HashSecret uses a plain SHA-256 since the secret is random and long enough
+// to make a slow password hash unnecessary.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate checks the key and returns a payload acting as its owner,
+// limited to the key's scopes.
+func Authenticate(ctx context.Context, store db.Store, key string) (payload *token.Payload, err error) {
+	var (
+		apiKey db.GetApiKeyRow
+		id     uuid.UUID
+		secret string
+	)
+
+	if id, secret, err = Parse(key); err != nil {
+		return nil, err
+	}
+
+	dbID := pgtype.UUID{Bytes: id, Valid: true}
+
+	if apiKey, err = store.GetApiKey(ctx, dbID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ERR_INVALID_KEY
+		}
+
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(apiKey.HashedSecret)) != 1 {
+		return nil, ERR_INVALID_KEY
+	}
+
+	if apiKey.RevokedAt.Valid {
+		return nil, ERR_REVOKED_KEY
+	}
+
+	if time.Now().After(apiKey.ExpiresAt.Time) {
+		return nil, ERR_EXPIRED_KEY
+	}
+
+	if err = store.TouchApiKey(ctx, dbID); err != nil {
+		log.Warn().Err(err).Str("api_key", id.String()).Msg("failed to record the API key usage")
+	}
+
+	scopes := apiKey.Scopes
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	payload = &token.Payload{
+		ID:        id,
+		Username:  apiKey.Owner,
+		Role:      apiKey.Role,
+		Scopes:    scopes,
+		IssuedAt:  apiKey.CreatedAt.Time,
+		ExpiredAt: apiKey.ExpiresAt.Time,
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,110 @@
+package apikey
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+)
+
+func TestGenerateAndParse(t *testing.T) {
+	id, key, hashedSecret, err := Generate()
+	require.NoError(t, err)
+
+	parsedID, secret, err := Parse(key)
+	require.NoError(t, err)
+	require.Equal(t, id, parsedID)
+	require.Equal(t, hashedSecret, HashSecret(secret))
+
+	for _, malformed := range []string{"", "sbk_", "sbk_zz_secret", "abc_" + id.String() + "_secret"} {
+		_, _, err = Parse(malformed)
+		require.ErrorIs(t, err, ERR_MALFORMED_KEY)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	id, key, hashedSecret, err := Generate()
+	require.NoError(t, err)
+
+	dbID := pgtype.UUID{Bytes: id, Valid: true}
+	testCases := []struct {
+		err        error
+		name       string
+		key        string
+		buildStubs func(store *mocks.Store, row db.GetApiKeyRow)
+	}{
+		{
+			name: "OK",
+			key:  key,
+			buildStubs: func(store *mocks.Store, row db.GetApiKeyRow) {
+				store.EXPECT().GetApiKey(mock.Anything, dbID).Once().Return(row, nil)
+				store.EXPECT().TouchApiKey(mock.Anything, dbID).Once().Return(nil)
+			},
+		},
+		{
+			name: "WrongSecret",
+			key:  key + "x",
+			err:  ERR_INVALID_KEY,
+			buildStubs: func(store *mocks.Store, row db.GetApiKeyRow) {
+				store.EXPECT().GetApiKey(mock.Anything, dbID).Once().Return(row, nil)
+			},
+		},
+		{
+			name: "NotFound",
+			key:  key,
+			err:  ERR_INVALID_KEY,
+			buildStubs: func(store *mocks.Store, _ db.GetApiKeyRow) {
+				store.EXPECT().GetApiKey(mock.Anything, dbID).Once().Return(db.GetApiKeyRow{}, pgx.ErrNoRows)
+			},
+		},
+		{
+			name: "Revoked",
+			key:  key,
+			err:  ERR_REVOKED_KEY,
+			buildStubs: func(store *mocks.Store, row db.GetApiKeyRow) {
+				row.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+				store.EXPECT().GetApiKey(mock.Anything, dbID).Once().Return(row, nil)
+			},
+		},
+		{
+			name: "Expired",
+			key:  key,
+			err:  ERR_EXPIRED_KEY,
+			buildStubs: func(store *mocks.Store, row db.GetApiKeyRow) {
+				row.ExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+				store.EXPECT().GetApiKey(mock.Anything, dbID).Once().Return(row, nil)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			tc.buildStubs(store, db.GetApiKeyRow{
+				ID:           dbID,
+				Owner:        "alice",
+				Role:         "depositor",
+				HashedSecret: hashedSecret,
+				Scopes:       []string{"account:read"},
+				ExpiresAt:    pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+			})
+
+			payload, err := Authenticate(context.Background(), store, tc.key)
+			require.ErrorIs(t, err, tc.err)
+
+			if tc.err == nil {
+				require.Equal(t, "alice", payload.Username)
+				require.Equal(t, "depositor", payload.Role)
+				require.Equal(t, []string{"account:read"}, payload.Scopes)
+			}
+		})
+	}
+}
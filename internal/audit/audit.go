@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+const (
+	ActionLogin               = "user.login"
+	ActionCreateUser          = "user.create"
+	ActionUpdateUser          = "user.update"
+	ActionRenewToken          = "token.renew"
+	ActionReportSession       = "session.report"
+	ActionCreateAccount       = "account.create"
+	ActionCreateTransfer      = "transfer.create"
+	ActionCreateWebhook       = "webhook.create"
+	ActionDeleteWebhook       = "webhook.delete"
+	ActionRunTask             = "task.run"
+	ActionDeleteTask          = "task.delete"
+	ActionUpdateNotifications = "notification.update"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeDenied is a failure to authenticate or authorize the actor.
+	OutcomeDenied = "denied"
+)
+
+var ERR_BROKEN_CHAIN = errors.New("[Err]: The audit event chain was tampered with")
+
+// Record appends the event, logging instead of failing: by the time it's
+// recorded, the action has already taken place.
+func Record(ctx context.Context, store db.Store, event db.AppendAuditEventTxParams) {
+	if _, err := store.AppendAuditEventTx(ctx, event); err != nil {
+		log.Error().
+			Err(err).
+			Str("actor", event.Actor).
+			Str("action", event.Action).
+			Str("outcome", event.Outcome).
+			Msg("failed to record audit event")
+	}
+}
+
+// Verify checks that events, ordered by id, are chained to prevHash and to
+// each other, and that none was modified. prevHash is empty for the first
+// event ever recorded.
+func Verify(prevHash []byte, events []db.AuditEvent) error {
+	for _, event := range events {
+		if !bytes.Equal(event.PrevHash, prevHash) {
+			return fmt.Errorf("%w: event %d doesn't follow the previous one", ERR_BROKEN_CHAIN, event.ID)
+		}
+
+		if !bytes.Equal(db.HashAuditEvent(event), event.Hash) {
+			return fmt.Errorf("%w: event %d was modified", ERR_BROKEN_CHAIN, event.ID)
+		}
+
+		prevHash = event.Hash
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+func chain(t *testing.T, n int) []db.AuditEvent {
+	t.Helper()
+
+	var prevHash []byte
+
+	events := make([]db.AuditEvent, n)
+	createdAt := time.Now().Truncate(time.Microsecond)
+
+	for i := range events {
+		events[i] = db.AuditEvent{
+			ID:        int64(i + 1),
+			Actor:     "someone",
+			Action:    ActionCreateTransfer,
+			Resource:  "accounts/1",
+			Outcome:   OutcomeSuccess,
+			ClientIp:  "127.0.0.1",
+			UserAgent: "test",
+			PrevHash:  prevHash,
+			CreatedAt: pgtype.Timestamptz{Time: createdAt.Add(time.Duration(i) * time.Second), Valid: true},
+		}
+		events[i].Hash = db.HashAuditEvent(events[i])
+		prevHash = events[i].Hash
+	}
+
+	return events
+}
+
+func TestVerify(t *testing.T) {
+	events := chain(t, 5)
+	require.NoError(t, Verify(nil, events))
+	require.NoError(t, Verify(events[1].Hash, events[2:]))
+
+	modified := chain(t, 5)
+	modified[2].Outcome = OutcomeFailure
+	require.ErrorIs(t, Verify(nil, modified), ERR_BROKEN_CHAIN)
+
+	deleted := chain(t, 5)
+	deleted = append(deleted[:2], deleted[3:]...)
+	require.ErrorIs(t, Verify(nil, deleted), ERR_BROKEN_CHAIN)
+}
@@ -0,0 +1,152 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+type (
+	Action string
+	scope  int
+)
+
+const (
+	RoleDepositor = "depositor"
+	RoleBanker    = "banker"
+	RoleAuditor   = "auditor"
+	RoleAdmin     = "admin"
+
+	ActionReadAccount     Action = "account:read"
+	ActionWriteAccount    Action = "account:write"
+	ActionCreateTransfer  Action = "transfer:create"
+	ActionReadUser        Action = "user:read"
+	ActionWriteUser       Action = "user:write"
+	ActionManageUsers     Action = "user:manage"
+	ActionReadAuditEvents Action = "audit:read"
+	ActionManageWebhooks  Action = "webhook:manage"
+	ActionManageTasks     Action = "task:manage"
+)
+
+const (
+	scopeNone scope = iota
+	scopeOwn
+	scopeAny
+)
+
+var (
+	ERR_PERMISSION_DENIED = errors.New("[Err]: Permission denied")
+	ERR_NOT_OWNER         = errors.New("[Err]: The resource doesn't belong to the authenticated user")
+	ERR_SCOPE_NOT_GRANTED = errors.New("[Err]: The API key wasn't granted this scope")
+	ERR_INVALID_SCOPE     = errors.New("[Err]: Invalid scope")
+	ERR_SESSION_REQUIRED  = errors.New("[Err]: This action requires a user session, not an API key")
+)
+
+var policies = map[string]map[Action]scope{
+	RoleDepositor: {
+		ActionReadAccount:    scopeOwn,
+		ActionWriteAccount:   scopeOwn,
+		ActionCreateTransfer: scopeOwn,
+		ActionReadUser:       scopeOwn,
+		ActionWriteUser:      scopeOwn,
+		ActionManageWebhooks: scopeOwn,
+	},
+	RoleBanker: {
+		ActionReadAccount:    scopeAny,
+		ActionWriteAccount:   scopeOwn,
+		ActionCreateTransfer: scopeOwn,
+		ActionReadUser:       scopeAny,
+		ActionWriteUser:      scopeOwn,
+		ActionManageWebhooks: scopeOwn,
+	},
+	RoleAuditor: {
+		ActionReadAccount:     scopeAny,
+		ActionReadUser:        scopeAny,
+		ActionReadAuditEvents: scopeAny,
+	},
+	RoleAdmin: {
+		ActionReadAccount:     scopeAny,
+		ActionWriteAccount:    scopeOwn,
+		ActionCreateTransfer:  scopeOwn,
+		ActionReadUser:        scopeAny,
+		ActionWriteUser:       scopeAny,
+		ActionManageUsers:     scopeAny,
+		ActionReadAuditEvents: scopeAny,
+		ActionManageWebhooks:  scopeOwn,
+		ActionManageTasks:     scopeAny,
+	},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := policies[role]
+	return ok
+}
+
+// Can reports whether the role may perform the action on at least its own
+// resources. Use Authorize once the resource owner is known.
+func Can(role string, action Action) bool {
+	return policies[role][action] != scopeNone
+}
+
+// Permits is Can for an authenticated caller, also honoring API key scopes.
+func Permits(payload *token.Payload, action Action) bool {
+	return Can(payload.Role, action) && hasScope(payload, action)
+}
+
+func Authorize(payload *token.Payload, action Action, owner string) error {
+	if !hasScope(payload, action) {
+		return ERR_SCOPE_NOT_GRANTED
+	}
+
+	switch policies[payload.Role][action] {
+	case scopeAny:
+		return nil
+	case scopeOwn:
+		if owner == payload.Username {
+			return nil
+		}
+
+		return ERR_NOT_OWNER
+	}
+
+	return ERR_PERMISSION_DENIED
+}
+
+// IsValidScope reports whether the scope is an action some role may perform.
+func IsValidScope(scope string) bool {
+	for role := range policies {
+		if Can(role, Action(scope)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateScopes checks that the scopes requested for an API key are actions
+// the owner's role may perform.
+func ValidateScopes(role string, scopes []string) error {
+	for _, scope := range scopes {
+		if !Can(role, Action(scope)) {
+			return fmt.Errorf("%w: %s", ERR_INVALID_SCOPE, scope)
+		}
+	}
+
+	return nil
+}
+
+// RequireSession rejects API keys, for actions that only the account holder
+// may take.
+func RequireSession(payload *token.Payload) error {
+	if payload.Scopes != nil {
+		return ERR_SESSION_REQUIRED
+	}
+
+	return nil
+}
+
+func hasScope(payload *token.Payload, action Action) bool {
+	return payload.Scopes == nil || slices.Contains(payload.Scopes, string(action))
+}
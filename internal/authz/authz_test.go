@@ -0,0 +1,69 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+func TestAuthorize(t *testing.T) {
+	testCases := []struct {
+		err    error
+		name   string
+		role   string
+		owner  string
+		action Action
+	}{
+		{name: "DepositorOwnAccount", role: RoleDepositor, action: ActionReadAccount, owner: "alice"},
+		{name: "DepositorOtherAccount", role: RoleDepositor, action: ActionReadAccount, owner: "bob", err: ERR_NOT_OWNER},
+		{name: "DepositorManageUsers", role: RoleDepositor, action: ActionManageUsers, owner: "bob", err: ERR_PERMISSION_DENIED},
+		{name: "BankerReadAnyAccount", role: RoleBanker, action: ActionReadAccount, owner: "bob"},
+		{name: "BankerWriteOtherAccount", role: RoleBanker, action: ActionWriteAccount, owner: "bob", err: ERR_NOT_OWNER},
+		{name: "AuditorReadAnyUser", role: RoleAuditor, action: ActionReadUser, owner: "bob"},
+		{name: "AuditorWriteOwnAccount", role: RoleAuditor, action: ActionWriteAccount, owner: "alice", err: ERR_PERMISSION_DENIED},
+		{name: "AdminWriteOtherUser", role: RoleAdmin, action: ActionWriteUser, owner: "bob"},
+		{name: "AdminManageUsers", role: RoleAdmin, action: ActionManageUsers, owner: "bob"},
+		{name: "UnknownRole", role: "root", action: ActionReadAccount, owner: "alice", err: ERR_PERMISSION_DENIED},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			payload := &token.Payload{Username: "alice", Role: tc.role}
+			require.ErrorIs(t, Authorize(payload, tc.action, tc.owner), tc.err)
+		})
+	}
+}
+
+func TestCan(t *testing.T) {
+	require.True(t, Can(RoleDepositor, ActionWriteAccount))
+	require.False(t, Can(RoleAuditor, ActionWriteAccount))
+	require.False(t, Can(RoleBanker, ActionManageUsers))
+	require.True(t, Can(RoleAdmin, ActionManageUsers))
+	require.False(t, Can("", ActionReadAccount))
+	require.True(t, IsValidRole(RoleAuditor))
+	require.False(t, IsValidRole("root"))
+}
+
+func TestScopes(t *testing.T) {
+	payload := &token.Payload{
+		Username: "alice",
+		Role:     RoleDepositor,
+		Scopes:   []string{string(ActionReadAccount)},
+	}
+
+	require.True(t, Permits(payload, ActionReadAccount))
+	require.False(t, Permits(payload, ActionCreateTransfer))
+	require.NoError(t, Authorize(payload, ActionReadAccount, "alice"))
+	require.ErrorIs(t, Authorize(payload, ActionCreateTransfer, "alice"), ERR_SCOPE_NOT_GRANTED)
+	require.ErrorIs(t, RequireSession(payload), ERR_SESSION_REQUIRED)
+	require.NoError(t, RequireSession(&token.Payload{Role: RoleDepositor}))
+
+	require.NoError(t, ValidateScopes(RoleDepositor, []string{"account:read", "transfer:create"}))
+	require.ErrorIs(t, ValidateScopes(RoleDepositor, []string{"user:manage"}), ERR_INVALID_SCOPE)
+	require.ErrorIs(t, ValidateScopes(RoleAdmin, []string{"root"}), ERR_INVALID_SCOPE)
+	require.True(t, IsValidScope(string(ActionManageUsers)))
+	require.False(t, IsValidScope("root"))
+}
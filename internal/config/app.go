@@ -13,10 +13,46 @@ type AppConfig = struct {
 	Env                  string        `default:"production" env:"ENV"`
 	Secret               string        `                     env:"SECRET"`
 	TokenSymmetricKey    string        `                     env:"TOKEN_SYMMETRIC_KEY"`
+	TokenKeyringFile     string        `                     env:"TOKEN_KEYRING_FILE"`
+	TokenFormat          string        `default:"local"      env:"TOKEN_FORMAT"`
 	HTTPPort             int           `default:"0"          env:"HTTP_PORT"`
 	GrpcPort             int           `default:"9090"       env:"GRPC_PORT"`
 	AccessTokenDuration  time.Duration `default:"15m"        env:"ACCESS_TOKEN_DURATION"`
 	RefreshTokenDuration time.Duration `default:"24h"        env:"REFRESH_TOKEN_DURATION"`
+	TOTPIssuer           string        `default:"SimpleBank" env:"TOTP_ISSUER"`
+	MFAChallengeDuration time.Duration `default:"5m"         env:"MFA_CHALLENGE_DURATION"`
+	StepUpTransferAmount int64         `default:"0"          env:"STEP_UP_TRANSFER_AMOUNT"`
+	WebAuthnRPID         string        `default:"localhost"  env:"WEBAUTHN_RP_ID"`
+	WebAuthnRPName       string        `default:"SimpleBank" env:"WEBAUTHN_RP_NAME"`
+	LoginMaxAttempts     int64         `default:"5"          env:"LOGIN_MAX_ATTEMPTS"`
+	LoginIPMaxAttempts   int64         `default:"50"         env:"LOGIN_IP_MAX_ATTEMPTS"`
+	LoginAttemptWindow   time.Duration `default:"15m"        env:"LOGIN_ATTEMPT_WINDOW"`
+	LoginLockoutDuration time.Duration `default:"15m"        env:"LOGIN_LOCKOUT_DURATION"`
+	LoginBaseDelay       time.Duration `default:"1s"         env:"LOGIN_BASE_DELAY"`
+	APIKeyMaxLifetime    time.Duration `default:"8760h"      env:"API_KEY_MAX_LIFETIME"`
+	OAuthCodeDuration    time.Duration `default:"1m"         env:"OAUTH_CODE_DURATION"`
+	LoginAlertReportURL  string        `                     env:"LOGIN_ALERT_REPORT_URL"`
+	PasswordMinLength    int           `default:"10"         env:"PASSWORD_MIN_LENGTH"`
+	PasswordMinClasses   int           `default:"3"          env:"PASSWORD_MIN_CLASSES"`
+	PasswordHistorySize  int           `default:"5"          env:"PASSWORD_HISTORY_SIZE"`
+	PasswordBreachFile   string        `                     env:"PASSWORD_BREACH_FILE"`
+	PasswordPepper       string        `                     env:"PASSWORD_PEPPER"`
+	ArgonMemory          uint32        `default:"131072"     env:"ARGON_MEMORY"`
+	ArgonIterations      uint32        `default:"4"          env:"ARGON_ITERATIONS"`
+	ArgonParallelism     uint8         `default:"4"          env:"ARGON_PARALLELISM"`
+	ArgonSaltLength      uint32        `default:"128"        env:"ARGON_SALT_LENGTH"`
+	ArgonKeyLength       uint32        `default:"128"        env:"ARGON_KEY_LENGTH"`
+	OutboxRelayInterval  time.Duration `default:"1s"         env:"OUTBOX_RELAY_INTERVAL"`
+	OutboxRelayBatchSize int32         `default:"100"        env:"OUTBOX_RELAY_BATCH_SIZE"`
+	EventStream          string        `default:"events"     env:"EVENT_STREAM"`
+	EventStreamMaxLen    int64         `default:"1000000"    env:"EVENT_STREAM_MAX_LEN"`
+	WebhookTimeout       time.Duration `default:"10s"        env:"WEBHOOK_TIMEOUT"`
+	WebhookMaxRetry      int           `default:"12"         env:"WEBHOOK_MAX_RETRY"`
+	WebhookMaxFailures   int32         `default:"20"         env:"WEBHOOK_MAX_FAILURES"`
+	SchedulerLockTTL     time.Duration `default:"30s"        env:"SCHEDULER_LOCK_TTL"`
+	SessionCleanupCron   string        `default:"@hourly"    env:"SESSION_CLEANUP_CRON"`
+	ReconciliationCron   string        `default:"0 3 * * *"  env:"RECONCILIATION_CRON"`
+	ShutdownTimeout      time.Duration `default:"30s"        env:"SHUTDOWN_TIMEOUT"`
 	IsDev                bool          `default:"false"`
 }
 
@@ -0,0 +1,4 @@
+alter table if exists "sessions"
+drop column if exists "rotated_at",
+drop column if exists "family_id"
+;
@@ -0,0 +1,14 @@
+alter table "sessions"
+add column "family_id" uuid,
+add column "rotated_at" timestamptz
+;
+
+update "sessions"
+set family_id = id
+;
+
+alter table "sessions"
+alter column "family_id" set not null
+;
+
+create index on "sessions" ("family_id");
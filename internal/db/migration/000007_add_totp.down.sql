@@ -0,0 +1,8 @@
+drop table if exists mfa_challenges;
+
+drop table if exists recovery_codes;
+
+alter table if exists "users"
+drop column if exists "totp_enabled_at",
+drop column if exists "totp_secret"
+;
@@ -0,0 +1,24 @@
+alter table "users"
+add column "totp_secret" varchar,
+add column "totp_enabled_at" timestamptz
+;
+
+create table "recovery_codes" (
+    "id" bigserial primary key,
+    "username" varchar references users (username) not null,
+    "hashed_code" varchar not null,
+    "used_at" timestamptz,
+    "created_at" timestamptz not null default 'now()'
+)
+;
+
+create index on "recovery_codes" ("username");
+
+create table "mfa_challenges" (
+    "hashed_token" varchar primary key,
+    "username" varchar references users (username) not null,
+    "expires_at" timestamptz not null,
+    "consumed_at" timestamptz,
+    "created_at" timestamptz not null default 'now()'
+)
+;
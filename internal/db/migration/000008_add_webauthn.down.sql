@@ -0,0 +1,3 @@
+drop table if exists webauthn_challenges;
+
+drop table if exists webauthn_credentials;
@@ -0,0 +1,23 @@
+create table "webauthn_credentials" (
+    "id" bigserial primary key,
+    "username" varchar references users (username) not null,
+    "credential_id" bytea unique not null,
+    "public_key" bytea not null,
+    "sign_count" bigint not null default 0,
+    "name" varchar not null default '',
+    "last_used_at" timestamptz,
+    "created_at" timestamptz not null default 'now()'
+)
+;
+
+create index on "webauthn_credentials" ("username");
+
+create table "webauthn_challenges" (
+    "challenge" varchar primary key,
+    "username" varchar references users (username) not null,
+    "ceremony" varchar not null,
+    "expires_at" timestamptz not null,
+    "consumed_at" timestamptz,
+    "created_at" timestamptz not null default 'now()'
+)
+;
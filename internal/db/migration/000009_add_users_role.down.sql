@@ -0,0 +1,4 @@
+alter table if exists "users"
+drop constraint if exists "users_role_check",
+drop column if exists "role"
+;
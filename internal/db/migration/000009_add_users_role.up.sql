@@ -0,0 +1,8 @@
+alter table "users"
+add column "role" varchar not null default 'depositor'
+;
+
+alter table "users"
+add constraint "users_role_check"
+check ("role" in ('depositor', 'banker', 'auditor', 'admin'))
+;
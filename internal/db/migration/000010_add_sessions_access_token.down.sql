@@ -0,0 +1,4 @@
+alter table if exists "sessions"
+drop column if exists "access_token_expires_at",
+drop column if exists "access_token_id"
+;
@@ -0,0 +1,4 @@
+alter table "sessions"
+add column "access_token_id" uuid,
+add column "access_token_expires_at" timestamptz
+;
@@ -0,0 +1,14 @@
+create table "api_keys" (
+    "id" uuid primary key,
+    "owner" varchar references users (username) not null,
+    "name" varchar not null,
+    "hashed_secret" varchar not null,
+    "scopes" varchar [] not null,
+    "expires_at" timestamptz not null,
+    "last_used_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz not null default 'now()'
+)
+;
+
+create index on "api_keys" ("owner");
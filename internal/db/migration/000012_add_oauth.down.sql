@@ -0,0 +1,8 @@
+alter table if exists "sessions"
+drop column if exists "scopes",
+drop column if exists "client_id"
+;
+
+drop table if exists "oauth_authorization_codes";
+drop table if exists "oauth_consents";
+drop table if exists "oauth_clients";
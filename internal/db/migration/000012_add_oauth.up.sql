@@ -0,0 +1,42 @@
+create table "oauth_clients" (
+    "id" varchar primary key,
+    "owner" varchar references users (username) not null,
+    "name" varchar not null,
+    "hashed_secret" varchar,
+    "redirect_uris" varchar [] not null,
+    "scopes" varchar [] not null,
+    "created_at" timestamptz not null default 'now()'
+)
+;
+
+create index on "oauth_clients" ("owner");
+
+create table "oauth_consents" (
+    "username" varchar references users (username) not null,
+    "client_id" varchar references oauth_clients (id) not null,
+    "scopes" varchar [] not null,
+    "created_at" timestamptz not null default 'now()',
+    "updated_at" timestamptz not null default 'now()',
+    primary key ("username", "client_id")
+)
+;
+
+create table "oauth_authorization_codes" (
+    "hashed_code" varchar primary key,
+    "client_id" varchar references oauth_clients (id) not null,
+    "username" varchar references users (username) not null,
+    "redirect_uri" varchar not null,
+    "scopes" varchar [] not null,
+    "code_challenge" varchar not null,
+    "expires_at" timestamptz not null,
+    "consumed_at" timestamptz,
+    "created_at" timestamptz not null default 'now()'
+)
+;
+
+alter table "sessions"
+add column "client_id" varchar references oauth_clients (id),
+add column "scopes" varchar []
+;
+
+create index on "sessions" ("username", "client_id");
@@ -0,0 +1,3 @@
+drop table if exists "audit_events";
+
+drop function if exists "audit_events_append_only";
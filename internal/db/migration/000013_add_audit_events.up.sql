@@ -0,0 +1,32 @@
+create table "audit_events" (
+    "id" bigserial primary key,
+    "actor" varchar not null,
+    "action" varchar not null,
+    "resource" varchar not null,
+    "outcome" varchar not null,
+    "detail" varchar not null,
+    "client_ip" varchar not null,
+    "user_agent" varchar not null,
+    "prev_hash" bytea not null,
+    "hash" bytea unique not null,
+    "created_at" timestamptz not null
+)
+;
+
+create index on "audit_events" ("actor", "created_at");
+
+create index on "audit_events" ("action", "created_at");
+
+create index on "audit_events" ("created_at");
+
+create function "audit_events_append_only"() returns trigger as $$
+begin
+    raise exception 'audit_events is append-only';
+end;
+$$ language plpgsql
+;
+
+create trigger "audit_events_append_only"
+before update or delete or truncate on "audit_events"
+for each statement execute function "audit_events_append_only"()
+;
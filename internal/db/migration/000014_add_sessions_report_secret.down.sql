@@ -0,0 +1,5 @@
+drop index if exists "sessions_username_user_agent_client_ip_idx";
+
+alter table if exists "sessions"
+drop column if exists "report_secret_hash"
+;
@@ -0,0 +1,5 @@
+alter table "sessions"
+add column "report_secret_hash" varchar
+;
+
+create index on "sessions" ("username", "user_agent", "client_ip");
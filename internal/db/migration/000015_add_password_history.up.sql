@@ -0,0 +1,9 @@
+create table "password_history" (
+    "id" bigserial primary key,
+    "username" varchar references users (username) not null,
+    "hashed_password" varchar not null,
+    "created_at" timestamptz not null default 'now()'
+)
+;
+
+create index on "password_history" ("username", "id");
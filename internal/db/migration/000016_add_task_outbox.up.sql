@@ -0,0 +1,13 @@
+create table "task_outbox" (
+    "id" bigserial primary key,
+    "task_type" varchar not null,
+    "payload" bytea not null,
+    "queue" varchar not null,
+    "max_retry" integer not null,
+    "process_at" timestamptz not null default 'now()',
+    "sent_at" timestamptz,
+    "created_at" timestamptz not null default 'now()'
+)
+;
+
+create index on "task_outbox" ("id") where "sent_at" is null;
@@ -0,0 +1,29 @@
+create table "webhook_endpoints" (
+    "id" bigserial primary key,
+    "account_id" bigint references accounts (id) not null,
+    "url" varchar not null,
+    "secret" varchar not null,
+    "consecutive_failures" integer not null default 0,
+    "disabled_at" timestamptz,
+    "created_at" timestamptz not null default 'now()'
+)
+;
+
+create index on "webhook_endpoints" ("account_id");
+
+create table "webhook_deliveries" (
+    "id" bigserial primary key,
+    "endpoint_id" bigint references webhook_endpoints (id) on delete cascade not null,
+    "event_type" varchar not null,
+    "payload" jsonb not null,
+    "status" varchar not null default 'pending',
+    "attempts" integer not null default 0,
+    "response_status" integer,
+    "last_error" varchar,
+    "delivered_at" timestamptz,
+    "created_at" timestamptz not null default 'now()',
+    "updated_at" timestamptz not null default 'now()'
+)
+;
+
+create index on "webhook_deliveries" ("endpoint_id", "id");
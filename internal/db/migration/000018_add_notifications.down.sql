@@ -0,0 +1,3 @@
+drop table if exists "low_balance_alerts";
+
+drop table if exists "notification_preferences";
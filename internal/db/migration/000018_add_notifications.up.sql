@@ -0,0 +1,16 @@
+create table "notification_preferences" (
+    "username" varchar references users (username) not null,
+    "event_type" varchar not null,
+    "channel" varchar not null,
+    "enabled" boolean not null,
+    "updated_at" timestamptz not null default 'now()',
+    primary key ("username", "event_type", "channel")
+)
+;
+
+create table "low_balance_alerts" (
+    "account_id" bigint primary key references accounts (id) on delete cascade,
+    "threshold" bigint not null,
+    "updated_at" timestamptz not null default 'now()'
+)
+;
@@ -0,0 +1,15 @@
+create table "login_devices" (
+    "username" varchar references users (username) not null,
+    "user_agent" varchar not null,
+    "client_ip" varchar not null,
+    "first_seen_at" timestamptz not null default 'now()',
+    "last_seen_at" timestamptz not null default 'now()',
+    primary key ("username", "user_agent", "client_ip")
+)
+;
+
+insert into "login_devices" ("username", "user_agent", "client_ip", "first_seen_at", "last_seen_at")
+select "username", "user_agent", "client_ip", min("created_at"), max("created_at")
+from "sessions"
+group by "username", "user_agent", "client_ip"
+;
@@ -0,0 +1,3 @@
+alter table if exists "users"
+drop column if exists "totp_last_counter"
+;
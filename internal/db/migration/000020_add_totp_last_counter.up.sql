@@ -0,0 +1,3 @@
+alter table "users"
+add column "totp_last_counter" bigint
+;
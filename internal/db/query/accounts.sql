@@ -46,3 +46,20 @@ returning *
 delete from accounts
 where id = $1
 ;
+
+-- name: NotifyAccountActivity :exec
+select pg_notify('account_activity', sqlc.arg(payload)::text)
+;
+
+-- name: ListUnreconciledAccounts :many
+select
+  a.id,
+  a.balance,
+  coalesce(sum(e.amount), 0)::bigint as entries_balance
+from accounts as a
+left join entries as e on e.account_id = a.id
+group by a.id
+having a.balance <> coalesce(sum(e.amount), 0)
+order by a.id
+limit $1
+;
@@ -0,0 +1,43 @@
+-- name: CreateApiKey :one
+insert into api_keys (
+  id,
+  owner,
+  name,
+  hashed_secret,
+  scopes,
+  expires_at
+)
+values ($1, $2, $3, $4, $5, $6)
+returning *
+;
+
+-- name: GetApiKey :one
+select
+  api_keys.id,
+  api_keys.owner,
+  api_keys.name,
+  api_keys.hashed_secret,
+  api_keys.scopes,
+  api_keys.expires_at,
+  api_keys.last_used_at,
+  api_keys.revoked_at,
+  api_keys.created_at,
+  users.role
+from api_keys
+join users on users.username = api_keys.owner
+where api_keys.id = $1
+limit 1
+;
+
+-- name: RevokeApiKey :one
+update api_keys
+set revoked_at = now()
+where id = $1 and owner = $2 and revoked_at is null
+returning *
+;
+
+-- name: TouchApiKey :exec
+update api_keys
+set last_used_at = now()
+where id = $1 and (last_used_at is null or last_used_at < now() - interval '1 minute')
+;
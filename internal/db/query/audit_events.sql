@@ -0,0 +1,48 @@
+-- name: LockAuditEvents :exec
+select pg_advisory_xact_lock(hashtext('audit_events'))
+;
+
+-- name: GetLastAuditEventHash :one
+select hash
+from audit_events
+order by id desc
+limit 1
+;
+
+-- name: CreateAuditEvent :one
+insert into audit_events (
+  actor,
+  action,
+  resource,
+  outcome,
+  detail,
+  client_ip,
+  user_agent,
+  prev_hash,
+  hash,
+  created_at
+)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+returning *
+;
+
+-- name: ListAuditEvents :many
+select *
+from audit_events
+where
+  (sqlc.narg(actor)::varchar is null or actor = sqlc.narg(actor))
+  and (sqlc.narg(action)::varchar is null or action = sqlc.narg(action))
+  and (sqlc.narg(created_from)::timestamptz is null or created_at >= sqlc.narg(created_from))
+  and (sqlc.narg(created_to)::timestamptz is null or created_at < sqlc.narg(created_to))
+order by id
+limit sqlc.arg('limit')
+offset sqlc.arg('offset')
+;
+
+-- name: ListAuditEventChain :many
+select *
+from audit_events
+where id > $1
+order by id
+limit $2
+;
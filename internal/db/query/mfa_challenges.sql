@@ -0,0 +1,17 @@
+-- name: CreateMFAChallenge :one
+insert into mfa_challenges (hashed_token, username, expires_at)
+values ($1, $2, $3)
+returning *
+;
+
+-- name: ConsumeMFAChallenge :one
+update mfa_challenges
+set consumed_at = now()
+where hashed_token = $1 and consumed_at is null and expires_at > now()
+returning *
+;
+
+-- name: DeleteExpiredMFAChallenges :execrows
+delete from mfa_challenges
+where expires_at < now()
+;
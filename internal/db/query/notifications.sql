@@ -0,0 +1,38 @@
+-- name: ListNotificationPreferences :many
+select *
+from notification_preferences
+where username = $1
+order by event_type, channel
+;
+
+-- name: UpsertNotificationPreference :one
+insert into notification_preferences (username, event_type, channel, enabled)
+values ($1, $2, $3, $4)
+on conflict (username, event_type, channel) do update
+set
+  enabled = excluded.enabled,
+  updated_at = now()
+returning *
+;
+
+-- name: GetLowBalanceAlert :one
+select *
+from low_balance_alerts
+where account_id = $1
+limit 1
+;
+
+-- name: UpsertLowBalanceAlert :one
+insert into low_balance_alerts (account_id, threshold)
+values ($1, $2)
+on conflict (account_id) do update
+set
+  threshold = excluded.threshold,
+  updated_at = now()
+returning *
+;
+
+-- name: DeleteLowBalanceAlert :exec
+delete from low_balance_alerts
+where account_id = $1
+;
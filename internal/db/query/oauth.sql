@@ -0,0 +1,95 @@
+-- name: CreateOauthClient :one
+insert into oauth_clients (
+  id,
+  owner,
+  name,
+  hashed_secret,
+  redirect_uris,
+  scopes
+)
+values ($1, $2, $3, $4, $5, $6)
+returning *
+;
+
+-- name: GetOauthClient :one
+select
+  id,
+  owner,
+  name,
+  hashed_secret,
+  redirect_uris,
+  scopes,
+  created_at
+from oauth_clients
+where id = $1
+limit 1
+;
+
+-- name: UpsertOauthConsent :one
+insert into oauth_consents (
+  username,
+  client_id,
+  scopes
+)
+values ($1, $2, $3)
+on conflict (username, client_id) do update
+set scopes = excluded.scopes, updated_at = now()
+returning *
+;
+
+-- name: GetOauthConsent :one
+select
+  username,
+  client_id,
+  scopes,
+  created_at,
+  updated_at
+from oauth_consents
+where username = $1 and client_id = $2
+limit 1
+;
+
+-- name: ListOauthConsents :many
+select
+  username,
+  client_id,
+  scopes,
+  created_at,
+  updated_at
+from oauth_consents
+where username = $1
+order by created_at
+;
+
+-- name: DeleteOauthConsent :execrows
+delete from oauth_consents
+where username = $1 and client_id = $2
+;
+
+-- name: CreateOauthAuthorizationCode :one
+insert into oauth_authorization_codes (
+  hashed_code,
+  client_id,
+  username,
+  redirect_uri,
+  scopes,
+  code_challenge,
+  expires_at
+)
+values ($1, $2, $3, $4, $5, $6, $7)
+returning *
+;
+
+-- name: ConsumeOauthAuthorizationCode :one
+update oauth_authorization_codes
+set consumed_at = now()
+where hashed_code = $1 and consumed_at is null
+returning *
+;
+
+-- name: BlockOauthClientSessions :many
+update "sessions"
+set is_blocked = true
+where username = $1 and client_id = $2 and is_blocked = false
+returning id, expires_at, access_token_id, access_token_expires_at
+;
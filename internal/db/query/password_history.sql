@@ -0,0 +1,25 @@
+-- name: CreatePasswordHistory :exec
+insert into password_history (username, hashed_password)
+values ($1, $2)
+;
+
+-- name: ListPasswordHistory :many
+select hashed_password
+from password_history
+where username = $1
+order by id desc
+limit $2
+;
+
+-- name: PrunePasswordHistory :exec
+delete from password_history
+where
+  username = sqlc.arg(username)
+  and id not in (
+    select id
+    from password_history
+    where username = sqlc.arg(username)
+    order by id desc
+    limit sqlc.arg(keep)
+  )
+;
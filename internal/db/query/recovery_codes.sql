@@ -0,0 +1,16 @@
+-- name: CreateRecoveryCode :exec
+insert into recovery_codes (username, hashed_code)
+values ($1, $2)
+;
+
+-- name: DeleteRecoveryCodes :exec
+delete from recovery_codes
+where username = $1
+;
+
+-- name: UseRecoveryCode :one
+update recovery_codes
+set used_at = now()
+where username = $1 and hashed_code = $2 and used_at is null
+returning *
+;
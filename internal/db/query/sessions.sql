@@ -1,20 +1,90 @@
 -- name: CreateSession :one
 insert into "sessions" (
   id,
+  family_id,
   username,
   refresh_token,
   user_agent,
   client_ip,
   is_blocked,
-  expires_at
+  expires_at,
+  access_token_id,
+  access_token_expires_at,
+  client_id,
+  scopes
 )
-values ($1, $2, $3, $4, $5, $6, $7)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 returning *
 ;
 
 -- name: GetSession :one
-select id, username, refresh_token, user_agent, client_ip, is_blocked, expires_at
+select
+  id,
+  username,
+  refresh_token,
+  user_agent,
+  client_ip,
+  is_blocked,
+  expires_at,
+  created_at,
+  family_id,
+  rotated_at,
+  access_token_id,
+  access_token_expires_at,
+  client_id,
+  scopes,
+  report_secret_hash
 from "sessions"
 where id = $1
 limit 1
 ;
+
+-- name: RotateSession :one
+update "sessions"
+set rotated_at = now()
+where id = $1 and rotated_at is null
+returning *
+;
+
+-- name: BlockSessionFamily :many
+update "sessions"
+set is_blocked = true
+where family_id = $1
+returning id, expires_at, access_token_id, access_token_expires_at
+;
+
+-- name: RecordLoginDevice :one
+-- Reports whether the device was known, and whether the user had any, before
+-- recording it. Devices outlive the sessions, which are deleted once expired.
+with "recorded" as (
+  insert into "login_devices" (username, user_agent, client_ip)
+  values (sqlc.arg(username), sqlc.arg(user_agent), sqlc.arg(client_ip))
+  on conflict (username, user_agent, client_ip) do update
+  set last_seen_at = now()
+)
+select
+  exists(
+    select 1
+    from "login_devices" as d
+    where
+      d.username = sqlc.arg(username)
+      and d.user_agent = sqlc.arg(user_agent)
+      and d.client_ip = sqlc.arg(client_ip)
+  ) as known_device,
+  exists(
+    select 1
+    from "login_devices" as d
+    where d.username = sqlc.arg(username)
+  ) as has_devices
+;
+
+-- name: SetSessionReportSecret :exec
+update "sessions"
+set report_secret_hash = $2
+where id = $1
+;
+
+-- name: DeleteExpiredSessions :execrows
+delete from "sessions"
+where expires_at < now()
+;
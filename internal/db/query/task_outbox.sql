@@ -0,0 +1,20 @@
+-- name: CreateTaskOutbox :one
+insert into task_outbox (task_type, payload, queue, max_retry, process_at)
+values ($1, $2, $3, $4, $5)
+returning *
+;
+
+-- name: ListPendingTaskOutbox :many
+select *
+from task_outbox
+where sent_at is null
+order by id
+limit $1
+for update skip locked
+;
+
+-- name: MarkTaskOutboxSent :exec
+update task_outbox
+set sent_at = now()
+where id = $1
+;
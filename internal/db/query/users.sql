@@ -5,7 +5,17 @@ returning *
 ;
 
 -- name: GetUser :one
-select id, username, hashed_password, full_name, email
+select
+  username,
+  hashed_password,
+  full_name,
+  email,
+  password_changed_at,
+  created_at,
+  totp_secret,
+  totp_enabled_at,
+  role,
+  totp_last_counter
 from users
 where username = $1
 limit 1
@@ -18,8 +28,42 @@ set
   hashed_password = COALESCE(sqlc.narg(hashed_password), hashed_password),
   password_changed_at = COALESCE(sqlc.narg(password_changed_at), password_changed_at),
   full_name = COALESCE(sqlc.narg(full_name), full_name),
-  email = COALESCE(sqlc.narg(email), email)
+  email = COALESCE(sqlc.narg(email), email),
+  role = COALESCE(sqlc.narg(role), role)
 where
     username = sqlc.arg(username)
 returning *
 ;
+
+-- name: SetUserTOTPSecret :one
+update users
+set
+  totp_secret = $2,
+  totp_enabled_at = null
+where username = $1
+returning *
+;
+
+-- name: EnableUserTOTP :one
+update users
+set totp_enabled_at = now()
+where username = $1 and totp_secret is not null
+returning *
+;
+
+-- name: UseUserTOTPCounter :one
+update users
+set totp_last_counter = sqlc.arg(counter)::bigint
+where
+  username = sqlc.arg(username)
+  and (totp_last_counter is null or totp_last_counter < sqlc.arg(counter)::bigint)
+returning *
+;
+
+-- name: RehashUserPassword :exec
+update users
+set hashed_password = sqlc.arg(new_hashed_password)
+where
+  username = sqlc.arg(username)
+  and hashed_password = sqlc.arg(hashed_password)
+;
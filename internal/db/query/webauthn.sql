@@ -0,0 +1,67 @@
+-- name: CreateWebauthnCredential :one
+insert into webauthn_credentials (
+  username,
+  credential_id,
+  public_key,
+  sign_count,
+  name
+)
+values ($1, $2, $3, $4, $5)
+returning *
+;
+
+-- name: GetWebauthnCredential :one
+select
+  id,
+  username,
+  credential_id,
+  public_key,
+  sign_count,
+  name,
+  last_used_at,
+  created_at
+from webauthn_credentials
+where credential_id = $1
+limit 1
+;
+
+-- name: ListWebauthnCredentials :many
+select
+  id,
+  username,
+  credential_id,
+  public_key,
+  sign_count,
+  name,
+  last_used_at,
+  created_at
+from webauthn_credentials
+where username = $1
+order by id
+;
+
+-- name: UpdateWebauthnCredentialSignCount :one
+update webauthn_credentials
+set
+  sign_count = sqlc.arg(sign_count),
+  last_used_at = now()
+where id = sqlc.arg(id) and sign_count = sqlc.arg(previous_sign_count)
+returning *
+;
+
+-- name: CreateWebauthnChallenge :one
+insert into webauthn_challenges (challenge, username, ceremony, expires_at)
+values ($1, $2, $3, $4)
+returning *
+;
+
+-- name: ConsumeWebauthnChallenge :one
+update webauthn_challenges
+set consumed_at = now()
+where
+  challenge = $1
+  and ceremony = $2
+  and consumed_at is null
+  and expires_at > now()
+returning *
+;
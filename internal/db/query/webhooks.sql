@@ -0,0 +1,104 @@
+-- name: CreateWebhookEndpoint :one
+insert into webhook_endpoints (account_id, url, secret)
+values ($1, $2, $3)
+returning *
+;
+
+-- name: GetWebhookEndpoint :one
+select *
+from webhook_endpoints
+where id = $1
+limit 1
+;
+
+-- name: ListWebhookEndpoints :many
+select *
+from webhook_endpoints
+where account_id = $1
+order by id
+;
+
+-- name: ListActiveWebhookEndpoints :many
+select *
+from webhook_endpoints
+where
+  account_id = any(sqlc.arg(account_ids)::bigint [])
+  and disabled_at is null
+order by id
+;
+
+-- name: DeleteWebhookEndpoint :exec
+delete from webhook_endpoints
+where id = $1
+;
+
+-- name: EnableWebhookEndpoint :one
+update webhook_endpoints
+set
+  disabled_at = null,
+  consecutive_failures = 0
+where id = $1
+returning *
+;
+
+-- name: RecordWebhookEndpointSuccess :exec
+update webhook_endpoints
+set consecutive_failures = 0
+where id = $1
+;
+
+-- name: RecordWebhookEndpointFailure :one
+update webhook_endpoints
+set
+  consecutive_failures = consecutive_failures + 1,
+  disabled_at = case
+    when consecutive_failures + 1 >= sqlc.arg(max_failures)::integer then coalesce(disabled_at, now())
+    else disabled_at
+  end
+where id = sqlc.arg(id)
+returning *
+;
+
+-- name: CreateWebhookDelivery :one
+insert into webhook_deliveries (endpoint_id, event_type, payload)
+values ($1, $2, $3)
+returning *
+;
+
+-- name: GetWebhookDelivery :one
+select *
+from webhook_deliveries
+where id = $1
+limit 1
+;
+
+-- name: ListWebhookDeliveries :many
+select *
+from webhook_deliveries
+where endpoint_id = $1
+order by id desc
+limit $2
+offset $3
+;
+
+-- name: RecordWebhookDeliveryAttempt :one
+update webhook_deliveries
+set
+  status = sqlc.arg(status),
+  attempts = attempts + 1,
+  response_status = sqlc.narg(response_status),
+  last_error = sqlc.narg(last_error),
+  delivered_at = sqlc.narg(delivered_at),
+  updated_at = now()
+where id = sqlc.arg(id)
+returning *
+;
+
+-- name: ResetWebhookDelivery :one
+update webhook_deliveries
+set
+  status = 'pending',
+  updated_at = now()
+where id = $1
+returning *
+;
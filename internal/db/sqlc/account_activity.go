@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AccountActivityChannel is the Postgres channel TransferTx notifies, on
+// commit, of the entries it posts.
+const AccountActivityChannel = "account_activity"
+
+// AccountActivity is an entry posted to an account and its resulting balance.
+type AccountActivity struct {
+	CreatedAt  time.Time `json:"created_at"`
+	Currency   string    `json:"currency"`
+	AccountID  int64     `json:"account_id"`
+	EntryID    int64     `json:"entry_id"`
+	TransferID int64     `json:"transfer_id"`
+	Amount     int64     `json:"amount"`
+	Balance    int64     `json:"balance"`
+}
+
+func notifyAccountActivity(ctx context.Context, q *Queries, result TransferTxResult) error {
+	for _, activity := range []AccountActivity{
+		newAccountActivity(result.FromEntry, result.FromAccount, result.Transfer.ID),
+		newAccountActivity(result.ToEntry, result.ToAccount, result.Transfer.ID),
+	} {
+		payload, err := json.Marshal(activity)
+		if err != nil {
+			return fmt.Errorf("failed to marshal account activity: %w", err)
+		}
+
+		if err = q.NotifyAccountActivity(ctx, string(payload)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newAccountActivity(entry Entry, account Account, transferID int64) AccountActivity {
+	return AccountActivity{
+		CreatedAt:  entry.CreatedAt.Time,
+		Currency:   account.Currency,
+		AccountID:  account.ID,
+		EntryID:    entry.ID,
+		TransferID: transferID,
+		Amount:     entry.Amount,
+		Balance:    account.Balance,
+	}
+}
+
+// ListenAccountActivity holds a connection listening on
+// AccountActivityChannel and calls handle with each notification, until ctx
+// is done or the connection fails.
+func (store *SQLStore) ListenAccountActivity(
+	ctx context.Context,
+	handle func(AccountActivity),
+) (err error) {
+	var conn *pgxpool.Conn
+
+	if conn, err = store.db.Acquire(ctx); err != nil {
+		return err
+	}
+
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, "listen "+AccountActivityChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			// The connection may still be listening, so it's closed rather
+			// than returned to the pool.
+			_ = conn.Conn().Close(context.Background())
+			return err
+		}
+
+		var activity AccountActivity
+
+		if err = json.Unmarshal([]byte(notification.Payload), &activity); err != nil {
+			continue
+		}
+
+		handle(activity)
+	}
+}
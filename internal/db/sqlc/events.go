@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/dharmavagabond/simple-bank/internal/events"
+	eventspb "github.com/dharmavagabond/simple-bank/internal/pb/events/v1"
+)
+
+// publishEventsMaxRetry is asynq's default.
+const publishEventsMaxRetry = 25
+
+// writeEvents writes the events to the task outbox with q, so they're
+// only published if its transaction commits.
+func writeEvents(ctx context.Context, q *Queries, evts ...events.Event) error {
+	payload, err := json.Marshal(evts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal events: %w", err)
+	}
+
+	_, err = q.CreateTaskOutbox(ctx, CreateTaskOutboxParams{
+		TaskType:  events.TaskPublishEvents,
+		Payload:   payload,
+		Queue:     events.TaskQueue,
+		MaxRetry:  publishEventsMaxRetry,
+		ProcessAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+
+	return err
+}
+
+func accountCreatedEvent(account Account) (events.Event, error) {
+	return events.New(events.TypeAccountCreated, accountKey(account.ID), &eventspb.AccountCreated{
+		AccountId: account.ID,
+		Owner:     account.Owner,
+		Currency:  account.Currency,
+		Balance:   account.Balance,
+		CreatedAt: timestamppb.New(account.CreatedAt.Time),
+	})
+}
+
+// transferEvents returns the transfer's completion, followed by the entries
+// posted to each account with the resulting balances.
+func transferEvents(result TransferTxResult) (evts []events.Event, err error) {
+	var event events.Event
+
+	if event, err = events.New(
+		events.TypeTransferCompleted,
+		"transfers/"+strconv.FormatInt(result.Transfer.ID, 10),
+		&eventspb.TransferCompleted{
+			TransferId:    result.Transfer.ID,
+			FromAccountId: result.Transfer.FromAccountID,
+			ToAccountId:   result.Transfer.ToAccountID,
+			Amount:        result.Transfer.Amount,
+			CreatedAt:     timestamppb.New(result.Transfer.CreatedAt.Time),
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	evts = append(evts, event)
+
+	for _, posted := range []struct {
+		entry   Entry
+		account Account
+	}{
+		{entry: result.FromEntry, account: result.FromAccount},
+		{entry: result.ToEntry, account: result.ToAccount},
+	} {
+		if event, err = events.New(events.TypeEntryPosted, accountKey(posted.account.ID), &eventspb.EntryPosted{
+			EntryId:    posted.entry.ID,
+			AccountId:  posted.account.ID,
+			TransferId: result.Transfer.ID,
+			Amount:     posted.entry.Amount,
+			Balance:    posted.account.Balance,
+			CreatedAt:  timestamppb.New(posted.entry.CreatedAt.Time),
+		}); err != nil {
+			return nil, err
+		}
+
+		evts = append(evts, event)
+	}
+
+	return evts, nil
+}
+
+func userUpdatedEvent(arg UpdateUserParams) (events.Event, error) {
+	fields := make([]string, 0, 4)
+
+	for _, field := range []struct {
+		name  string
+		valid bool
+	}{
+		{name: "password", valid: arg.HashedPassword.Valid},
+		{name: "full_name", valid: arg.FullName.Valid},
+		{name: "email", valid: arg.Email.Valid},
+		{name: "role", valid: arg.Role.Valid},
+	} {
+		if field.valid {
+			fields = append(fields, field.name)
+		}
+	}
+
+	return events.New(events.TypeUserUpdated, "users/"+arg.Username, &eventspb.UserUpdated{
+		Username: arg.Username,
+		Fields:   fields,
+	})
+}
+
+func accountKey(id int64) string {
+	return "accounts/" + strconv.FormatInt(id, 10)
+}
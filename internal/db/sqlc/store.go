@@ -14,11 +14,37 @@ import (
 
 type Store interface {
 	Querier
-	TransferTx(context.Context, CreateTransferParams) (TransferTxResult, error)
+	TransferTx(context.Context, TransferTxParams) (TransferTxResult, error)
 	CreateUserTx(
 		context.Context,
 		CreateUserTxParams,
 	) (CreateUserTxResult, error)
+	CreateAccountTx(
+		context.Context,
+		CreateAccountParams,
+	) (CreateAccountTxResult, error)
+	RotateSessionTx(
+		context.Context,
+		RotateSessionTxParams,
+	) (RotateSessionTxResult, error)
+	EnableTOTPTx(
+		context.Context,
+		EnableTOTPTxParams,
+	) (EnableTOTPTxResult, error)
+	UpdateUserTx(
+		context.Context,
+		UpdateUserTxParams,
+	) (UpdateUserTxResult, error)
+	RelayTaskOutboxTx(
+		context.Context,
+		RelayTaskOutboxTxParams,
+	) (RelayTaskOutboxTxResult, error)
+	AppendAuditEventTx(
+		context.Context,
+		AppendAuditEventTxParams,
+	) (AppendAuditEventTxResult, error)
+	ListenAccountActivity(context.Context, func(AccountActivity)) error
+	Close()
 }
 
 type SQLStore struct {
@@ -31,6 +57,14 @@ var (
 	store Store
 )
 
+// TransferTxParams takes an optional AfterTransfer, run in the transaction
+// with its queries so side effects, like tasks written to the outbox, commit
+// or roll back along with the transfer.
+type TransferTxParams struct {
+	AfterTransfer func(q Querier, result TransferTxResult) error
+	CreateTransferParams
+}
+
 type TransferTxResult struct {
 	Transfer    Transfer `json:"transfer"`
 	FromAccount Account  `json:"from_account"`
@@ -70,6 +104,12 @@ func NewStore() Store {
 	return store
 }
 
+// Close closes the connections of the pool, waiting for those in use to be
+// released.
+func (store *SQLStore) Close() {
+	store.db.Close()
+}
+
 func (store *SQLStore) execTx(
 	ctx context.Context,
 	fn func(*Queries) error,
@@ -97,7 +137,7 @@ func (store *SQLStore) execTx(
 
 func (store *SQLStore) TransferTx(
 	ctx context.Context,
-	arg CreateTransferParams,
+	arg TransferTxParams,
 ) (result TransferTxResult, txError error) {
 	txError = store.execTx(ctx, func(q *Queries) (err error) {
 		if result.Transfer, err = q.CreateTransfer(
@@ -143,7 +183,28 @@ func (store *SQLStore) TransferTx(
 			result.ToAccount, result.FromAccount, err = transferMoney(ctx, q, arg.ToAccountID, arg.FromAccountID, arg.Amount)
 		}
 
-		return err
+		if err != nil {
+			return err
+		}
+
+		evts, err := transferEvents(result)
+		if err != nil {
+			return err
+		}
+
+		if err = writeEvents(ctx, q, evts...); err != nil {
+			return err
+		}
+
+		if err = notifyAccountActivity(ctx, q, result); err != nil {
+			return err
+		}
+
+		if arg.AfterTransfer == nil {
+			return nil
+		}
+
+		return arg.AfterTransfer(q, result)
 	})
 
 	return result, txError
@@ -22,10 +22,12 @@ func TestTransferTx(t *testing.T) {
 		go func() {
 			result, err := store.TransferTx(
 				ctx,
-				CreateTransferParams{
-					FromAccountID: fromAccount.ID,
-					ToAccountID:   toAccount.ID,
-					Amount:        amountToTransfer,
+				TransferTxParams{
+					CreateTransferParams: CreateTransferParams{
+						FromAccountID: fromAccount.ID,
+						ToAccountID:   toAccount.ID,
+						Amount:        amountToTransfer,
+					},
 				},
 			)
 
@@ -135,10 +137,12 @@ func TestTransferDeadlockTx(t *testing.T) {
 		go func() {
 			_, err := store.TransferTx(
 				ctx,
-				CreateTransferParams{
-					FromAccountID: fromAccountID,
-					ToAccountID:   toAccountID,
-					Amount:        amountToTransfer,
+				TransferTxParams{
+					CreateTransferParams: CreateTransferParams{
+						FromAccountID: fromAccountID,
+						ToAccountID:   toAccountID,
+						Amount:        amountToTransfer,
+					},
 				},
 			)
 
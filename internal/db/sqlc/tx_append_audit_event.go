@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type AppendAuditEventTxParams struct {
+	Actor     string
+	Action    string
+	Resource  string
+	Outcome   string
+	Detail    string
+	ClientIp  string
+	UserAgent string
+}
+
+type AppendAuditEventTxResult struct {
+	AuditEvent AuditEvent
+}
+
+// AppendAuditEventTx chains the event to the last one. Appends are
+// serialized with an advisory lock, so the chain never forks.
+func (store *SQLStore) AppendAuditEventTx(
+	ctx context.Context,
+	arg AppendAuditEventTxParams,
+) (result AppendAuditEventTxResult, txError error) {
+	txError = store.execTx(ctx, func(q *Queries) (err error) {
+		var prevHash []byte
+
+		if err = q.LockAuditEvents(ctx); err != nil {
+			return err
+		}
+
+		if prevHash, err = q.GetLastAuditEventHash(ctx); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		event := AuditEvent{
+			Actor:     arg.Actor,
+			Action:    arg.Action,
+			Resource:  arg.Resource,
+			Outcome:   arg.Outcome,
+			Detail:    arg.Detail,
+			ClientIp:  arg.ClientIp,
+			UserAgent: arg.UserAgent,
+			PrevHash:  append([]byte{}, prevHash...),
+			// Postgres keeps microseconds, the hash must match what's stored.
+			CreatedAt: pgtype.Timestamptz{
+				Time:  time.Now().UTC().Truncate(time.Microsecond),
+				Valid: true,
+			},
+		}
+
+		result.AuditEvent, err = q.CreateAuditEvent(ctx, CreateAuditEventParams{
+			Actor:     event.Actor,
+			Action:    event.Action,
+			Resource:  event.Resource,
+			Outcome:   event.Outcome,
+			Detail:    event.Detail,
+			ClientIp:  event.ClientIp,
+			UserAgent: event.UserAgent,
+			PrevHash:  event.PrevHash,
+			Hash:      HashAuditEvent(event),
+			CreatedAt: event.CreatedAt,
+		})
+
+		return err
+	})
+
+	return result, txError
+}
+
+// HashAuditEvent hashes the event's fields together with the previous hash.
+// Fields are length-prefixed so they can't be shifted into one another.
+func HashAuditEvent(event AuditEvent) []byte {
+	hash := sha256.New()
+	fields := [][]byte{
+		event.PrevHash,
+		[]byte(event.Actor),
+		[]byte(event.Action),
+		[]byte(event.Resource),
+		[]byte(event.Outcome),
+		[]byte(event.Detail),
+		[]byte(event.ClientIp),
+		[]byte(event.UserAgent),
+		binary.BigEndian.AppendUint64(nil, uint64(event.CreatedAt.Time.UnixMicro())),
+	}
+
+	for _, field := range fields {
+		hash.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		hash.Write(field)
+	}
+
+	return hash.Sum(nil)
+}
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+)
+
+type CreateAccountTxResult struct {
+	Account Account
+}
+
+// CreateAccountTx creates the account and its AccountCreated event.
+func (store *SQLStore) CreateAccountTx(
+	ctx context.Context,
+	arg CreateAccountParams,
+) (result CreateAccountTxResult, txError error) {
+	txError = store.execTx(ctx, func(q *Queries) (err error) {
+		if result.Account, err = q.CreateAccount(ctx, arg); err != nil {
+			return err
+		}
+
+		event, err := accountCreatedEvent(result.Account)
+		if err != nil {
+			return err
+		}
+
+		return writeEvents(ctx, q, event)
+	})
+
+	return result, txError
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 )
 
+// CreateUserTxParams' AfterCreate runs in the transaction with its queries,
+// so side effects written to the outbox commit along with the user.
 type CreateUserTxParams struct {
-	AfterCreate func(user User) error
+	AfterCreate func(q Querier, user User) error
 	CreateUserParams
 }
 
@@ -22,7 +24,7 @@ func (store *SQLStore) CreateUserTx(
 			return err
 		}
 
-		return arg.AfterCreate(result.User)
+		return arg.AfterCreate(q, result.User)
 	})
 
 	return result, txError
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+)
+
+type EnableTOTPTxParams struct {
+	Username            string
+	HashedRecoveryCodes []string
+}
+
+type EnableTOTPTxResult struct {
+	User User
+}
+
+func (store *SQLStore) EnableTOTPTx(
+	ctx context.Context,
+	arg EnableTOTPTxParams,
+) (result EnableTOTPTxResult, txError error) {
+	txError = store.execTx(ctx, func(q *Queries) (err error) {
+		if result.User, err = q.EnableUserTOTP(ctx, arg.Username); err != nil {
+			return err
+		}
+
+		if err = q.DeleteRecoveryCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+
+		for _, hashedCode := range arg.HashedRecoveryCodes {
+			if err = q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username:   arg.Username,
+				HashedCode: hashedCode,
+			}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return result, txError
+}
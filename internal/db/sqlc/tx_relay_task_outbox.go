@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+)
+
+type RelayTaskOutboxTxParams struct {
+	// Publish is called for each pending task, oldest first. The first
+	// error stops the relay, keeping the tasks published before it as sent.
+	Publish func(task TaskOutbox) error
+	Limit   int32
+}
+
+type RelayTaskOutboxTxResult struct {
+	Sent int
+}
+
+// RelayTaskOutboxTx locks a batch of pending tasks, skipping those locked by
+// another relay, and marks the published ones as sent.
+func (store *SQLStore) RelayTaskOutboxTx(
+	ctx context.Context,
+	arg RelayTaskOutboxTxParams,
+) (result RelayTaskOutboxTxResult, txError error) {
+	var publishErr error
+
+	txError = store.execTx(ctx, func(q *Queries) (err error) {
+		var tasks []TaskOutbox
+
+		if tasks, err = q.ListPendingTaskOutbox(ctx, arg.Limit); err != nil {
+			return err
+		}
+
+		for _, task := range tasks {
+			if publishErr = arg.Publish(task); publishErr != nil {
+				break
+			}
+
+			if err = q.MarkTaskOutboxSent(ctx, task.ID); err != nil {
+				return err
+			}
+
+			result.Sent++
+		}
+
+		return nil
+	})
+
+	if txError != nil {
+		return RelayTaskOutboxTxResult{}, txError
+	}
+
+	return result, publishErr
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type RotateSessionTxParams struct {
+	CreateSessionParams
+	RotatedSessionID pgtype.UUID
+}
+
+type RotateSessionTxResult struct {
+	RotatedSession Session
+	Session        Session
+}
+
+// RotateSessionTx returns pgx.ErrNoRows when the session was already rotated,
+// which means its refresh token is being reused.
+func (store *SQLStore) RotateSessionTx(
+	ctx context.Context,
+	arg RotateSessionTxParams,
+) (result RotateSessionTxResult, txError error) {
+	txError = store.execTx(ctx, func(q *Queries) (err error) {
+		if result.RotatedSession, err = q.RotateSession(ctx, arg.RotatedSessionID); err != nil {
+			return err
+		}
+
+		arg.CreateSessionParams.FamilyID = result.RotatedSession.FamilyID
+		result.Session, err = q.CreateSession(ctx, arg.CreateSessionParams)
+
+		return err
+	})
+
+	return result, txError
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+)
+
+// UpdateUserTxParams keeps the replaced password hash when the password
+// changes, pruning the history to the HistorySize most recent ones.
+type UpdateUserTxParams struct {
+	PreviousHashedPassword string
+	UpdateUserParams
+	HistorySize int32
+}
+
+type UpdateUserTxResult struct {
+	User User
+}
+
+func (store *SQLStore) UpdateUserTx(
+	ctx context.Context,
+	arg UpdateUserTxParams,
+) (result UpdateUserTxResult, txError error) {
+	txError = store.execTx(ctx, func(q *Queries) (err error) {
+		if result.User, err = q.UpdateUser(ctx, arg.UpdateUserParams); err != nil {
+			return err
+		}
+
+		event, err := userUpdatedEvent(arg.UpdateUserParams)
+		if err != nil {
+			return err
+		}
+
+		if err = writeEvents(ctx, q, event); err != nil {
+			return err
+		}
+
+		if !arg.HashedPassword.Valid || len(arg.PreviousHashedPassword) == 0 || arg.HistorySize <= 0 {
+			return nil
+		}
+
+		if err = q.CreatePasswordHistory(ctx, CreatePasswordHistoryParams{
+			Username:       arg.Username,
+			HashedPassword: arg.PreviousHashedPassword,
+		}); err != nil {
+			return err
+		}
+
+		return q.PrunePasswordHistory(ctx, PrunePasswordHistoryParams{
+			Username: arg.Username,
+			Keep:     arg.HistorySize,
+		})
+	})
+
+	return result, txError
+}
@@ -49,6 +49,7 @@ func TestCreateUser(t *testing.T) {
 	require.Equal(t, arg.FullName, user.FullName)
 	require.True(t, user.PasswordChangedAt.Time.IsZero())
 	require.NotZero(t, user.CreatedAt)
+	require.Equal(t, "depositor", user.Role)
 }
 
 func TestGetUser(t *testing.T) {
@@ -59,6 +60,7 @@ func TestGetUser(t *testing.T) {
 	require.Equal(t, createdUser.Username, fetchedUser.Username)
 	require.Equal(t, createdUser.HashedPassword, fetchedUser.HashedPassword)
 	require.Equal(t, createdUser.Email, fetchedUser.Email)
+	require.Equal(t, createdUser.Role, fetchedUser.Role)
 	require.WithinDuration(t, createdUser.PasswordChangedAt.Time, fetchedUser.PasswordChangedAt.Time, time.Second)
 	require.WithinDuration(t, createdUser.CreatedAt.Time, fetchedUser.CreatedAt.Time, time.Second)
 }
@@ -79,3 +81,20 @@ func TestUpdate(t *testing.T) {
 	require.Equal(t, oldUser.Email, updatedUser.Email)
 	require.Equal(t, oldUser.HashedPassword, updatedUser.HashedPassword)
 }
+
+func TestUpdateUserRole(t *testing.T) {
+	oldUser, _ := createRandomUser(nil)
+	updatedUser, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: oldUser.Username,
+		Role:     pgtype.Text{String: "auditor", Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "auditor", updatedUser.Role)
+	require.Equal(t, oldUser.FullName, updatedUser.FullName)
+
+	_, err = testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: oldUser.Username,
+		Role:     pgtype.Text{String: "root", Valid: true},
+	})
+	require.Error(t, err)
+}
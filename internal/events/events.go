@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+// Event types are the full names of the protobuf messages in
+// proto/events, which include their schema version.
+const (
+	TypeAccountCreated    = "events.v1.AccountCreated"
+	TypeTransferCompleted = "events.v1.TransferCompleted"
+	TypeEntryPosted       = "events.v1.EntryPosted"
+	TypeUserUpdated       = "events.v1.UserUpdated"
+)
+
+// The store writes events to the task outbox, in the transaction producing
+// them, as a task the worker publishes. The queue is the worker's default.
+const (
+	TaskPublishEvents = "task:publish_events"
+	TaskQueue         = "default"
+)
+
+// Event is a domain event. ID is unique, letting consumers discard events
+// delivered more than once, and Key identifies the entity it's about.
+type Event struct {
+	OccurredAt time.Time `json:"occurred_at"`
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Key        string    `json:"key"`
+	Data       []byte    `json:"data"`
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, events ...Event) error
+}
+
+func New(eventType, key string, message proto.Message) (event Event, err error) {
+	if event.Data, err = proto.Marshal(message); err != nil {
+		return Event{}, fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+
+	event.ID = uuid.NewString()
+	event.Type = eventType
+	event.Key = key
+	event.OccurredAt = time.Now()
+
+	return event, nil
+}
+
+// Unmarshal decodes the event's data into message, which must be of the
+// event's type.
+func (event Event) Unmarshal(message proto.Message) error {
+	return proto.Unmarshal(event.Data, message)
+}
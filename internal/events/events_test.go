@@ -0,0 +1,55 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryPublisher(t *testing.T) {
+	publisher := NewMemoryPublisher()
+
+	require.NoError(t, publisher.Publish(
+		context.TODO(),
+		Event{ID: "1", Type: TypeTransferCompleted},
+		Event{ID: "2", Type: TypeEntryPosted},
+		Event{ID: "3", Type: TypeEntryPosted},
+	))
+	require.NoError(t, publisher.Publish(context.TODO(), Event{ID: "4", Type: TypeAccountCreated}))
+
+	require.Len(t, publisher.Events(), 4)
+	require.Len(t, publisher.Events(TypeEntryPosted), 2)
+	require.Len(t, publisher.Events(TypeAccountCreated, TypeTransferCompleted), 2)
+	require.Empty(t, publisher.Events(TypeUserUpdated))
+}
+
+func TestStreamEntry(t *testing.T) {
+	event := Event{
+		OccurredAt: time.Now().UTC(),
+		ID:         "b2a4c9a8-3c6f-4a4e-9d58-2f0b6a4f6a1e",
+		Type:       TypeEntryPosted,
+		Key:        "accounts/1",
+		Data:       []byte{0x08, 0x01, 0x10, 0x02},
+	}
+
+	// Redis returns every value as a string.
+	fields := encodeEntry(event)
+	values := make(map[string]interface{}, len(fields)/2)
+
+	for i := 0; i < len(fields); i += 2 {
+		values[fields[i].(string)] = fmt.Sprintf("%s", fields[i+1])
+	}
+
+	decoded, err := decodeEntry(redis.XMessage{ID: "1-0", Values: values})
+	require.NoError(t, err)
+	require.Equal(t, event, decoded)
+
+	delete(values, "type")
+
+	_, err = decodeEntry(redis.XMessage{ID: "1-0", Values: values})
+	require.ErrorIs(t, err, ERR_INVALID_STREAM_ENTRY)
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPublisher keeps the published events, for tests.
+type MemoryPublisher struct {
+	events []Event
+	mu     sync.Mutex
+}
+
+func (publisher *MemoryPublisher) Publish(_ context.Context, events ...Event) error {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	publisher.events = append(publisher.events, events...)
+
+	return nil
+}
+
+// Events returns the published events of the given types, or all of them
+// when none is given, in publishing order.
+func (publisher *MemoryPublisher) Events(types ...string) []Event {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	events := make([]Event, 0, len(publisher.events))
+
+	for _, event := range publisher.events {
+		if len(types) == 0 || contains(types, event.Type) {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
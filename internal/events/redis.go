@@ -0,0 +1,196 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+)
+
+var ERR_INVALID_STREAM_ENTRY = errors.New("[Err]: Invalid event stream entry")
+
+// RedisStream publishes events to a Redis stream in order. Every consumer
+// group receives all the events, which its consumers share, acknowledging
+// each one once it's handled.
+type RedisStream struct {
+	client redis.UniversalClient
+	name   string
+	maxLen int64
+}
+
+// Publish adds the events atomically. The stream is trimmed to about
+// maxLen entries.
+func (stream *RedisStream) Publish(ctx context.Context, events ...Event) error {
+	pipe := stream.client.TxPipeline()
+
+	for _, event := range events {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: stream.name,
+			MaxLen: stream.maxLen,
+			Approx: true,
+			Values: encodeEntry(event),
+		})
+	}
+
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+func (stream *RedisStream) Close() error {
+	return stream.client.Close()
+}
+
+// CreateGroup creates the consumer group, and the stream, if missing. A new
+// group receives the events published after its creation.
+func (stream *RedisStream) CreateGroup(ctx context.Context, group string) error {
+	err := stream.client.XGroupCreateMkStream(ctx, stream.name, group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+
+	return err
+}
+
+// Consume hands the group's events to handle, as consumer, until ctx is
+// done. The events a previous run left unacknowledged are handled first; an
+// event handle fails on stays pending until the consumer runs again.
+func (stream *RedisStream) Consume(
+	ctx context.Context,
+	group string,
+	consumer string,
+	handle func(ctx context.Context, event Event) error,
+) error {
+	pending := true
+
+	for ctx.Err() == nil {
+		id := ">"
+
+		if pending {
+			id = "0"
+		}
+
+		streams, err := stream.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: consumer,
+			Streams:  []string{stream.name, id},
+			Count:    100,
+			Block:    time.Second,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		acked := 0
+
+		for _, message := range streams[0].Messages {
+			if !stream.handleEntry(ctx, message, handle) {
+				continue
+			}
+
+			if err = stream.client.XAck(ctx, stream.name, group, message.ID).Err(); err != nil {
+				return err
+			}
+
+			acked++
+		}
+
+		// Stop going over the pending events once they're all handled, or
+		// none of them could be.
+		if pending && acked == 0 {
+			pending = false
+		}
+	}
+
+	return nil
+}
+
+// handleEntry reports whether the entry should be acknowledged: if it was
+// handled, or it's malformed and never will be.
+func (stream *RedisStream) handleEntry(
+	ctx context.Context,
+	message redis.XMessage,
+	handle func(ctx context.Context, event Event) error,
+) bool {
+	event, err := decodeEntry(message)
+	if err != nil {
+		log.Error().Err(err).Str("stream", stream.name).Str("entry", message.ID).Msg("dropped event")
+		return true
+	}
+
+	if err = handle(ctx, event); err != nil {
+		log.Error().
+			Err(err).
+			Str("stream", stream.name).
+			Str("id", event.ID).
+			Str("type", event.Type).
+			Msg("failed to handle event")
+
+		return false
+	}
+
+	return true
+}
+
+func encodeEntry(event Event) []interface{} {
+	return []interface{}{
+		"id", event.ID,
+		"type", event.Type,
+		"key", event.Key,
+		"occurred_at", event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		"data", event.Data,
+	}
+}
+
+func decodeEntry(message redis.XMessage) (event Event, err error) {
+	values := make(map[string]string, len(message.Values))
+
+	for _, field := range []string{"id", "type", "key", "occurred_at", "data"} {
+		value, ok := message.Values[field].(string)
+		if !ok {
+			return Event{}, fmt.Errorf("%w: missing %s", ERR_INVALID_STREAM_ENTRY, field)
+		}
+
+		values[field] = value
+	}
+
+	if event.OccurredAt, err = time.Parse(time.RFC3339Nano, values["occurred_at"]); err != nil {
+		return Event{}, fmt.Errorf("%w: %w", ERR_INVALID_STREAM_ENTRY, err)
+	}
+
+	event.ID = values["id"]
+	event.Type = values["type"]
+	event.Key = values["key"]
+	event.Data = []byte(values["data"])
+
+	return event, nil
+}
+
+func NewRedisStream(client redis.UniversalClient, name string, maxLen int64) *RedisStream {
+	return &RedisStream{
+		client: client,
+		name:   name,
+		maxLen: maxLen,
+	}
+}
+
+func NewConfiguredRedisStream() *RedisStream {
+	client := redis.NewClient(&redis.Options{
+		Addr: net.JoinHostPort(config.Redis.Host, config.Redis.Port),
+	})
+
+	return NewRedisStream(client, config.App.EventStream, config.App.EventStreamMaxLen)
+}
@@ -0,0 +1,147 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/dharmavagabond/simple-bank/internal/activity"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+const (
+	maxWatchedAccounts    = 20
+	watchedAccountsHeader = "x-watched-accounts"
+)
+
+// WatchAccount streams the entries posted to the accounts, and their
+// resulting balances, as transfers commit. It only sends activity from the
+// moment it subscribes.
+func (server *Server) WatchAccount(
+	req *pb.WatchAccountRequest,
+	stream pb.SimpleBankService_WatchAccountServer,
+) (err error) {
+	var accountIDs []int64
+
+	ctx := stream.Context()
+	authPayload := authPayloadFromContext(ctx)
+
+	if violations := validateWatchAccountRequest(req); len(violations) > 0 {
+		return invalidArgumentError(violations)
+	}
+
+	if accountIDs, err = server.watchableAccounts(ctx, authPayload, req.GetAccountIds()); err != nil {
+		return err
+	}
+
+	sub := server.accountActivity.Subscribe(accountIDs...)
+	defer sub.Close()
+
+	// Headers tell clients, like the SSE handler, the stream was accepted.
+	if err = stream.SendHeader(metadata.Pairs(watchedAccountsHeader, joinIDs(accountIDs))); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case accountActivity, ok := <-sub.C():
+			if !ok {
+				if errors.Is(sub.Err(), activity.ERR_HUB_CLOSED) {
+					return status.Error(codes.Unavailable, "the server is shutting down")
+				}
+
+				return status.Error(codes.ResourceExhausted, sub.Err().Error())
+			}
+
+			if err = stream.Send(convertAccountActivity(accountActivity)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watchableAccounts checks that the caller may read each account, defaulting
+// to the caller's own accounts.
+func (server *Server) watchableAccounts(
+	ctx context.Context,
+	authPayload *token.Payload,
+	accountIDs []int64,
+) ([]int64, error) {
+	if len(accountIDs) == 0 {
+		accounts, err := server.store.ListAccounts(ctx, db.ListAccountsParams{
+			Owner: authPayload.Username,
+			Limit: maxWatchedAccounts,
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to list accounts: %s", err.Error())
+		}
+
+		if len(accounts) == 0 {
+			return nil, status.Error(codes.NotFound, "the user has no accounts")
+		}
+
+		for _, account := range accounts {
+			accountIDs = append(accountIDs, account.ID)
+		}
+
+		return accountIDs, nil
+	}
+
+	for _, id := range accountIDs {
+		account, err := server.store.GetAccount(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, status.Errorf(codes.NotFound, "account %d not found", id)
+			}
+
+			return nil, status.Errorf(codes.Internal, "failed to get account: %s", err.Error())
+		}
+
+		if err = authz.Authorize(authPayload, authz.ActionReadAccount, account.Owner); err != nil {
+			return nil, status.Errorf(codes.PermissionDenied, "account %d: %s", id, err.Error())
+		}
+	}
+
+	return accountIDs, nil
+}
+
+func validateWatchAccountRequest(req *pb.WatchAccountRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	accountIDs := req.GetAccountIds()
+
+	if len(accountIDs) > maxWatchedAccounts {
+		violations = append(violations, fieldViolation(
+			"account_ids",
+			fmt.Errorf("must have at most %d accounts", maxWatchedAccounts),
+		))
+	}
+
+	if slices.ContainsFunc(accountIDs, func(id int64) bool { return id < 1 }) {
+		violations = append(violations, fieldViolation("account_ids", errors.New("must be positive")))
+	}
+
+	return violations
+}
+
+func joinIDs(ids []int64) string {
+	values := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		values = append(values, strconv.FormatInt(id, 10))
+	}
+
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,175 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+type watchAccountStream struct {
+	ggrpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+	sent   chan *pb.AccountActivity
+}
+
+func (stream *watchAccountStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *watchAccountStream) SendHeader(md metadata.MD) error {
+	stream.header = md
+	return nil
+}
+
+func (stream *watchAccountStream) Send(activity *pb.AccountActivity) error {
+	select {
+	case stream.sent <- activity:
+	case <-stream.ctx.Done():
+	}
+
+	return nil
+}
+
+func TestWatchAccount(t *testing.T) {
+	owner := &token.Payload{Username: "alice", Role: authz.RoleDepositor}
+	testCases := []struct {
+		name       string
+		payload    *token.Payload
+		accountIDs []int64
+		buildStubs func(store *mocks.Store)
+		code       codes.Code
+	}{
+		{
+			name:    "OwnAccounts",
+			payload: owner,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					ListAccounts(mock.Anything, db.ListAccountsParams{Owner: "alice", Limit: maxWatchedAccounts}).
+					Once().
+					Return([]db.Account{{ID: 1, Owner: "alice"}, {ID: 2, Owner: "alice"}}, nil)
+			},
+			code: codes.OK,
+		},
+		{
+			name:       "OwnedAccount",
+			payload:    owner,
+			accountIDs: []int64{2},
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetAccount(mock.Anything, int64(2)).
+					Once().
+					Return(db.Account{ID: 2, Owner: "alice"}, nil)
+			},
+			code: codes.OK,
+		},
+		{
+			name:       "OtherOwnersAccount",
+			payload:    owner,
+			accountIDs: []int64{2},
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetAccount(mock.Anything, int64(2)).
+					Once().
+					Return(db.Account{ID: 2, Owner: "bob"}, nil)
+			},
+			code: codes.PermissionDenied,
+		},
+		{
+			name:       "AccountNotFound",
+			payload:    owner,
+			accountIDs: []int64{2},
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetAccount(mock.Anything, int64(2)).
+					Once().
+					Return(db.Account{}, pgx.ErrNoRows)
+			},
+			code: codes.NotFound,
+		},
+		{
+			name:       "InvalidAccountID",
+			payload:    owner,
+			accountIDs: []int64{0},
+			buildStubs: func(store *mocks.Store) {},
+			code:       codes.InvalidArgument,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			tc.buildStubs(store)
+			server, err := NewServer(store, nil)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.WithValue(context.Background(), authPayloadKey{}, tc.payload))
+			defer cancel()
+
+			stream := &watchAccountStream{ctx: ctx, sent: make(chan *pb.AccountActivity, 1)}
+			done := make(chan error, 1)
+
+			go func() {
+				done <- server.WatchAccount(&pb.WatchAccountRequest{AccountIds: tc.accountIDs}, stream)
+			}()
+
+			if tc.code != codes.OK {
+				require.Equal(t, tc.code, status.Code(<-done))
+				return
+			}
+
+			// Publish until the subscription is in place.
+			require.Eventually(t, func() bool {
+				server.accountActivity.Publish(db.AccountActivity{AccountID: 2, EntryID: 7, Balance: 90})
+				return len(stream.sent) > 0
+			}, time.Second, 10*time.Millisecond)
+
+			activity := <-stream.sent
+			require.Equal(t, int64(2), activity.GetAccountId())
+			require.Equal(t, int64(7), activity.GetEntryId())
+			require.Equal(t, int64(90), activity.GetBalance())
+			require.NotEmpty(t, stream.header.Get(watchedAccountsHeader))
+
+			cancel()
+			require.NoError(t, <-done)
+		})
+	}
+}
+
+func TestWatchAccountShutdown(t *testing.T) {
+	store := mocks.NewStore(t)
+	store.
+		EXPECT().
+		GetAccount(mock.Anything, int64(2)).
+		Once().
+		Return(db.Account{ID: 2, Owner: "alice"}, nil)
+	server, err := NewServer(store, nil)
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), authPayloadKey{}, &token.Payload{Username: "alice", Role: authz.RoleDepositor})
+	stream := &watchAccountStream{ctx: ctx, sent: make(chan *pb.AccountActivity, 1)}
+
+	server.accountActivity.Close()
+
+	err = server.WatchAccount(&pb.WatchAccountRequest{AccountIds: []int64{2}}, stream)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+}
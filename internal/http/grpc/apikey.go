@@ -0,0 +1,131 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/dharmavagabond/simple-bank/internal/apikey"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+)
+
+func (server *Server) CreateAPIKey(
+	ctx context.Context,
+	req *pb.CreateAPIKeyRequest,
+) (res *pb.CreateAPIKeyResponse, err error) {
+	var (
+		apiKey       db.ApiKey
+		id           uuid.UUID
+		key          string
+		hashedSecret string
+	)
+
+	authPayload := authPayloadFromContext(ctx)
+
+	if violations := validateCreateAPIKeyRequest(req, authPayload.Role); len(violations) > 0 {
+		return nil, invalidArgumentError(violations)
+	}
+
+	if id, key, hashedSecret, err = apikey.Generate(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if apiKey, err = server.store.CreateApiKey(ctx, db.CreateApiKeyParams{
+		ID:           pgtype.UUID{Bytes: id, Valid: true},
+		Owner:        authPayload.Username,
+		Name:         req.GetName(),
+		HashedSecret: hashedSecret,
+		Scopes:       req.GetScopes(),
+		ExpiresAt:    pgtype.Timestamptz{Time: req.GetExpiresAt().AsTime(), Valid: true},
+	}); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to create API key: %s",
+			err.Error(),
+		)
+	}
+
+	res = &pb.CreateAPIKeyResponse{
+		Id:        id.String(),
+		Name:      apiKey.Name,
+		Key:       key,
+		Scopes:    apiKey.Scopes,
+		ExpiresAt: timestamppb.New(apiKey.ExpiresAt.Time),
+		CreatedAt: timestamppb.New(apiKey.CreatedAt.Time),
+	}
+
+	return res, nil
+}
+
+func (server *Server) RevokeAPIKey(
+	ctx context.Context,
+	req *pb.RevokeAPIKeyRequest,
+) (res *pb.RevokeAPIKeyResponse, err error) {
+	var (
+		id uuid.UUID
+	)
+
+	authPayload := authPayloadFromContext(ctx)
+
+	if id, err = uuid.Parse(req.GetId()); err != nil {
+		return nil, invalidArgumentError([]*errdetails.BadRequest_FieldViolation{
+			fieldViolation("id", err),
+		})
+	}
+
+	if _, err = server.store.RevokeApiKey(ctx, db.RevokeApiKeyParams{
+		ID:    pgtype.UUID{Bytes: id, Valid: true},
+		Owner: authPayload.Username,
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "API key not found")
+		}
+
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to revoke API key: %s",
+			err.Error(),
+		)
+	}
+
+	return &pb.RevokeAPIKeyResponse{}, nil
+}
+
+func validateCreateAPIKeyRequest(
+	req *pb.CreateAPIKeyRequest,
+	role string,
+) (violations []*errdetails.BadRequest_FieldViolation) {
+	violations = make([]*errdetails.BadRequest_FieldViolation, 0, 3)
+
+	if len(req.GetName()) == 0 || len(req.GetName()) > 64 {
+		violations = append(violations, fieldViolation("name", errors.New("must have 1-64 characters")))
+	}
+
+	if len(req.GetScopes()) == 0 {
+		violations = append(violations, fieldViolation("scopes", errors.New("is required")))
+	} else if err := authz.ValidateScopes(role, req.GetScopes()); err != nil {
+		violations = append(violations, fieldViolation("scopes", err))
+	}
+
+	lifetime := time.Until(req.GetExpiresAt().AsTime())
+
+	if req.GetExpiresAt() == nil || lifetime <= 0 || lifetime > config.App.APIKeyMaxLifetime {
+		violations = append(violations, fieldViolation(
+			"expires_at",
+			errors.New("must be in the future and within the maximum API key lifetime"),
+		))
+	}
+
+	return violations
+}
@@ -0,0 +1,36 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+// recordAudit records the outcome of a handler from the error it returned.
+func (server *Server) recordAudit(ctx context.Context, event db.AppendAuditEventTxParams, err error) {
+	mtdt := server.extractMetadata(ctx)
+	event.Outcome = audit.OutcomeSuccess
+	event.ClientIp = clientHost(mtdt.ClientIP)
+	event.UserAgent = mtdt.UserAgent
+
+	if err != nil {
+		code := status.Code(err)
+		event.Outcome = audit.OutcomeFailure
+
+		if code == codes.Unauthenticated || code == codes.PermissionDenied {
+			event.Outcome = audit.OutcomeDenied
+		}
+
+		if len(event.Detail) > 0 {
+			event.Detail += ": "
+		}
+
+		event.Detail += code.String()
+	}
+
+	audit.Record(ctx, server.store, event)
+}
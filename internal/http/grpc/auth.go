@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/dharmavagabond/simple-bank/internal/apikey"
 	"github.com/dharmavagabond/simple-bank/internal/token"
 	"google.golang.org/grpc/metadata"
 )
@@ -13,6 +14,7 @@ import (
 const (
 	authorizationHeader = "authorization"
 	authorizationBearer = "bearer"
+	authorizationAPIKey = "apikey"
 )
 
 func (server *Server) authorizeUser(ctx context.Context) (payload *token.Payload, err error) {
@@ -39,13 +41,22 @@ func (server *Server) authorizeUser(ctx context.Context) (payload *token.Payload
 	authType = strings.ToLower(fields[0])
 	accessToken = fields[1]
 
-	if authType != authorizationBearer {
+	switch authType {
+	case authorizationBearer:
+		if payload, err = server.tokenMaker.VerifyToken(accessToken); err != nil {
+			return nil, fmt.Errorf("invalid access token: %w", err)
+		}
+
+		if payload.IsRefresh() {
+			return nil, fmt.Errorf("invalid access token: %w", token.ERR_REFRESH_TOKEN)
+		}
+	case authorizationAPIKey:
+		if payload, err = apikey.Authenticate(ctx, server.store, accessToken); err != nil {
+			return nil, fmt.Errorf("invalid API key: %w", err)
+		}
+	default:
 		return nil, errors.New("unsupported authorization type")
 	}
 
-	if payload, err = server.tokenMaker.VerifyToken(accessToken); err != nil {
-		return nil, fmt.Errorf("invalid access token: %w", err)
-	}
-
 	return payload, nil
 }
@@ -12,7 +12,20 @@ func convertUser(user db.User) *pb.User {
 		Username:          user.Username,
 		FullName:          user.FullName,
 		Email:             user.Email,
+		Role:              user.Role,
 		PasswordChangedAt: timestamppb.New(user.PasswordChangedAt.Time),
 		CreatedAt:         timestamppb.New(user.CreatedAt.Time),
 	}
 }
+
+func convertAccountActivity(activity db.AccountActivity) *pb.AccountActivity {
+	return &pb.AccountActivity{
+		AccountId:  activity.AccountID,
+		EntryId:    activity.EntryID,
+		TransferId: activity.TransferID,
+		Amount:     activity.Amount,
+		Balance:    activity.Balance,
+		Currency:   activity.Currency,
+		CreatedAt:  timestamppb.New(activity.CreatedAt),
+	}
+}
@@ -0,0 +1,131 @@
+package grpc
+
+import (
+	"context"
+
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+type (
+	accessLevel int
+	// methodPolicy describes who may call a method. When action is set, the
+	// caller's role (and API key or OAuth scopes) must permit it.
+	methodPolicy struct {
+		action authz.Action
+		access accessLevel
+	}
+	authPayloadKey   struct{}
+	authServerStream struct {
+		ggrpc.ServerStream
+		ctx context.Context
+	}
+)
+
+const (
+	accessPublic accessLevel = iota
+	accessAuthenticated
+	// accessSession rejects delegated credentials (API keys, OAuth tokens).
+	accessSession
+)
+
+// methodPolicies is deny by default: a method missing from the table can't
+// be called.
+var methodPolicies = map[string]methodPolicy{
+	pb.SimpleBankService_CreateUser_FullMethodName:                {access: accessPublic},
+	pb.SimpleBankService_UpdateUser_FullMethodName:                {access: accessAuthenticated, action: authz.ActionWriteUser},
+	pb.SimpleBankService_LoginUser_FullMethodName:                 {access: accessPublic},
+	pb.SimpleBankService_RenewAccessToken_FullMethodName:          {access: accessPublic},
+	pb.SimpleBankService_LogoutUser_FullMethodName:                {access: accessPublic},
+	pb.SimpleBankService_LoginUserTOTP_FullMethodName:             {access: accessPublic},
+	pb.SimpleBankService_EnrollTOTP_FullMethodName:                {access: accessSession},
+	pb.SimpleBankService_ConfirmTOTP_FullMethodName:               {access: accessSession},
+	pb.SimpleBankService_BeginPasskeyRegistration_FullMethodName:  {access: accessSession},
+	pb.SimpleBankService_FinishPasskeyRegistration_FullMethodName: {access: accessSession},
+	pb.SimpleBankService_BeginPasskeyLogin_FullMethodName:         {access: accessPublic},
+	pb.SimpleBankService_FinishPasskeyLogin_FullMethodName:        {access: accessPublic},
+	pb.SimpleBankService_CreateAPIKey_FullMethodName:              {access: accessSession},
+	pb.SimpleBankService_RevokeAPIKey_FullMethodName:              {access: accessSession},
+	pb.SimpleBankService_ListTokenKeys_FullMethodName:             {access: accessPublic},
+	pb.SimpleBankService_GetReportedSession_FullMethodName:        {access: accessPublic},
+	pb.SimpleBankService_ReportSession_FullMethodName:             {access: accessPublic},
+	pb.SimpleBankService_WatchAccount_FullMethodName:              {access: accessAuthenticated, action: authz.ActionReadAccount},
+}
+
+func (stream *authServerStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (server *Server) authUnaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *ggrpc.UnaryServerInfo,
+	handler ggrpc.UnaryHandler,
+) (interface{}, error) {
+	ctx, err := server.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (server *Server) authStreamInterceptor(
+	srv interface{},
+	stream ggrpc.ServerStream,
+	info *ggrpc.StreamServerInfo,
+	handler ggrpc.StreamHandler,
+) error {
+	ctx, err := server.authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authServerStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticate applies the method's policy and, for non public methods,
+// returns a context carrying the caller's payload.
+func (server *Server) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	var (
+		payload *token.Payload
+		err     error
+	)
+
+	policy, ok := methodPolicies[fullMethod]
+	if !ok {
+		return nil, status.Errorf(codes.PermissionDenied, "no access policy for %s", fullMethod)
+	}
+
+	if policy.access == accessPublic {
+		return ctx, nil
+	}
+
+	if payload, err = server.authorizeUser(ctx); err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	if policy.access == accessSession {
+		if err = authz.RequireSession(payload); err != nil {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+
+	if policy.action != "" && !authz.Permits(payload, policy.action) {
+		return nil, status.Error(codes.PermissionDenied, authz.ERR_PERMISSION_DENIED.Error())
+	}
+
+	return context.WithValue(ctx, authPayloadKey{}, payload), nil
+}
+
+// authPayloadFromContext returns the payload injected by the auth
+// interceptors. It's only nil for public methods.
+func authPayloadFromContext(ctx context.Context) *token.Payload {
+	payload, _ := ctx.Value(authPayloadKey{}).(*token.Payload)
+	return payload
+}
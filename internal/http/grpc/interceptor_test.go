@@ -0,0 +1,140 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+func TestMethodPolicies(t *testing.T) {
+	desc := pb.SimpleBankService_ServiceDesc
+
+	for _, method := range desc.Methods {
+		fullMethod := fmt.Sprintf("/%s/%s", desc.ServiceName, method.MethodName)
+		require.Contains(t, methodPolicies, fullMethod)
+	}
+
+	for _, stream := range desc.Streams {
+		fullMethod := fmt.Sprintf("/%s/%s", desc.ServiceName, stream.StreamName)
+		require.Contains(t, methodPolicies, fullMethod)
+	}
+}
+
+func TestAuthUnaryInterceptor(t *testing.T) {
+	server, err := NewServer(mocks.NewStore(t), nil)
+	require.NoError(t, err)
+
+	accessToken, _, err := server.tokenMaker.CreateToken("someone", authz.RoleDepositor, time.Minute)
+	require.NoError(t, err)
+
+	scopedToken, _, err := server.tokenMaker.CreateScopedToken(
+		"someone",
+		authz.RoleDepositor,
+		[]string{string(authz.ActionReadAccount)},
+		time.Minute,
+	)
+	require.NoError(t, err)
+
+	refreshToken, _, err := server.tokenMaker.CreateRefreshToken("someone", authz.RoleDepositor, nil, time.Minute)
+	require.NoError(t, err)
+
+	withToken := func(accessToken string) context.Context {
+		return metadata.NewIncomingContext(
+			context.Background(),
+			metadata.Pairs(authorizationHeader, fmt.Sprintf("%s %s", authorizationBearer, accessToken)),
+		)
+	}
+
+	testCases := []struct {
+		name       string
+		ctx        context.Context
+		fullMethod string
+		code       codes.Code
+	}{
+		{
+			name:       "Public",
+			ctx:        context.Background(),
+			fullMethod: pb.SimpleBankService_LoginUser_FullMethodName,
+			code:       codes.OK,
+		},
+		{
+			name:       "Authenticated",
+			ctx:        withToken(accessToken),
+			fullMethod: pb.SimpleBankService_UpdateUser_FullMethodName,
+			code:       codes.OK,
+		},
+		{
+			name:       "NoAuthorization",
+			ctx:        context.Background(),
+			fullMethod: pb.SimpleBankService_UpdateUser_FullMethodName,
+			code:       codes.Unauthenticated,
+		},
+		{
+			name:       "InvalidToken",
+			ctx:        withToken("invalid"),
+			fullMethod: pb.SimpleBankService_UpdateUser_FullMethodName,
+			code:       codes.Unauthenticated,
+		},
+		{
+			name:       "RefreshToken",
+			ctx:        withToken(refreshToken),
+			fullMethod: pb.SimpleBankService_UpdateUser_FullMethodName,
+			code:       codes.Unauthenticated,
+		},
+		{
+			name:       "ScopeNotGranted",
+			ctx:        withToken(scopedToken),
+			fullMethod: pb.SimpleBankService_UpdateUser_FullMethodName,
+			code:       codes.PermissionDenied,
+		},
+		{
+			name:       "SessionRequired",
+			ctx:        withToken(scopedToken),
+			fullMethod: pb.SimpleBankService_EnrollTOTP_FullMethodName,
+			code:       codes.PermissionDenied,
+		},
+		{
+			name:       "UnknownMethod",
+			ctx:        withToken(accessToken),
+			fullMethod: "/user.v1.SimpleBankService/Unknown",
+			code:       codes.PermissionDenied,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			var payload *token.Payload
+
+			handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+				payload = authPayloadFromContext(ctx)
+				return nil, nil
+			}
+
+			_, err := server.authUnaryInterceptor(
+				tc.ctx,
+				nil,
+				&ggrpc.UnaryServerInfo{FullMethod: tc.fullMethod},
+				handler,
+			)
+			require.Equal(t, tc.code, status.Code(err))
+
+			if tc.code == codes.OK && tc.ctx != context.Background() {
+				require.NotNil(t, payload)
+				require.Equal(t, "someone", payload.Username)
+			}
+		})
+	}
+}
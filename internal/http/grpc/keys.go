@@ -0,0 +1,34 @@
+package grpc
+
+import (
+	"context"
+
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+// ListTokenKeys publishes the keys that verify v4.public tokens, so other
+// services can check access tokens without sharing a secret. It's empty when
+// tokens are symmetric.
+func (server *Server) ListTokenKeys(
+	_ context.Context,
+	_ *pb.ListTokenKeysRequest,
+) (*pb.ListTokenKeysResponse, error) {
+	res := &pb.ListTokenKeysResponse{}
+	publisher, ok := server.tokenMaker.(token.KeyPublisher)
+
+	if !ok {
+		return res, nil
+	}
+
+	for _, key := range publisher.PublicKeys() {
+		res.Keys = append(res.Keys, &pb.TokenKey{
+			Kid:       key.ID,
+			Version:   key.Version,
+			PublicKey: key.PublicKey,
+			Status:    key.Status,
+		})
+	}
+
+	return res, nil
+}
@@ -31,6 +31,15 @@ func (rec *ResponseRecorder) Write(body []byte) (int, error) {
 	return rec.ResponseWriter.Write(body)
 }
 
+// Flush lets streamed responses through the recorder.
+func (rec *ResponseRecorder) Flush() {
+	_ = http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+func (rec *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
 func init() {
 	if config.App.IsDev {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
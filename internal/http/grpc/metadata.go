@@ -2,6 +2,8 @@ package grpc
 
 import (
 	"context"
+	"net"
+	"strings"
 
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
@@ -15,18 +17,28 @@ type Metadata struct {
 const (
 	grpcGatewayUserAgentHeader = "grpcgateway-user-agent"
 	userAgentHeader            = "user-agent"
+	xForwardedForHeader        = "x-forwarded-for"
 )
 
 func (server *Server) extractMetadata(ctx context.Context) *Metadata {
+	var forwardedFor string
+
 	mtdt := &Metadata{}
 
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		// Through the gateway, user-agent is grpc-go's own, and the caller's
+		// is forwarded instead.
 		if userAgents := md.Get(grpcGatewayUserAgentHeader); len(userAgents) > 0 {
 			mtdt.UserAgent = userAgents[0]
+		} else if userAgents = md.Get(userAgentHeader); len(userAgents) > 0 {
+			mtdt.UserAgent = userAgents[0]
 		}
 
-		if userAgents := md.Get(userAgentHeader); len(userAgents) > 0 {
-			mtdt.UserAgent = userAgents[0]
+		// The gateway appends the caller's address last, so that's the only
+		// entry that can't be spoofed by the client.
+		if values := md.Get(xForwardedForHeader); len(values) > 0 {
+			addrs := strings.Split(values[len(values)-1], ",")
+			forwardedFor = strings.TrimSpace(addrs[len(addrs)-1])
 		}
 	}
 
@@ -34,5 +46,24 @@ func (server *Server) extractMetadata(ctx context.Context) *Metadata {
 		mtdt.ClientIP = p.Addr.String()
 	}
 
+	// Only trust the forwarded address from the gateway, which dials in over
+	// loopback.
+	if len(forwardedFor) > 0 && (len(mtdt.ClientIP) == 0 || isLoopback(mtdt.ClientIP)) {
+		mtdt.ClientIP = forwardedFor
+	}
+
 	return mtdt
 }
+
+func clientHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
+
+func isLoopback(addr string) bool {
+	ip := net.ParseIP(clientHost(addr))
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,67 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestExtractMetadata(t *testing.T) {
+	testCases := []struct {
+		name              string
+		md                metadata.MD
+		peer              string
+		expectedUserAgent string
+		expectedClientIP  string
+	}{
+		{
+			name:              "Direct",
+			md:                metadata.Pairs(userAgentHeader, "grpcurl/1.8.9"),
+			peer:              "203.0.113.7:52000",
+			expectedUserAgent: "grpcurl/1.8.9",
+			expectedClientIP:  "203.0.113.7:52000",
+		},
+		{
+			name: "Gateway",
+			md: metadata.Pairs(
+				userAgentHeader, "grpc-go/1.58.3",
+				grpcGatewayUserAgentHeader, "Mozilla/5.0",
+				xForwardedForHeader, "203.0.113.7",
+			),
+			peer:              "127.0.0.1:52000",
+			expectedUserAgent: "Mozilla/5.0",
+			expectedClientIP:  "203.0.113.7",
+		},
+		{
+			name: "SpoofedForwardedFor",
+			md: metadata.Pairs(
+				userAgentHeader, "grpcurl/1.8.9",
+				xForwardedForHeader, "198.51.100.1",
+			),
+			peer:              "203.0.113.7:52000",
+			expectedUserAgent: "grpcurl/1.8.9",
+			expectedClientIP:  "203.0.113.7:52000",
+		},
+	}
+
+	server := &Server{}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			addr, err := net.ResolveTCPAddr("tcp", tc.peer)
+			require.NoError(t, err)
+
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+			ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+
+			mtdt := server.extractMetadata(ctx)
+			require.Equal(t, tc.expectedUserAgent, mtdt.UserAgent)
+			require.Equal(t, tc.expectedClientIP, mtdt.ClientIP)
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/valid"
+	"github.com/dharmavagabond/simple-bank/internal/webauthn"
+)
+
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+func (server *Server) BeginPasskeyRegistration(
+	ctx context.Context,
+	_ *pb.BeginPasskeyRegistrationRequest,
+) (res *pb.BeginPasskeyRegistrationResponse, err error) {
+	var (
+		user        db.User
+		credentials []db.WebauthnCredential
+		challenge   db.WebauthnChallenge
+	)
+
+	authPayload := authPayloadFromContext(ctx)
+
+	if user, err = server.store.GetUser(ctx, authPayload.Username); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to find user: %s",
+			err.Error(),
+		)
+	}
+
+	if credentials, err = server.store.ListWebauthnCredentials(ctx, user.Username); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to list passkeys: %s",
+			err.Error(),
+		)
+	}
+
+	if challenge, err = server.createWebauthnChallenge(ctx, user.Username, ceremonyRegistration); err != nil {
+		return nil, err
+	}
+
+	userID := sha256.Sum256([]byte(user.Username))
+	res = &pb.BeginPasskeyRegistrationResponse{
+		Challenge:            challenge.Challenge,
+		RpId:                 server.relyingParty.ID,
+		RpName:               server.relyingParty.Name,
+		UserId:               userID[:],
+		UserName:             user.Username,
+		UserDisplayName:      user.FullName,
+		ExcludeCredentialIds: credentialIDs(credentials),
+		ExpiresAt:            timestamppb.New(challenge.ExpiresAt.Time),
+	}
+
+	return res, nil
+}
+
+func (server *Server) FinishPasskeyRegistration(
+	ctx context.Context,
+	req *pb.FinishPasskeyRegistrationRequest,
+) (res *pb.FinishPasskeyRegistrationResponse, err error) {
+	var (
+		challenge  db.WebauthnChallenge
+		credential *webauthn.Credential
+		stored     db.WebauthnCredential
+	)
+
+	authPayload := authPayloadFromContext(ctx)
+
+	if violations := validateFinishPasskeyRegistrationRequest(req); len(violations) > 0 {
+		return nil, invalidArgumentError(violations)
+	}
+
+	if challenge, err = server.consumeWebauthnChallenge(ctx, req.GetChallenge(), ceremonyRegistration); err != nil {
+		return nil, err
+	}
+
+	if challenge.Username != authPayload.Username {
+		return nil, status.Error(codes.PermissionDenied, "challenge belongs to another user")
+	}
+
+	if credential, err = server.relyingParty.VerifyRegistration(
+		challenge.Challenge,
+		req.GetClientDataJson(),
+		req.GetAttestationObject(),
+	); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid passkey: %s", err.Error())
+	}
+
+	if stored, err = server.store.CreateWebauthnCredential(ctx, db.CreateWebauthnCredentialParams{
+		Username:     authPayload.Username,
+		CredentialID: credential.ID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    int64(credential.SignCount),
+		Name:         req.GetName(),
+	}); err != nil {
+		var pgErr *pgconn.PgError
+
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return nil, status.Error(codes.AlreadyExists, "passkey already registered")
+		}
+
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to store the passkey: %s",
+			err.Error(),
+		)
+	}
+
+	res = &pb.FinishPasskeyRegistrationResponse{
+		CredentialId: stored.CredentialID,
+		Name:         stored.Name,
+		CreatedAt:    timestamppb.New(stored.CreatedAt.Time),
+	}
+
+	return res, nil
+}
+
+func (server *Server) BeginPasskeyLogin(
+	ctx context.Context,
+	req *pb.BeginPasskeyLoginRequest,
+) (res *pb.BeginPasskeyLoginResponse, err error) {
+	var (
+		credentials []db.WebauthnCredential
+		challenge   db.WebauthnChallenge
+	)
+
+	if err = valid.ValidateUsername(req.GetUsername()); err != nil {
+		return nil, invalidArgumentError([]*errdetails.BadRequest_FieldViolation{
+			fieldViolation("username", err),
+		})
+	}
+
+	if credentials, err = server.store.ListWebauthnCredentials(ctx, req.GetUsername()); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to list passkeys: %s",
+			err.Error(),
+		)
+	}
+
+	// Unknown users, and those without passkeys, get a challenge that can't
+	// succeed, so the response doesn't tell them apart.
+	if len(credentials) == 0 {
+		res = &pb.BeginPasskeyLoginResponse{
+			Challenge:          webauthn.NewChallenge(),
+			RpId:               server.relyingParty.ID,
+			AllowCredentialIds: [][]byte{server.relyingParty.DecoyCredentialID(req.GetUsername())},
+			ExpiresAt:          timestamppb.New(time.Now().Add(config.App.MFAChallengeDuration)),
+		}
+
+		return res, nil
+	}
+
+	if challenge, err = server.createWebauthnChallenge(ctx, req.GetUsername(), ceremonyLogin); err != nil {
+		return nil, err
+	}
+
+	res = &pb.BeginPasskeyLoginResponse{
+		Challenge:          challenge.Challenge,
+		RpId:               server.relyingParty.ID,
+		AllowCredentialIds: credentialIDs(credentials),
+		ExpiresAt:          timestamppb.New(challenge.ExpiresAt.Time),
+	}
+
+	return res, nil
+}
+
+func (server *Server) FinishPasskeyLogin(
+	ctx context.Context,
+	req *pb.FinishPasskeyLoginRequest,
+) (res *pb.LoginUserResponse, err error) {
+	var (
+		challenge db.WebauthnChallenge
+		stored    db.WebauthnCredential
+		user      db.User
+		signCount uint32
+	)
+
+	if violations := validateFinishPasskeyLoginRequest(req); len(violations) > 0 {
+		return nil, invalidArgumentError(violations)
+	}
+
+	defer func() {
+		server.recordAudit(ctx, db.AppendAuditEventTxParams{
+			Actor:    stored.Username,
+			Action:   audit.ActionLogin,
+			Resource: "users/" + stored.Username,
+			Detail:   "passkey",
+		}, err)
+	}()
+
+	if challenge, err = server.consumeWebauthnChallenge(ctx, req.GetChallenge(), ceremonyLogin); err != nil {
+		return nil, err
+	}
+
+	if stored, err = server.store.GetWebauthnCredential(ctx, req.GetCredentialId()); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.Unauthenticated, "unknown passkey")
+		}
+
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to find passkey: %s",
+			err.Error(),
+		)
+	}
+
+	if stored.Username != challenge.Username {
+		return nil, status.Error(codes.Unauthenticated, "unknown passkey")
+	}
+
+	if signCount, err = server.relyingParty.VerifyAssertion(
+		challenge.Challenge,
+		&webauthn.Credential{
+			ID:        stored.CredentialID,
+			PublicKey: stored.PublicKey,
+			SignCount: uint32(stored.SignCount),
+		},
+		req.GetClientDataJson(),
+		req.GetAuthenticatorData(),
+		req.GetSignature(),
+	); err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid passkey assertion: %s", err.Error())
+	}
+
+	if _, err = server.store.UpdateWebauthnCredentialSignCount(ctx, db.UpdateWebauthnCredentialSignCountParams{
+		ID:                stored.ID,
+		SignCount:         int64(signCount),
+		PreviousSignCount: stored.SignCount,
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.Unauthenticated, "passkey was used concurrently")
+		}
+
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to update passkey: %s",
+			err.Error(),
+		)
+	}
+
+	if user, err = server.store.GetUser(ctx, stored.Username); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to find user: %s",
+			err.Error(),
+		)
+	}
+
+	return server.createUserSession(ctx, user)
+}
+
+func (server *Server) createWebauthnChallenge(
+	ctx context.Context,
+	username string,
+	ceremony string,
+) (challenge db.WebauthnChallenge, err error) {
+	if challenge, err = server.store.CreateWebauthnChallenge(ctx, db.CreateWebauthnChallengeParams{
+		Challenge: webauthn.NewChallenge(),
+		Username:  username,
+		Ceremony:  ceremony,
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  time.Now().Add(config.App.MFAChallengeDuration),
+			Valid: true,
+		},
+	}); err != nil {
+		return challenge, status.Errorf(
+			codes.Internal,
+			"failed to create challenge: %s",
+			err.Error(),
+		)
+	}
+
+	return challenge, nil
+}
+
+func (server *Server) consumeWebauthnChallenge(
+	ctx context.Context,
+	challenge string,
+	ceremony string,
+) (result db.WebauthnChallenge, err error) {
+	if result, err = server.store.ConsumeWebauthnChallenge(ctx, db.ConsumeWebauthnChallengeParams{
+		Challenge: challenge,
+		Ceremony:  ceremony,
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return result, status.Error(codes.Unauthenticated, "invalid or expired challenge")
+		}
+
+		return result, status.Errorf(
+			codes.Internal,
+			"failed to consume challenge: %s",
+			err.Error(),
+		)
+	}
+
+	return result, nil
+}
+
+func credentialIDs(credentials []db.WebauthnCredential) [][]byte {
+	ids := make([][]byte, len(credentials))
+
+	for i := range credentials {
+		ids[i] = credentials[i].CredentialID
+	}
+
+	return ids
+}
+
+func validateFinishPasskeyRegistrationRequest(
+	req *pb.FinishPasskeyRegistrationRequest,
+) (violations []*errdetails.BadRequest_FieldViolation) {
+	violations = make([]*errdetails.BadRequest_FieldViolation, 0, 4)
+
+	if len(req.GetChallenge()) == 0 {
+		violations = append(violations, fieldViolation("challenge", errors.New("is required")))
+	}
+
+	if len(req.GetClientDataJson()) == 0 {
+		violations = append(violations, fieldViolation("client_data_json", errors.New("is required")))
+	}
+
+	if len(req.GetAttestationObject()) == 0 {
+		violations = append(violations, fieldViolation("attestation_object", errors.New("is required")))
+	}
+
+	if err := valid.ValidateString(req.GetName(), 0, 100); err != nil {
+		violations = append(violations, fieldViolation("name", err))
+	}
+
+	return violations
+}
+
+func validateFinishPasskeyLoginRequest(
+	req *pb.FinishPasskeyLoginRequest,
+) (violations []*errdetails.BadRequest_FieldViolation) {
+	violations = make([]*errdetails.BadRequest_FieldViolation, 0, 5)
+
+	if len(req.GetChallenge()) == 0 {
+		violations = append(violations, fieldViolation("challenge", errors.New("is required")))
+	}
+
+	if len(req.GetCredentialId()) == 0 {
+		violations = append(violations, fieldViolation("credential_id", errors.New("is required")))
+	}
+
+	if len(req.GetClientDataJson()) == 0 {
+		violations = append(violations, fieldViolation("client_data_json", errors.New("is required")))
+	}
+
+	if len(req.GetAuthenticatorData()) == 0 {
+		violations = append(violations, fieldViolation("authenticator_data", errors.New("is required")))
+	}
+
+	if len(req.GetSignature()) == 0 {
+		violations = append(violations, fieldViolation("signature", errors.New("is required")))
+	}
+
+	return violations
+}
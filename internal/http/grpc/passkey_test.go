@@ -0,0 +1,364 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+	"github.com/dharmavagabond/simple-bank/internal/webauthn/webauthntest"
+)
+
+const passkeyOrigin = "http://localhost:8080"
+
+func passkeyContext(username string) context.Context {
+	return context.WithValue(
+		context.Background(),
+		authPayloadKey{},
+		&token.Payload{Username: username, Role: authz.RoleDepositor},
+	)
+}
+
+func expectWebauthnChallenge(store *mocks.Store, username, ceremony string) {
+	store.
+		EXPECT().
+		CreateWebauthnChallenge(
+			mock.Anything,
+			mock.MatchedBy(func(arg db.CreateWebauthnChallengeParams) bool {
+				return arg.Username == username && arg.Ceremony == ceremony
+			}),
+		).
+		Once().
+		Return(db.WebauthnChallenge{Challenge: "challenge", Username: username, Ceremony: ceremony}, nil)
+}
+
+func TestBeginPasskeyRegistration(t *testing.T) {
+	user := db.User{Username: "alice", FullName: "Alice"}
+	registered := db.WebauthnCredential{Username: user.Username, CredentialID: []byte("registered")}
+
+	store := mocks.NewStore(t)
+	store.
+		EXPECT().
+		GetUser(mock.Anything, user.Username).
+		Once().
+		Return(user, nil)
+	store.
+		EXPECT().
+		ListWebauthnCredentials(mock.Anything, user.Username).
+		Once().
+		Return([]db.WebauthnCredential{registered}, nil)
+	expectWebauthnChallenge(store, user.Username, ceremonyRegistration)
+	server, err := NewServer(store, nil)
+	require.NoError(t, err)
+
+	res, err := server.BeginPasskeyRegistration(passkeyContext(user.Username), &pb.BeginPasskeyRegistrationRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "challenge", res.GetChallenge())
+	require.Equal(t, server.relyingParty.ID, res.GetRpId())
+	require.Equal(t, user.Username, res.GetUserName())
+	require.Equal(t, [][]byte{registered.CredentialID}, res.GetExcludeCredentialIds())
+}
+
+func TestFinishPasskeyRegistration(t *testing.T) {
+	authenticator := webauthntest.NewAuthenticator(t)
+	testCases := []struct {
+		name         string
+		challenge    db.WebauthnChallenge
+		origin       string
+		buildStubs   func(store *mocks.Store)
+		expectedCode codes.Code
+	}{
+		{
+			name:      "OK",
+			challenge: db.WebauthnChallenge{Challenge: "challenge", Username: "alice"},
+			origin:    passkeyOrigin,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					CreateWebauthnCredential(mock.Anything, db.CreateWebauthnCredentialParams{
+						Username:     "alice",
+						CredentialID: authenticator.CredentialID,
+						PublicKey:    authenticator.PublicKey,
+						Name:         "laptop",
+					}).
+					Once().
+					Return(db.WebauthnCredential{CredentialID: authenticator.CredentialID, Name: "laptop"}, nil)
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "OtherUsersChallenge",
+			challenge:    db.WebauthnChallenge{Challenge: "challenge", Username: "bob"},
+			origin:       passkeyOrigin,
+			buildStubs:   func(store *mocks.Store) {},
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "ForeignOrigin",
+			challenge:    db.WebauthnChallenge{Challenge: "challenge", Username: "alice"},
+			origin:       "https://evil.example",
+			buildStubs:   func(store *mocks.Store) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:      "AlreadyRegistered",
+			challenge: db.WebauthnChallenge{Challenge: "challenge", Username: "alice"},
+			origin:    passkeyOrigin,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					CreateWebauthnCredential(mock.Anything, mock.Anything).
+					Once().
+					Return(db.WebauthnCredential{}, &pgconn.PgError{Code: pgerrcode.UniqueViolation})
+			},
+			expectedCode: codes.AlreadyExists,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			store.
+				EXPECT().
+				ConsumeWebauthnChallenge(mock.Anything, db.ConsumeWebauthnChallengeParams{
+					Challenge: tc.challenge.Challenge,
+					Ceremony:  ceremonyRegistration,
+				}).
+				Once().
+				Return(tc.challenge, nil)
+			tc.buildStubs(store)
+			server, err := NewServer(store, nil)
+			require.NoError(t, err)
+
+			clientDataJSON, attestationObject := authenticator.Register(
+				t,
+				server.relyingParty.ID,
+				tc.origin,
+				tc.challenge.Challenge,
+			)
+			res, err := server.FinishPasskeyRegistration(passkeyContext("alice"), &pb.FinishPasskeyRegistrationRequest{
+				Challenge:         tc.challenge.Challenge,
+				ClientDataJson:    clientDataJSON,
+				AttestationObject: attestationObject,
+				Name:              "laptop",
+			})
+			require.Equal(t, tc.expectedCode, status.Code(err))
+
+			if tc.expectedCode == codes.OK {
+				require.Equal(t, authenticator.CredentialID, res.GetCredentialId())
+			}
+		})
+	}
+}
+
+func TestBeginPasskeyLogin(t *testing.T) {
+	registered := db.WebauthnCredential{Username: "alice", CredentialID: []byte("registered")}
+
+	store := mocks.NewStore(t)
+	store.
+		EXPECT().
+		ListWebauthnCredentials(mock.Anything, "alice").
+		Once().
+		Return([]db.WebauthnCredential{registered}, nil)
+	store.
+		EXPECT().
+		ListWebauthnCredentials(mock.Anything, "nobody").
+		Twice().
+		Return([]db.WebauthnCredential{}, nil)
+	expectWebauthnChallenge(store, "alice", ceremonyLogin)
+	server, err := NewServer(store, nil)
+	require.NoError(t, err)
+
+	res, err := server.BeginPasskeyLogin(context.TODO(), &pb.BeginPasskeyLoginRequest{Username: "alice"})
+	require.NoError(t, err)
+	require.Equal(t, "challenge", res.GetChallenge())
+	require.Equal(t, [][]byte{registered.CredentialID}, res.GetAllowCredentialIds())
+
+	// Users without passkeys can't be told apart from those with some.
+	res, err = server.BeginPasskeyLogin(context.TODO(), &pb.BeginPasskeyLoginRequest{Username: "nobody"})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.GetChallenge())
+	require.Equal(t, server.relyingParty.ID, res.GetRpId())
+	require.Len(t, res.GetAllowCredentialIds(), 1)
+	require.NotNil(t, res.GetExpiresAt())
+
+	again, err := server.BeginPasskeyLogin(context.TODO(), &pb.BeginPasskeyLoginRequest{Username: "nobody"})
+	require.NoError(t, err)
+	require.NotEqual(t, res.GetChallenge(), again.GetChallenge())
+	require.Equal(t, res.GetAllowCredentialIds(), again.GetAllowCredentialIds())
+
+	_, err = server.BeginPasskeyLogin(context.TODO(), &pb.BeginPasskeyLoginRequest{Username: "no"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestFinishPasskeyLogin(t *testing.T) {
+	user := db.User{Username: "alice", Role: authz.RoleDepositor}
+	challenge := db.WebauthnChallenge{Challenge: "challenge", Username: user.Username, Ceremony: ceremonyLogin}
+	testCases := []struct {
+		name         string
+		buildStubs   func(store *mocks.Store, stored db.WebauthnCredential)
+		tamper       bool
+		expectedCode codes.Code
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mocks.Store, stored db.WebauthnCredential) {
+				store.
+					EXPECT().
+					GetWebauthnCredential(mock.Anything, stored.CredentialID).
+					Once().
+					Return(stored, nil)
+				store.
+					EXPECT().
+					UpdateWebauthnCredentialSignCount(mock.Anything, db.UpdateWebauthnCredentialSignCountParams{
+						ID:                stored.ID,
+						SignCount:         stored.SignCount + 1,
+						PreviousSignCount: stored.SignCount,
+					}).
+					Once().
+					Return(stored, nil)
+				store.
+					EXPECT().
+					GetUser(mock.Anything, user.Username).
+					Once().
+					Return(user, nil)
+				store.
+					EXPECT().
+					CreateSession(
+						mock.Anything,
+						mock.MatchedBy(func(arg db.CreateSessionParams) bool {
+							return arg.Username == user.Username
+						}),
+					).
+					Once().
+					Return(db.Session{ID: pgtype.UUID{Bytes: uuid.New(), Valid: true}, Username: user.Username}, nil)
+				store.
+					EXPECT().
+					RecordLoginDevice(mock.Anything, mock.Anything).
+					Once().
+					Return(db.RecordLoginDeviceRow{KnownDevice: true, HasDevices: true}, nil)
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name: "UnknownPasskey",
+			buildStubs: func(store *mocks.Store, stored db.WebauthnCredential) {
+				store.
+					EXPECT().
+					GetWebauthnCredential(mock.Anything, stored.CredentialID).
+					Once().
+					Return(db.WebauthnCredential{}, pgx.ErrNoRows)
+			},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name: "OtherUsersPasskey",
+			buildStubs: func(store *mocks.Store, stored db.WebauthnCredential) {
+				stored.Username = "bob"
+				store.
+					EXPECT().
+					GetWebauthnCredential(mock.Anything, stored.CredentialID).
+					Once().
+					Return(stored, nil)
+			},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name: "InvalidSignature",
+			buildStubs: func(store *mocks.Store, stored db.WebauthnCredential) {
+				store.
+					EXPECT().
+					GetWebauthnCredential(mock.Anything, stored.CredentialID).
+					Once().
+					Return(stored, nil)
+			},
+			tamper:       true,
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name: "ConcurrentUse",
+			buildStubs: func(store *mocks.Store, stored db.WebauthnCredential) {
+				store.
+					EXPECT().
+					GetWebauthnCredential(mock.Anything, stored.CredentialID).
+					Once().
+					Return(stored, nil)
+				store.
+					EXPECT().
+					UpdateWebauthnCredentialSignCount(mock.Anything, mock.Anything).
+					Once().
+					Return(db.WebauthnCredential{}, pgx.ErrNoRows)
+			},
+			expectedCode: codes.Unauthenticated,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			authenticator := webauthntest.NewAuthenticator(t)
+			stored := db.WebauthnCredential{
+				ID:           1,
+				Username:     user.Username,
+				CredentialID: authenticator.CredentialID,
+				PublicKey:    authenticator.PublicKey,
+			}
+
+			store := mocks.NewStore(t)
+			store.
+				EXPECT().
+				ConsumeWebauthnChallenge(mock.Anything, db.ConsumeWebauthnChallengeParams{
+					Challenge: challenge.Challenge,
+					Ceremony:  ceremonyLogin,
+				}).
+				Once().
+				Return(challenge, nil)
+			store.
+				EXPECT().
+				AppendAuditEventTx(mock.Anything, mock.Anything).
+				Maybe().
+				Return(db.AppendAuditEventTxResult{}, nil)
+			tc.buildStubs(store, stored)
+			server, err := NewServer(store, nil)
+			require.NoError(t, err)
+
+			clientDataJSON, authData, signature := authenticator.Assert(
+				t,
+				server.relyingParty.ID,
+				passkeyOrigin,
+				challenge.Challenge,
+			)
+			if tc.tamper {
+				signature[len(signature)-1] ^= 0xff
+			}
+
+			res, err := server.FinishPasskeyLogin(context.TODO(), &pb.FinishPasskeyLoginRequest{
+				Challenge:         challenge.Challenge,
+				CredentialId:      authenticator.CredentialID,
+				ClientDataJson:    clientDataJSON,
+				AuthenticatorData: authData,
+				Signature:         signature,
+			})
+			require.Equal(t, tc.expectedCode, status.Code(err))
+
+			if tc.expectedCode == codes.OK {
+				require.NotEmpty(t, res.GetAccessToken())
+				require.Equal(t, user.Username, res.GetUser().GetUsername())
+			}
+		})
+	}
+}
@@ -1,38 +1,63 @@
 package grpc
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/thanhpk/randstr"
 	ggrpc "google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/dharmavagabond/simple-bank/internal/activity"
 	"github.com/dharmavagabond/simple-bank/internal/config"
 	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/lockout"
+	"github.com/dharmavagabond/simple-bank/internal/password"
 	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
 	"github.com/dharmavagabond/simple-bank/internal/token"
+	"github.com/dharmavagabond/simple-bank/internal/webauthn"
 	"github.com/dharmavagabond/simple-bank/internal/worker"
 )
 
 type (
 	Server struct {
 		pb.UnimplementedSimpleBankServiceServer
-		store           db.Store
-		tokenMaker      token.Maker
-		taskDistributor worker.TaskDistributor
+		store             db.Store
+		tokenMaker        token.Maker
+		revocations       token.RevocationStore
+		taskDistributor   worker.TaskDistributor
+		outboxDistributor func(db.Querier) worker.TaskDistributor
+		relyingParty      *webauthn.RelyingParty
+		loginGuard        *lockout.Guard
+		passwordHasher    *password.Hasher
+		passwordPolicy    *password.Policy
+		accountActivity   *activity.Hub
 	}
 )
 
-func (server *Server) Start() error {
+// Start serves until ctx is done, then stops gracefully: account activity
+// streams are ended, and the other RPCs in progress are waited for up to the
+// shutdown timeout before being cancelled.
+func (server *Server) Start(ctx context.Context) error {
 	var (
 		listener net.Listener
 		err      error
 	)
 
 	addr := net.JoinHostPort(config.App.Host, strconv.Itoa(config.App.GrpcPort))
-	grpcLogger := ggrpc.UnaryInterceptor(gRPCLogger)
-	rpcServer := ggrpc.NewServer(grpcLogger)
+	rpcServer := ggrpc.NewServer(
+		ggrpc.ChainUnaryInterceptor(gRPCLogger, server.authUnaryInterceptor),
+		ggrpc.StreamInterceptor(server.authStreamInterceptor),
+	)
+
+	go func() {
+		_ = server.accountActivity.Run(ctx, server.store)
+	}()
 
 	pb.RegisterSimpleBankServiceServer(rpcServer, server)
 	reflection.Register(rpcServer)
@@ -43,20 +68,95 @@ func (server *Server) Start() error {
 
 	log.Info().Msgf("Listening gRPC at %s", listener.Addr().String())
 
-	return rpcServer.Serve(listener)
+	served := make(chan error, 1)
+
+	go func() {
+		served <- rpcServer.Serve(listener)
+	}()
+
+	select {
+	case err = <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info().Msg("shutting down gRPC server")
+
+	server.accountActivity.Close()
+
+	stopped := make(chan struct{})
+
+	go func() {
+		rpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(config.App.ShutdownTimeout):
+		log.Warn().Msg("gRPC server shutdown timed out, cancelling RPCs in progress")
+		rpcServer.Stop()
+	}
+
+	// Serve returns ErrServerStopped when the server is stopped before it
+	// starts serving.
+	if err = <-served; errors.Is(err, ggrpc.ErrServerStopped) {
+		return nil
+	}
+
+	return err
+}
+
+// Close closes the connections of the login guard and the revocation store.
+func (server *Server) Close() error {
+	var err error
+
+	if closer, ok := server.revocations.(io.Closer); ok {
+		err = closer.Close()
+	}
+
+	return errors.Join(err, server.loginGuard.Close())
 }
 
 func NewServer(store db.Store, taskDistributor worker.TaskDistributor) (server *Server, err error) {
-	var tokenMaker token.Maker
+	var (
+		tokenMaker     token.Maker
+		passwordPolicy *password.Policy
+	)
 
-	if tokenMaker, err = token.NewPasetoMaker(config.App.TokenSymmetricKey); err != nil {
+	if tokenMaker, err = token.NewConfiguredMaker(); err != nil {
 		return nil, err
 	}
 
+	passwordHasher := password.NewConfiguredHasher()
+
+	if passwordPolicy, err = password.NewConfiguredPolicy(passwordHasher); err != nil {
+		return nil, err
+	}
+
+	// Without a configured secret, decoy passkeys are only stable while the
+	// process runs.
+	passkeySecret := []byte(config.App.Secret)
+	if len(passkeySecret) == 0 {
+		passkeySecret = randstr.Bytes(32)
+	}
+
+	revocations := token.NewConfiguredRevocationStore()
 	server = &Server{
-		store:           store,
-		tokenMaker:      tokenMaker,
-		taskDistributor: taskDistributor,
+		store:             store,
+		tokenMaker:        token.NewRevokingMaker(tokenMaker, revocations),
+		revocations:       revocations,
+		taskDistributor:   taskDistributor,
+		outboxDistributor: worker.NewOutboxTaskDistributor,
+		relyingParty: &webauthn.RelyingParty{
+			ID:     config.App.WebAuthnRPID,
+			Name:   config.App.WebAuthnRPName,
+			Secret: passkeySecret,
+		},
+		loginGuard:      lockout.NewRedisGuard(),
+		passwordHasher:  passwordHasher,
+		passwordPolicy:  passwordPolicy,
+		accountActivity: activity.NewHub(),
 	}
 
 	return server, nil
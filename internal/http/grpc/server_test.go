@@ -0,0 +1,45 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+)
+
+func TestServerShutdown(t *testing.T) {
+	config.App.GrpcPort = 0
+
+	store := mocks.NewStore(t)
+	store.
+		EXPECT().
+		ListenAccountActivity(mock.Anything, mock.Anything).
+		Maybe().
+		Return(context.Canceled)
+	server, err := NewServer(store, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan error, 1)
+
+	go func() {
+		started <- server.Start(ctx)
+	}()
+
+	cancel()
+
+	select {
+	case err = <-started:
+		require.NoError(t, err)
+	case <-time.After(config.App.ShutdownTimeout):
+		t.Fatal("server didn't shut down")
+	}
+
+	// Cancelled before serving, the server is stopped before Serve runs.
+	require.NoError(t, server.Start(ctx))
+}
@@ -0,0 +1,162 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
+)
+
+// GetReportedSession shows the session behind a "this wasn't me" link of the
+// new sign-in alert, without blocking it: mail scanners follow those links.
+func (server *Server) GetReportedSession(
+	ctx context.Context,
+	req *pb.GetReportedSessionRequest,
+) (*pb.GetReportedSessionResponse, error) {
+	if violations := validateReportLink(req.GetSessionId(), req.GetSecret()); len(violations) > 0 {
+		return nil, invalidArgumentError(violations)
+	}
+
+	session, err := server.getReportedSession(ctx, uuid.MustParse(req.GetSessionId()), req.GetSecret())
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pb.GetReportedSessionResponse{
+		SessionId: req.GetSessionId(),
+		UserAgent: session.UserAgent,
+		ClientIp:  session.ClientIp,
+		CreatedAt: timestamppb.New(session.CreatedAt.Time),
+		IsBlocked: session.IsBlocked,
+	}
+
+	return res, nil
+}
+
+// ReportSession blocks the session reported through the new sign-in alert,
+// and every session rotated from the same login.
+func (server *Server) ReportSession(
+	ctx context.Context,
+	req *pb.ReportSessionRequest,
+) (res *pb.ReportSessionResponse, err error) {
+	var (
+		session db.Session
+		id      uuid.UUID
+	)
+
+	if violations := validateReportLink(req.GetSessionId(), req.GetSecret()); len(violations) > 0 {
+		return nil, invalidArgumentError(violations)
+	}
+
+	id = uuid.MustParse(req.GetSessionId())
+
+	defer func() {
+		server.recordAudit(ctx, db.AppendAuditEventTxParams{
+			Actor:    session.Username,
+			Action:   audit.ActionReportSession,
+			Resource: "sessions/" + id.String(),
+		}, err)
+	}()
+
+	if session, err = server.getReportedSession(ctx, id, req.GetSecret()); err != nil {
+		return nil, err
+	}
+
+	if err = server.revokeSessionFamily(ctx, session.FamilyID); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to block session family: %s",
+			err.Error(),
+		)
+	}
+
+	return &pb.ReportSessionResponse{}, nil
+}
+
+// getReportedSession finds the session of a report link, checking its secret.
+func (server *Server) getReportedSession(
+	ctx context.Context,
+	id uuid.UUID,
+	secret string,
+) (session db.Session, err error) {
+	if session, err = server.store.GetSession(ctx, pgtype.UUID{Bytes: id, Valid: true}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return session, status.Error(codes.NotFound, "session not found")
+		}
+
+		return session, status.Errorf(
+			codes.Internal,
+			"failed to get session: %s",
+			err.Error(),
+		)
+	}
+
+	hashedSecret := worker.HashReportSecret(secret)
+
+	if !session.ReportSecretHash.Valid ||
+		subtle.ConstantTimeCompare([]byte(hashedSecret), []byte(session.ReportSecretHash.String)) != 1 {
+		return session, status.Error(codes.PermissionDenied, "invalid report link")
+	}
+
+	return session, nil
+}
+
+// notifyNewDevice records the session's device and alerts the user when it's
+// a user agent and IP never seen for them. An account's first device isn't
+// reported.
+func (server *Server) notifyNewDevice(ctx context.Context, session db.Session) {
+	device, err := server.store.RecordLoginDevice(ctx, db.RecordLoginDeviceParams{
+		Username:  session.Username,
+		UserAgent: session.UserAgent,
+		ClientIp:  session.ClientIp,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("username", session.Username).Msg("failed to record the login device")
+		return
+	}
+
+	if device.KnownDevice || !device.HasDevices {
+		return
+	}
+
+	if err = worker.SendLoginAlert.Enqueue(
+		ctx,
+		server.taskDistributor,
+		&worker.PayloadSendLoginAlert{
+			SessionID: session.ID.Bytes,
+			Username:  session.Username,
+			UserAgent: session.UserAgent,
+			ClientIP:  session.ClientIp,
+			CreatedAt: session.CreatedAt.Time,
+		},
+	); err != nil {
+		log.Error().Err(err).Str("username", session.Username).Msg("failed to notify the login")
+	}
+}
+
+func validateReportLink(sessionID, secret string) (violations []*errdetails.BadRequest_FieldViolation) {
+	violations = make([]*errdetails.BadRequest_FieldViolation, 0, 2)
+
+	if _, err := uuid.Parse(sessionID); err != nil {
+		violations = append(violations, fieldViolation("session_id", err))
+	}
+
+	if len(secret) == 0 {
+		violations = append(violations, fieldViolation("secret", errors.New("is required")))
+	}
+
+	return violations
+}
@@ -0,0 +1,158 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+)
+
+const (
+	WatchAccountSSEPath = "/v1/watch_account/events"
+	sseHeartbeat        = 15 * time.Second
+)
+
+type watchAccountResult struct {
+	activity *pb.AccountActivity
+	err      error
+}
+
+// NewWatchAccountSSEHandler serves WatchAccount as Server-Sent Events for
+// browser dashboards. Like the gateway, it calls the gRPC server through
+// client, forwarding the Authorization header, so the same interceptors
+// apply. Accounts are given as repeated account_ids query parameters. The
+// streams end when shutdown is closed.
+func NewWatchAccountSSEHandler(client pb.SimpleBankServiceClient, shutdown <-chan struct{}) http.Handler {
+	marshaler := protojson.MarshalOptions{UseProtoNames: true}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var (
+			stream pb.SimpleBankService_WatchAccountClient
+			header metadata.MD
+			err    error
+		)
+
+		req := &pb.WatchAccountRequest{}
+
+		for _, value := range r.URL.Query()["account_ids"] {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				http.Error(rw, "invalid account_ids", http.StatusBadRequest)
+				return
+			}
+
+			req.AccountIds = append(req.AccountIds, id)
+		}
+
+		ctx := r.Context()
+
+		if authorization := r.Header.Get("Authorization"); len(authorization) > 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, authorizationHeader, authorization)
+		}
+
+		ctx = metadata.AppendToOutgoingContext(ctx, grpcGatewayUserAgentHeader, r.UserAgent())
+
+		if stream, err = client.WatchAccount(ctx, req); err == nil {
+			header, err = stream.Header()
+		}
+
+		// A stream refused before sending headers carries its status in
+		// the first message.
+		if err == nil && len(header) == 0 {
+			_, err = stream.Recv()
+		}
+
+		if err != nil {
+			st := status.Convert(err)
+			http.Error(rw, st.Message(), runtime.HTTPStatusFromCode(st.Code()))
+			return
+		}
+
+		rc := http.NewResponseController(rw)
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.Header().Set("X-Accel-Buffering", "no")
+		rw.WriteHeader(http.StatusOK)
+
+		if err = rc.Flush(); err != nil {
+			return
+		}
+
+		results := make(chan watchAccountResult)
+
+		go func() {
+			for {
+				activity, err := stream.Recv()
+
+				select {
+				case results <- watchAccountResult{activity: activity, err: err}:
+				case <-ctx.Done():
+					return
+				}
+
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-shutdown:
+				return
+			case <-heartbeat.C:
+				_, err = io.WriteString(rw, ": heartbeat\n\n")
+			case result := <-results:
+				if result.err != nil {
+					if !errors.Is(result.err, io.EOF) {
+						_, _ = fmt.Fprintf(rw, "event: error\ndata: %s\n\n", status.Convert(result.err).Message())
+						_ = rc.Flush()
+					}
+
+					return
+				}
+
+				err = writeAccountActivityEvent(rw, marshaler, result.activity)
+			}
+
+			if err == nil {
+				err = rc.Flush()
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	})
+}
+
+func writeAccountActivityEvent(
+	w io.Writer,
+	marshaler protojson.MarshalOptions,
+	activity *pb.AccountActivity,
+) error {
+	data, err := marshaler.Marshal(activity)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: account_activity\ndata: %s\n\n", activity.GetEntryId(), data)
+
+	return err
+}
@@ -0,0 +1,144 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+)
+
+type watchAccountClient struct {
+	pb.SimpleBankServiceClient
+	stream pb.SimpleBankService_WatchAccountClient
+	md     metadata.MD
+	req    *pb.WatchAccountRequest
+}
+
+func (client *watchAccountClient) WatchAccount(
+	ctx context.Context,
+	req *pb.WatchAccountRequest,
+	_ ...ggrpc.CallOption,
+) (pb.SimpleBankService_WatchAccountClient, error) {
+	client.md, _ = metadata.FromOutgoingContext(ctx)
+	client.req = req
+	return client.stream, nil
+}
+
+// refusedWatchAccountStream ends without headers, as a stream rejected by
+// the interceptors or the handler's checks.
+type refusedWatchAccountStream struct {
+	ggrpc.ClientStream
+	err error
+}
+
+func (stream *refusedWatchAccountStream) Header() (metadata.MD, error) {
+	return nil, nil
+}
+
+func (stream *refusedWatchAccountStream) Recv() (*pb.AccountActivity, error) {
+	return nil, stream.err
+}
+
+func TestWatchAccountSSEHandler(t *testing.T) {
+	testCases := []struct {
+		name         string
+		query        string
+		err          error
+		expectedCode int
+	}{
+		{
+			name:         "Unauthenticated",
+			query:        "?account_ids=1&account_ids=2",
+			err:          status.Error(codes.Unauthenticated, "missing authorization header"),
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "PermissionDenied",
+			query:        "?account_ids=1&account_ids=2",
+			err:          status.Error(codes.PermissionDenied, "not the owner"),
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "InvalidAccountID",
+			query:        "?account_ids=one",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			client := &watchAccountClient{stream: &refusedWatchAccountStream{err: tc.err}}
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(
+				context.TODO(),
+				http.MethodGet,
+				WatchAccountSSEPath+tc.query,
+				nil,
+			)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer token")
+
+			NewWatchAccountSSEHandler(client, nil).ServeHTTP(rec, req)
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.err != nil {
+				require.Equal(t, []int64{1, 2}, client.req.GetAccountIds())
+				require.Equal(t, []string{"Bearer token"}, client.md.Get(authorizationHeader))
+			}
+		})
+	}
+}
+
+// openWatchAccountStream is accepted and sends nothing until closed.
+type openWatchAccountStream struct {
+	ggrpc.ClientStream
+	closed chan struct{}
+}
+
+func (stream *openWatchAccountStream) Header() (metadata.MD, error) {
+	return metadata.Pairs(watchedAccountsHeader, "1"), nil
+}
+
+func (stream *openWatchAccountStream) Recv() (*pb.AccountActivity, error) {
+	<-stream.closed
+	return nil, io.EOF
+}
+
+func TestWatchAccountSSEHandlerShutdown(t *testing.T) {
+	stream := &openWatchAccountStream{closed: make(chan struct{})}
+	defer close(stream.closed)
+
+	client := &watchAccountClient{stream: stream}
+	shutdown := make(chan struct{})
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, WatchAccountSSEPath, nil)
+	require.NoError(t, err)
+
+	served := make(chan struct{})
+
+	go func() {
+		NewWatchAccountSSEHandler(client, shutdown).ServeHTTP(rec, req)
+		close(served)
+	}()
+
+	close(shutdown)
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("SSE handler didn't end on shutdown")
+	}
+
+	require.Equal(t, http.StatusOK, rec.Code)
+}
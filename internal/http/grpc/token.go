@@ -0,0 +1,280 @@
+package grpc
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+func (server *Server) RenewAccessToken(
+	ctx context.Context,
+	req *pb.RenewAccessTokenRequest,
+) (res *pb.RenewAccessTokenResponse, err error) {
+	var (
+		session             db.Session
+		user                db.User
+		txResult            db.RotateSessionTxResult
+		accessToken         string
+		accessTokenPayload  *token.Payload
+		refreshToken        string
+		refreshTokenPayload *token.Payload
+		sessionID           driver.Value
+	)
+
+	if violations := validateRenewAccessTokenRequest(req); len(violations) > 0 {
+		return nil, invalidArgumentError(violations)
+	}
+
+	if refreshTokenPayload, err = server.tokenMaker.VerifyToken(req.GetRefreshToken()); err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	id := pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true}
+
+	defer func() {
+		server.recordAudit(ctx, db.AppendAuditEventTxParams{
+			Actor:    refreshTokenPayload.Username,
+			Action:   audit.ActionRenewToken,
+			Resource: "sessions/" + refreshTokenPayload.ID.String(),
+		}, err)
+	}()
+
+	if session, err = server.store.GetSession(ctx, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "session not found")
+		}
+
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to find session: %s",
+			err.Error(),
+		)
+	}
+
+	if session.RefreshToken != req.GetRefreshToken() {
+		return nil, status.Error(codes.Unauthenticated, "mismatched session token")
+	}
+
+	if session.RotatedAt.Valid {
+		return nil, server.blockSessionFamily(ctx, session)
+	}
+
+	if session.IsBlocked {
+		return nil, status.Error(codes.Unauthenticated, "blocked session")
+	}
+
+	if session.ClientID.Valid {
+		return nil, status.Error(codes.Unauthenticated, "OAuth sessions are renewed at /oauth/token")
+	}
+
+	if session.Username != refreshTokenPayload.Username {
+		return nil, status.Error(codes.Unauthenticated, "incorrect session user")
+	}
+
+	if time.Now().After(session.ExpiresAt.Time) {
+		return nil, status.Error(codes.Unauthenticated, "expired session")
+	}
+
+	// The role is read again, so a change of role applies from the next
+	// renewal rather than when the session expires.
+	if user, err = server.store.GetUser(ctx, session.Username); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.Unauthenticated, "user not found")
+		}
+
+		return nil, status.Errorf(codes.Internal, "failed to find user: %s", err.Error())
+	}
+
+	if accessToken, accessTokenPayload, err = server.tokenMaker.CreateToken(
+		user.Username,
+		user.Role,
+		config.App.AccessTokenDuration,
+	); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if refreshToken, refreshTokenPayload, err = server.tokenMaker.CreateRefreshToken(
+		user.Username,
+		user.Role,
+		nil,
+		time.Until(session.ExpiresAt.Time),
+	); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	mtdt := server.extractMetadata(ctx)
+
+	if txResult, err = server.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+		RotatedSessionID: session.ID,
+		CreateSessionParams: db.CreateSessionParams{
+			ID:            pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true},
+			Username:      session.Username,
+			RefreshToken:  refreshToken,
+			UserAgent:     mtdt.UserAgent,
+			ClientIp:      clientHost(mtdt.ClientIP),
+			ExpiresAt:     session.ExpiresAt,
+			AccessTokenID: pgtype.UUID{Bytes: accessTokenPayload.ID, Valid: true},
+			AccessTokenExpiresAt: pgtype.Timestamptz{
+				Time:  accessTokenPayload.ExpiredAt,
+				Valid: true,
+			},
+		},
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, server.blockSessionFamily(ctx, session)
+		}
+
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if sessionID, err = txResult.Session.ID.Value(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	res = &pb.RenewAccessTokenResponse{
+		SessionId:             sessionID.(string),
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  timestamppb.New(accessTokenPayload.ExpiredAt),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: timestamppb.New(refreshTokenPayload.ExpiredAt),
+	}
+
+	return res, nil
+}
+
+func (server *Server) LogoutUser(
+	ctx context.Context,
+	req *pb.LogoutUserRequest,
+) (res *pb.LogoutUserResponse, err error) {
+	var (
+		session             db.Session
+		refreshTokenPayload *token.Payload
+	)
+
+	if violations := validateLogoutUserRequest(req); len(violations) > 0 {
+		return nil, invalidArgumentError(violations)
+	}
+
+	if refreshTokenPayload, err = server.tokenMaker.VerifyToken(req.GetRefreshToken()); err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	id := pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true}
+
+	if session, err = server.store.GetSession(ctx, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "session not found")
+		}
+
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to find session: %s",
+			err.Error(),
+		)
+	}
+
+	if session.RefreshToken != req.GetRefreshToken() {
+		return nil, status.Error(codes.Unauthenticated, "mismatched session token")
+	}
+
+	if err = server.revokeSessionFamily(ctx, session.FamilyID); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to block session family: %s",
+			err.Error(),
+		)
+	}
+
+	if err = server.revocations.Revoke(ctx, refreshTokenPayload.ID, refreshTokenPayload.ExpiredAt); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to revoke refresh token: %s",
+			err.Error(),
+		)
+	}
+
+	return &pb.LogoutUserResponse{}, nil
+}
+
+func (server *Server) blockSessionFamily(ctx context.Context, session db.Session) error {
+	if err := server.revokeSessionFamily(ctx, session.FamilyID); err != nil {
+		return status.Errorf(
+			codes.Internal,
+			"failed to block session family: %s",
+			err.Error(),
+		)
+	}
+
+	return status.Error(codes.Unauthenticated, "refresh token reuse detected")
+}
+
+// revokeSessionFamily blocks every session in the family and revokes its
+// refresh and access tokens, so they stop working before they expire.
+func (server *Server) revokeSessionFamily(ctx context.Context, familyID pgtype.UUID) error {
+	sessions, err := server.store.BlockSessionFamily(ctx, familyID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if err = server.revokeToken(ctx, session.ID, session.ExpiresAt); err != nil {
+			return err
+		}
+
+		if err = server.revokeToken(ctx, session.AccessTokenID, session.AccessTokenExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (server *Server) revokeToken(
+	ctx context.Context,
+	id pgtype.UUID,
+	expiresAt pgtype.Timestamptz,
+) error {
+	if !id.Valid {
+		return nil
+	}
+
+	return server.revocations.Revoke(ctx, id.Bytes, expiresAt.Time)
+}
+
+func validateRenewAccessTokenRequest(
+	req *pb.RenewAccessTokenRequest,
+) (violations []*errdetails.BadRequest_FieldViolation) {
+	violations = make([]*errdetails.BadRequest_FieldViolation, 0, 1)
+
+	if len(req.GetRefreshToken()) == 0 {
+		violations = append(violations, fieldViolation("refresh_token", errors.New("is required")))
+	}
+
+	return violations
+}
+
+func validateLogoutUserRequest(
+	req *pb.LogoutUserRequest,
+) (violations []*errdetails.BadRequest_FieldViolation) {
+	violations = make([]*errdetails.BadRequest_FieldViolation, 0, 1)
+
+	if len(req.GetRefreshToken()) == 0 {
+		violations = append(violations, fieldViolation("refresh_token", errors.New("is required")))
+	}
+
+	return violations
+}
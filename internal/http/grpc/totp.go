@@ -0,0 +1,304 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/totp"
+)
+
+const recoveryCodesCount = 10
+
+func (server *Server) EnrollTOTP(
+	ctx context.Context,
+	_ *pb.EnrollTOTPRequest,
+) (res *pb.EnrollTOTPResponse, err error) {
+	var (
+		user db.User
+	)
+
+	authPayload := authPayloadFromContext(ctx)
+
+	if user, err = server.store.GetUser(ctx, authPayload.Username); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to find user: %s",
+			err.Error(),
+		)
+	}
+
+	if user.TotpEnabledAt.Valid {
+		return nil, status.Error(
+			codes.FailedPrecondition,
+			"two-factor authentication is already enabled",
+		)
+	}
+
+	secret := totp.GenerateSecret()
+
+	if _, err = server.store.SetUserTOTPSecret(ctx, db.SetUserTOTPSecretParams{
+		Username:   user.Username,
+		TotpSecret: pgtype.Text{String: secret, Valid: true},
+	}); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to store the TOTP secret: %s",
+			err.Error(),
+		)
+	}
+
+	res = &pb.EnrollTOTPResponse{
+		Secret:     secret,
+		OtpauthUri: totp.URI(config.App.TOTPIssuer, user.Username, secret),
+	}
+
+	return res, nil
+}
+
+func (server *Server) ConfirmTOTP(
+	ctx context.Context,
+	req *pb.ConfirmTOTPRequest,
+) (res *pb.ConfirmTOTPResponse, err error) {
+	var (
+		user db.User
+	)
+
+	authPayload := authPayloadFromContext(ctx)
+
+	if violations := validateTOTPCode(req.GetCode()); len(violations) > 0 {
+		return nil, invalidArgumentError(violations)
+	}
+
+	if user, err = server.store.GetUser(ctx, authPayload.Username); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to find user: %s",
+			err.Error(),
+		)
+	}
+
+	if user.TotpEnabledAt.Valid {
+		return nil, status.Error(
+			codes.FailedPrecondition,
+			"two-factor authentication is already enabled",
+		)
+	}
+
+	if !user.TotpSecret.Valid {
+		return nil, status.Error(
+			codes.FailedPrecondition,
+			"two-factor authentication enrolment not started",
+		)
+	}
+
+	step, ok := totp.Validate(req.GetCode(), user.TotpSecret.String, time.Now())
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid TOTP code")
+	}
+
+	if _, err = server.store.UseUserTOTPCounter(ctx, db.UseUserTOTPCounterParams{
+		Username: user.Username,
+		Counter:  step,
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.InvalidArgument, "invalid TOTP code")
+		}
+
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to use TOTP code: %s",
+			err.Error(),
+		)
+	}
+
+	recoveryCodes := totp.GenerateRecoveryCodes(recoveryCodesCount)
+	hashedRecoveryCodes := make([]string, len(recoveryCodes))
+
+	for i, code := range recoveryCodes {
+		hashedRecoveryCodes[i] = totp.HashRecoveryCode(code)
+	}
+
+	if _, err = server.store.EnableTOTPTx(ctx, db.EnableTOTPTxParams{
+		Username:            user.Username,
+		HashedRecoveryCodes: hashedRecoveryCodes,
+	}); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to enable two-factor authentication: %s",
+			err.Error(),
+		)
+	}
+
+	res = &pb.ConfirmTOTPResponse{
+		RecoveryCodes: recoveryCodes,
+	}
+
+	return res, nil
+}
+
+func (server *Server) LoginUserTOTP(
+	ctx context.Context,
+	req *pb.LoginUserTOTPRequest,
+) (res *pb.LoginUserResponse, err error) {
+	var (
+		challenge db.MfaChallenge
+		user      db.User
+		ok        bool
+	)
+
+	if violations := validateLoginUserTOTPRequest(req); len(violations) > 0 {
+		return nil, invalidArgumentError(violations)
+	}
+
+	defer func() {
+		server.recordAudit(ctx, db.AppendAuditEventTxParams{
+			Actor:    challenge.Username,
+			Action:   audit.ActionLogin,
+			Resource: "users/" + challenge.Username,
+			Detail:   "totp",
+		}, err)
+	}()
+
+	if challenge, err = server.store.ConsumeMFAChallenge(
+		ctx,
+		totp.HashChallenge(req.GetChallengeToken()),
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired challenge")
+		}
+
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to consume challenge: %s",
+			err.Error(),
+		)
+	}
+
+	if user, err = server.store.GetUser(ctx, challenge.Username); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to find user: %s",
+			err.Error(),
+		)
+	}
+
+	if ok, err = server.verifySecondFactor(ctx, user, req.GetCode()); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to verify the second factor: %s",
+			err.Error(),
+		)
+	} else if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid TOTP code")
+	}
+
+	return server.createUserSession(ctx, user)
+}
+
+func (server *Server) createMFAChallenge(
+	ctx context.Context,
+	user db.User,
+) (res *pb.LoginUserResponse, err error) {
+	var challenge db.MfaChallenge
+
+	challengeToken, hashedChallengeToken := totp.NewChallenge()
+
+	if challenge, err = server.store.CreateMFAChallenge(ctx, db.CreateMFAChallengeParams{
+		HashedToken: hashedChallengeToken,
+		Username:    user.Username,
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  time.Now().Add(config.App.MFAChallengeDuration),
+			Valid: true,
+		},
+	}); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to create challenge: %s",
+			err.Error(),
+		)
+	}
+
+	res = &pb.LoginUserResponse{
+		MfaRequired:        true,
+		ChallengeToken:     challengeToken,
+		ChallengeExpiresAt: timestamppb.New(challenge.ExpiresAt.Time),
+	}
+
+	return res, nil
+}
+
+func (server *Server) verifySecondFactor(
+	ctx context.Context,
+	user db.User,
+	code string,
+) (bool, error) {
+	if !user.TotpEnabledAt.Valid {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(code, user.TotpSecret.String, time.Now()); ok {
+		// Each time step is accepted once, so an intercepted code can't be
+		// replayed while it's still valid.
+		if _, err := server.store.UseUserTOTPCounter(ctx, db.UseUserTOTPCounterParams{
+			Username: user.Username,
+			Counter:  step,
+		}); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if _, err := server.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+		Username:   user.Username,
+		HashedCode: totp.HashRecoveryCode(code),
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func validateTOTPCode(code string) (violations []*errdetails.BadRequest_FieldViolation) {
+	violations = make([]*errdetails.BadRequest_FieldViolation, 0, 1)
+
+	if len(code) == 0 {
+		violations = append(violations, fieldViolation("code", errors.New("is required")))
+	}
+
+	return violations
+}
+
+func validateLoginUserTOTPRequest(
+	req *pb.LoginUserTOTPRequest,
+) (violations []*errdetails.BadRequest_FieldViolation) {
+	violations = make([]*errdetails.BadRequest_FieldViolation, 0, 2)
+
+	if len(req.GetChallengeToken()) == 0 {
+		violations = append(violations, fieldViolation("challenge_token", errors.New("is required")))
+	}
+
+	violations = append(violations, validateTOTPCode(req.GetCode())...)
+
+	return violations
+}
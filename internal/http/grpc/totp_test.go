@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+	"github.com/dharmavagabond/simple-bank/internal/totp"
+)
+
+func TestConfirmTOTP(t *testing.T) {
+	user := db.User{
+		Username:   "alice",
+		TotpSecret: pgtype.Text{String: totp.GenerateSecret(), Valid: true},
+	}
+	code, err := totp.GenerateCode(user.TotpSecret.String, time.Now())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mocks.Store)
+		code       codes.Code
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					UseUserTOTPCounter(
+						mock.Anything,
+						mock.MatchedBy(func(arg db.UseUserTOTPCounterParams) bool {
+							return arg.Username == user.Username && arg.Counter > 0
+						}),
+					).
+					Once().
+					Return(user, nil)
+				store.
+					EXPECT().
+					EnableTOTPTx(mock.Anything, mock.Anything).
+					Once().
+					Return(db.EnableTOTPTxResult{}, nil)
+			},
+			code: codes.OK,
+		},
+		{
+			// The code that confirmed enrolment can't log in afterwards.
+			name: "Replayed",
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					UseUserTOTPCounter(mock.Anything, mock.Anything).
+					Once().
+					Return(db.User{}, pgx.ErrNoRows)
+			},
+			code: codes.InvalidArgument,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			store.
+				EXPECT().
+				GetUser(mock.Anything, user.Username).
+				Once().
+				Return(user, nil)
+			tc.buildStubs(store)
+			server, err := NewServer(store, nil)
+			require.NoError(t, err)
+
+			ctx := context.WithValue(
+				context.Background(),
+				authPayloadKey{},
+				&token.Payload{Username: user.Username, Role: authz.RoleDepositor},
+			)
+			_, err = server.ConfirmTOTP(ctx, &pb.ConfirmTOTPRequest{Code: code})
+			require.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
@@ -4,35 +4,32 @@ import (
 	"context"
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"time"
 
-	"github.com/alexedwards/argon2id"
 	"github.com/hibiken/asynq"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
 	"github.com/dharmavagabond/simple-bank/internal/config"
 	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/lockout"
+	"github.com/dharmavagabond/simple-bank/internal/password"
 	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
 	"github.com/dharmavagabond/simple-bank/internal/token"
 	"github.com/dharmavagabond/simple-bank/internal/valid"
 	"github.com/dharmavagabond/simple-bank/internal/worker"
 )
 
-var argonParams = &argon2id.Params{
-	Memory:      128 * 1024,
-	Iterations:  4,
-	Parallelism: 4,
-	SaltLength:  128,
-	KeyLength:   128,
-}
-
 func (server *Server) CreateUser(
 	ctx context.Context,
 	req *pb.CreateUserRequest,
@@ -42,11 +39,19 @@ func (server *Server) CreateUser(
 		hashPassword string
 	)
 
-	if violations := validateCreateUserRequest(req); len(violations) > 0 {
+	if violations := validateCreateUserRequest(req, server.passwordPolicy); len(violations) > 0 {
 		return nil, invalidArgumentError(violations)
 	}
 
-	if hashPassword, err = argon2id.CreateHash(req.GetPassword(), argonParams); err != nil {
+	defer func() {
+		server.recordAudit(ctx, db.AppendAuditEventTxParams{
+			Actor:    req.GetUsername(),
+			Action:   audit.ActionCreateUser,
+			Resource: "users/" + req.GetUsername(),
+		}, err)
+	}()
+
+	if hashPassword, err = server.passwordHasher.Hash(req.GetPassword()); err != nil {
 		return nil, status.Errorf(
 			codes.Internal,
 			"failed to hash the password: %s",
@@ -61,20 +66,17 @@ func (server *Server) CreateUser(
 			FullName:       req.GetFullName(),
 			Email:          req.GetEmail(),
 		},
-		AfterCreate: func(user db.User) error {
+		AfterCreate: func(q db.Querier, user db.User) error {
 			taskPayload := &worker.PayloadSendVerifyEmail{
 				Username: user.Username,
 			}
-			opts := []asynq.Option{
-				asynq.MaxRetry(10),
-				asynq.ProcessIn(10 * time.Second),
-				asynq.Queue(worker.QueueCritical),
-			}
 
-			return server.taskDistributor.DistributeTaskSendVerifyEmail(
+			return worker.SendVerifyEmail.Enqueue(
 				ctx,
+				server.outboxDistributor(q),
 				taskPayload,
-				opts...)
+				asynq.ProcessIn(10*time.Second),
+			)
 		},
 	}
 
@@ -110,62 +112,179 @@ func (server *Server) LoginUser(
 	req *pb.LoginUserRequest,
 ) (res *pb.LoginUserResponse, err error) {
 	var (
-		user                db.User
-		ok                  bool
-		session             db.Session
-		accessToken         string
-		accessTokenPayload  *token.Payload
-		refreshToken        string
-		refreshTokenPayload *token.Payload
-		sessionID           driver.Value
+		user       db.User
+		ok         bool
+		rehash     bool
+		retryAfter time.Duration
 	)
 
 	if violations := validateLoginUserRequest(req); len(violations) > 0 {
 		return nil, invalidArgumentError(violations)
 	}
 
-	if user, err = server.store.GetUser(ctx, req.GetUsername()); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, status.Error(codes.NotFound, "user not found")
+	defer func() {
+		server.recordAudit(ctx, db.AppendAuditEventTxParams{
+			Actor:    req.GetUsername(),
+			Action:   audit.ActionLogin,
+			Resource: "users/" + req.GetUsername(),
+			Detail:   "password",
+		}, err)
+	}()
+
+	clientIP := clientHost(server.extractMetadata(ctx).ClientIP)
+
+	if retryAfter, err = server.loginGuard.Check(ctx, req.GetUsername(), clientIP); err != nil {
+		if errors.Is(err, lockout.ERR_LOCKED) || errors.Is(err, lockout.ERR_TOO_MANY_ATTEMPTS) {
+			return nil, status.Errorf(
+				codes.ResourceExhausted,
+				"too many failed login attempts, retry in %s",
+				retryAfter.Round(time.Second),
+			)
 		}
 
 		return nil, status.Errorf(
 			codes.Internal,
-			"failed to find user: %s",
+			"failed to check login attempts: %s",
 			err.Error(),
 		)
 	}
 
-	if ok, err = argon2id.ComparePasswordAndHash(req.Password, user.HashedPassword); err != nil {
+	if user, err = server.store.GetUser(ctx, req.GetUsername()); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Errorf(
+				codes.Internal,
+				"failed to find user: %s",
+				err.Error(),
+			)
+		}
+
+		server.passwordHasher.VerifyDummy(req.GetPassword())
+
+		return nil, server.failLogin(ctx, req.GetUsername(), clientIP, false)
+	}
+
+	if ok, rehash, err = server.passwordHasher.Verify(req.GetPassword(), user.HashedPassword); err != nil {
 		return nil, status.Errorf(
 			codes.Internal,
 			"couldn't compare the password: %s",
 			err.Error(),
 		)
 	} else if !ok {
-		return nil, status.Error(codes.Internal, "incorrect password")
+		return nil, server.failLogin(ctx, user.Username, clientIP, true)
+	}
+
+	if rehash {
+		server.rehashPassword(ctx, user, req.GetPassword())
+	}
+
+	if err = server.loginGuard.Succeed(ctx, user.Username); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to reset login attempts: %s",
+			err.Error(),
+		)
+	}
+
+	if user.TotpEnabledAt.Valid {
+		return server.createMFAChallenge(ctx, user)
+	}
+
+	return server.createUserSession(ctx, user)
+}
+
+// rehashPassword upgrades a hash created with older parameters. Failing
+// to do it doesn't fail the login, since the old hash still works, and it's
+// skipped if the password changed in the meantime.
+func (server *Server) rehashPassword(ctx context.Context, user db.User, plain string) {
+	hash, err := server.passwordHasher.Hash(plain)
+
+	if err == nil {
+		err = server.store.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+			NewHashedPassword: hash,
+			Username:          user.Username,
+			HashedPassword:    user.HashedPassword,
+		})
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to rehash the password")
+	}
+}
+
+func (server *Server) failLogin(
+	ctx context.Context,
+	username string,
+	clientIP string,
+	userExists bool,
+) error {
+	locked, err := server.loginGuard.Fail(ctx, username, clientIP)
+	if err != nil {
+		return status.Errorf(
+			codes.Internal,
+			"failed to record login attempt: %s",
+			err.Error(),
+		)
+	}
+
+	if locked && userExists {
+		if err = worker.SendLockoutEmail.Enqueue(
+			ctx,
+			server.taskDistributor,
+			&worker.PayloadSendLockoutEmail{
+				Username:    username,
+				ClientIP:    clientIP,
+				LockedUntil: time.Now().Add(config.App.LoginLockoutDuration),
+			},
+		); err != nil {
+			log.Error().Err(err).Str("username", username).Msg("failed to notify the lockout")
+		}
 	}
 
-	if accessToken, accessTokenPayload, err = server.tokenMaker.CreateToken(user.Username, config.App.AccessTokenDuration); err != nil {
+	return status.Error(codes.Unauthenticated, "invalid username or password")
+}
+
+func (server *Server) createUserSession(
+	ctx context.Context,
+	user db.User,
+) (res *pb.LoginUserResponse, err error) {
+	var (
+		session             db.Session
+		accessToken         string
+		accessTokenPayload  *token.Payload
+		refreshToken        string
+		refreshTokenPayload *token.Payload
+		sessionID           driver.Value
+	)
+
+	if accessToken, accessTokenPayload, err = server.tokenMaker.CreateToken(user.Username, user.Role, config.App.AccessTokenDuration); err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	if refreshToken, refreshTokenPayload, err = server.tokenMaker.CreateToken(
-		req.Username,
+	if refreshToken, refreshTokenPayload, err = server.tokenMaker.CreateRefreshToken(
+		user.Username,
+		user.Role,
+		nil,
 		config.App.RefreshTokenDuration,
 	); err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	mtdt := server.extractMetadata(ctx)
+	id := pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true}
 
 	if session, err = server.store.CreateSession(ctx, db.CreateSessionParams{
-		ID:           pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true},
-		Username:     req.Username,
-		RefreshToken: refreshToken,
-		UserAgent:    mtdt.UserAgent,
-		ClientIp:     mtdt.ClientIP,
-		ExpiresAt:    pgtype.Timestamptz{Time: refreshTokenPayload.ExpiredAt, Valid: true},
+		ID:            id,
+		FamilyID:      id,
+		Username:      user.Username,
+		RefreshToken:  refreshToken,
+		UserAgent:     mtdt.UserAgent,
+		ClientIp:      clientHost(mtdt.ClientIP),
+		ExpiresAt:     pgtype.Timestamptz{Time: refreshTokenPayload.ExpiredAt, Valid: true},
+		AccessTokenID: pgtype.UUID{Bytes: accessTokenPayload.ID, Valid: true},
+		AccessTokenExpiresAt: pgtype.Timestamptz{
+			Time:  accessTokenPayload.ExpiredAt,
+			Valid: true,
+		},
 	}); err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -174,6 +293,8 @@ func (server *Server) LoginUser(
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	server.notifyNewDevice(ctx, session)
+
 	res = &pb.LoginUserResponse{
 		SessionId:             sessionID.(string),
 		AccessToken:           accessToken,
@@ -191,29 +312,66 @@ func (server *Server) UpdateUser(
 	req *pb.UpdateUserRequest,
 ) (res *pb.UpdateUserResponse, err error) {
 	var (
-		authPayload      *token.Payload
 		user             db.User
+		txResult         db.UpdateUserTxResult
 		hashPassword     string
 		isPasswordHashed bool
 	)
 
-	if authPayload, err = server.authorizeUser(ctx); err != nil {
-		return nil, unauthenticatedError(err)
-	}
+	authPayload := authPayloadFromContext(ctx)
 
 	if violations := validateUpdateUserRequest(req); len(violations) > 0 {
 		return nil, invalidArgumentError(violations)
 	}
 
-	if authPayload.Username != req.GetUsername() {
+	defer func() {
+		server.recordAudit(ctx, db.AppendAuditEventTxParams{
+			Actor:    authPayload.Username,
+			Action:   audit.ActionUpdateUser,
+			Resource: "users/" + req.GetUsername(),
+		}, err)
+	}()
+
+	if err = authz.Authorize(authPayload, authz.ActionWriteUser, req.GetUsername()); err != nil {
 		return nil, status.Errorf(
 			codes.PermissionDenied,
-			"cannot update other user's info",
+			"cannot update other user's info: %s",
+			err.Error(),
 		)
 	}
 
+	if req.Role != nil {
+		if err = authz.Authorize(authPayload, authz.ActionManageUsers, req.GetUsername()); err != nil {
+			return nil, status.Errorf(
+				codes.PermissionDenied,
+				"cannot change the user's role: %s",
+				err.Error(),
+			)
+		}
+	}
+
 	if len(req.GetPassword()) > 0 {
-		if hashPassword, err = argon2id.CreateHash(req.GetPassword(), argonParams); err != nil {
+		if user, err = server.store.GetUser(ctx, req.GetUsername()); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, status.Errorf(
+					codes.NotFound,
+					"user not Found: %s",
+					err.Error(),
+				)
+			}
+
+			return nil, status.Errorf(
+				codes.Internal,
+				"failed to get user: %s",
+				err.Error(),
+			)
+		}
+
+		if err = server.checkNewPassword(ctx, req, user); err != nil {
+			return nil, err
+		}
+
+		if hashPassword, err = server.passwordHasher.Hash(req.GetPassword()); err != nil {
 			return nil, status.Errorf(
 				codes.Internal,
 				"failed to hash the password: %s",
@@ -238,13 +396,21 @@ func (server *Server) UpdateUser(
 			String: req.GetEmail(),
 			Valid:  len(req.GetEmail()) > 0,
 		},
+		Role: pgtype.Text{
+			String: req.GetRole(),
+			Valid:  req.Role != nil,
+		},
 		PasswordChangedAt: pgtype.Timestamptz{
 			Time:  time.Now(),
 			Valid: isPasswordHashed,
 		},
 	}
 
-	if user, err = server.store.UpdateUser(ctx, arg); err != nil {
+	if txResult, err = server.store.UpdateUserTx(ctx, db.UpdateUserTxParams{
+		UpdateUserParams:       arg,
+		PreviousHashedPassword: user.HashedPassword,
+		HistorySize:            int32(server.passwordPolicy.HistorySize() - 1),
+	}); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, status.Errorf(
 				codes.NotFound,
@@ -261,14 +427,74 @@ func (server *Server) UpdateUser(
 	}
 
 	res = &pb.UpdateUserResponse{
-		User: convertUser(user),
+		User: convertUser(txResult.User),
 	}
 
 	return res, nil
 }
 
+// checkNewPassword applies the password policy to an update, comparing the
+// password with the new email when it also changes, and with the current
+// password and the previous ones kept in the history.
+func (server *Server) checkNewPassword(
+	ctx context.Context,
+	req *pb.UpdateUserRequest,
+	user db.User,
+) (err error) {
+	var hashes []string
+
+	email := user.Email
+
+	if len(req.GetEmail()) > 0 {
+		email = req.GetEmail()
+	}
+
+	if err = server.passwordPolicy.Validate(req.GetPassword(), user.Username, email); err != nil {
+		return invalidArgumentError([]*errdetails.BadRequest_FieldViolation{
+			fieldViolation("password", err),
+		})
+	}
+
+	historySize := server.passwordPolicy.HistorySize()
+
+	if historySize == 0 {
+		return nil
+	}
+
+	if hashes, err = server.store.ListPasswordHistory(ctx, db.ListPasswordHistoryParams{
+		Username: user.Username,
+		Limit:    int32(historySize - 1),
+	}); err != nil {
+		return status.Errorf(
+			codes.Internal,
+			"failed to get password history: %s",
+			err.Error(),
+		)
+	}
+
+	if err = server.passwordPolicy.CheckHistory(
+		req.GetPassword(),
+		append([]string{user.HashedPassword}, hashes...),
+	); err != nil {
+		if errors.Is(err, password.ERR_PASSWORD_REUSED) {
+			return invalidArgumentError([]*errdetails.BadRequest_FieldViolation{
+				fieldViolation("password", fmt.Errorf("must differ from the last %d passwords", historySize)),
+			})
+		}
+
+		return status.Errorf(
+			codes.Internal,
+			"failed to check password history: %s",
+			err.Error(),
+		)
+	}
+
+	return nil
+}
+
 func validateCreateUserRequest(
 	req *pb.CreateUserRequest,
+	policy *password.Policy,
 ) (violations []*errdetails.BadRequest_FieldViolation) {
 	violations = make([]*errdetails.BadRequest_FieldViolation, 0, 4)
 
@@ -276,7 +502,7 @@ func validateCreateUserRequest(
 		violations = append(violations, fieldViolation("username", err))
 	}
 
-	if err := valid.ValidatePassword(req.GetPassword()); err != nil {
+	if err := policy.Validate(req.GetPassword(), req.GetUsername(), req.GetEmail()); err != nil {
 		violations = append(violations, fieldViolation("password", err))
 	}
 
@@ -300,7 +526,7 @@ func validateLoginUserRequest(
 		violations = append(violations, fieldViolation("username", err))
 	}
 
-	if err := valid.ValidatePassword(req.GetPassword()); err != nil {
+	if err := valid.ValidateLoginPassword(req.GetPassword()); err != nil {
 		violations = append(violations, fieldViolation("password", err))
 	}
 
@@ -316,12 +542,6 @@ func validateUpdateUserRequest(
 		violations = append(violations, fieldViolation("username", err))
 	}
 
-	if len(req.GetPassword()) > 0 {
-		if err := valid.ValidatePassword(req.GetPassword()); err != nil {
-			violations = append(violations, fieldViolation("password", err))
-		}
-	}
-
 	if len(req.GetFullName()) > 0 {
 		if err := valid.ValidateFullname(req.GetFullName()); err != nil {
 			violations = append(violations, fieldViolation("full_name", err))
@@ -334,5 +554,9 @@ func validateUpdateUserRequest(
 		}
 	}
 
+	if req.Role != nil && !authz.IsValidRole(req.GetRole()) {
+		violations = append(violations, fieldViolation("role", errors.New("is not a valid role")))
+	}
+
 	return violations
 }
@@ -0,0 +1,81 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	pb "github.com/dharmavagabond/simple-bank/internal/pb/user/v1"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
+)
+
+// txStore runs the AfterCreate hook of CreateUserTx, as the SQL store does
+// in its transaction.
+type txStore struct {
+	*mocks.Store
+}
+
+func (store txStore) CreateUserTx(ctx context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+	result, err := store.Store.CreateUserTx(ctx, arg)
+	if err != nil {
+		return result, err
+	}
+
+	return result, arg.AfterCreate(store, result.User)
+}
+
+func TestCreateUserSendsVerifyEmail(t *testing.T) {
+	store := mocks.NewStore(t)
+	distributor := worker.NewMemoryTaskDistributor()
+	user := db.User{Username: "alice", FullName: "Alice", Email: "alice@example.com"}
+
+	server, err := NewServer(txStore{store}, distributor)
+	require.NoError(t, err)
+
+	server.outboxDistributor = distributor.OutboxDistributor
+
+	store.
+		EXPECT().
+		CreateUserTx(
+			mock.Anything,
+			mock.MatchedBy(func(arg db.CreateUserTxParams) bool {
+				return arg.Username == user.Username && arg.Email == user.Email
+			}),
+		).
+		Once().
+		Return(db.CreateUserTxResult{User: user}, nil)
+	store.
+		EXPECT().
+		AppendAuditEventTx(mock.Anything, mock.Anything).
+		Maybe().
+		Return(db.AppendAuditEventTxResult{}, nil)
+	store.
+		EXPECT().
+		GetUser(mock.Anything, user.Username).
+		Once().
+		Return(user, nil)
+
+	res, err := server.CreateUser(context.TODO(), &pb.CreateUserRequest{
+		Username: user.Username,
+		FullName: user.FullName,
+		Email:    user.Email,
+		Password: "Correct-Horse-9",
+	})
+	require.NoError(t, err)
+	require.Equal(t, user.Username, res.GetUser().GetUsername())
+
+	tasks := distributor.Tasks(worker.TaskSendVerifyEmail)
+	require.Len(t, tasks, 1)
+	require.Equal(t, worker.QueueCritical, tasks[0].Queue)
+	require.Equal(t, 10, tasks[0].MaxRetry)
+	require.Equal(t, 10*time.Second, tasks[0].Delay)
+	require.JSONEq(t, `{"username":"alice"}`, string(tasks[0].Payload))
+
+	require.NoError(t, worker.NewMemoryTaskProcessor(store, distributor).Start())
+	require.Equal(t, worker.TaskStateCompleted, distributor.Tasks()[0].State)
+}
@@ -2,8 +2,11 @@ package rest
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
 	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
 	"github.com/dharmavagabond/simple-bank/internal/token"
 	"github.com/jackc/pgerrcode"
@@ -22,13 +25,14 @@ type (
 	}
 
 	listAccountRequest struct {
-		PageID   int32 `query:"page_id" validate:"required,min=1"`
-		PageSize int32 `query:"page_size" validate:"required,min=5,max=10"`
+		PageID   int32  `query:"page_id"   validate:"required,min=1"`
+		PageSize int32  `query:"page_size" validate:"required,min=5,max=10"`
+		Owner    string `query:"owner"     validate:"omitempty,alphanum"`
 	}
 )
 
 func (server *Server) createAccount(ectx echo.Context) (err error) {
-	var account db.Account
+	var txResult db.CreateAccountTxResult
 	req := &createAccountRequest{}
 
 	if err = ectx.Bind(req); err != nil {
@@ -40,12 +44,22 @@ func (server *Server) createAccount(ectx echo.Context) (err error) {
 	}
 
 	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    authPayload.Username,
+			Action:   audit.ActionCreateAccount,
+			Resource: fmt.Sprintf("accounts/%d", txResult.Account.ID),
+			Detail:   req.Currency,
+		}, err)
+	}()
+
 	arg := db.CreateAccountParams{
 		Owner:    authPayload.Username,
 		Currency: req.Currency,
 	}
 
-	if account, err = server.store.CreateAccount(ectx.Request().Context(), arg); err != nil {
+	if txResult, err = server.store.CreateAccountTx(ectx.Request().Context(), arg); err != nil {
 		var pgErr *pgconn.PgError
 
 		if errors.As(err, &pgErr) {
@@ -58,7 +72,7 @@ func (server *Server) createAccount(ectx echo.Context) (err error) {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	return ectx.JSON(http.StatusOK, account)
+	return ectx.JSON(http.StatusOK, txResult.Account)
 }
 
 func (server *Server) getAccount(ectx echo.Context) (err error) {
@@ -81,13 +95,8 @@ func (server *Server) getAccount(ectx echo.Context) (err error) {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
-
-	if account.Owner != authPayload.Username {
-		return echo.NewHTTPError(
-			http.StatusUnauthorized,
-			errors.New("the account doesn't belong to the authenticated user"),
-		)
+	if err = authorizeOwner(ectx, authz.ActionReadAccount, account.Owner); err != nil {
+		return err
 	}
 
 	return ectx.JSON(http.StatusOK, account)
@@ -109,8 +118,18 @@ func (server *Server) listAccounts(ectx echo.Context) (err error) {
 	}
 
 	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+	owner := authPayload.Username
+
+	if len(req.Owner) > 0 {
+		owner = req.Owner
+	}
+
+	if err = authorizeOwner(ectx, authz.ActionReadAccount, owner); err != nil {
+		return err
+	}
+
 	arg := db.ListAccountsParams{
-		Owner:  authPayload.Username,
+		Owner:  owner,
 		Limit:  req.PageSize,
 		Offset: (req.PageID - 1) * req.PageSize,
 	}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/Pallinder/go-randomdata"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
 	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
 	"github.com/dharmavagabond/simple-bank/internal/mocks"
 	"github.com/dharmavagabond/simple-bank/internal/token"
@@ -55,6 +56,7 @@ func TestGetAccountAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					account.Owner,
+					authz.RoleDepositor,
 					time.Minute,
 				)
 			},
@@ -82,6 +84,7 @@ func TestGetAccountAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					"unauthorized_user",
+					authz.RoleDepositor,
 					time.Minute,
 				)
 			},
@@ -94,7 +97,35 @@ func TestGetAccountAPI(t *testing.T) {
 			},
 			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
 				t.Helper()
-				require.Equal(t, http.StatusUnauthorized, rec.Code)
+				require.Equal(t, http.StatusForbidden, rec.Code)
+			},
+		},
+		{
+			name:      "BankerViewsAnyAccount",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				t.Helper()
+				addAuthorization(
+					t,
+					req,
+					tokenMaker,
+					AUTH_TYPE_BEARER,
+					"banker_user",
+					authz.RoleBanker,
+					time.Minute,
+				)
+			},
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetAccount(mock.AnythingOfType("context.todoCtx"), mock.IsType(account.ID)).
+					Once().
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				requireBodyMatchAccount(t, rec.Body, account)
+				require.Equal(t, http.StatusOK, rec.Code)
 			},
 		},
 		{
@@ -124,6 +155,7 @@ func TestGetAccountAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					user.Username,
+					authz.RoleDepositor,
 					time.Minute,
 				)
 			},
@@ -150,6 +182,7 @@ func TestGetAccountAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					user.Username,
+					authz.RoleDepositor,
 					time.Minute,
 				)
 			},
@@ -176,6 +209,7 @@ func TestGetAccountAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					user.Username,
+					authz.RoleDepositor,
 					time.Minute,
 				)
 			},
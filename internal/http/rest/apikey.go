@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	"github.com/dharmavagabond/simple-bank/internal/apikey"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+type (
+	createAPIKeyRequest struct {
+		ExpiresAt time.Time `json:"expires_at" validate:"required"`
+		Name      string    `json:"name"       validate:"required,max=64"`
+		Scopes    []string  `json:"scopes"     validate:"required,min=1,dive,required"`
+	}
+	createAPIKeyResponse struct {
+		ExpiresAt time.Time `json:"expires_at"`
+		CreatedAt time.Time `json:"created_at"`
+		Name      string    `json:"name"`
+		Key       string    `json:"key"`
+		Scopes    []string  `json:"scopes"`
+		ID        uuid.UUID `json:"id"`
+	}
+	revokeAPIKeyRequest struct {
+		ID uuid.UUID `param:"id" validate:"required"`
+	}
+)
+
+func (server *Server) createAPIKey(ectx echo.Context) (err error) {
+	var (
+		apiKey       db.ApiKey
+		id           uuid.UUID
+		key          string
+		hashedSecret string
+		req          = &createAPIKeyRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	if err = authz.ValidateScopes(authPayload.Role, req.Scopes); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	lifetime := time.Until(req.ExpiresAt)
+
+	if lifetime <= 0 || lifetime > config.App.APIKeyMaxLifetime {
+		return echo.NewHTTPError(
+			http.StatusBadRequest,
+			"expires_at must be in the future and within the maximum API key lifetime",
+		)
+	}
+
+	if id, key, hashedSecret, err = apikey.Generate(); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if apiKey, err = server.store.CreateApiKey(ectx.Request().Context(), db.CreateApiKeyParams{
+		ID:           pgtype.UUID{Bytes: id, Valid: true},
+		Owner:        authPayload.Username,
+		Name:         req.Name,
+		HashedSecret: hashedSecret,
+		Scopes:       req.Scopes,
+		ExpiresAt:    pgtype.Timestamptz{Time: req.ExpiresAt, Valid: true},
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := createAPIKeyResponse{
+		ID:        id,
+		Name:      apiKey.Name,
+		Key:       key,
+		Scopes:    apiKey.Scopes,
+		ExpiresAt: apiKey.ExpiresAt.Time,
+		CreatedAt: apiKey.CreatedAt.Time,
+	}
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+func (server *Server) revokeAPIKey(ectx echo.Context) (err error) {
+	req := &revokeAPIKeyRequest{}
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	if _, err = server.store.RevokeApiKey(ectx.Request().Context(), db.RevokeApiKeyParams{
+		ID:    pgtype.UUID{Bytes: req.ID, Valid: true},
+		Owner: authPayload.Username,
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
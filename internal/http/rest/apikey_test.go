@@ -0,0 +1,281 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/apikey"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+func addAPIKeyAuthorization(t *testing.T, req *http.Request, store *mocks.Store, owner string, scopes []string) {
+	t.Helper()
+	id, key, hashedSecret, err := apikey.Generate()
+	require.NoError(t, err)
+
+	dbID := pgtype.UUID{Bytes: id, Valid: true}
+	store.
+		EXPECT().
+		GetApiKey(mock.AnythingOfType("context.todoCtx"), dbID).
+		Once().
+		Return(db.GetApiKeyRow{
+			ID:           dbID,
+			Owner:        owner,
+			Role:         authz.RoleDepositor,
+			HashedSecret: hashedSecret,
+			Scopes:       scopes,
+			ExpiresAt:    pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		}, nil)
+	store.
+		EXPECT().
+		TouchApiKey(mock.AnythingOfType("context.todoCtx"), dbID).
+		Once().
+		Return(nil)
+	req.Header.Set(AUTH_HEADER, fmt.Sprintf("%s %s", AUTH_TYPE_API_KEY, key))
+}
+
+func TestCreateAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser()
+	expiresAt := time.Now().Add(24 * time.Hour).UTC()
+	testCases := []struct {
+		name          string
+		body          echo.Map
+		setupAuth     func(t *testing.T, req *http.Request, tokenMaker token.Maker, store *mocks.Store)
+		buildStubs    func(store *mocks.Store)
+		checkResponse func(t *testing.T, rec *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: echo.Map{
+				"name":       "reports",
+				"scopes":     []string{string(authz.ActionReadAccount)},
+				"expires_at": expiresAt,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker, _ *mocks.Store) {
+				t.Helper()
+				addAuthorization(t, req, tokenMaker, AUTH_TYPE_BEARER, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					CreateApiKey(
+						mock.AnythingOfType("context.todoCtx"),
+						mock.MatchedBy(func(arg db.CreateApiKeyParams) bool {
+							return arg.Owner == user.Username &&
+								arg.Name == "reports" &&
+								len(arg.HashedSecret) > 0 &&
+								arg.ExpiresAt.Time.Equal(expiresAt)
+						}),
+					).
+					Once().
+					Return(db.ApiKey{
+						Owner:     user.Username,
+						Name:      "reports",
+						Scopes:    []string{string(authz.ActionReadAccount)},
+						ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusOK, rec.Code)
+
+				data, err := io.ReadAll(rec.Body)
+				require.NoError(t, err)
+
+				var res createAPIKeyResponse
+				require.NoError(t, json.Unmarshal(data, &res))
+
+				id, _, err := apikey.Parse(res.Key)
+				require.NoError(t, err)
+				require.Equal(t, res.ID, id)
+			},
+		},
+		{
+			name: "ScopeNotAllowedForRole",
+			body: echo.Map{
+				"name":       "admin",
+				"scopes":     []string{string(authz.ActionManageUsers)},
+				"expires_at": expiresAt,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker, _ *mocks.Store) {
+				t.Helper()
+				addAuthorization(t, req, tokenMaker, AUTH_TYPE_BEARER, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(_ *mocks.Store) {},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			name: "TooLongLived",
+			body: echo.Map{
+				"name":       "forever",
+				"scopes":     []string{string(authz.ActionReadAccount)},
+				"expires_at": time.Now().Add(100 * 365 * 24 * time.Hour),
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker, _ *mocks.Store) {
+				t.Helper()
+				addAuthorization(t, req, tokenMaker, AUTH_TYPE_BEARER, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(_ *mocks.Store) {},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			name: "APIKeyCaller",
+			body: echo.Map{
+				"name":       "nested",
+				"scopes":     []string{string(authz.ActionReadAccount)},
+				"expires_at": expiresAt,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, _ token.Maker, store *mocks.Store) {
+				t.Helper()
+				addAPIKeyAuthorization(t, req, store, user.Username, []string{string(authz.ActionReadAccount)})
+			},
+			buildStubs: func(_ *mocks.Store) {},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusForbidden, rec.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			tc.buildStubs(store)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			req, err := http.NewRequestWithContext(
+				context.TODO(),
+				http.MethodPost,
+				"/api-keys",
+				bytes.NewReader(data),
+			)
+			require.NoError(t, err)
+			req.Header.Add("Content-Type", "application/json")
+			tc.setupAuth(t, req, server.tokenMaker, store)
+			server.router.ServeHTTP(rec, req)
+			tc.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	user, _ := randomUser()
+	account := createRandomAccount(user.Username)
+	testCases := []struct {
+		name         string
+		scopes       []string
+		buildStubs   func(store *mocks.Store)
+		expectedCode int
+	}{
+		{
+			name:   "Granted",
+			scopes: []string{string(authz.ActionReadAccount)},
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetAccount(mock.AnythingOfType("context.todoCtx"), account.ID).
+					Once().
+					Return(account, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "NotGranted",
+			scopes:       []string{string(authz.ActionCreateTransfer)},
+			buildStubs:   func(_ *mocks.Store) {},
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			tc.buildStubs(store)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(
+				context.TODO(),
+				http.MethodGet,
+				fmt.Sprintf("/accounts/%d", account.ID),
+				nil,
+			)
+			require.NoError(t, err)
+			addAPIKeyAuthorization(t, req, store, user.Username, tc.scopes)
+			server.router.ServeHTTP(rec, req)
+			require.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestRevokeAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser()
+	id := uuid.New()
+	testCases := []struct {
+		name         string
+		err          error
+		expectedCode int
+	}{
+		{name: "OK", expectedCode: http.StatusNoContent},
+		{name: "NotFound", err: pgx.ErrNoRows, expectedCode: http.StatusNotFound},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			store.
+				EXPECT().
+				RevokeApiKey(mock.AnythingOfType("context.todoCtx"), db.RevokeApiKeyParams{
+					ID:    pgtype.UUID{Bytes: id, Valid: true},
+					Owner: user.Username,
+				}).
+				Once().
+				Return(db.ApiKey{}, tc.err)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(
+				context.TODO(),
+				http.MethodDelete,
+				"/api-keys/"+id.String(),
+				nil,
+			)
+			require.NoError(t, err)
+			addAuthorization(t, req, server.tokenMaker, AUTH_TYPE_BEARER, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(rec, req)
+			require.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+const auditChainBatchSize = 500
+
+type (
+	listAuditEventsRequest struct {
+		From     time.Time `query:"from"`
+		To       time.Time `query:"to"`
+		Actor    string    `query:"actor"`
+		Action   string    `query:"action"`
+		PageID   int32     `query:"page_id"   validate:"required,min=1"`
+		PageSize int32     `query:"page_size" validate:"required,min=5,max=100"`
+	}
+	verifyAuditEventsResponse struct {
+		Error    string `json:"error,omitempty"`
+		Verified int    `json:"verified"`
+		Valid    bool   `json:"valid"`
+	}
+)
+
+func (server *Server) listAuditEvents(ectx echo.Context) (err error) {
+	var (
+		events []db.AuditEvent
+		req    = &listAuditEventsRequest{
+			PageID:   1,
+			PageSize: 20,
+		}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	if events, err = server.store.ListAuditEvents(ectx.Request().Context(), db.ListAuditEventsParams{
+		Actor:       pgtype.Text{String: req.Actor, Valid: len(req.Actor) > 0},
+		Action:      pgtype.Text{String: req.Action, Valid: len(req.Action) > 0},
+		CreatedFrom: pgtype.Timestamptz{Time: req.From, Valid: !req.From.IsZero()},
+		CreatedTo:   pgtype.Timestamptz{Time: req.To, Valid: !req.To.IsZero()},
+		Limit:       req.PageSize,
+		Offset:      (req.PageID - 1) * req.PageSize,
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.JSON(http.StatusOK, events)
+}
+
+// verifyAuditEvents walks the whole hash chain, so it's meant for periodic
+// compliance checks rather than frequent polling.
+func (server *Server) verifyAuditEvents(ectx echo.Context) (err error) {
+	var (
+		events   []db.AuditEvent
+		prevHash []byte
+		lastID   int64
+		res      verifyAuditEventsResponse
+	)
+
+	ctx := ectx.Request().Context()
+
+	for {
+		if events, err = server.store.ListAuditEventChain(ctx, db.ListAuditEventChainParams{
+			ID:    lastID,
+			Limit: auditChainBatchSize,
+		}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		if err = audit.Verify(prevHash, events); err != nil {
+			if !errors.Is(err, audit.ERR_BROKEN_CHAIN) {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+
+			res.Error = err.Error()
+
+			return ectx.JSON(http.StatusOK, res)
+		}
+
+		res.Verified += len(events)
+
+		if len(events) < auditChainBatchSize {
+			break
+		}
+
+		lastID = events[len(events)-1].ID
+		prevHash = events[len(events)-1].Hash
+	}
+
+	res.Valid = true
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+// recordAudit records the outcome of a handler from the error it returned.
+func (server *Server) recordAudit(ectx echo.Context, event db.AppendAuditEventTxParams, err error) {
+	event.Outcome = audit.OutcomeSuccess
+	event.ClientIp = ectx.RealIP()
+	event.UserAgent = ectx.Request().UserAgent()
+
+	if err != nil {
+		var httpErr *echo.HTTPError
+
+		code := http.StatusInternalServerError
+
+		if errors.As(err, &httpErr) {
+			code = httpErr.Code
+		}
+
+		event.Outcome = audit.OutcomeFailure
+
+		if code == http.StatusUnauthorized || code == http.StatusForbidden {
+			event.Outcome = audit.OutcomeDenied
+		}
+
+		if len(event.Detail) > 0 {
+			event.Detail += ": "
+		}
+
+		event.Detail += http.StatusText(code)
+	}
+
+	audit.Record(ectx.Request().Context(), server.store, event)
+}
@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/lockout"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+)
+
+// expectAuditEvents accepts the audit events recorded by handlers under test.
+func expectAuditEvents(store *mocks.Store) {
+	store.
+		EXPECT().
+		AppendAuditEventTx(mock.Anything, mock.Anything).
+		Maybe().
+		Return(db.AppendAuditEventTxResult{}, nil)
+}
+
+func auditChain(n int) []db.AuditEvent {
+	var prevHash []byte
+
+	events := make([]db.AuditEvent, n)
+
+	for i := range events {
+		events[i] = db.AuditEvent{
+			ID:        int64(i + 1),
+			Actor:     "someone",
+			Action:    audit.ActionLogin,
+			Outcome:   audit.OutcomeSuccess,
+			PrevHash:  prevHash,
+			CreatedAt: pgtype.Timestamptz{Time: time.Now().Truncate(time.Microsecond), Valid: true},
+		}
+		events[i].Hash = db.HashAuditEvent(events[i])
+		prevHash = events[i].Hash
+	}
+
+	return events
+}
+
+func TestLoginAuditEvent(t *testing.T) {
+	user, password := randomUser()
+	store := mocks.NewStore(t)
+	server, err := NewServer(store)
+	require.NoError(t, err)
+
+	server.loginGuard = lockout.NewGuard(lockout.NewMemoryBackend(), lockout.Config{
+		MaxAttempts:     3,
+		IPMaxAttempts:   10,
+		Window:          time.Hour,
+		LockoutDuration: time.Hour,
+		BaseDelay:       time.Minute,
+	})
+	store.
+		EXPECT().
+		GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+		Once().
+		Return(user, nil)
+	store.
+		EXPECT().
+		AppendAuditEventTx(mock.Anything, db.AppendAuditEventTxParams{
+			Actor:     user.Username,
+			Action:    audit.ActionLogin,
+			Resource:  "users/" + user.Username,
+			Outcome:   audit.OutcomeDenied,
+			Detail:    "password: " + http.StatusText(http.StatusUnauthorized),
+			ClientIp:  "192.0.2.1",
+			UserAgent: "audit-test",
+		}).
+		Once().
+		Return(db.AppendAuditEventTxResult{}, nil)
+
+	rec := httptest.NewRecorder()
+	req := newLoginRequest(t, user.Username, password+"x")
+	req.Header.Set("User-Agent", "audit-test")
+	req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.7")
+	req.Header.Set(echo.HeaderXRealIP, "203.0.113.8")
+	req.RemoteAddr = "192.0.2.1:1234"
+	server.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestVerifyAuditEventsAPI(t *testing.T) {
+	tampered := auditChain(3)
+	tampered[1].Detail = "changed"
+
+	testCases := []struct {
+		name   string
+		role   string
+		events []db.AuditEvent
+		code   int
+		valid  bool
+	}{
+		{
+			name:   "Valid",
+			role:   authz.RoleAuditor,
+			events: auditChain(3),
+			code:   http.StatusOK,
+			valid:  true,
+		},
+		{
+			name:   "Tampered",
+			role:   authz.RoleAuditor,
+			events: tampered,
+			code:   http.StatusOK,
+		},
+		{
+			name: "Forbidden",
+			role: authz.RoleDepositor,
+			code: http.StatusForbidden,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			if tc.events != nil {
+				store.
+					EXPECT().
+					ListAuditEventChain(mock.AnythingOfType("context.todoCtx"), db.ListAuditEventChainParams{
+						Limit: auditChainBatchSize,
+					}).
+					Once().
+					Return(tc.events, nil)
+			}
+
+			req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, "/audit-events/verify", nil)
+			require.NoError(t, err)
+			addAuthorization(t, req, server.tokenMaker, AUTH_TYPE_BEARER, "someone", tc.role, time.Minute)
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, req)
+			require.Equal(t, tc.code, rec.Code)
+
+			if tc.code == http.StatusOK {
+				var res verifyAuditEventsResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+				require.Equal(t, tc.valid, res.Valid)
+			}
+		})
+	}
+}
@@ -2,9 +2,12 @@ package rest
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/MadAppGang/httplog"
-	"github.com/dharmavagabond/simple-bank/internal/config"
+	"github.com/dharmavagabond/simple-bank/internal/apikey"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
 	"github.com/dharmavagabond/simple-bank/internal/token"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -13,28 +16,92 @@ import (
 const (
 	AUTH_HEADER               = "Authorization"
 	AUTH_TYPE_BEARER          = "Bearer"
+	AUTH_TYPE_API_KEY         = "ApiKey"
 	AUTHORIZATION_PAYLOAD_KEY = "authorizationPayloadKey"
 )
 
-var authMiddleware = middleware.KeyAuth(func(key string, ectx echo.Context) (bool, error) {
-	var (
-		tokenMaker token.Maker
-		payload    *token.Payload
-		err        error
-	)
+// authMiddleware accepts either a Bearer access token or an ApiKey. Refresh
+// tokens are refused: they only renew a session.
+func authMiddleware(tokenMaker token.Maker, store db.Store) echo.MiddlewareFunc {
+	bearerAuth := middleware.KeyAuth(func(key string, ectx echo.Context) (bool, error) {
+		payload, err := tokenMaker.VerifyToken(key)
+		if err != nil {
+			return false, err
+		}
 
-	if tokenMaker, err = token.NewPasetoMaker(config.App.TokenSymmetricKey); err != nil {
-		return false, err
+		if payload.IsRefresh() {
+			return false, token.ERR_REFRESH_TOKEN
+		}
+
+		ectx.Set(AUTHORIZATION_PAYLOAD_KEY, payload)
+
+		return true, nil
+	})
+	apiKeyAuth := middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		AuthScheme: AUTH_TYPE_API_KEY,
+		Validator: func(key string, ectx echo.Context) (bool, error) {
+			payload, err := apikey.Authenticate(ectx.Request().Context(), store, key)
+			if err != nil {
+				return false, err
+			}
+
+			ectx.Set(AUTHORIZATION_PAYLOAD_KEY, payload)
+
+			return true, nil
+		},
+	})
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withBearer := bearerAuth(next)
+		withAPIKey := apiKeyAuth(next)
+
+		return func(ectx echo.Context) error {
+			scheme, _, _ := strings.Cut(ectx.Request().Header.Get(AUTH_HEADER), " ")
+
+			if strings.EqualFold(scheme, AUTH_TYPE_API_KEY) {
+				return withAPIKey(ectx)
+			}
+
+			return withBearer(ectx)
+		}
+	}
+}
+
+func permissionMiddleware(action authz.Action) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ectx echo.Context) error {
+			payload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+			if !authz.Permits(payload, action) {
+				return echo.NewHTTPError(http.StatusForbidden, authz.ERR_PERMISSION_DENIED.Error())
+			}
+
+			return next(ectx)
+		}
 	}
+}
+
+func sessionMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ectx echo.Context) error {
+		payload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+		if err := authz.RequireSession(payload); err != nil {
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		}
 
-	if payload, err = tokenMaker.VerifyToken(key); err != nil {
-		return false, err
+		return next(ectx)
 	}
+}
 
-	ectx.Set(AUTHORIZATION_PAYLOAD_KEY, payload)
+func authorizeOwner(ectx echo.Context, action authz.Action, owner string) error {
+	payload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
 
-	return true, nil
-})
+	if err := authz.Authorize(payload, action, owner); err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+
+	return nil
+}
 
 func loggerMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(ectx echo.Context) error {
@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dharmavagabond/simple-bank/internal/authz"
 	"github.com/dharmavagabond/simple-bank/internal/token"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/require"
@@ -27,10 +28,11 @@ func addAuthorization(
 	tokenMaker token.Maker,
 	authorizationType string,
 	username string,
+	role string,
 	duration time.Duration,
 ) {
 	t.Helper()
-	token, _, err := tokenMaker.CreateToken(username, duration)
+	token, _, err := tokenMaker.CreateToken(username, role, duration)
 	require.NoError(t, err)
 
 	authorizationToken := fmt.Sprintf("%s %s", authorizationType, token)
@@ -48,7 +50,7 @@ func TestAuthMiddleware(t *testing.T) {
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
 				t.Helper()
 				username := "erosennin"
-				addAuthorization(t, req, tokenMaker, AUTH_TYPE_BEARER, username, time.Minute)
+				addAuthorization(t, req, tokenMaker, AUTH_TYPE_BEARER, username, authz.RoleDepositor, time.Minute)
 			},
 			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
 				t.Helper()
@@ -69,7 +71,7 @@ func TestAuthMiddleware(t *testing.T) {
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
 				t.Helper()
 				username := "erosennin"
-				addAuthorization(t, req, tokenMaker, "Token", username, time.Minute)
+				addAuthorization(t, req, tokenMaker, "Token", username, authz.RoleDepositor, time.Minute)
 			},
 			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
 				t.Helper()
@@ -82,7 +84,7 @@ func TestAuthMiddleware(t *testing.T) {
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
 				t.Helper()
 				username := "erosennin"
-				addAuthorization(t, req, tokenMaker, "", username, time.Minute)
+				addAuthorization(t, req, tokenMaker, "", username, authz.RoleDepositor, time.Minute)
 			},
 			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
 				t.Helper()
@@ -95,7 +97,7 @@ func TestAuthMiddleware(t *testing.T) {
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
 				t.Helper()
 				username := "erosennin"
-				addAuthorization(t, req, tokenMaker, AUTH_TYPE_BEARER, username, -time.Minute)
+				addAuthorization(t, req, tokenMaker, AUTH_TYPE_BEARER, username, authz.RoleDepositor, -time.Minute)
 			},
 			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
 				t.Helper()
@@ -115,7 +117,7 @@ func TestAuthMiddleware(t *testing.T) {
 				func(ectx echo.Context) error {
 					return ectx.JSON(http.StatusOK, echo.Map{})
 				},
-				authMiddleware,
+				authMiddleware(server.tokenMaker, server.store),
 			)
 			rec := httptest.NewRecorder()
 			req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, authPath, nil)
@@ -128,6 +130,47 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 }
 
+func TestClientIP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		expectedIP string
+	}{
+		{
+			name:       "Direct",
+			remoteAddr: "192.0.2.1:1234",
+			expectedIP: "192.0.2.1",
+		},
+		{
+			name:       "PrivatePeer",
+			remoteAddr: "10.0.0.2:1234",
+			expectedIP: "10.0.0.2",
+		},
+		{
+			name:       "LoopbackProxy",
+			remoteAddr: "127.0.0.1:1234",
+			expectedIP: "198.51.100.4",
+		},
+	}
+
+	server, err := NewServer(nil)
+	require.NoError(t, err)
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, "/", nil)
+			require.NoError(t, err)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.7, 198.51.100.4")
+			req.Header.Set(echo.HeaderXRealIP, "203.0.113.8")
+
+			ectx := server.router.NewContext(req, httptest.NewRecorder())
+			require.Equal(t, tc.expectedIP, ectx.RealIP())
+		})
+	}
+}
+
 func checkErrorMessage(t *testing.T, body *bytes.Buffer, message string) {
 	t.Helper()
 	data, err := io.ReadAll(body)
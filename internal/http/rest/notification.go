@@ -0,0 +1,222 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/notification"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+type (
+	updateNotificationPreferenceRequest struct {
+		Enabled   *bool  `json:"enabled"    validate:"required"`
+		EventType string `json:"event_type" validate:"required,oneof=transfer.receipt transfer.incoming balance.low"`
+		Channel   string `json:"channel"    validate:"required,oneof=email"`
+	}
+	lowBalanceAlertRequest struct {
+		AccountID int64 `param:"id" validate:"required,min=1"`
+	}
+	setLowBalanceAlertRequest struct {
+		AccountID int64 `param:"id"        validate:"required,min=1"`
+		Threshold int64 `json:"threshold" validate:"required,gt=0"`
+	}
+	lowBalanceAlertResponse struct {
+		UpdatedAt time.Time `json:"updated_at"`
+		AccountID int64     `json:"account_id"`
+		Threshold int64     `json:"threshold"`
+	}
+)
+
+// listNotificationPreferences returns the user's preference for every event
+// and channel, including those left to their default.
+func (server *Server) listNotificationPreferences(ectx echo.Context) error {
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	stored, err := server.store.ListNotificationPreferences(ectx.Request().Context(), authPayload.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.JSON(http.StatusOK, notification.Preferences(stored))
+}
+
+func (server *Server) updateNotificationPreference(ectx echo.Context) (err error) {
+	var (
+		pref db.NotificationPreference
+		req  = &updateNotificationPreferenceRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    authPayload.Username,
+			Action:   audit.ActionUpdateNotifications,
+			Resource: fmt.Sprintf("users/%s/notification-preferences", authPayload.Username),
+			Detail:   fmt.Sprintf("%s %s enabled=%t", req.EventType, req.Channel, *req.Enabled),
+		}, err)
+	}()
+
+	if pref, err = server.store.UpsertNotificationPreference(
+		ectx.Request().Context(),
+		db.UpsertNotificationPreferenceParams{
+			Username:  authPayload.Username,
+			EventType: req.EventType,
+			Channel:   req.Channel,
+			Enabled:   *req.Enabled,
+		},
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.JSON(http.StatusOK, notification.Preference{
+		EventType: pref.EventType,
+		Channel:   pref.Channel,
+		Enabled:   pref.Enabled,
+	})
+}
+
+func (server *Server) getLowBalanceAlert(ectx echo.Context) (err error) {
+	var (
+		account db.Account
+		alert   db.LowBalanceAlert
+		req     = &lowBalanceAlertRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	if account, err = getAccount(req.AccountID, server.store, ectx.Request().Context()); err != nil {
+		return err
+	}
+
+	if err = authorizeOwner(ectx, authz.ActionReadAccount, account.Owner); err != nil {
+		return err
+	}
+
+	if alert, err = server.store.GetLowBalanceAlert(ectx.Request().Context(), account.ID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "the account has no low balance alert")
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.JSON(http.StatusOK, newLowBalanceAlertResponse(alert))
+}
+
+// setLowBalanceAlert alerts the owner when a transfer takes the account's
+// balance below the threshold.
+func (server *Server) setLowBalanceAlert(ectx echo.Context) (err error) {
+	var (
+		account db.Account
+		alert   db.LowBalanceAlert
+		req     = &setLowBalanceAlertRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    authPayload.Username,
+			Action:   audit.ActionUpdateNotifications,
+			Resource: fmt.Sprintf("accounts/%d/low-balance-alert", req.AccountID),
+			Detail:   fmt.Sprintf("threshold=%d", req.Threshold),
+		}, err)
+	}()
+
+	if account, err = getAccount(req.AccountID, server.store, ectx.Request().Context()); err != nil {
+		return err
+	}
+
+	if err = authorizeOwner(ectx, authz.ActionWriteAccount, account.Owner); err != nil {
+		return err
+	}
+
+	if alert, err = server.store.UpsertLowBalanceAlert(ectx.Request().Context(), db.UpsertLowBalanceAlertParams{
+		AccountID: account.ID,
+		Threshold: req.Threshold,
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.JSON(http.StatusOK, newLowBalanceAlertResponse(alert))
+}
+
+func (server *Server) deleteLowBalanceAlert(ectx echo.Context) (err error) {
+	var (
+		account db.Account
+		req     = &lowBalanceAlertRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    authPayload.Username,
+			Action:   audit.ActionUpdateNotifications,
+			Resource: fmt.Sprintf("accounts/%d/low-balance-alert", req.AccountID),
+			Detail:   "deleted",
+		}, err)
+	}()
+
+	if account, err = getAccount(req.AccountID, server.store, ectx.Request().Context()); err != nil {
+		return err
+	}
+
+	if err = authorizeOwner(ectx, authz.ActionWriteAccount, account.Owner); err != nil {
+		return err
+	}
+
+	if err = server.store.DeleteLowBalanceAlert(ectx.Request().Context(), account.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
+
+func newLowBalanceAlertResponse(alert db.LowBalanceAlert) lowBalanceAlertResponse {
+	return lowBalanceAlertResponse{
+		UpdatedAt: alert.UpdatedAt.Time,
+		AccountID: alert.AccountID,
+		Threshold: alert.Threshold,
+	}
+}
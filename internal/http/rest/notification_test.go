@@ -0,0 +1,207 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/notification"
+)
+
+func TestNotificationPreferencesAPI(t *testing.T) {
+	user, _ := randomUser()
+	store := mocks.NewStore(t)
+	store.
+		EXPECT().
+		ListNotificationPreferences(mock.AnythingOfType("context.todoCtx"), user.Username).
+		Once().
+		Return([]db.NotificationPreference{{
+			Username:  user.Username,
+			EventType: notification.EventTransferIncoming,
+			Channel:   notification.ChannelEmail,
+		}}, nil)
+	store.
+		EXPECT().
+		UpsertNotificationPreference(mock.AnythingOfType("context.todoCtx"), db.UpsertNotificationPreferenceParams{
+			Username:  user.Username,
+			EventType: notification.EventLowBalance,
+			Channel:   notification.ChannelEmail,
+			Enabled:   false,
+		}).
+		Once().
+		Return(db.NotificationPreference{
+			Username:  user.Username,
+			EventType: notification.EventLowBalance,
+			Channel:   notification.ChannelEmail,
+		}, nil)
+	expectAuditEvents(store)
+	server, err := NewServer(store)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, "/notification-preferences", nil)
+	require.NoError(t, err)
+	addAuthorization(t, req, server.tokenMaker, AUTH_TYPE_BEARER, user.Username, authz.RoleDepositor, time.Minute)
+	server.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var prefs []notification.Preference
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &prefs))
+	require.Len(t, prefs, len(notification.Events)*len(notification.Channels))
+	require.Contains(t, prefs, notification.Preference{
+		EventType: notification.EventTransferIncoming,
+		Channel:   notification.ChannelEmail,
+	})
+	require.Contains(t, prefs, notification.Preference{
+		EventType: notification.EventTransferReceipt,
+		Channel:   notification.ChannelEmail,
+		Enabled:   true,
+	})
+
+	for body, expectedCode := range map[string]int{
+		`{"event_type":"balance.low","channel":"sms","enabled":false}`:   http.StatusBadRequest,
+		`{"event_type":"balance.low","channel":"email"}`:                 http.StatusBadRequest,
+		`{"event_type":"balance.low","channel":"email","enabled":false}`: http.StatusOK,
+	} {
+		rec = httptest.NewRecorder()
+		req, err = http.NewRequestWithContext(
+			context.TODO(),
+			http.MethodPut,
+			"/notification-preferences",
+			bytes.NewReader([]byte(body)),
+		)
+		require.NoError(t, err)
+		req.Header.Add("Content-Type", "application/json")
+		addAuthorization(t, req, server.tokenMaker, AUTH_TYPE_BEARER, user.Username, authz.RoleDepositor, time.Minute)
+		server.router.ServeHTTP(rec, req)
+		require.Equal(t, expectedCode, rec.Code, body)
+	}
+}
+
+func TestLowBalanceAlertAPI(t *testing.T) {
+	user, _ := randomUser()
+	account := createRandomAccount(user.Username)
+	alert := db.LowBalanceAlert{AccountID: account.ID, Threshold: 100}
+	testCases := []struct {
+		name         string
+		username     string
+		method       string
+		body         string
+		buildStubs   func(store *mocks.Store)
+		expectedCode int
+	}{
+		{
+			name:     "Get",
+			username: user.Username,
+			method:   http.MethodGet,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetLowBalanceAlert(mock.AnythingOfType("context.todoCtx"), account.ID).
+					Once().
+					Return(alert, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:     "GetNotFound",
+			username: user.Username,
+			method:   http.MethodGet,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetLowBalanceAlert(mock.AnythingOfType("context.todoCtx"), account.ID).
+					Once().
+					Return(db.LowBalanceAlert{}, pgx.ErrNoRows)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:     "Set",
+			username: user.Username,
+			method:   http.MethodPut,
+			body:     `{"threshold":100}`,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					UpsertLowBalanceAlert(mock.AnythingOfType("context.todoCtx"), db.UpsertLowBalanceAlertParams{
+						AccountID: account.ID,
+						Threshold: 100,
+					}).
+					Once().
+					Return(alert, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "SetInvalidThreshold",
+			username:     user.Username,
+			method:       http.MethodPut,
+			body:         `{"threshold":-1}`,
+			buildStubs:   func(store *mocks.Store) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "SetNotOwner",
+			username:     "other_user",
+			method:       http.MethodPut,
+			body:         `{"threshold":100}`,
+			buildStubs:   func(store *mocks.Store) {},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:     "Delete",
+			username: user.Username,
+			method:   http.MethodDelete,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					DeleteLowBalanceAlert(mock.AnythingOfType("context.todoCtx"), account.ID).
+					Once().
+					Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			store.
+				EXPECT().
+				GetAccount(mock.AnythingOfType("context.todoCtx"), account.ID).
+				Maybe().
+				Return(account, nil)
+			tc.buildStubs(store)
+			expectAuditEvents(store)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(
+				context.TODO(),
+				tc.method,
+				fmt.Sprintf("/accounts/%d/low-balance-alert", account.ID),
+				bytes.NewReader([]byte(tc.body)),
+			)
+			require.NoError(t, err)
+			req.Header.Add("Content-Type", "application/json")
+			addAuthorization(t, req, server.tokenMaker, AUTH_TYPE_BEARER, tc.username, authz.RoleDepositor, time.Minute)
+			server.router.ServeHTTP(rec, req)
+			require.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
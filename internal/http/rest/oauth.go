@@ -0,0 +1,621 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/oauth"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+type (
+	registerOauthClientRequest struct {
+		Name         string   `json:"name"          validate:"required,max=64"`
+		RedirectURIs []string `json:"redirect_uris" validate:"omitempty,dive,url"`
+		Scopes       []string `json:"scopes"        validate:"required,min=1,dive,required"`
+		Confidential bool     `json:"confidential"`
+	}
+	registerOauthClientResponse struct {
+		CreatedAt    time.Time `json:"created_at"`
+		ClientID     string    `json:"client_id"`
+		ClientSecret string    `json:"client_secret,omitempty"`
+		Name         string    `json:"name"`
+		RedirectURIs []string  `json:"redirect_uris"`
+		Scopes       []string  `json:"scopes"`
+	}
+	oauthAuthorizeRequest struct {
+		ResponseType        string `json:"response_type"         validate:"required,eq=code"`
+		ClientID            string `json:"client_id"             validate:"required"`
+		RedirectURI         string `json:"redirect_uri"          validate:"required,url"`
+		Scope               string `json:"scope"                 validate:"required"`
+		State               string `json:"state"`
+		CodeChallenge       string `json:"code_challenge"        validate:"required,min=43,max=128"`
+		CodeChallengeMethod string `json:"code_challenge_method" validate:"required,eq=S256"`
+	}
+	oauthAuthorizeResponse struct {
+		RedirectURI string `json:"redirect_uri"`
+	}
+	oauthTokenRequest struct {
+		GrantType    string `form:"grant_type"`
+		Code         string `form:"code"`
+		RedirectURI  string `form:"redirect_uri"`
+		CodeVerifier string `form:"code_verifier"`
+		ClientID     string `form:"client_id"`
+		ClientSecret string `form:"client_secret"`
+		Scope        string `form:"scope"`
+		RefreshToken string `form:"refresh_token"`
+	}
+	oauthTokenResponse struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+		Scope        string `json:"scope"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	oauthErrorResponse struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+	revokeOauthConsentRequest struct {
+		ClientID string `param:"client_id" validate:"required"`
+	}
+)
+
+func (server *Server) registerOauthClient(ectx echo.Context) (err error) {
+	var (
+		client       db.OauthClient
+		clientID     string
+		clientSecret string
+		hashedSecret string
+		req          = &registerOauthClientRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	for _, scope := range req.Scopes {
+		if !authz.IsValidScope(scope) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s: %s", authz.ERR_INVALID_SCOPE, scope))
+		}
+	}
+
+	if !req.Confidential && len(req.RedirectURIs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Public clients need at least one redirect URI")
+	}
+
+	if req.RedirectURIs == nil {
+		req.RedirectURIs = []string{}
+	}
+
+	if clientID, err = oauth.NewClientID(); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if req.Confidential {
+		if clientSecret, hashedSecret, err = oauth.NewSecret(); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	if client, err = server.store.CreateOauthClient(ectx.Request().Context(), db.CreateOauthClientParams{
+		ID:           clientID,
+		Owner:        authPayload.Username,
+		Name:         req.Name,
+		HashedSecret: pgtype.Text{String: hashedSecret, Valid: req.Confidential},
+		RedirectUris: req.RedirectURIs,
+		Scopes:       req.Scopes,
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := registerOauthClientResponse{
+		ClientID:     client.ID,
+		ClientSecret: clientSecret,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		CreatedAt:    client.CreatedAt.Time,
+	}
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+// authorizeOauthClient records the authenticated user's consent and returns
+// where to redirect them with a single-use authorization code.
+func (server *Server) authorizeOauthClient(ectx echo.Context) (err error) {
+	var (
+		client      db.OauthClient
+		code        string
+		hashedCode  string
+		redirectURI *url.URL
+		req         = &oauthAuthorizeRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	ctx := ectx.Request().Context()
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	if client, err = server.store.GetOauthClient(ctx, req.ClientID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown OAuth client")
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Unregistered redirect URI")
+	}
+
+	if redirectURI, err = url.Parse(req.RedirectURI); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	scopes := oauth.ParseScope(req.Scope)
+
+	if !oauth.ContainsAll(client.Scopes, scopes) {
+		return echo.NewHTTPError(http.StatusBadRequest, "The client may not request these scopes")
+	}
+
+	if err = authz.ValidateScopes(authPayload.Role, scopes); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if _, err = server.store.UpsertOauthConsent(ctx, db.UpsertOauthConsentParams{
+		Username: authPayload.Username,
+		ClientID: client.ID,
+		Scopes:   scopes,
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if code, hashedCode, err = oauth.NewSecret(); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if _, err = server.store.CreateOauthAuthorizationCode(ctx, db.CreateOauthAuthorizationCodeParams{
+		HashedCode:    hashedCode,
+		ClientID:      client.ID,
+		Username:      authPayload.Username,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  time.Now().Add(config.App.OAuthCodeDuration),
+			Valid: true,
+		},
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	query := redirectURI.Query()
+	query.Set("code", code)
+
+	if len(req.State) > 0 {
+		query.Set("state", req.State)
+	}
+
+	redirectURI.RawQuery = query.Encode()
+
+	return ectx.JSON(http.StatusOK, oauthAuthorizeResponse{RedirectURI: redirectURI.String()})
+}
+
+// issueOauthToken is the RFC 6749 token endpoint. Clients authenticate with
+// HTTP Basic or form parameters; public clients send only their client_id.
+func (server *Server) issueOauthToken(ectx echo.Context) (err error) {
+	var (
+		client db.OauthClient
+		req    = &oauthTokenRequest{}
+	)
+
+	ectx.Response().Header().Set("Cache-Control", "no-store")
+
+	if err = ectx.Bind(req); err != nil {
+		return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidRequest, err.Error())
+	}
+
+	clientID, clientSecret, ok := ectx.Request().BasicAuth()
+
+	if !ok {
+		clientID, clientSecret = req.ClientID, req.ClientSecret
+	}
+
+	if client, err = server.store.GetOauthClient(ectx.Request().Context(), clientID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return oauthError(ectx, http.StatusUnauthorized, oauth.ErrInvalidClient, "Unknown client")
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if (client.HashedSecret.Valid && !oauth.VerifySecret(clientSecret, client.HashedSecret.String)) ||
+		(!client.HashedSecret.Valid && len(clientSecret) > 0) {
+		return oauthError(ectx, http.StatusUnauthorized, oauth.ErrInvalidClient, "Invalid client credentials")
+	}
+
+	switch req.GrantType {
+	case oauth.GrantAuthorizationCode:
+		return server.exchangeOauthCode(ectx, client, req)
+	case oauth.GrantClientCredentials:
+		return server.issueOauthClientToken(ectx, client, req)
+	case oauth.GrantRefreshToken:
+		return server.refreshOauthToken(ectx, client, req)
+	}
+
+	return oauthError(ectx, http.StatusBadRequest, oauth.ErrUnsupportedGrantType, "")
+}
+
+func (server *Server) exchangeOauthCode(
+	ectx echo.Context,
+	client db.OauthClient,
+	req *oauthTokenRequest,
+) (err error) {
+	var (
+		authCode db.OauthAuthorizationCode
+		user     db.User
+	)
+
+	ctx := ectx.Request().Context()
+
+	if authCode, err = server.store.ConsumeOauthAuthorizationCode(ctx, oauth.HashSecret(req.Code)); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Invalid authorization code")
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if authCode.ClientID != client.ID ||
+		authCode.RedirectUri != req.RedirectURI ||
+		time.Now().After(authCode.ExpiresAt.Time) {
+		return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Invalid authorization code")
+	}
+
+	if !oauth.VerifyCodeChallenge(req.CodeVerifier, authCode.CodeChallenge) {
+		return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Invalid code verifier")
+	}
+
+	if user, err = server.store.GetUser(ctx, authCode.Username); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return server.createOauthSession(ectx, client, user.Username, user.Role, authCode.Scopes)
+}
+
+// issueOauthClientToken lets a confidential client act as the user who
+// registered it, within the client's scopes. No refresh token is issued.
+func (server *Server) issueOauthClientToken(
+	ectx echo.Context,
+	client db.OauthClient,
+	req *oauthTokenRequest,
+) (err error) {
+	var (
+		owner              db.User
+		accessToken        string
+		accessTokenPayload *token.Payload
+	)
+
+	if !client.HashedSecret.Valid {
+		return oauthError(
+			ectx,
+			http.StatusBadRequest,
+			oauth.ErrUnauthorizedClient,
+			"Public clients can't use client credentials",
+		)
+	}
+
+	scopes := oauth.ParseScope(req.Scope)
+
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+
+	if !oauth.ContainsAll(client.Scopes, scopes) {
+		return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidScope, "")
+	}
+
+	if owner, err = server.store.GetUser(ectx.Request().Context(), client.Owner); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if err = authz.ValidateScopes(owner.Role, scopes); err != nil {
+		return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidScope, err.Error())
+	}
+
+	if accessToken, accessTokenPayload, err = server.tokenMaker.CreateScopedToken(
+		owner.Username,
+		owner.Role,
+		scopes,
+		config.App.AccessTokenDuration,
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := oauthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   AUTH_TYPE_BEARER,
+		Scope:       oauth.FormatScope(scopes),
+		ExpiresIn:   int64(time.Until(accessTokenPayload.ExpiredAt).Seconds()),
+	}
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+func (server *Server) refreshOauthToken(
+	ectx echo.Context,
+	client db.OauthClient,
+	req *oauthTokenRequest,
+) (err error) {
+	var (
+		session             db.Session
+		refreshTokenPayload *token.Payload
+		accessToken         string
+		accessTokenPayload  *token.Payload
+		refreshToken        string
+	)
+
+	ctx := ectx.Request().Context()
+
+	if refreshTokenPayload, err = server.tokenMaker.VerifyToken(req.RefreshToken); err != nil {
+		return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, err.Error())
+	}
+
+	if session, err = server.store.GetSession(ctx, pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Unknown refresh token")
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if session.RefreshToken != req.RefreshToken || session.ClientID.String != client.ID {
+		return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Unknown refresh token")
+	}
+
+	if session.RotatedAt.Valid {
+		if err = server.revokeSessionFamily(ctx, session.FamilyID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Refresh token reuse detected")
+	}
+
+	if session.IsBlocked || time.Now().After(session.ExpiresAt.Time) {
+		return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Expired or revoked session")
+	}
+
+	consent, err := server.store.GetOauthConsent(ctx, db.GetOauthConsentParams{
+		Username: session.Username,
+		ClientID: client.ID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Consent was revoked")
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if !oauth.ContainsAll(consent.Scopes, session.Scopes) {
+		return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Consent was narrowed")
+	}
+
+	// The role is read again, so a change of role applies from the next
+	// refresh rather than when the session expires.
+	user, err := server.store.GetUser(ctx, session.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Unknown user")
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if accessToken, accessTokenPayload, err = server.tokenMaker.CreateScopedToken(
+		user.Username,
+		user.Role,
+		session.Scopes,
+		config.App.AccessTokenDuration,
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if refreshToken, refreshTokenPayload, err = server.tokenMaker.CreateRefreshToken(
+		user.Username,
+		user.Role,
+		session.Scopes,
+		time.Until(session.ExpiresAt.Time),
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if _, err = server.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+		RotatedSessionID: session.ID,
+		CreateSessionParams: db.CreateSessionParams{
+			ID:                   pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true},
+			Username:             session.Username,
+			RefreshToken:         refreshToken,
+			UserAgent:            ectx.Request().UserAgent(),
+			ClientIp:             ectx.RealIP(),
+			ExpiresAt:            session.ExpiresAt,
+			AccessTokenID:        pgtype.UUID{Bytes: accessTokenPayload.ID, Valid: true},
+			AccessTokenExpiresAt: pgtype.Timestamptz{Time: accessTokenPayload.ExpiredAt, Valid: true},
+			ClientID:             session.ClientID,
+			Scopes:               session.Scopes,
+		},
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if err = server.revokeSessionFamily(ctx, session.FamilyID); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+
+			return oauthError(ectx, http.StatusBadRequest, oauth.ErrInvalidGrant, "Refresh token reuse detected")
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    AUTH_TYPE_BEARER,
+		RefreshToken: refreshToken,
+		Scope:        oauth.FormatScope(session.Scopes),
+		ExpiresIn:    int64(time.Until(accessTokenPayload.ExpiredAt).Seconds()),
+	}
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+// createOauthSession issues scoped tokens backed by a session, so they can be
+// refreshed and revoked like the user's own.
+func (server *Server) createOauthSession(
+	ectx echo.Context,
+	client db.OauthClient,
+	username string,
+	role string,
+	scopes []string,
+) (err error) {
+	var (
+		accessToken         string
+		accessTokenPayload  *token.Payload
+		refreshToken        string
+		refreshTokenPayload *token.Payload
+	)
+
+	if accessToken, accessTokenPayload, err = server.tokenMaker.CreateScopedToken(
+		username,
+		role,
+		scopes,
+		config.App.AccessTokenDuration,
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if refreshToken, refreshTokenPayload, err = server.tokenMaker.CreateRefreshToken(
+		username,
+		role,
+		scopes,
+		config.App.RefreshTokenDuration,
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	id := pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true}
+
+	if _, err = server.store.CreateSession(ectx.Request().Context(), db.CreateSessionParams{
+		ID:                   id,
+		FamilyID:             id,
+		Username:             username,
+		RefreshToken:         refreshToken,
+		UserAgent:            ectx.Request().UserAgent(),
+		ClientIp:             ectx.RealIP(),
+		ExpiresAt:            pgtype.Timestamptz{Time: refreshTokenPayload.ExpiredAt, Valid: true},
+		AccessTokenID:        pgtype.UUID{Bytes: accessTokenPayload.ID, Valid: true},
+		AccessTokenExpiresAt: pgtype.Timestamptz{Time: accessTokenPayload.ExpiredAt, Valid: true},
+		ClientID:             pgtype.Text{String: client.ID, Valid: true},
+		Scopes:               scopes,
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    AUTH_TYPE_BEARER,
+		RefreshToken: refreshToken,
+		Scope:        oauth.FormatScope(scopes),
+		ExpiresIn:    int64(time.Until(accessTokenPayload.ExpiredAt).Seconds()),
+	}
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+func (server *Server) listOauthConsents(ectx echo.Context) error {
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	consents, err := server.store.ListOauthConsents(ectx.Request().Context(), authPayload.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.JSON(http.StatusOK, consents)
+}
+
+// revokeOauthConsent also blocks the client's sessions for the user and
+// revokes their access tokens.
+func (server *Server) revokeOauthConsent(ectx echo.Context) (err error) {
+	var (
+		deleted  int64
+		sessions []db.BlockOauthClientSessionsRow
+		req      = &revokeOauthConsentRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	ctx := ectx.Request().Context()
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	if deleted, err = server.store.DeleteOauthConsent(ctx, db.DeleteOauthConsentParams{
+		Username: authPayload.Username,
+		ClientID: req.ClientID,
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if deleted == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Consent not found")
+	}
+
+	if sessions, err = server.store.BlockOauthClientSessions(ctx, db.BlockOauthClientSessionsParams{
+		Username: authPayload.Username,
+		ClientID: pgtype.Text{String: req.ClientID, Valid: true},
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	for _, session := range sessions {
+		if err = server.revokeToken(ctx, session.ID, session.ExpiresAt); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		if err = server.revokeToken(ctx, session.AccessTokenID, session.AccessTokenExpiresAt); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
+
+func oauthError(ectx echo.Context, status int, code, description string) error {
+	return ectx.JSON(status, oauthErrorResponse{Error: code, ErrorDescription: description})
+}
@@ -0,0 +1,317 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/oauth"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+const testRedirectURI = "https://app.example.com/callback"
+
+func newOauthTokenRequest(t *testing.T, form url.Values) *http.Request {
+	t.Helper()
+	req, err := http.NewRequestWithContext(
+		context.TODO(),
+		http.MethodPost,
+		"/oauth/token",
+		strings.NewReader(form.Encode()),
+	)
+	require.NoError(t, err)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+
+	return req
+}
+
+func readOauthTokenResponse(t *testing.T, rec *httptest.ResponseRecorder) oauthTokenResponse {
+	t.Helper()
+	data, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+
+	var res oauthTokenResponse
+	require.NoError(t, json.Unmarshal(data, &res))
+
+	return res
+}
+
+func TestOauthAuthorizationCodeFlow(t *testing.T) {
+	user, _ := randomUser()
+	client := db.OauthClient{
+		ID:           "sbc_test",
+		Owner:        "developer",
+		RedirectUris: []string{testRedirectURI},
+		Scopes:       []string{string(authz.ActionReadAccount), string(authz.ActionCreateTransfer)},
+	}
+	verifier := strings.Repeat("v", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	scopes := []string{string(authz.ActionReadAccount)}
+
+	store := mocks.NewStore(t)
+	server, err := NewServer(store)
+	require.NoError(t, err)
+
+	var authCode db.OauthAuthorizationCode
+	store.
+		EXPECT().
+		GetOauthClient(mock.AnythingOfType("context.todoCtx"), client.ID).
+		Return(client, nil)
+	store.
+		EXPECT().
+		UpsertOauthConsent(mock.AnythingOfType("context.todoCtx"), db.UpsertOauthConsentParams{
+			Username: user.Username,
+			ClientID: client.ID,
+			Scopes:   scopes,
+		}).
+		Once().
+		Return(db.OauthConsent{}, nil)
+	store.
+		EXPECT().
+		CreateOauthAuthorizationCode(
+			mock.AnythingOfType("context.todoCtx"),
+			mock.MatchedBy(func(arg db.CreateOauthAuthorizationCodeParams) bool {
+				authCode = db.OauthAuthorizationCode{
+					HashedCode:    arg.HashedCode,
+					ClientID:      arg.ClientID,
+					Username:      arg.Username,
+					RedirectUri:   arg.RedirectUri,
+					Scopes:        arg.Scopes,
+					CodeChallenge: arg.CodeChallenge,
+					ExpiresAt:     arg.ExpiresAt,
+				}
+
+				return arg.CodeChallenge == challenge
+			}),
+		).
+		Once().
+		Return(db.OauthAuthorizationCode{}, nil)
+
+	data, err := json.Marshal(echo.Map{
+		"response_type":         oauth.ResponseTypeCode,
+		"client_id":             client.ID,
+		"redirect_uri":          testRedirectURI,
+		"scope":                 oauth.FormatScope(scopes),
+		"state":                 "xyz",
+		"code_challenge":        challenge,
+		"code_challenge_method": oauth.CodeChallengeMethodS256,
+	})
+	require.NoError(t, err)
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, "/oauth/authorize", bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	addAuthorization(t, req, server.tokenMaker, AUTH_TYPE_BEARER, user.Username, user.Role, time.Minute)
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var authorizeRes oauthAuthorizeResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &authorizeRes))
+	redirectURI, err := url.Parse(authorizeRes.RedirectURI)
+	require.NoError(t, err)
+	require.Equal(t, "xyz", redirectURI.Query().Get("state"))
+	code := redirectURI.Query().Get("code")
+	require.Equal(t, authCode.HashedCode, oauth.HashSecret(code))
+
+	t.Run("WrongVerifier", func(t *testing.T) {
+		store.
+			EXPECT().
+			ConsumeOauthAuthorizationCode(mock.AnythingOfType("context.todoCtx"), authCode.HashedCode).
+			Once().
+			Return(authCode, nil)
+
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, newOauthTokenRequest(t, url.Values{
+			"grant_type":    {oauth.GrantAuthorizationCode},
+			"client_id":     {client.ID},
+			"code":          {code},
+			"redirect_uri":  {testRedirectURI},
+			"code_verifier": {strings.Repeat("w", 43)},
+		}))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Contains(t, rec.Body.String(), oauth.ErrInvalidGrant)
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		store.
+			EXPECT().
+			ConsumeOauthAuthorizationCode(mock.AnythingOfType("context.todoCtx"), authCode.HashedCode).
+			Once().
+			Return(authCode, nil)
+		store.
+			EXPECT().
+			GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+			Once().
+			Return(user, nil)
+		store.
+			EXPECT().
+			CreateSession(
+				mock.AnythingOfType("context.todoCtx"),
+				mock.MatchedBy(func(arg db.CreateSessionParams) bool {
+					return arg.ClientID.String == client.ID && arg.AccessTokenID.Valid
+				}),
+			).
+			Once().
+			Return(db.Session{}, nil)
+
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, newOauthTokenRequest(t, url.Values{
+			"grant_type":    {oauth.GrantAuthorizationCode},
+			"client_id":     {client.ID},
+			"code":          {code},
+			"redirect_uri":  {testRedirectURI},
+			"code_verifier": {verifier},
+		}))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		res := readOauthTokenResponse(t, rec)
+		require.NotEmpty(t, res.RefreshToken)
+		require.Equal(t, oauth.FormatScope(scopes), res.Scope)
+
+		payload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+		require.NoError(t, err)
+		require.Equal(t, user.Username, payload.Username)
+		require.Equal(t, scopes, payload.Scopes)
+		require.ErrorIs(t, authz.Authorize(payload, authz.ActionCreateTransfer, user.Username), authz.ERR_SCOPE_NOT_GRANTED)
+	})
+}
+
+func TestOauthClientCredentials(t *testing.T) {
+	owner, _ := randomUser()
+	secret, hashedSecret, err := oauth.NewSecret()
+	require.NoError(t, err)
+
+	client := db.OauthClient{
+		ID:           "sbc_backoffice",
+		Owner:        owner.Username,
+		HashedSecret: pgtype.Text{String: hashedSecret, Valid: true},
+		RedirectUris: []string{},
+		Scopes:       []string{string(authz.ActionReadAccount)},
+	}
+	testCases := []struct {
+		name          string
+		secret        string
+		buildStubs    func(store *mocks.Store)
+		checkResponse func(t *testing.T, rec *httptest.ResponseRecorder, tokenMaker token.Maker)
+	}{
+		{
+			name:   "OK",
+			secret: secret,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), owner.Username).
+					Once().
+					Return(owner, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				t.Helper()
+				require.Equal(t, http.StatusOK, rec.Code)
+
+				res := readOauthTokenResponse(t, rec)
+				require.Empty(t, res.RefreshToken)
+
+				payload, err := tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, owner.Username, payload.Username)
+				require.Equal(t, client.Scopes, payload.Scopes)
+			},
+		},
+		{
+			name:       "WrongSecret",
+			secret:     "nope",
+			buildStubs: func(_ *mocks.Store) {},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ token.Maker) {
+				t.Helper()
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+				require.Contains(t, rec.Body.String(), oauth.ErrInvalidClient)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			store.
+				EXPECT().
+				GetOauthClient(mock.AnythingOfType("context.todoCtx"), client.ID).
+				Once().
+				Return(client, nil)
+			tc.buildStubs(store)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			req := newOauthTokenRequest(t, url.Values{"grant_type": {oauth.GrantClientCredentials}})
+			req.SetBasicAuth(client.ID, tc.secret)
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, req)
+			tc.checkResponse(t, rec, server.tokenMaker)
+		})
+	}
+}
+
+func TestRevokeOauthConsentAPI(t *testing.T) {
+	user, _ := randomUser()
+	store := mocks.NewStore(t)
+	server, err := NewServer(store)
+	require.NoError(t, err)
+
+	clientToken, clientPayload, err := server.tokenMaker.CreateScopedToken(
+		user.Username,
+		user.Role,
+		[]string{string(authz.ActionReadAccount)},
+		time.Minute,
+	)
+	require.NoError(t, err)
+
+	store.
+		EXPECT().
+		DeleteOauthConsent(mock.AnythingOfType("context.todoCtx"), db.DeleteOauthConsentParams{
+			Username: user.Username,
+			ClientID: "sbc_test",
+		}).
+		Once().
+		Return(int64(1), nil)
+	store.
+		EXPECT().
+		BlockOauthClientSessions(mock.AnythingOfType("context.todoCtx"), db.BlockOauthClientSessionsParams{
+			Username: user.Username,
+			ClientID: pgtype.Text{String: "sbc_test", Valid: true},
+		}).
+		Once().
+		Return([]db.BlockOauthClientSessionsRow{
+			{
+				AccessTokenID:        pgtype.UUID{Bytes: clientPayload.ID, Valid: true},
+				AccessTokenExpiresAt: pgtype.Timestamptz{Time: clientPayload.ExpiredAt, Valid: true},
+			},
+		}, nil)
+
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodDelete, "/oauth/consents/sbc_test", nil)
+	require.NoError(t, err)
+	addAuthorization(t, req, server.tokenMaker, AUTH_TYPE_BEARER, user.Username, user.Role, time.Minute)
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	_, err = server.tokenMaker.VerifyToken(clientToken)
+	require.ErrorIs(t, err, token.ERR_REVOKED_TOKEN)
+}
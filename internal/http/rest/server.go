@@ -6,18 +6,28 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/dharmavagabond/simple-bank/internal/authz"
 	"github.com/dharmavagabond/simple-bank/internal/config"
 	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/lockout"
+	"github.com/dharmavagabond/simple-bank/internal/password"
 	"github.com/dharmavagabond/simple-bank/internal/token"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
 type (
 	Server struct {
-		store      db.Store
-		tokenMaker token.Maker
-		router     *echo.Echo
+		store           db.Store
+		tokenMaker      token.Maker
+		revocations     token.RevocationStore
+		router          *echo.Echo
+		loginGuard      *lockout.Guard
+		taskDistributor worker.TaskDistributor
+		passwordHasher  *password.Hasher
+		passwordPolicy  *password.Policy
+		taskAdmin       *worker.TaskAdmin
 	}
 
 	customValidator struct {
@@ -39,20 +49,42 @@ func (server *Server) Start() error {
 }
 
 func NewServer(store db.Store) (server *Server, err error) {
-	var tokenMaker token.Maker
+	var (
+		tokenMaker     token.Maker
+		passwordPolicy *password.Policy
+	)
 
 	router := echo.New()
 
-	if tokenMaker, err = token.NewPasetoMaker(config.App.TokenSymmetricKey); err != nil {
+	if tokenMaker, err = token.NewConfiguredMaker(); err != nil {
 		return nil, fmt.Errorf("%w: %w", token.ERR_CANT_CREATE_TOKEN_MAKER, err)
 	}
 
+	passwordHasher := password.NewConfiguredHasher()
+
+	if passwordPolicy, err = password.NewConfiguredPolicy(passwordHasher); err != nil {
+		return nil, err
+	}
+
+	revocations := token.NewConfiguredRevocationStore()
 	server = &Server{
-		store:      store,
-		tokenMaker: tokenMaker,
+		store:           store,
+		tokenMaker:      token.NewRevokingMaker(tokenMaker, revocations),
+		revocations:     revocations,
+		loginGuard:      lockout.NewRedisGuard(),
+		taskDistributor: worker.NewRedisTaskDistributor(),
+		passwordHasher:  passwordHasher,
+		passwordPolicy:  passwordPolicy,
+		taskAdmin:       worker.NewRedisTaskAdmin(),
 	}
 	sbvalidator := validator.New()
 	router.Debug = config.App.IsDev
+	// X-Forwarded-For is only trusted from a proxy on loopback, as in the
+	// gRPC server. Echo's default trusts any client-supplied header.
+	router.IPExtractor = echo.ExtractIPFromXFFHeader(
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	)
 	router.Validator = &customValidator{validator: sbvalidator}
 	server.router = router
 
@@ -66,12 +98,182 @@ func NewServer(store db.Store) (server *Server, err error) {
 }
 
 func (server *Server) setupRouter() {
+	auth := authMiddleware(server.tokenMaker, server.store)
+
 	server.router.Use(loggerMiddleware)
 	server.router.POST("/signin", server.loginUser)
-	server.router.GET("/accounts", server.listAccounts, authMiddleware)
-	server.router.GET("/accounts/:id", server.getAccount, authMiddleware)
-	server.router.POST("/accounts", server.createAccount, authMiddleware)
-	server.router.POST("/transfers", server.createTransfer, authMiddleware)
-	server.router.POST("/users", server.createUser, authMiddleware)
+	server.router.POST("/signin/totp", server.loginUserTOTP)
+	server.router.GET(
+		"/accounts",
+		server.listAccounts,
+		auth,
+		permissionMiddleware(authz.ActionReadAccount),
+	)
+	server.router.GET(
+		"/accounts/:id",
+		server.getAccount,
+		auth,
+		permissionMiddleware(authz.ActionReadAccount),
+	)
+	server.router.POST(
+		"/accounts",
+		server.createAccount,
+		auth,
+		permissionMiddleware(authz.ActionWriteAccount),
+	)
+	server.router.POST(
+		"/transfers",
+		server.createTransfer,
+		auth,
+		permissionMiddleware(authz.ActionCreateTransfer),
+	)
+	server.router.POST(
+		"/accounts/:id/webhooks",
+		server.createWebhook,
+		auth,
+		permissionMiddleware(authz.ActionManageWebhooks),
+	)
+	server.router.GET(
+		"/accounts/:id/webhooks",
+		server.listWebhooks,
+		auth,
+		permissionMiddleware(authz.ActionManageWebhooks),
+	)
+	server.router.DELETE(
+		"/webhooks/:id",
+		server.deleteWebhook,
+		auth,
+		permissionMiddleware(authz.ActionManageWebhooks),
+	)
+	server.router.POST(
+		"/webhooks/:id/enable",
+		server.enableWebhook,
+		auth,
+		permissionMiddleware(authz.ActionManageWebhooks),
+	)
+	server.router.GET(
+		"/webhooks/:id/deliveries",
+		server.listWebhookDeliveries,
+		auth,
+		permissionMiddleware(authz.ActionManageWebhooks),
+	)
+	server.router.POST(
+		"/webhooks/:id/deliveries/:delivery_id/redeliver",
+		server.redeliverWebhook,
+		auth,
+		permissionMiddleware(authz.ActionManageWebhooks),
+	)
+	server.router.GET(
+		"/accounts/:id/low-balance-alert",
+		server.getLowBalanceAlert,
+		auth,
+		permissionMiddleware(authz.ActionReadAccount),
+	)
+	server.router.PUT(
+		"/accounts/:id/low-balance-alert",
+		server.setLowBalanceAlert,
+		auth,
+		permissionMiddleware(authz.ActionWriteAccount),
+	)
+	server.router.DELETE(
+		"/accounts/:id/low-balance-alert",
+		server.deleteLowBalanceAlert,
+		auth,
+		permissionMiddleware(authz.ActionWriteAccount),
+	)
+	server.router.GET(
+		"/notification-preferences",
+		server.listNotificationPreferences,
+		auth,
+		permissionMiddleware(authz.ActionReadUser),
+	)
+	server.router.PUT(
+		"/notification-preferences",
+		server.updateNotificationPreference,
+		auth,
+		permissionMiddleware(authz.ActionWriteUser),
+	)
+	server.router.POST(
+		"/users",
+		server.createUser,
+		auth,
+		permissionMiddleware(authz.ActionManageUsers),
+	)
+	server.router.GET(
+		"/audit-events",
+		server.listAuditEvents,
+		auth,
+		permissionMiddleware(authz.ActionReadAuditEvents),
+	)
+	server.router.GET(
+		"/audit-events/verify",
+		server.verifyAuditEvents,
+		auth,
+		permissionMiddleware(authz.ActionReadAuditEvents),
+	)
+	server.router.GET(
+		"/admin/queues",
+		server.listQueues,
+		auth,
+		permissionMiddleware(authz.ActionManageTasks),
+	)
+	server.router.GET(
+		"/admin/queues/:queue/tasks",
+		server.listTasks,
+		auth,
+		permissionMiddleware(authz.ActionManageTasks),
+	)
+	server.router.POST(
+		"/admin/queues/:queue/tasks/run",
+		server.runAllTasks,
+		auth,
+		permissionMiddleware(authz.ActionManageTasks),
+	)
+	server.router.DELETE(
+		"/admin/queues/:queue/tasks",
+		server.deleteAllTasks,
+		auth,
+		permissionMiddleware(authz.ActionManageTasks),
+	)
+	server.router.GET(
+		"/admin/queues/:queue/tasks/:id",
+		server.getTask,
+		auth,
+		permissionMiddleware(authz.ActionManageTasks),
+	)
+	server.router.POST(
+		"/admin/queues/:queue/tasks/:id/run",
+		server.runTask,
+		auth,
+		permissionMiddleware(authz.ActionManageTasks),
+	)
+	server.router.DELETE(
+		"/admin/queues/:queue/tasks/:id",
+		server.deleteTask,
+		auth,
+		permissionMiddleware(authz.ActionManageTasks),
+	)
+	server.router.GET(
+		"/admin/schedules",
+		server.listSchedules,
+		auth,
+		permissionMiddleware(authz.ActionManageTasks),
+	)
+	server.router.POST("/api-keys", server.createAPIKey, auth, sessionMiddleware)
+	server.router.DELETE("/api-keys/:id", server.revokeAPIKey, auth, sessionMiddleware)
+	server.router.POST("/oauth/clients", server.registerOauthClient, auth, sessionMiddleware)
+	server.router.POST("/oauth/authorize", server.authorizeOauthClient, auth, sessionMiddleware)
+	server.router.POST("/oauth/token", server.issueOauthToken)
+	server.router.GET("/oauth/consents", server.listOauthConsents, auth, sessionMiddleware)
+	server.router.DELETE(
+		"/oauth/consents/:client_id",
+		server.revokeOauthConsent,
+		auth,
+		sessionMiddleware,
+	)
+	server.router.GET("/sessions/report", server.getReportedSession)
+	server.router.POST("/sessions/report", server.reportSession)
+	server.router.POST("/signout", server.logoutUser)
 	server.router.POST("/token/refresh", server.renewAccessToken)
+	server.router.GET("/.well-known/paseto-keys", server.listTokenKeys)
 }
@@ -0,0 +1,160 @@
+package rest
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
+)
+
+type (
+	reportLinkRequest struct {
+		Secret    string    `query:"secret"     validate:"required"`
+		SessionID uuid.UUID `query:"session_id" validate:"required"`
+	}
+	reportSessionRequest struct {
+		Secret    string    `json:"secret"     validate:"required"`
+		SessionID uuid.UUID `json:"session_id" validate:"required"`
+	}
+	reportedSessionResponse struct {
+		CreatedAt time.Time `json:"created_at"`
+		UserAgent string    `json:"user_agent"`
+		ClientIP  string    `json:"client_ip"`
+		SessionID uuid.UUID `json:"session_id"`
+		IsBlocked bool      `json:"is_blocked"`
+	}
+)
+
+// getReportedSession serves the "this wasn't me" link of the new sign-in
+// alert. It only shows the session: mail scanners follow those links, so
+// blocking it takes a POST.
+func (server *Server) getReportedSession(ectx echo.Context) error {
+	req := &reportLinkRequest{}
+
+	if err := ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ectx.Validate(req); err != nil {
+		return err
+	}
+
+	session, err := server.findReportedSession(ectx.Request().Context(), req.SessionID, req.Secret)
+	if err != nil {
+		return err
+	}
+
+	return ectx.JSON(http.StatusOK, reportedSessionResponse{
+		SessionID: req.SessionID,
+		UserAgent: session.UserAgent,
+		ClientIP:  session.ClientIp,
+		CreatedAt: session.CreatedAt.Time,
+		IsBlocked: session.IsBlocked,
+	})
+}
+
+// reportSession blocks the session reported through the new sign-in alert,
+// and every session rotated from the same login.
+func (server *Server) reportSession(ectx echo.Context) (err error) {
+	var (
+		session db.Session
+		req     = &reportSessionRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	ctx := ectx.Request().Context()
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    session.Username,
+			Action:   audit.ActionReportSession,
+			Resource: "sessions/" + req.SessionID.String(),
+		}, err)
+	}()
+
+	if session, err = server.findReportedSession(ctx, req.SessionID, req.Secret); err != nil {
+		return err
+	}
+
+	if err = server.revokeSessionFamily(ctx, session.FamilyID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
+
+// findReportedSession finds the session of a report link, checking its
+// secret.
+func (server *Server) findReportedSession(
+	ctx context.Context,
+	id uuid.UUID,
+	secret string,
+) (session db.Session, err error) {
+	if session, err = server.store.GetSession(ctx, pgtype.UUID{Bytes: id, Valid: true}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return session, echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+
+		return session, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	hashedSecret := worker.HashReportSecret(secret)
+
+	if !session.ReportSecretHash.Valid ||
+		subtle.ConstantTimeCompare([]byte(hashedSecret), []byte(session.ReportSecretHash.String)) != 1 {
+		return session, echo.NewHTTPError(http.StatusForbidden, errors.New("Invalid report link"))
+	}
+
+	return session, nil
+}
+
+// notifyNewDevice records the session's device and alerts the user when it's
+// a user agent and IP never seen for them. An account's first device isn't
+// reported.
+func (server *Server) notifyNewDevice(ctx context.Context, session db.Session) {
+	device, err := server.store.RecordLoginDevice(ctx, db.RecordLoginDeviceParams{
+		Username:  session.Username,
+		UserAgent: session.UserAgent,
+		ClientIp:  session.ClientIp,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("username", session.Username).Msg("failed to record the login device")
+		return
+	}
+
+	if device.KnownDevice || !device.HasDevices {
+		return
+	}
+
+	if err = worker.SendLoginAlert.Enqueue(
+		ctx,
+		server.taskDistributor,
+		&worker.PayloadSendLoginAlert{
+			SessionID: session.ID.Bytes,
+			Username:  session.Username,
+			UserAgent: session.UserAgent,
+			ClientIP:  session.ClientIp,
+			CreatedAt: session.CreatedAt.Time,
+		},
+	); err != nil {
+		log.Error().Err(err).Str("username", session.Username).Msg("failed to notify the login")
+	}
+}
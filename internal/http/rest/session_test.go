@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
+)
+
+func TestReportSessionAPI(t *testing.T) {
+	sessionID := uuid.New()
+	session := db.Session{
+		ID:               pgtype.UUID{Bytes: sessionID, Valid: true},
+		FamilyID:         pgtype.UUID{Bytes: sessionID, Valid: true},
+		Username:         "someone",
+		ReportSecretHash: pgtype.Text{String: worker.HashReportSecret("secret"), Valid: true},
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		secret     string
+		buildStubs func(store *mocks.Store)
+		code       int
+	}{
+		{
+			// Mail scanners follow the link, so showing it must not block.
+			name:       "Show",
+			method:     http.MethodGet,
+			secret:     "secret",
+			buildStubs: func(_ *mocks.Store) {},
+			code:       http.StatusOK,
+		},
+		{
+			name:   "Report",
+			method: http.MethodPost,
+			secret: "secret",
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					BlockSessionFamily(mock.AnythingOfType("context.todoCtx"), session.FamilyID).
+					Once().
+					Return([]db.BlockSessionFamilyRow{}, nil)
+			},
+			code: http.StatusNoContent,
+		},
+		{
+			name:       "ShowWrongSecret",
+			method:     http.MethodGet,
+			secret:     "guess",
+			buildStubs: func(_ *mocks.Store) {},
+			code:       http.StatusForbidden,
+		},
+		{
+			name:       "ReportWrongSecret",
+			method:     http.MethodPost,
+			secret:     "guess",
+			buildStubs: func(_ *mocks.Store) {},
+			code:       http.StatusForbidden,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			expectAuditEvents(store)
+			store.
+				EXPECT().
+				GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+				Once().
+				Return(session, nil)
+			tc.buildStubs(store)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			var req *http.Request
+
+			if tc.method == http.MethodGet {
+				query := url.Values{"session_id": {sessionID.String()}, "secret": {tc.secret}}
+				req, err = http.NewRequestWithContext(
+					context.TODO(),
+					http.MethodGet,
+					"/sessions/report?"+query.Encode(),
+					nil,
+				)
+				require.NoError(t, err)
+			} else {
+				data, err := json.Marshal(echo.Map{"session_id": sessionID, "secret": tc.secret})
+				require.NoError(t, err)
+				req, err = http.NewRequestWithContext(
+					context.TODO(),
+					http.MethodPost,
+					"/sessions/report",
+					bytes.NewReader(data),
+				)
+				require.NoError(t, err)
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, req)
+			require.Equal(t, tc.code, rec.Code)
+		})
+	}
+}
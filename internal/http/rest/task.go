@@ -0,0 +1,207 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
+)
+
+type (
+	listTasksRequest struct {
+		Queue    string `param:"queue"     validate:"required"`
+		State    string `query:"state"     validate:"required"`
+		PageID   int    `query:"page_id"   validate:"required,min=1"`
+		PageSize int    `query:"page_size" validate:"required,min=5,max=100"`
+	}
+	taskRequest struct {
+		Queue string `param:"queue" validate:"required"`
+		ID    string `param:"id"    validate:"required"`
+	}
+	allTasksRequest struct {
+		Queue string `param:"queue" validate:"required"`
+		State string `query:"state" validate:"required,oneof=retry archived"`
+	}
+	allTasksResponse struct {
+		Count int `json:"count"`
+	}
+)
+
+func (server *Server) listQueues(ectx echo.Context) error {
+	queues, err := server.taskAdmin.Queues()
+	if err != nil {
+		return taskAdminError(err)
+	}
+
+	return ectx.JSON(http.StatusOK, queues)
+}
+
+func (server *Server) listSchedules(ectx echo.Context) error {
+	schedules, err := server.taskAdmin.Schedules(ectx.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.JSON(http.StatusOK, schedules)
+}
+
+// listTasks lists archived (dead) tasks unless given another state.
+func (server *Server) listTasks(ectx echo.Context) (err error) {
+	var tasks []worker.TaskSummary
+
+	req := &listTasksRequest{
+		State:    worker.TaskStateArchived,
+		PageID:   1,
+		PageSize: 20,
+	}
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	if tasks, err = server.taskAdmin.ListTasks(req.Queue, req.State, req.PageID, req.PageSize); err != nil {
+		return taskAdminError(err)
+	}
+
+	return ectx.JSON(http.StatusOK, tasks)
+}
+
+func (server *Server) getTask(ectx echo.Context) (err error) {
+	var task worker.TaskSummary
+
+	req := &taskRequest{}
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	if task, err = server.taskAdmin.GetTask(req.Queue, req.ID); err != nil {
+		return taskAdminError(err)
+	}
+
+	return ectx.JSON(http.StatusOK, task)
+}
+
+func (server *Server) runTask(ectx echo.Context) (err error) {
+	req := &taskRequest{}
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	defer server.recordTaskAudit(ectx, audit.ActionRunTask, req.Queue, req.ID, &err)
+
+	if err = server.taskAdmin.RunTask(req.Queue, req.ID); err != nil {
+		return taskAdminError(err)
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
+
+func (server *Server) deleteTask(ectx echo.Context) (err error) {
+	req := &taskRequest{}
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	defer server.recordTaskAudit(ectx, audit.ActionDeleteTask, req.Queue, req.ID, &err)
+
+	if err = server.taskAdmin.DeleteTask(req.Queue, req.ID); err != nil {
+		return taskAdminError(err)
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
+
+func (server *Server) runAllTasks(ectx echo.Context) (err error) {
+	var count int
+
+	req := &allTasksRequest{}
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	defer server.recordTaskAudit(ectx, audit.ActionRunTask, req.Queue, "*"+req.State, &err)
+
+	if count, err = server.taskAdmin.RunAllTasks(req.Queue, req.State); err != nil {
+		return taskAdminError(err)
+	}
+
+	return ectx.JSON(http.StatusOK, allTasksResponse{Count: count})
+}
+
+func (server *Server) deleteAllTasks(ectx echo.Context) (err error) {
+	var count int
+
+	req := &allTasksRequest{}
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	defer server.recordTaskAudit(ectx, audit.ActionDeleteTask, req.Queue, "*"+req.State, &err)
+
+	if count, err = server.taskAdmin.DeleteAllTasks(req.Queue, req.State); err != nil {
+		return taskAdminError(err)
+	}
+
+	return ectx.JSON(http.StatusOK, allTasksResponse{Count: count})
+}
+
+// recordTaskAudit records an admin's change to a task, or to all the tasks
+// in a state when id is "*<state>".
+func (server *Server) recordTaskAudit(ectx echo.Context, action, queue, id string, err *error) {
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	server.recordAudit(ectx, db.AppendAuditEventTxParams{
+		Actor:    authPayload.Username,
+		Action:   action,
+		Resource: fmt.Sprintf("queues/%s/tasks/%s", queue, id),
+	}, *err)
+}
+
+func taskAdminError(err error) error {
+	switch {
+	case errors.Is(err, worker.ERR_UNKNOWN_QUEUE), errors.Is(err, worker.ERR_INVALID_TASK_STATE):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, asynq.ErrTaskNotFound), errors.Is(err, asynq.ErrQueueNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, worker.ERR_TASK_STATE_CONFLICT):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
+)
+
+type taskInspector struct {
+	worker.TaskInspector
+	ran []string
+}
+
+func (inspector *taskInspector) GetQueueInfo(queue string) (*asynq.QueueInfo, error) {
+	return &asynq.QueueInfo{Queue: queue, Archived: 1}, nil
+}
+
+func (inspector *taskInspector) GetTaskInfo(_, id string) (*asynq.TaskInfo, error) {
+	switch id {
+	case "dead":
+		return &asynq.TaskInfo{ID: id, State: asynq.TaskStateArchived}, nil
+	case "busy":
+		return &asynq.TaskInfo{ID: id, State: asynq.TaskStateActive}, nil
+	}
+
+	return nil, asynq.ErrTaskNotFound
+}
+
+func (inspector *taskInspector) RunTask(_, id string) error {
+	inspector.ran = append(inspector.ran, id)
+	return nil
+}
+
+func TestTaskAdminAPI(t *testing.T) {
+	testCases := []struct {
+		name         string
+		role         string
+		method       string
+		url          string
+		expectedCode int
+		expectedRuns []string
+	}{
+		{
+			name:         "ListQueues",
+			role:         authz.RoleAdmin,
+			method:       http.MethodGet,
+			url:          "/admin/queues",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "NotAdmin",
+			role:         authz.RoleBanker,
+			method:       http.MethodGet,
+			url:          "/admin/queues",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "RunArchivedTask",
+			role:         authz.RoleAdmin,
+			method:       http.MethodPost,
+			url:          "/admin/queues/critical/tasks/dead/run",
+			expectedCode: http.StatusNoContent,
+			expectedRuns: []string{"dead"},
+		},
+		{
+			name:         "RunActiveTask",
+			role:         authz.RoleAdmin,
+			method:       http.MethodPost,
+			url:          "/admin/queues/critical/tasks/busy/run",
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "TaskNotFound",
+			role:         authz.RoleAdmin,
+			method:       http.MethodGet,
+			url:          "/admin/queues/critical/tasks/missing",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "UnknownQueue",
+			role:         authz.RoleAdmin,
+			method:       http.MethodGet,
+			url:          "/admin/queues/low/tasks",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			store.
+				EXPECT().
+				AppendAuditEventTx(
+					mock.Anything,
+					mock.MatchedBy(func(arg db.AppendAuditEventTxParams) bool {
+						return arg.Action == audit.ActionRunTask && arg.Actor == "root"
+					}),
+				).
+				Maybe().
+				Return(db.AppendAuditEventTxResult{}, nil)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			inspector := &taskInspector{}
+			server.taskAdmin = worker.NewTaskAdmin(inspector, nil)
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(context.TODO(), tc.method, tc.url, nil)
+			require.NoError(t, err)
+			addAuthorization(t, req, server.tokenMaker, AUTH_TYPE_BEARER, "root", tc.role, time.Minute)
+			server.router.ServeHTTP(rec, req)
+			require.Equal(t, tc.expectedCode, rec.Code)
+			require.Equal(t, tc.expectedRuns, inspector.ran)
+		})
+	}
+}
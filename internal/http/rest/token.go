@@ -1,14 +1,17 @@
 package rest
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
 
+	"github.com/dharmavagabond/simple-bank/internal/audit"
 	"github.com/dharmavagabond/simple-bank/internal/config"
 	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
 	"github.com/dharmavagabond/simple-bank/internal/token"
@@ -18,17 +21,35 @@ type (
 	renewAccessTokenRequest struct {
 		RefreshToken string `json:"refresh_token" validate:"required"`
 	}
+	logoutUserRequest struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
 	renewAccessTokenResponse struct {
-		AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
-		AccessToken          string    `json:"access_token"`
+		AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+		RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+		AccessToken           string    `json:"access_token"`
+		RefreshToken          string    `json:"refresh_token"`
+		SessionID             uuid.UUID `json:"session_id"`
+	}
+	tokenKey struct {
+		KID       string `json:"kid"`
+		Version   string `json:"version"`
+		PublicKey string `json:"public_key"`
+		Status    string `json:"status"`
+	}
+	listTokenKeysResponse struct {
+		Keys []tokenKey `json:"keys"`
 	}
 )
 
 func (server *Server) renewAccessToken(ectx echo.Context) (err error) {
 	var (
 		session             db.Session
+		user                db.User
+		txResult            db.RotateSessionTxResult
 		accessToken         string
 		accessTokenPayload  *token.Payload
+		refreshToken        string
 		refreshTokenPayload *token.Payload
 		req                 = &renewAccessTokenRequest{}
 	)
@@ -45,9 +66,18 @@ func (server *Server) renewAccessToken(ectx echo.Context) (err error) {
 		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 	}
 
+	ctx := ectx.Request().Context()
 	id := pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true}
 
-	if session, err = server.store.GetSession(ectx.Request().Context(), id); err != nil {
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    refreshTokenPayload.Username,
+			Action:   audit.ActionRenewToken,
+			Resource: "sessions/" + refreshTokenPayload.ID.String(),
+		}, err)
+	}()
+
+	if session, err = server.store.GetSession(ctx, id); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return echo.NewHTTPError(http.StatusNotFound, err.Error())
 		}
@@ -59,10 +89,18 @@ func (server *Server) renewAccessToken(ectx echo.Context) (err error) {
 		return echo.NewHTTPError(http.StatusUnauthorized, errors.New("Missmatched session token"))
 	}
 
+	if session.RotatedAt.Valid {
+		return server.blockSessionFamily(ctx, session)
+	}
+
 	if session.IsBlocked {
 		return echo.NewHTTPError(http.StatusUnauthorized, errors.New("Blocked session"))
 	}
 
+	if session.ClientID.Valid {
+		return echo.NewHTTPError(http.StatusUnauthorized, errors.New("OAuth sessions are renewed at /oauth/token"))
+	}
+
 	if session.Username != refreshTokenPayload.Username {
 		return echo.NewHTTPError(http.StatusUnauthorized, errors.New("Incorrect session user"))
 	}
@@ -71,16 +109,165 @@ func (server *Server) renewAccessToken(ectx echo.Context) (err error) {
 		return echo.NewHTTPError(http.StatusUnauthorized, errors.New("Expired session"))
 	}
 
+	// The role is read again, so a change of role applies from the next
+	// renewal rather than when the session expires.
+	if user, err = server.store.GetUser(ctx, session.Username); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusUnauthorized, errors.New("User not found"))
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
 	if accessToken, accessTokenPayload, err = server.tokenMaker.CreateToken(
-		refreshTokenPayload.Username,
-		config.App.RefreshTokenDuration,
+		user.Username,
+		user.Role,
+		config.App.AccessTokenDuration,
 	); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	if refreshToken, refreshTokenPayload, err = server.tokenMaker.CreateRefreshToken(
+		user.Username,
+		user.Role,
+		nil,
+		time.Until(session.ExpiresAt.Time),
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if txResult, err = server.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+		RotatedSessionID: session.ID,
+		CreateSessionParams: db.CreateSessionParams{
+			ID:            pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true},
+			Username:      session.Username,
+			RefreshToken:  refreshToken,
+			UserAgent:     ectx.Request().UserAgent(),
+			ClientIp:      ectx.RealIP(),
+			ExpiresAt:     session.ExpiresAt,
+			AccessTokenID: pgtype.UUID{Bytes: accessTokenPayload.ID, Valid: true},
+			AccessTokenExpiresAt: pgtype.Timestamptz{
+				Time:  accessTokenPayload.ExpiredAt,
+				Valid: true,
+			},
+		},
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return server.blockSessionFamily(ctx, session)
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
 	res := renewAccessTokenResponse{
-		AccessToken:          accessToken,
-		AccessTokenExpiresAt: accessTokenPayload.ExpiredAt,
+		SessionID:             txResult.Session.ID.Bytes,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessTokenPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshTokenPayload.ExpiredAt,
+	}
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+func (server *Server) logoutUser(ectx echo.Context) (err error) {
+	var (
+		session             db.Session
+		refreshTokenPayload *token.Payload
+		req                 = &logoutUserRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	if refreshTokenPayload, err = server.tokenMaker.VerifyToken(req.RefreshToken); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+
+	ctx := ectx.Request().Context()
+	id := pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true}
+
+	if session, err = server.store.GetSession(ctx, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if session.RefreshToken != req.RefreshToken {
+		return echo.NewHTTPError(http.StatusUnauthorized, errors.New("Missmatched session token"))
+	}
+
+	if err = server.revokeSessionFamily(ctx, session.FamilyID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if err = server.revocations.Revoke(ctx, refreshTokenPayload.ID, refreshTokenPayload.ExpiredAt); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
+
+func (server *Server) blockSessionFamily(ctx context.Context, session db.Session) error {
+	if err := server.revokeSessionFamily(ctx, session.FamilyID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return echo.NewHTTPError(http.StatusUnauthorized, errors.New("Refresh token reuse detected"))
+}
+
+// revokeSessionFamily blocks every session in the family and revokes its
+// refresh and access tokens, so they stop working before they expire.
+func (server *Server) revokeSessionFamily(ctx context.Context, familyID pgtype.UUID) error {
+	sessions, err := server.store.BlockSessionFamily(ctx, familyID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if err = server.revokeToken(ctx, session.ID, session.ExpiresAt); err != nil {
+			return err
+		}
+
+		if err = server.revokeToken(ctx, session.AccessTokenID, session.AccessTokenExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (server *Server) revokeToken(
+	ctx context.Context,
+	id pgtype.UUID,
+	expiresAt pgtype.Timestamptz,
+) error {
+	if !id.Valid {
+		return nil
+	}
+
+	return server.revocations.Revoke(ctx, id.Bytes, expiresAt.Time)
+}
+
+func (server *Server) listTokenKeys(ectx echo.Context) error {
+	res := listTokenKeysResponse{Keys: []tokenKey{}}
+
+	if publisher, ok := server.tokenMaker.(token.KeyPublisher); ok {
+		for _, key := range publisher.PublicKeys() {
+			res.Keys = append(res.Keys, tokenKey{
+				KID:       key.ID,
+				Version:   key.Version,
+				PublicKey: key.PublicKey,
+				Status:    key.Status,
+			})
+		}
 	}
 
 	return ectx.JSON(http.StatusOK, res)
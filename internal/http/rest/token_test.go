@@ -0,0 +1,418 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+)
+
+func TestRenewAccessTokenAPI(t *testing.T) {
+	user, _ := randomUser()
+	user.Role = authz.RoleAdmin
+	newSessionID := pgtype.UUID{Bytes: uuid.New(), Valid: true}
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mocks.Store, session db.Session)
+		checkResponse func(t *testing.T, rec *httptest.ResponseRecorder, session db.Session)
+		expectedRole  string
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mocks.Store, session db.Session) {
+				store.
+					EXPECT().
+					GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+					Once().
+					Return(session, nil)
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(user, nil)
+				store.
+					EXPECT().
+					RotateSessionTx(
+						mock.AnythingOfType("context.todoCtx"),
+						mock.MatchedBy(func(arg db.RotateSessionTxParams) bool {
+							return arg.RotatedSessionID == session.ID &&
+								arg.CreateSessionParams.ID != session.ID &&
+								arg.CreateSessionParams.RefreshToken != session.RefreshToken &&
+								arg.CreateSessionParams.ExpiresAt == session.ExpiresAt
+						}),
+					).
+					Once().
+					Return(db.RotateSessionTxResult{Session: db.Session{ID: newSessionID}}, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, session db.Session) {
+				t.Helper()
+				require.Equal(t, http.StatusOK, rec.Code)
+
+				data, err := io.ReadAll(rec.Body)
+				require.NoError(t, err)
+
+				var res renewAccessTokenResponse
+				require.NoError(t, json.Unmarshal(data, &res))
+				require.NotEmpty(t, res.AccessToken)
+				require.NotEmpty(t, res.RefreshToken)
+				require.NotEqual(t, session.RefreshToken, res.RefreshToken)
+				require.Equal(t, newSessionID.Bytes, [16]byte(res.SessionID))
+				require.WithinDuration(t, session.ExpiresAt.Time, res.RefreshTokenExpiresAt, time.Second)
+				require.True(t, res.AccessTokenExpiresAt.Before(res.RefreshTokenExpiresAt))
+			},
+		},
+		{
+			name: "ReusedRefreshToken",
+			buildStubs: func(store *mocks.Store, session db.Session) {
+				session.RotatedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+				store.
+					EXPECT().
+					GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+					Once().
+					Return(session, nil)
+				store.
+					EXPECT().
+					BlockSessionFamily(mock.AnythingOfType("context.todoCtx"), session.FamilyID).
+					Once().
+					Return(nil, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ db.Session) {
+				t.Helper()
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+			},
+		},
+		{
+			name: "ConcurrentRotation",
+			buildStubs: func(store *mocks.Store, session db.Session) {
+				store.
+					EXPECT().
+					GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+					Once().
+					Return(session, nil)
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(user, nil)
+				store.
+					EXPECT().
+					RotateSessionTx(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Once().
+					Return(db.RotateSessionTxResult{}, pgx.ErrNoRows)
+				store.
+					EXPECT().
+					BlockSessionFamily(mock.AnythingOfType("context.todoCtx"), session.FamilyID).
+					Once().
+					Return(nil, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ db.Session) {
+				t.Helper()
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+			},
+		},
+		{
+			name: "BlockedSession",
+			buildStubs: func(store *mocks.Store, session db.Session) {
+				session.IsBlocked = true
+				store.
+					EXPECT().
+					GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+					Once().
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ db.Session) {
+				t.Helper()
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+			},
+		},
+		{
+			name: "RoleChanged",
+			buildStubs: func(store *mocks.Store, session db.Session) {
+				demoted := user
+				demoted.Role = authz.RoleDepositor
+				store.
+					EXPECT().
+					GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+					Once().
+					Return(session, nil)
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(demoted, nil)
+				store.
+					EXPECT().
+					RotateSessionTx(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Once().
+					Return(db.RotateSessionTxResult{Session: db.Session{ID: newSessionID}}, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ db.Session) {
+				t.Helper()
+				require.Equal(t, http.StatusOK, rec.Code)
+			},
+			expectedRole: authz.RoleDepositor,
+		},
+		{
+			name: "UserNotFound",
+			buildStubs: func(store *mocks.Store, session db.Session) {
+				store.
+					EXPECT().
+					GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+					Once().
+					Return(session, nil)
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(db.User{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ db.Session) {
+				t.Helper()
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+			},
+		},
+		{
+			name: "SessionNotFound",
+			buildStubs: func(store *mocks.Store, session db.Session) {
+				store.
+					EXPECT().
+					GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+					Once().
+					Return(db.Session{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ db.Session) {
+				t.Helper()
+				require.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			expectAuditEvents(store)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			refreshToken, payload, err := server.tokenMaker.CreateRefreshToken(user.Username, user.Role, nil, time.Hour)
+			require.NoError(t, err)
+
+			id := pgtype.UUID{Bytes: payload.ID, Valid: true}
+			session := db.Session{
+				ID:           id,
+				FamilyID:     id,
+				Username:     user.Username,
+				RefreshToken: refreshToken,
+				ExpiresAt:    pgtype.Timestamptz{Time: payload.ExpiredAt, Valid: true},
+			}
+			tc.buildStubs(store, session)
+
+			rec := httptest.NewRecorder()
+			data, err := json.Marshal(echo.Map{"refresh_token": refreshToken})
+			require.NoError(t, err)
+			req, err := http.NewRequestWithContext(
+				context.TODO(),
+				http.MethodPost,
+				"/token/refresh",
+				bytes.NewReader(data),
+			)
+			require.NoError(t, err)
+			req.Header.Add("Content-Type", "application/json")
+			server.router.ServeHTTP(rec, req)
+			tc.checkResponse(t, rec, session)
+
+			if len(tc.expectedRole) > 0 {
+				var res renewAccessTokenResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+				accessPayload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, tc.expectedRole, accessPayload.Role)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenAsBearer(t *testing.T) {
+	user, _ := randomUser()
+	store := mocks.NewStore(t)
+	expectAuditEvents(store)
+	server, err := NewServer(store)
+	require.NoError(t, err)
+
+	getAccount := func(accessToken string) int {
+		rec := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, "/accounts/1", nil)
+		require.NoError(t, err)
+		req.Header.Set(AUTH_HEADER, AUTH_TYPE_BEARER+" "+accessToken)
+		server.router.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	refreshToken, _, err := server.tokenMaker.CreateRefreshToken(user.Username, user.Role, nil, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, getAccount(refreshToken))
+
+	// Refresh tokens issued without a type are revoked with their family.
+	legacyToken, payload, err := server.tokenMaker.CreateToken(user.Username, user.Role, time.Hour)
+	require.NoError(t, err)
+
+	id := pgtype.UUID{Bytes: payload.ID, Valid: true}
+	session := db.Session{
+		ID:           id,
+		FamilyID:     id,
+		Username:     user.Username,
+		RefreshToken: legacyToken,
+		ExpiresAt:    pgtype.Timestamptz{Time: payload.ExpiredAt, Valid: true},
+		RotatedAt:    pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+	store.
+		EXPECT().
+		GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+		Once().
+		Return(session, nil)
+	store.
+		EXPECT().
+		BlockSessionFamily(mock.AnythingOfType("context.todoCtx"), session.FamilyID).
+		Once().
+		Return([]db.BlockSessionFamilyRow{{ID: session.ID, ExpiresAt: session.ExpiresAt}}, nil)
+
+	data, err := json.Marshal(echo.Map{"refresh_token": legacyToken})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, "/token/refresh", bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/json")
+	server.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, http.StatusUnauthorized, getAccount(legacyToken))
+}
+
+func TestLogoutUserAPI(t *testing.T) {
+	user, _ := randomUser()
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mocks.Store, session db.Session)
+		checkResponse func(t *testing.T, rec *httptest.ResponseRecorder, server *Server, accessToken string)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mocks.Store, session db.Session) {
+				store.
+					EXPECT().
+					GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+					Once().
+					Return(session, nil)
+				store.
+					EXPECT().
+					BlockSessionFamily(mock.AnythingOfType("context.todoCtx"), session.FamilyID).
+					Once().
+					Return([]db.BlockSessionFamilyRow{
+						{
+							AccessTokenID:        session.AccessTokenID,
+							AccessTokenExpiresAt: session.AccessTokenExpiresAt,
+						},
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, server *Server, accessToken string) {
+				t.Helper()
+				require.Equal(t, http.StatusNoContent, rec.Code)
+
+				_, err := server.tokenMaker.VerifyToken(accessToken)
+				require.ErrorIs(t, err, token.ERR_REVOKED_TOKEN)
+			},
+		},
+		{
+			name: "MismatchedToken",
+			buildStubs: func(store *mocks.Store, session db.Session) {
+				session.RefreshToken = "other"
+				store.
+					EXPECT().
+					GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+					Once().
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, server *Server, accessToken string) {
+				t.Helper()
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+				_, err := server.tokenMaker.VerifyToken(accessToken)
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "SessionNotFound",
+			buildStubs: func(store *mocks.Store, session db.Session) {
+				store.
+					EXPECT().
+					GetSession(mock.AnythingOfType("context.todoCtx"), session.ID).
+					Once().
+					Return(db.Session{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ *Server, _ string) {
+				t.Helper()
+				require.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, time.Minute)
+			require.NoError(t, err)
+
+			refreshToken, refreshPayload, err := server.tokenMaker.CreateRefreshToken(user.Username, user.Role, nil, time.Hour)
+			require.NoError(t, err)
+
+			id := pgtype.UUID{Bytes: refreshPayload.ID, Valid: true}
+			session := db.Session{
+				ID:                   id,
+				FamilyID:             id,
+				Username:             user.Username,
+				RefreshToken:         refreshToken,
+				ExpiresAt:            pgtype.Timestamptz{Time: refreshPayload.ExpiredAt, Valid: true},
+				AccessTokenID:        pgtype.UUID{Bytes: accessPayload.ID, Valid: true},
+				AccessTokenExpiresAt: pgtype.Timestamptz{Time: accessPayload.ExpiredAt, Valid: true},
+			}
+			tc.buildStubs(store, session)
+
+			rec := httptest.NewRecorder()
+			data, err := json.Marshal(echo.Map{"refresh_token": refreshToken})
+			require.NoError(t, err)
+			req, err := http.NewRequestWithContext(
+				context.TODO(),
+				http.MethodPost,
+				"/signout",
+				bytes.NewReader(data),
+			)
+			require.NoError(t, err)
+			req.Header.Add("Content-Type", "application/json")
+			server.router.ServeHTTP(rec, req)
+			tc.checkResponse(t, rec, server, accessToken)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/totp"
+)
+
+type (
+	loginUserTOTPRequest struct {
+		ChallengeToken string `json:"challenge_token" validate:"required"`
+		Code           string `json:"code"            validate:"required"`
+	}
+	mfaChallengeResponse struct {
+		ChallengeExpiresAt time.Time `json:"challenge_expires_at"`
+		ChallengeToken     string    `json:"challenge_token"`
+		MFARequired        bool      `json:"mfa_required"`
+	}
+)
+
+func (server *Server) loginUserTOTP(ectx echo.Context) (err error) {
+	var (
+		challenge db.MfaChallenge
+		user      db.User
+		ok        bool
+		req       = &loginUserTOTPRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	ctx := ectx.Request().Context()
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    challenge.Username,
+			Action:   audit.ActionLogin,
+			Resource: "users/" + challenge.Username,
+			Detail:   "totp",
+		}, err)
+	}()
+
+	if challenge, err = server.store.ConsumeMFAChallenge(
+		ctx,
+		totp.HashChallenge(req.ChallengeToken),
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusUnauthorized, errors.New("Invalid or expired challenge"))
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if user, err = server.store.GetUser(ctx, challenge.Username); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if ok, err = server.verifySecondFactor(ctx, user, req.Code); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	} else if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, errors.New("Invalid TOTP code"))
+	}
+
+	return server.createUserSession(ectx, user)
+}
+
+func (server *Server) createMFAChallenge(ectx echo.Context, user db.User) (err error) {
+	var challenge db.MfaChallenge
+
+	challengeToken, hashedChallengeToken := totp.NewChallenge()
+
+	if challenge, err = server.store.CreateMFAChallenge(
+		ectx.Request().Context(),
+		db.CreateMFAChallengeParams{
+			HashedToken: hashedChallengeToken,
+			Username:    user.Username,
+			ExpiresAt: pgtype.Timestamptz{
+				Time:  time.Now().Add(config.App.MFAChallengeDuration),
+				Valid: true,
+			},
+		},
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := mfaChallengeResponse{
+		MFARequired:        true,
+		ChallengeToken:     challengeToken,
+		ChallengeExpiresAt: challenge.ExpiresAt.Time,
+	}
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+func (server *Server) verifySecondFactor(
+	ctx context.Context,
+	user db.User,
+	code string,
+) (bool, error) {
+	if !user.TotpEnabledAt.Valid {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(code, user.TotpSecret.String, time.Now()); ok {
+		// Each time step is accepted once, so an intercepted code can't be
+		// replayed while it's still valid.
+		if _, err := server.store.UseUserTOTPCounter(ctx, db.UseUserTOTPCounterParams{
+			Username: user.Username,
+			Counter:  step,
+		}); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if _, err := server.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+		Username:   user.Username,
+		HashedCode: totp.HashRecoveryCode(code),
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
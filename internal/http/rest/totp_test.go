@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/totp"
+)
+
+func TestVerifySecondFactor(t *testing.T) {
+	user, _ := randomUser()
+	user.TotpSecret = pgtype.Text{String: totp.GenerateSecret(), Valid: true}
+	user.TotpEnabledAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	code, err := totp.GenerateCode(user.TotpSecret.String, time.Now())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		code       string
+		buildStubs func(store *mocks.Store)
+		ok         bool
+	}{
+		{
+			name: "OK",
+			code: code,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					UseUserTOTPCounter(
+						mock.AnythingOfType("context.todoCtx"),
+						mock.MatchedBy(func(arg db.UseUserTOTPCounterParams) bool {
+							return arg.Username == user.Username && arg.Counter > 0
+						}),
+					).
+					Once().
+					Return(user, nil)
+			},
+			ok: true,
+		},
+		{
+			name: "Replayed",
+			code: code,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					UseUserTOTPCounter(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Once().
+					Return(db.User{}, pgx.ErrNoRows)
+			},
+		},
+		{
+			name: "RecoveryCode",
+			code: "abcde-12345",
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					UseRecoveryCode(mock.AnythingOfType("context.todoCtx"), db.UseRecoveryCodeParams{
+						Username:   user.Username,
+						HashedCode: totp.HashRecoveryCode("abcde-12345"),
+					}).
+					Once().
+					Return(db.RecoveryCode{Username: user.Username}, nil)
+			},
+			ok: true,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			tc.buildStubs(store)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			ok, err := server.verifySecondFactor(context.TODO(), user, tc.code)
+			require.NoError(t, err)
+			require.Equal(t, tc.ok, ok)
+		})
+	}
+}
@@ -9,8 +9,12 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	"github.com/dharmavagabond/simple-bank/internal/config"
 	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
 	"github.com/dharmavagabond/simple-bank/internal/token"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
 )
 
 type (
@@ -19,6 +23,7 @@ type (
 		FromAccountID int64  `json:"from_account_id" validate:"required,min=1"`
 		ToAccountID   int64  `json:"to_account_id"   validate:"required,min=1"`
 		Amount        int64  `json:"amount"          validate:"required,gt=0"`
+		TOTPCode      string `json:"totp_code"`
 	}
 )
 
@@ -39,17 +44,28 @@ func (server *Server) createTransfer(ectx echo.Context) (err error) {
 		return err
 	}
 
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    authPayload.Username,
+			Action:   audit.ActionCreateTransfer,
+			Resource: fmt.Sprintf("accounts/%d", req.FromAccountID),
+			Detail: fmt.Sprintf(
+				"%d %s to accounts/%d",
+				req.Amount,
+				req.Currency,
+				req.ToAccountID,
+			),
+		}, err)
+	}()
+
 	if fromAccount, err = getAccount(req.FromAccountID, server.store, ectx.Request().Context()); err != nil {
 		return err
 	}
 
-	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
-
-	if fromAccount.Owner != authPayload.Username {
-		return echo.NewHTTPError(
-			http.StatusUnauthorized,
-			errors.New("From account doesn't belong to the authenticated user"),
-		)
+	if err = authorizeOwner(ectx, authz.ActionCreateTransfer, fromAccount.Owner); err != nil {
+		return err
 	}
 
 	if ok, err = server.isSameCurrency(fromAccount, req.Currency); !ok {
@@ -60,10 +76,23 @@ func (server *Server) createTransfer(ectx echo.Context) (err error) {
 		return err
 	}
 
-	arg := db.CreateTransferParams{
-		FromAccountID: req.FromAccountID,
-		ToAccountID:   req.ToAccountID,
-		Amount:        req.Amount,
+	if err = server.requireStepUp(ectx, authPayload.Username, req); err != nil {
+		return err
+	}
+
+	arg := db.TransferTxParams{
+		CreateTransferParams: db.CreateTransferParams{
+			FromAccountID: req.FromAccountID,
+			ToAccountID:   req.ToAccountID,
+			Amount:        req.Amount,
+		},
+		AfterTransfer: func(q db.Querier, result db.TransferTxResult) error {
+			if err := worker.EnqueueTransferWebhooks(ectx.Request().Context(), q, result); err != nil {
+				return err
+			}
+
+			return worker.EnqueueTransferNotifications(ectx.Request().Context(), q, result)
+		},
 	}
 
 	if result, err = server.store.TransferTx(ectx.Request().Context(), arg); err != nil {
@@ -111,3 +140,41 @@ func (server *Server) isSameCurrency(
 
 	return
 }
+
+func (server *Server) requireStepUp(
+	ectx echo.Context,
+	username string,
+	req *transferRequest,
+) (err error) {
+	var (
+		user db.User
+		ok   bool
+	)
+
+	if config.App.StepUpTransferAmount <= 0 || req.Amount < config.App.StepUpTransferAmount {
+		return nil
+	}
+
+	if user, err = server.store.GetUser(ectx.Request().Context(), username); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if !user.TotpEnabledAt.Valid {
+		return echo.NewHTTPError(
+			http.StatusForbidden,
+			errors.New("Two-factor authentication is required for this transfer amount"),
+		)
+	}
+
+	if len(req.TOTPCode) == 0 {
+		return echo.NewHTTPError(http.StatusForbidden, errors.New("Missing TOTP code"))
+	}
+
+	if ok, err = server.verifySecondFactor(ectx.Request().Context(), user, req.TOTPCode); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	} else if !ok {
+		return echo.NewHTTPError(http.StatusForbidden, errors.New("Invalid TOTP code"))
+	}
+
+	return nil
+}
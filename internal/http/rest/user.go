@@ -1,27 +1,34 @@
 package rest
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/alexedwards/argon2id"
 	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
 
+	"github.com/dharmavagabond/simple-bank/internal/audit"
 	"github.com/dharmavagabond/simple-bank/internal/config"
 	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/lockout"
 	"github.com/dharmavagabond/simple-bank/internal/token"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
 )
 
 type (
 	createUserRequest struct {
 		Username string `json:"username"  validate:"required,alphanum"`
-		Password string `json:"password"  validate:"required,min=10"`
+		Password string `json:"password"  validate:"required"`
 		FullName string `json:"full_name" validate:"required"`
 		Email    string `json:"email"     validate:"required,email"`
 	}
@@ -31,10 +38,11 @@ type (
 		Username          string    `json:"username"`
 		FullName          string    `json:"full_name"`
 		Email             string    `json:"email"`
+		Role              string    `json:"role"`
 	}
 	loginUserRequest struct {
 		Username string `json:"username" validate:"required,alphanum"`
-		Password string `json:"password" validate:"required,min=10"`
+		Password string `json:"password" validate:"required,max=100"`
 	}
 	loginUserResponse struct {
 		User                  userResponse `json:"user"`
@@ -46,19 +54,12 @@ type (
 	}
 )
 
-var argonParams = &argon2id.Params{
-	Memory:      128 * 1024,
-	Iterations:  4,
-	Parallelism: 4,
-	SaltLength:  128,
-	KeyLength:   128,
-}
-
 func newUserResponse(user db.User) userResponse {
 	return userResponse{
 		Username:          user.Username,
 		FullName:          user.FullName,
 		Email:             user.Email,
+		Role:              user.Role,
 		PasswordChangedAt: user.PasswordChangedAt.Time,
 		CreatedAt:         user.CreatedAt.Time,
 	}
@@ -79,7 +80,21 @@ func (server *Server) createUser(ectx echo.Context) (err error) {
 		return err
 	}
 
-	if hashPassword, err = argon2id.CreateHash(req.Password, argonParams); err != nil {
+	if err = server.passwordPolicy.Validate(req.Password, req.Username, req.Email); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("password %s", err.Error()))
+	}
+
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    authPayload.Username,
+			Action:   audit.ActionCreateUser,
+			Resource: "users/" + req.Username,
+		}, err)
+	}()
+
+	if hashPassword, err = server.passwordHasher.Hash(req.Password); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
@@ -107,14 +122,11 @@ func (server *Server) createUser(ectx echo.Context) (err error) {
 
 func (server *Server) loginUser(ectx echo.Context) (err error) {
 	var (
-		session             db.Session
-		accessToken         string
-		accessTokenPayload  *token.Payload
-		refreshToken        string
-		refreshTokenPayload *token.Payload
-		user                db.User
-		ok                  bool
-		req                 = &loginUserRequest{}
+		user       db.User
+		ok         bool
+		rehash     bool
+		retryAfter time.Duration
+		req        = &loginUserRequest{}
 	)
 
 	if err = ectx.Bind(req); err != nil {
@@ -125,38 +137,137 @@ func (server *Server) loginUser(ectx echo.Context) (err error) {
 		return err
 	}
 
-	if user, err = server.store.GetUser(ectx.Request().Context(), req.Username); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	ctx := ectx.Request().Context()
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    req.Username,
+			Action:   audit.ActionLogin,
+			Resource: "users/" + req.Username,
+			Detail:   "password",
+		}, err)
+	}()
+
+	if retryAfter, err = server.loginGuard.Check(ctx, req.Username, ectx.RealIP()); err != nil {
+		if errors.Is(err, lockout.ERR_LOCKED) || errors.Is(err, lockout.ERR_TOO_MANY_ATTEMPTS) {
+			ectx.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			return echo.NewHTTPError(http.StatusTooManyRequests, "Demasiados intentos fallidos.")
 		}
 
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	if ok, err = argon2id.ComparePasswordAndHash(req.Password, user.HashedPassword); err != nil {
+	if user, err = server.store.GetUser(ctx, req.Username); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		server.passwordHasher.VerifyDummy(req.Password)
+
+		return server.failLogin(ectx, req.Username, false)
+	}
+
+	if ok, rehash, err = server.passwordHasher.Verify(req.Password, user.HashedPassword); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	} else if !ok {
-		return echo.NewHTTPError(http.StatusUnauthorized, "Contraseña incorrecta.")
+		return server.failLogin(ectx, user.Username, true)
 	}
 
-	if accessToken, accessTokenPayload, err = server.tokenMaker.CreateToken(user.Username, config.App.AccessTokenDuration); err != nil {
+	if rehash {
+		server.rehashPassword(ctx, user, req.Password)
+	}
+
+	if err = server.loginGuard.Succeed(ctx, user.Username); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	if refreshToken, refreshTokenPayload, err = server.tokenMaker.CreateToken(
-		req.Username,
+	if user.TotpEnabledAt.Valid {
+		return server.createMFAChallenge(ectx, user)
+	}
+
+	return server.createUserSession(ectx, user)
+}
+
+// rehashPassword upgrades a hash created with older parameters. Failing
+// to do it doesn't fail the login, since the old hash still works, and it's
+// skipped if the password changed in the meantime.
+func (server *Server) rehashPassword(ctx context.Context, user db.User, plain string) {
+	hash, err := server.passwordHasher.Hash(plain)
+
+	if err == nil {
+		err = server.store.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+			NewHashedPassword: hash,
+			Username:          user.Username,
+			HashedPassword:    user.HashedPassword,
+		})
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to rehash the password")
+	}
+}
+
+func (server *Server) failLogin(ectx echo.Context, username string, userExists bool) error {
+	ctx := ectx.Request().Context()
+	locked, err := server.loginGuard.Fail(ctx, username, ectx.RealIP())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if locked && userExists {
+		if err = worker.SendLockoutEmail.Enqueue(
+			ctx,
+			server.taskDistributor,
+			&worker.PayloadSendLockoutEmail{
+				Username:    username,
+				ClientIP:    ectx.RealIP(),
+				LockedUntil: time.Now().Add(config.App.LoginLockoutDuration),
+			},
+		); err != nil {
+			log.Error().Err(err).Str("username", username).Msg("failed to notify the lockout")
+		}
+	}
+
+	return echo.NewHTTPError(http.StatusUnauthorized, "Usuario o contraseña incorrectos.")
+}
+
+func (server *Server) createUserSession(ectx echo.Context, user db.User) (err error) {
+	var (
+		session             db.Session
+		accessToken         string
+		accessTokenPayload  *token.Payload
+		refreshToken        string
+		refreshTokenPayload *token.Payload
+	)
+
+	if accessToken, accessTokenPayload, err = server.tokenMaker.CreateToken(user.Username, user.Role, config.App.AccessTokenDuration); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if refreshToken, refreshTokenPayload, err = server.tokenMaker.CreateRefreshToken(
+		user.Username,
+		user.Role,
+		nil,
 		config.App.RefreshTokenDuration,
 	); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	id := pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true}
+
 	if session, err = server.store.CreateSession(ectx.Request().Context(), db.CreateSessionParams{
-		ID:           pgtype.UUID{Bytes: refreshTokenPayload.ID, Valid: true},
-		Username:     req.Username,
-		RefreshToken: refreshToken,
-		UserAgent:    ectx.Request().UserAgent(),
-		ClientIp:     ectx.RealIP(),
-		ExpiresAt:    pgtype.Timestamptz{Time: refreshTokenPayload.ExpiredAt, Valid: true},
+		ID:            id,
+		FamilyID:      id,
+		Username:      user.Username,
+		RefreshToken:  refreshToken,
+		UserAgent:     ectx.Request().UserAgent(),
+		ClientIp:      ectx.RealIP(),
+		ExpiresAt:     pgtype.Timestamptz{Time: refreshTokenPayload.ExpiredAt, Valid: true},
+		AccessTokenID: pgtype.UUID{Bytes: accessTokenPayload.ID, Valid: true},
+		AccessTokenExpiresAt: pgtype.Timestamptz{
+			Time:  accessTokenPayload.ExpiredAt,
+			Valid: true,
+		},
 	}); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
@@ -166,6 +277,8 @@ func (server *Server) loginUser(ectx echo.Context) (err error) {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	server.notifyNewDevice(ectx.Request().Context(), session)
+
 	res := loginUserResponse{
 		SessionID:             sessionID,
 		AccessToken:           accessToken,
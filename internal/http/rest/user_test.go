@@ -14,10 +14,16 @@ import (
 
 	"github.com/Pallinder/go-randomdata"
 	"github.com/alexedwards/argon2id"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
 	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/lockout"
 	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/password"
 	"github.com/dharmavagabond/simple-bank/internal/token"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
+	"github.com/hibiken/asynq"
 	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/mock"
@@ -49,6 +55,7 @@ func TestCreateUserAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					user.Username,
+					authz.RoleAdmin,
 					time.Minute,
 				)
 			},
@@ -81,6 +88,32 @@ func TestCreateUserAPI(t *testing.T) {
 				requireBodyMatchUser(t, rec.Body, user)
 			},
 		},
+		{
+			name: "Forbidden",
+			body: echo.Map{
+				"username":  user.Username,
+				"password":  password,
+				"full_name": user.FullName,
+				"email":     user.Email,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				t.Helper()
+				addAuthorization(
+					t,
+					req,
+					tokenMaker,
+					AUTH_TYPE_BEARER,
+					user.Username,
+					authz.RoleBanker,
+					time.Minute,
+				)
+			},
+			buildStubs: func(store *mocks.Store) {},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusForbidden, rec.Code)
+			},
+		},
 		{
 			name: "InternalError",
 			body: echo.Map{
@@ -97,6 +130,7 @@ func TestCreateUserAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					user.Username,
+					authz.RoleAdmin,
 					time.Minute,
 				)
 			},
@@ -128,6 +162,7 @@ func TestCreateUserAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					user.Username,
+					authz.RoleAdmin,
 					time.Minute,
 				)
 			},
@@ -159,6 +194,7 @@ func TestCreateUserAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					user.Username,
+					authz.RoleAdmin,
 					time.Minute,
 				)
 			},
@@ -189,6 +225,7 @@ func TestCreateUserAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					user.Username,
+					authz.RoleAdmin,
 					time.Minute,
 				)
 			},
@@ -219,6 +256,69 @@ func TestCreateUserAPI(t *testing.T) {
 					tokenMaker,
 					AUTH_TYPE_BEARER,
 					user.Username,
+					authz.RoleAdmin,
+					time.Minute,
+				)
+			},
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					CreateUser(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Maybe()
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			name: "SingleClassPassword",
+			body: echo.Map{
+				"username":  user.Username,
+				"password":  "onlylowercaseletters",
+				"full_name": user.FullName,
+				"email":     user.Email,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				t.Helper()
+				addAuthorization(
+					t,
+					req,
+					tokenMaker,
+					AUTH_TYPE_BEARER,
+					user.Username,
+					authz.RoleAdmin,
+					time.Minute,
+				)
+			},
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					CreateUser(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Maybe()
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			name: "PasswordContainsUsername",
+			body: echo.Map{
+				"username":  user.Username,
+				"password":  "X1-" + user.Username,
+				"full_name": user.FullName,
+				"email":     user.Email,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				t.Helper()
+				addAuthorization(
+					t,
+					req,
+					tokenMaker,
+					AUTH_TYPE_BEARER,
+					user.Username,
+					authz.RoleAdmin,
 					time.Minute,
 				)
 			},
@@ -239,6 +339,7 @@ func TestCreateUserAPI(t *testing.T) {
 		tc := testCases[i]
 		t.Run(tc.name, func(t *testing.T) {
 			store := mocks.NewStore(t)
+			expectAuditEvents(store)
 			tc.buildStubs(store)
 			server, err := NewServer(store)
 			require.NoError(t, err)
@@ -262,8 +363,293 @@ func TestCreateUserAPI(t *testing.T) {
 	}
 }
 
+type taskNotifier struct {
+	worker.TaskDistributor
+	lockouts    []*worker.PayloadSendLockoutEmail
+	loginAlerts []*worker.PayloadSendLoginAlert
+	webhooks    []*worker.PayloadDeliverWebhook
+}
+
+// Enqueue records the payloads of the tasks the tests look at.
+func (notifier *taskNotifier) Enqueue(_ context.Context, task *asynq.Task, _ ...asynq.Option) error {
+	switch task.Type() {
+	case worker.SendLockoutEmail.Type():
+		payload := &worker.PayloadSendLockoutEmail{}
+		notifier.lockouts = append(notifier.lockouts, payload)
+
+		return json.Unmarshal(task.Payload(), payload)
+	case worker.SendLoginAlert.Type():
+		payload := &worker.PayloadSendLoginAlert{}
+		notifier.loginAlerts = append(notifier.loginAlerts, payload)
+
+		return json.Unmarshal(task.Payload(), payload)
+	case worker.DeliverWebhook.Type():
+		payload := &worker.PayloadDeliverWebhook{}
+		notifier.webhooks = append(notifier.webhooks, payload)
+
+		return json.Unmarshal(task.Payload(), payload)
+	}
+
+	return nil
+}
+
+func TestLoginUserAPI(t *testing.T) {
+	user, plainPassword := randomUser()
+	hashedPassword, err := password.NewConfiguredHasher().Hash(plainPassword)
+	require.NoError(t, err)
+	user.HashedPassword = hashedPassword
+	legacyUser := user
+	legacyUser.HashedPassword, err = argon2id.CreateHash(plainPassword, argon2id.DefaultParams)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		password      string
+		setup         func(t *testing.T, server *Server, store *mocks.Store)
+		checkResponse func(t *testing.T, rec *httptest.ResponseRecorder, notifier *taskNotifier)
+	}{
+		{
+			name:     "OK",
+			password: plainPassword,
+			setup: func(t *testing.T, _ *Server, store *mocks.Store) {
+				t.Helper()
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(user, nil)
+				store.
+					EXPECT().
+					CreateSession(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Once().
+					Return(db.Session{}, nil)
+				store.
+					EXPECT().
+					RecordLoginDevice(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Once().
+					Return(db.RecordLoginDeviceRow{KnownDevice: true, HasDevices: true}, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, notifier *taskNotifier) {
+				t.Helper()
+				require.Equal(t, http.StatusOK, rec.Code)
+				require.Empty(t, notifier.loginAlerts)
+			},
+		},
+		{
+			name:     "NewDevice",
+			password: plainPassword,
+			setup: func(t *testing.T, _ *Server, store *mocks.Store) {
+				t.Helper()
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(user, nil)
+				store.
+					EXPECT().
+					CreateSession(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Once().
+					Return(db.Session{Username: user.Username, UserAgent: "new-device"}, nil)
+				store.
+					EXPECT().
+					RecordLoginDevice(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Once().
+					Return(db.RecordLoginDeviceRow{KnownDevice: false, HasDevices: true}, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, notifier *taskNotifier) {
+				t.Helper()
+				require.Equal(t, http.StatusOK, rec.Code)
+				require.Len(t, notifier.loginAlerts, 1)
+				require.Equal(t, user.Username, notifier.loginAlerts[0].Username)
+			},
+		},
+		{
+			name:     "RehashesLegacyHash",
+			password: plainPassword,
+			setup: func(t *testing.T, server *Server, store *mocks.Store) {
+				t.Helper()
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(legacyUser, nil)
+				store.
+					EXPECT().
+					RehashUserPassword(
+						mock.AnythingOfType("context.todoCtx"),
+						mock.MatchedBy(func(arg db.RehashUserPasswordParams) bool {
+							match, rehash, err := server.passwordHasher.Verify(plainPassword, arg.NewHashedPassword)
+
+							return err == nil && match && !rehash &&
+								arg.HashedPassword == legacyUser.HashedPassword
+						}),
+					).
+					Once().
+					Return(nil)
+				store.
+					EXPECT().
+					CreateSession(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Once().
+					Return(db.Session{}, nil)
+				store.
+					EXPECT().
+					RecordLoginDevice(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Once().
+					Return(db.RecordLoginDeviceRow{KnownDevice: true, HasDevices: true}, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ *taskNotifier) {
+				t.Helper()
+				require.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			name:     "UserNotFound",
+			password: plainPassword,
+			setup: func(t *testing.T, _ *Server, store *mocks.Store) {
+				t.Helper()
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(db.User{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ *taskNotifier) {
+				t.Helper()
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+				checkErrorMessage(t, rec.Body, "Usuario o contraseña incorrectos.")
+			},
+		},
+		{
+			name:     "IncorrectPassword",
+			password: randomdata.Alphanumeric(16),
+			setup: func(t *testing.T, _ *Server, store *mocks.Store) {
+				t.Helper()
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ *taskNotifier) {
+				t.Helper()
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+				checkErrorMessage(t, rec.Body, "Usuario o contraseña incorrectos.")
+			},
+		},
+		{
+			// The policy minimum may have been raised since the password was
+			// set, so login leaves the length to the credential check.
+			name:     "ShortPassword",
+			password: "short",
+			setup: func(t *testing.T, _ *Server, store *mocks.Store) {
+				t.Helper()
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ *taskNotifier) {
+				t.Helper()
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+			},
+		},
+		{
+			name:     "TooLongPassword",
+			password: randomdata.Alphanumeric(101),
+			setup:    func(t *testing.T, _ *Server, _ *mocks.Store) { t.Helper() },
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ *taskNotifier) {
+				t.Helper()
+				require.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			name:     "TooSoonAfterFailure",
+			password: plainPassword,
+			setup: func(t *testing.T, server *Server, _ *mocks.Store) {
+				t.Helper()
+				_, err := server.loginGuard.Fail(context.Background(), user.Username, "")
+				require.NoError(t, err)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, _ *taskNotifier) {
+				t.Helper()
+				require.Equal(t, http.StatusTooManyRequests, rec.Code)
+				require.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+			},
+		},
+		{
+			name:     "LockedOut",
+			password: plainPassword,
+			setup: func(t *testing.T, server *Server, store *mocks.Store) {
+				t.Helper()
+				store.
+					EXPECT().
+					GetUser(mock.AnythingOfType("context.todoCtx"), user.Username).
+					Once().
+					Return(user, nil)
+				server.loginGuard = lockout.NewGuard(lockout.NewMemoryBackend(), lockout.Config{
+					MaxAttempts:     1,
+					IPMaxAttempts:   10,
+					Window:          time.Hour,
+					LockoutDuration: time.Hour,
+				})
+				server.router.ServeHTTP(httptest.NewRecorder(), newLoginRequest(t, user.Username, "wrong-password"))
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder, notifier *taskNotifier) {
+				t.Helper()
+				require.Equal(t, http.StatusTooManyRequests, rec.Code)
+				require.Len(t, notifier.lockouts, 1)
+				require.Equal(t, user.Username, notifier.lockouts[0].Username)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			expectAuditEvents(store)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			notifier := &taskNotifier{}
+			server.taskDistributor = notifier
+			server.loginGuard = lockout.NewGuard(lockout.NewMemoryBackend(), lockout.Config{
+				MaxAttempts:     3,
+				IPMaxAttempts:   10,
+				Window:          time.Hour,
+				LockoutDuration: time.Hour,
+				BaseDelay:       time.Minute,
+			})
+			tc.setup(t, server, store)
+
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, newLoginRequest(t, user.Username, tc.password))
+			tc.checkResponse(t, rec, notifier)
+		})
+	}
+}
+
+func newLoginRequest(t *testing.T, username, password string) *http.Request {
+	t.Helper()
+	data, err := json.Marshal(echo.Map{"username": username, "password": password})
+	require.NoError(t, err)
+
+	req, err := http.NewRequestWithContext(
+		context.TODO(),
+		http.MethodPost,
+		"/signin",
+		bytes.NewReader(data),
+	)
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/json")
+
+	return req
+}
+
 func randomUser() (user db.User, password string) {
-	password = randomdata.Alphanumeric(16)
+	// The suffix satisfies the default policy of three character classes.
+	password = randomdata.Alphanumeric(13) + "aA1"
 	hashedPassword, _ := argon2id.CreateHash(
 		randomdata.Alphanumeric(16),
 		argon2id.DefaultParams,
@@ -273,6 +659,7 @@ func randomUser() (user db.User, password string) {
 		HashedPassword: hashedPassword,
 		FullName:       randomdata.FullName(randomdata.RandomGender),
 		Email:          randomdata.Email(),
+		Role:           authz.RoleDepositor,
 	}
 	return
 }
@@ -0,0 +1,412 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/dharmavagabond/simple-bank/internal/audit"
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/token"
+	"github.com/dharmavagabond/simple-bank/internal/webhook"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
+)
+
+type (
+	createWebhookRequest struct {
+		URL       string `json:"url" validate:"required,url,max=2048"`
+		AccountID int64  `param:"id" validate:"required,min=1"`
+	}
+	listWebhooksRequest struct {
+		AccountID int64 `param:"id" validate:"required,min=1"`
+	}
+	webhookRequest struct {
+		ID int64 `param:"id" validate:"required,min=1"`
+	}
+	listWebhookDeliveriesRequest struct {
+		ID       int64 `param:"id"        validate:"required,min=1"`
+		PageID   int32 `query:"page_id"   validate:"required,min=1"`
+		PageSize int32 `query:"page_size" validate:"required,min=5,max=50"`
+	}
+	redeliverWebhookRequest struct {
+		ID         int64 `param:"id"          validate:"required,min=1"`
+		DeliveryID int64 `param:"delivery_id" validate:"required,min=1"`
+	}
+	webhookResponse struct {
+		CreatedAt           time.Time  `json:"created_at"`
+		DisabledAt          *time.Time `json:"disabled_at"`
+		URL                 string     `json:"url"`
+		Secret              string     `json:"secret,omitempty"`
+		ID                  int64      `json:"id"`
+		AccountID           int64      `json:"account_id"`
+		ConsecutiveFailures int32      `json:"consecutive_failures"`
+	}
+	webhookDeliveryResponse struct {
+		CreatedAt      time.Time       `json:"created_at"`
+		UpdatedAt      time.Time       `json:"updated_at"`
+		DeliveredAt    *time.Time      `json:"delivered_at"`
+		ResponseStatus *int32          `json:"response_status"`
+		EventType      string          `json:"event_type"`
+		Status         string          `json:"status"`
+		LastError      string          `json:"last_error,omitempty"`
+		Payload        json.RawMessage `json:"payload"`
+		ID             int64           `json:"id"`
+		EndpointID     int64           `json:"endpoint_id"`
+		Attempts       int32           `json:"attempts"`
+	}
+)
+
+var ERR_WEBHOOK_DISABLED = errors.New("[Err]: The webhook endpoint is disabled")
+
+// createWebhook registers an endpoint for the account's transfers. Its
+// signing secret is only returned here.
+func (server *Server) createWebhook(ectx echo.Context) (err error) {
+	var (
+		account  db.Account
+		endpoint db.WebhookEndpoint
+		secret   string
+		req      = &createWebhookRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	if err = validateWebhookURL(req.URL); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    authPayload.Username,
+			Action:   audit.ActionCreateWebhook,
+			Resource: fmt.Sprintf("accounts/%d", req.AccountID),
+			Detail:   req.URL,
+		}, err)
+	}()
+
+	if account, err = getAccount(req.AccountID, server.store, ectx.Request().Context()); err != nil {
+		return err
+	}
+
+	if err = authorizeOwner(ectx, authz.ActionManageWebhooks, account.Owner); err != nil {
+		return err
+	}
+
+	if secret, err = webhook.NewSecret(); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if endpoint, err = server.store.CreateWebhookEndpoint(
+		ectx.Request().Context(),
+		db.CreateWebhookEndpointParams{
+			AccountID: account.ID,
+			Url:       req.URL,
+			Secret:    secret,
+		},
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := newWebhookResponse(endpoint)
+	res.Secret = endpoint.Secret
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+func (server *Server) listWebhooks(ectx echo.Context) (err error) {
+	var (
+		account   db.Account
+		endpoints []db.WebhookEndpoint
+		req       = &listWebhooksRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	if account, err = getAccount(req.AccountID, server.store, ectx.Request().Context()); err != nil {
+		return err
+	}
+
+	if err = authorizeOwner(ectx, authz.ActionManageWebhooks, account.Owner); err != nil {
+		return err
+	}
+
+	if endpoints, err = server.store.ListWebhookEndpoints(ectx.Request().Context(), account.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := make([]webhookResponse, 0, len(endpoints))
+
+	for _, endpoint := range endpoints {
+		res = append(res, newWebhookResponse(endpoint))
+	}
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+func (server *Server) deleteWebhook(ectx echo.Context) (err error) {
+	var (
+		endpoint db.WebhookEndpoint
+		req      = &webhookRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	authPayload := ectx.Get(AUTHORIZATION_PAYLOAD_KEY).(*token.Payload)
+
+	defer func() {
+		server.recordAudit(ectx, db.AppendAuditEventTxParams{
+			Actor:    authPayload.Username,
+			Action:   audit.ActionDeleteWebhook,
+			Resource: fmt.Sprintf("accounts/%d", endpoint.AccountID),
+			Detail:   endpoint.Url,
+		}, err)
+	}()
+
+	if endpoint, err = server.getOwnedWebhook(ectx, req.ID); err != nil {
+		return err
+	}
+
+	if err = server.store.DeleteWebhookEndpoint(ectx.Request().Context(), endpoint.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
+
+// enableWebhook re-enables an endpoint disabled after too many failed
+// deliveries. The deliveries that failed meanwhile have to be redelivered.
+func (server *Server) enableWebhook(ectx echo.Context) (err error) {
+	var (
+		endpoint db.WebhookEndpoint
+		req      = &webhookRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	if endpoint, err = server.getOwnedWebhook(ectx, req.ID); err != nil {
+		return err
+	}
+
+	if endpoint, err = server.store.EnableWebhookEndpoint(ectx.Request().Context(), endpoint.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.JSON(http.StatusOK, newWebhookResponse(endpoint))
+}
+
+func (server *Server) listWebhookDeliveries(ectx echo.Context) (err error) {
+	var (
+		endpoint   db.WebhookEndpoint
+		deliveries []db.WebhookDelivery
+		req        = &listWebhookDeliveriesRequest{
+			PageID:   1,
+			PageSize: 20,
+		}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	if endpoint, err = server.getOwnedWebhook(ectx, req.ID); err != nil {
+		return err
+	}
+
+	if deliveries, err = server.store.ListWebhookDeliveries(
+		ectx.Request().Context(),
+		db.ListWebhookDeliveriesParams{
+			EndpointID: endpoint.ID,
+			Limit:      req.PageSize,
+			Offset:     (req.PageID - 1) * req.PageSize,
+		},
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := make([]webhookDeliveryResponse, 0, len(deliveries))
+
+	for _, delivery := range deliveries {
+		res = append(res, newWebhookDeliveryResponse(delivery))
+	}
+
+	return ectx.JSON(http.StatusOK, res)
+}
+
+// redeliverWebhook sends a delivery again, with its original payload, whatever
+// its status.
+func (server *Server) redeliverWebhook(ectx echo.Context) (err error) {
+	var (
+		endpoint db.WebhookEndpoint
+		delivery db.WebhookDelivery
+		req      = &redeliverWebhookRequest{}
+	)
+
+	if err = ectx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = ectx.Validate(req); err != nil {
+		return err
+	}
+
+	if endpoint, err = server.getOwnedWebhook(ectx, req.ID); err != nil {
+		return err
+	}
+
+	if endpoint.DisabledAt.Valid {
+		return echo.NewHTTPError(http.StatusConflict, ERR_WEBHOOK_DISABLED.Error())
+	}
+
+	ctx := ectx.Request().Context()
+
+	if delivery, err = server.store.GetWebhookDelivery(ctx, req.DeliveryID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if delivery.EndpointID != endpoint.ID {
+		return echo.NewHTTPError(http.StatusNotFound, pgx.ErrNoRows.Error())
+	}
+
+	if delivery, err = server.store.ResetWebhookDelivery(ctx, delivery.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if err = worker.DeliverWebhook.Enqueue(
+		ctx,
+		server.taskDistributor,
+		&worker.PayloadDeliverWebhook{DeliveryID: delivery.ID},
+		asynq.MaxRetry(config.App.WebhookMaxRetry),
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ectx.JSON(http.StatusAccepted, newWebhookDeliveryResponse(delivery))
+}
+
+// getOwnedWebhook gets the endpoint, if the caller may manage the webhooks of
+// its account.
+func (server *Server) getOwnedWebhook(ectx echo.Context, id int64) (endpoint db.WebhookEndpoint, err error) {
+	var account db.Account
+	ctx := ectx.Request().Context()
+
+	if endpoint, err = server.store.GetWebhookEndpoint(ctx, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return endpoint, echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+
+		return endpoint, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if account, err = getAccount(endpoint.AccountID, server.store, ctx); err != nil {
+		return endpoint, err
+	}
+
+	return endpoint, authorizeOwner(ectx, authz.ActionManageWebhooks, account.Owner)
+}
+
+// validateWebhookURL only allows plain HTTP endpoints, and internal
+// addresses, in development. Hostnames are checked again when delivering,
+// once resolved.
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "https" && !(config.App.IsDev && u.Scheme == "http") {
+		return errors.New("url must use https")
+	}
+
+	if config.App.IsDev {
+		return nil
+	}
+
+	if ip := net.ParseIP(u.Hostname()); u.Hostname() == "localhost" || (ip != nil && !webhook.PublicIP(ip)) {
+		return webhook.ERR_FORBIDDEN_ADDRESS
+	}
+
+	return nil
+}
+
+func newWebhookResponse(endpoint db.WebhookEndpoint) webhookResponse {
+	res := webhookResponse{
+		CreatedAt:           endpoint.CreatedAt.Time,
+		URL:                 endpoint.Url,
+		ID:                  endpoint.ID,
+		AccountID:           endpoint.AccountID,
+		ConsecutiveFailures: endpoint.ConsecutiveFailures,
+	}
+
+	if endpoint.DisabledAt.Valid {
+		res.DisabledAt = &endpoint.DisabledAt.Time
+	}
+
+	return res
+}
+
+func newWebhookDeliveryResponse(delivery db.WebhookDelivery) webhookDeliveryResponse {
+	res := webhookDeliveryResponse{
+		CreatedAt:  delivery.CreatedAt.Time,
+		UpdatedAt:  delivery.UpdatedAt.Time,
+		EventType:  delivery.EventType,
+		Status:     delivery.Status,
+		LastError:  delivery.LastError.String,
+		Payload:    delivery.Payload,
+		ID:         delivery.ID,
+		EndpointID: delivery.EndpointID,
+		Attempts:   delivery.Attempts,
+	}
+
+	if delivery.DeliveredAt.Valid {
+		res.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+
+	if delivery.ResponseStatus.Valid {
+		res.ResponseStatus = &delivery.ResponseStatus.Int32
+	}
+
+	return res
+}
@@ -0,0 +1,238 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/authz"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/webhook"
+	"github.com/dharmavagabond/simple-bank/internal/worker"
+)
+
+func TestCreateWebhookAPI(t *testing.T) {
+	user, _ := randomUser()
+	account := createRandomAccount(user.Username)
+	testCases := []struct {
+		name          string
+		username      string
+		url           string
+		buildStubs    func(store *mocks.Store)
+		checkResponse func(t *testing.T, rec *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			url:      "https://example.com/hooks",
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetAccount(mock.AnythingOfType("context.todoCtx"), account.ID).
+					Once().
+					Return(account, nil)
+				store.
+					EXPECT().
+					CreateWebhookEndpoint(
+						mock.AnythingOfType("context.todoCtx"),
+						mock.MatchedBy(func(arg db.CreateWebhookEndpointParams) bool {
+							return arg.AccountID == account.ID &&
+								arg.Url == "https://example.com/hooks" &&
+								strings.HasPrefix(arg.Secret, "whsec_")
+						}),
+					).
+					Once().
+					Return(db.WebhookEndpoint{
+						ID:        1,
+						AccountID: account.ID,
+						Url:       "https://example.com/hooks",
+						Secret:    "whsec_secret",
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusOK, rec.Code)
+
+				var res webhookResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+				require.Equal(t, "whsec_secret", res.Secret)
+				require.Equal(t, account.ID, res.AccountID)
+			},
+		},
+		{
+			name:       "PlainHTTP",
+			username:   user.Username,
+			url:        "http://example.com/hooks",
+			buildStubs: func(store *mocks.Store) {},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			name:       "InternalAddress",
+			username:   user.Username,
+			url:        "https://127.0.0.1/hooks",
+			buildStubs: func(store *mocks.Store) {},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			name:     "NotOwner",
+			username: "other_user",
+			url:      "https://example.com/hooks",
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetAccount(mock.AnythingOfType("context.todoCtx"), account.ID).
+					Once().
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				require.Equal(t, http.StatusForbidden, rec.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			tc.buildStubs(store)
+			expectAuditEvents(store)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			body, err := json.Marshal(map[string]any{"url": tc.url})
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(
+				context.TODO(),
+				http.MethodPost,
+				fmt.Sprintf("/accounts/%d/webhooks", account.ID),
+				bytes.NewReader(body),
+			)
+			require.NoError(t, err)
+			req.Header.Add("Content-Type", "application/json")
+			addAuthorization(t, req, server.tokenMaker, AUTH_TYPE_BEARER, tc.username, authz.RoleDepositor, time.Minute)
+			server.router.ServeHTTP(rec, req)
+			tc.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestRedeliverWebhookAPI(t *testing.T) {
+	user, _ := randomUser()
+	account := createRandomAccount(user.Username)
+	endpoint := db.WebhookEndpoint{ID: 3, AccountID: account.ID, Url: "https://example.com/hooks"}
+	disabled := endpoint
+	disabled.DisabledAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	delivery := db.WebhookDelivery{
+		ID:         9,
+		EndpointID: endpoint.ID,
+		EventType:  webhook.EventTransferIn,
+		Payload:    []byte(`{"id":"x"}`),
+		Status:     webhook.StatusFailed,
+	}
+	testCases := []struct {
+		name          string
+		endpoint      db.WebhookEndpoint
+		deliveryID    int64
+		buildStubs    func(store *mocks.Store)
+		expectedCode  int
+		expectedTasks []*worker.PayloadDeliverWebhook
+	}{
+		{
+			name:       "OK",
+			endpoint:   endpoint,
+			deliveryID: delivery.ID,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetWebhookDelivery(mock.AnythingOfType("context.todoCtx"), delivery.ID).
+					Once().
+					Return(delivery, nil)
+				pending := delivery
+				pending.Status = webhook.StatusPending
+				store.
+					EXPECT().
+					ResetWebhookDelivery(mock.AnythingOfType("context.todoCtx"), delivery.ID).
+					Once().
+					Return(pending, nil)
+			},
+			expectedCode:  http.StatusAccepted,
+			expectedTasks: []*worker.PayloadDeliverWebhook{{DeliveryID: delivery.ID}},
+		},
+		{
+			name:         "EndpointDisabled",
+			endpoint:     disabled,
+			deliveryID:   delivery.ID,
+			buildStubs:   func(store *mocks.Store) {},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:       "OtherEndpointDelivery",
+			endpoint:   endpoint,
+			deliveryID: 10,
+			buildStubs: func(store *mocks.Store) {
+				store.
+					EXPECT().
+					GetWebhookDelivery(mock.AnythingOfType("context.todoCtx"), int64(10)).
+					Once().
+					Return(db.WebhookDelivery{ID: 10, EndpointID: 4}, nil)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			store := mocks.NewStore(t)
+			store.
+				EXPECT().
+				GetWebhookEndpoint(mock.AnythingOfType("context.todoCtx"), endpoint.ID).
+				Once().
+				Return(tc.endpoint, nil)
+			store.
+				EXPECT().
+				GetAccount(mock.AnythingOfType("context.todoCtx"), account.ID).
+				Once().
+				Return(account, nil)
+			tc.buildStubs(store)
+			server, err := NewServer(store)
+			require.NoError(t, err)
+
+			notifier := &taskNotifier{}
+			server.taskDistributor = notifier
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(
+				context.TODO(),
+				http.MethodPost,
+				fmt.Sprintf("/webhooks/%d/deliveries/%d/redeliver", endpoint.ID, tc.deliveryID),
+				nil,
+			)
+			require.NoError(t, err)
+			addAuthorization(t, req, server.tokenMaker, AUTH_TYPE_BEARER, user.Username, authz.RoleDepositor, time.Minute)
+			server.router.ServeHTTP(rec, req)
+			require.Equal(t, tc.expectedCode, rec.Code)
+			require.Equal(t, tc.expectedTasks, notifier.webhooks)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend is the minimal set of expiring-counter operations the guard needs.
+// TTL returns zero for missing keys.
+type Backend interface {
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	Set(ctx context.Context, key string, ttl time.Duration) error
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Del(ctx context.Context, keys ...string) error
+}
+
+type RedisBackend struct {
+	client redis.UniversalClient
+}
+
+func (backend *RedisBackend) Incr(ctx context.Context, key string, ttl time.Duration) (n int64, err error) {
+	if n, err = backend.client.Incr(ctx, key).Result(); err != nil {
+		return 0, err
+	}
+
+	if n == 1 {
+		if err = backend.client.PExpire(ctx, key, ttl).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+func (backend *RedisBackend) Set(ctx context.Context, key string, ttl time.Duration) error {
+	return backend.client.Set(ctx, key, 1, ttl).Err()
+}
+
+func (backend *RedisBackend) TTL(ctx context.Context, key string) (ttl time.Duration, err error) {
+	if ttl, err = backend.client.PTTL(ctx, key).Result(); err != nil {
+		return 0, err
+	}
+
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+func (backend *RedisBackend) Del(ctx context.Context, keys ...string) error {
+	return backend.client.Del(ctx, keys...).Err()
+}
+
+func (backend *RedisBackend) Close() error {
+	return backend.client.Close()
+}
+
+func NewRedisBackend(client redis.UniversalClient) Backend {
+	return &RedisBackend{client: client}
+}
+
+type (
+	MemoryBackend struct {
+		entries map[string]*memoryEntry
+		now     func() time.Time
+		mu      sync.Mutex
+	}
+
+	memoryEntry struct {
+		expiresAt time.Time
+		value     int64
+	}
+)
+
+func (backend *MemoryBackend) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	entry := backend.get(key)
+
+	if entry == nil {
+		entry = &memoryEntry{expiresAt: backend.now().Add(ttl)}
+		backend.entries[key] = entry
+	}
+
+	entry.value++
+
+	return entry.value, nil
+}
+
+func (backend *MemoryBackend) Set(_ context.Context, key string, ttl time.Duration) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	backend.entries[key] = &memoryEntry{value: 1, expiresAt: backend.now().Add(ttl)}
+
+	return nil
+}
+
+func (backend *MemoryBackend) TTL(_ context.Context, key string) (time.Duration, error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	if entry := backend.get(key); entry != nil {
+		return entry.expiresAt.Sub(backend.now()), nil
+	}
+
+	return 0, nil
+}
+
+func (backend *MemoryBackend) Del(_ context.Context, keys ...string) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	for _, key := range keys {
+		delete(backend.entries, key)
+	}
+
+	return nil
+}
+
+func (backend *MemoryBackend) get(key string) *memoryEntry {
+	entry, ok := backend.entries[key]
+	if !ok {
+		return nil
+	}
+
+	if !backend.now().Before(entry.expiresAt) {
+		delete(backend.entries, key)
+		return nil
+	}
+
+	return entry
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		entries: make(map[string]*memoryEntry),
+		now:     time.Now,
+	}
+}
@@ -0,0 +1,172 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+)
+
+const (
+	keyPrefix     = "login:"
+	userAttempts  = keyPrefix + "attempts:user:"
+	ipAttempts    = keyPrefix + "attempts:ip:"
+	userLock      = keyPrefix + "lock:user:"
+	ipLock        = keyPrefix + "lock:ip:"
+	userDelay     = keyPrefix + "delay:user:"
+	maxDelayShift = 16
+)
+
+var (
+	ERR_LOCKED            = errors.New("[Err]: Too many failed login attempts, try again later")
+	ERR_TOO_MANY_ATTEMPTS = errors.New("[Err]: Login attempted too soon after a failure")
+)
+
+type (
+	Config struct {
+		MaxAttempts     int64
+		IPMaxAttempts   int64
+		Window          time.Duration
+		LockoutDuration time.Duration
+		BaseDelay       time.Duration
+	}
+
+	// Guard tracks failed logins per username and per client IP. Every
+	// failure imposes an exponentially growing delay before the next
+	// attempt; reaching the limit locks the username (or IP) out.
+	Guard struct {
+		backend Backend
+		config  Config
+	}
+)
+
+// Check returns ERR_LOCKED or ERR_TOO_MANY_ATTEMPTS, along with how long the
+// caller must wait, when the attempt must be rejected without checking the
+// password. An empty clientIP disables the per-IP limits.
+func (guard *Guard) Check(ctx context.Context, username, clientIP string) (time.Duration, error) {
+	keys := []string{userLock + username}
+
+	if len(clientIP) > 0 {
+		keys = append(keys, ipLock+clientIP)
+	}
+
+	for _, key := range keys {
+		ttl, err := guard.backend.TTL(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+
+		if ttl > 0 {
+			return ttl, ERR_LOCKED
+		}
+	}
+
+	ttl, err := guard.backend.TTL(ctx, userDelay+username)
+	if err != nil {
+		return 0, err
+	}
+
+	if ttl > 0 {
+		return ttl, ERR_TOO_MANY_ATTEMPTS
+	}
+
+	return 0, nil
+}
+
+// Fail records a failed attempt and reports whether it locked the username
+// out, so the caller can notify the account owner.
+func (guard *Guard) Fail(ctx context.Context, username, clientIP string) (locked bool, err error) {
+	var userFailures int64
+
+	if len(clientIP) > 0 {
+		if err = guard.failIP(ctx, clientIP); err != nil {
+			return false, err
+		}
+	}
+
+	if userFailures, err = guard.backend.Incr(ctx, userAttempts+username, guard.config.Window); err != nil {
+		return false, err
+	}
+
+	if userFailures >= guard.config.MaxAttempts {
+		if err = guard.backend.Set(ctx, userLock+username, guard.config.LockoutDuration); err != nil {
+			return false, err
+		}
+
+		return true, guard.backend.Del(ctx, userAttempts+username, userDelay+username)
+	}
+
+	return false, guard.backend.Set(ctx, userDelay+username, guard.delay(userFailures))
+}
+
+func (guard *Guard) failIP(ctx context.Context, clientIP string) error {
+	failures, err := guard.backend.Incr(ctx, ipAttempts+clientIP, guard.config.Window)
+	if err != nil {
+		return err
+	}
+
+	if failures < guard.config.IPMaxAttempts {
+		return nil
+	}
+
+	if err = guard.backend.Set(ctx, ipLock+clientIP, guard.config.LockoutDuration); err != nil {
+		return err
+	}
+
+	return guard.backend.Del(ctx, ipAttempts+clientIP)
+}
+
+func (guard *Guard) Succeed(ctx context.Context, username string) error {
+	return guard.backend.Del(ctx, userAttempts+username, userDelay+username)
+}
+
+func (guard *Guard) delay(failures int64) time.Duration {
+	shift := failures - 1
+
+	if shift > maxDelayShift {
+		shift = maxDelayShift
+	}
+
+	delay := guard.config.BaseDelay << shift
+
+	if delay > guard.config.LockoutDuration {
+		return guard.config.LockoutDuration
+	}
+
+	return delay
+}
+
+// Close closes the backend's connections, if it has any.
+func (guard *Guard) Close() error {
+	if closer, ok := guard.backend.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+func NewGuard(backend Backend, cfg Config) *Guard {
+	return &Guard{
+		backend: backend,
+		config:  cfg,
+	}
+}
+
+func NewRedisGuard() *Guard {
+	client := redis.NewClient(&redis.Options{
+		Addr: net.JoinHostPort(config.Redis.Host, config.Redis.Port),
+	})
+
+	return NewGuard(NewRedisBackend(client), Config{
+		MaxAttempts:     config.App.LoginMaxAttempts,
+		IPMaxAttempts:   config.App.LoginIPMaxAttempts,
+		Window:          config.App.LoginAttemptWindow,
+		LockoutDuration: config.App.LoginLockoutDuration,
+		BaseDelay:       config.App.LoginBaseDelay,
+	})
+}
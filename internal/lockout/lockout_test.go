@@ -0,0 +1,108 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGuard() (*Guard, *time.Time) {
+	now := time.Now()
+	backend := NewMemoryBackend()
+	backend.now = func() time.Time { return now }
+	guard := NewGuard(backend, Config{
+		MaxAttempts:     3,
+		IPMaxAttempts:   5,
+		Window:          time.Hour,
+		LockoutDuration: 15 * time.Minute,
+		BaseDelay:       time.Second,
+	})
+
+	return guard, &now
+}
+
+func TestProgressiveDelay(t *testing.T) {
+	ctx := context.Background()
+	guard, now := newTestGuard()
+
+	wait, err := guard.Check(ctx, "alice", "10.0.0.1")
+	require.NoError(t, err)
+	require.Zero(t, wait)
+
+	locked, err := guard.Fail(ctx, "alice", "10.0.0.1")
+	require.NoError(t, err)
+	require.False(t, locked)
+
+	wait, err = guard.Check(ctx, "alice", "10.0.0.1")
+	require.ErrorIs(t, err, ERR_TOO_MANY_ATTEMPTS)
+	require.Equal(t, time.Second, wait)
+
+	*now = now.Add(time.Second)
+	_, err = guard.Check(ctx, "alice", "10.0.0.1")
+	require.NoError(t, err)
+
+	_, err = guard.Fail(ctx, "alice", "10.0.0.1")
+	require.NoError(t, err)
+
+	wait, err = guard.Check(ctx, "alice", "10.0.0.1")
+	require.ErrorIs(t, err, ERR_TOO_MANY_ATTEMPTS)
+	require.Equal(t, 2*time.Second, wait)
+
+	require.NoError(t, guard.Succeed(ctx, "alice"))
+	_, err = guard.Check(ctx, "alice", "10.0.0.1")
+	require.NoError(t, err)
+}
+
+func TestUserLockout(t *testing.T) {
+	ctx := context.Background()
+	guard, now := newTestGuard()
+
+	for i := 1; i <= 3; i++ {
+		locked, err := guard.Fail(ctx, "alice", "10.0.0.1")
+		require.NoError(t, err)
+		require.Equal(t, i == 3, locked)
+	}
+
+	wait, err := guard.Check(ctx, "alice", "10.0.0.2")
+	require.ErrorIs(t, err, ERR_LOCKED)
+	require.Equal(t, 15*time.Minute, wait)
+
+	_, err = guard.Check(ctx, "bob", "10.0.0.1")
+	require.NoError(t, err)
+
+	*now = now.Add(15 * time.Minute)
+	_, err = guard.Check(ctx, "alice", "10.0.0.2")
+	require.NoError(t, err)
+}
+
+func TestIPLockout(t *testing.T) {
+	ctx := context.Background()
+	guard, _ := newTestGuard()
+
+	for _, username := range []string{"a", "b", "c", "d", "e"} {
+		locked, err := guard.Fail(ctx, username, "10.0.0.1")
+		require.NoError(t, err)
+		require.False(t, locked)
+	}
+
+	_, err := guard.Check(ctx, "f", "10.0.0.1")
+	require.ErrorIs(t, err, ERR_LOCKED)
+
+	_, err = guard.Check(ctx, "f", "10.0.0.2")
+	require.NoError(t, err)
+}
+
+func TestUnknownClientIP(t *testing.T) {
+	ctx := context.Background()
+	guard, _ := newTestGuard()
+
+	for i := 0; i < 10; i++ {
+		_, err := guard.Fail(ctx, "alice", "")
+		require.NoError(t, err)
+	}
+
+	_, err := guard.Check(ctx, "bob", "")
+	require.NoError(t, err)
+}
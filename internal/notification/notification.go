@@ -0,0 +1,63 @@
+package notification
+
+import (
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+const (
+	EventTransferReceipt  = "transfer.receipt"
+	EventTransferIncoming = "transfer.incoming"
+	EventLowBalance       = "balance.low"
+)
+
+const ChannelEmail = "email"
+
+var (
+	Events   = []string{EventTransferReceipt, EventTransferIncoming, EventLowBalance}
+	Channels = []string{ChannelEmail}
+)
+
+// Preference is whether a user is notified of an event through a channel.
+type Preference struct {
+	EventType string `json:"event_type"`
+	Channel   string `json:"channel"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// Enabled reports whether the user who set the stored preferences is
+// notified of the event through the channel. Users are notified of every
+// event until they opt out.
+func Enabled(stored []db.NotificationPreference, eventType, channel string) bool {
+	for _, pref := range stored {
+		if pref.EventType == eventType && pref.Channel == channel {
+			return pref.Enabled
+		}
+	}
+
+	return true
+}
+
+// Preferences returns the user's preference for every event and channel,
+// from the stored ones and the defaults.
+func Preferences(stored []db.NotificationPreference) []Preference {
+	prefs := make([]Preference, 0, len(Events)*len(Channels))
+
+	for _, eventType := range Events {
+		for _, channel := range Channels {
+			prefs = append(prefs, Preference{
+				EventType: eventType,
+				Channel:   channel,
+				Enabled:   Enabled(stored, eventType, channel),
+			})
+		}
+	}
+
+	return prefs
+}
+
+// CrossedBelow reports whether a balance that changed from previous to
+// current went below the threshold, so an alert is sent once per crossing
+// rather than for every transfer while it stays below.
+func CrossedBelow(previous, current, threshold int64) bool {
+	return previous >= threshold && current < threshold
+}
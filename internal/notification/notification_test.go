@@ -0,0 +1,30 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+func TestPreferences(t *testing.T) {
+	stored := []db.NotificationPreference{
+		{Username: "alice", EventType: EventTransferIncoming, Channel: ChannelEmail, Enabled: false},
+	}
+
+	require.True(t, Enabled(stored, EventTransferReceipt, ChannelEmail))
+	require.False(t, Enabled(stored, EventTransferIncoming, ChannelEmail))
+	require.Equal(t, []Preference{
+		{EventType: EventTransferReceipt, Channel: ChannelEmail, Enabled: true},
+		{EventType: EventTransferIncoming, Channel: ChannelEmail, Enabled: false},
+		{EventType: EventLowBalance, Channel: ChannelEmail, Enabled: true},
+	}, Preferences(stored))
+}
+
+func TestCrossedBelow(t *testing.T) {
+	require.True(t, CrossedBelow(100, 99, 100))
+	require.True(t, CrossedBelow(150, 20, 100))
+	require.False(t, CrossedBelow(99, 50, 100))
+	require.False(t, CrossedBelow(150, 100, 100))
+}
@@ -0,0 +1,113 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+
+	ResponseTypeCode        = "code"
+	CodeChallengeMethodS256 = "S256"
+
+	clientIDPrefix = "sbc_"
+	randomSize     = 32
+)
+
+// Error codes from RFC 6749, section 5.2.
+const (
+	ErrInvalidRequest       = "invalid_request"
+	ErrInvalidClient        = "invalid_client"
+	ErrInvalidGrant         = "invalid_grant"
+	ErrInvalidScope         = "invalid_scope"
+	ErrUnauthorizedClient   = "unauthorized_client"
+	ErrUnsupportedGrantType = "unsupported_grant_type"
+)
+
+// NewClientID returns a random, public client identifier.
+func NewClientID() (string, error) {
+	id, err := randomString(randomSize / 2)
+	if err != nil {
+		return "", err
+	}
+
+	return clientIDPrefix + id, nil
+}
+
+// NewSecret returns a random secret and its hash, for client secrets and
+// authorization codes. Only the hash is stored.
+func NewSecret() (secret string, hashedSecret string, err error) {
+	if secret, err = randomString(randomSize); err != nil {
+		return "", "", err
+	}
+
+	return secret, HashSecret(secret), nil
+}
+
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func VerifySecret(secret, hashedSecret string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(hashedSecret)) == 1
+}
+
+// VerifyCodeChallenge checks a PKCE code verifier against its S256 challenge
+// (RFC 7636, section 4.6).
+func VerifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// ParseScope splits a space-delimited scope parameter, dropping duplicates.
+func ParseScope(scope string) []string {
+	scopes := make([]string, 0)
+
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes
+}
+
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// ContainsAll reports whether every requested scope was granted.
+func ContainsAll(granted, requested []string) bool {
+	for _, s := range requested {
+		if !slices.Contains(granted, s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,45 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// Example from RFC 7636, appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	require.True(t, VerifyCodeChallenge(verifier, challenge))
+	require.False(t, VerifyCodeChallenge(verifier+"x", challenge))
+	require.False(t, VerifyCodeChallenge("short", "short"))
+
+	long := strings.Repeat("a", 129)
+	sum := sha256.Sum256([]byte(long))
+	require.False(t, VerifyCodeChallenge(long, base64.RawURLEncoding.EncodeToString(sum[:])))
+}
+
+func TestSecrets(t *testing.T) {
+	secret, hashedSecret, err := NewSecret()
+	require.NoError(t, err)
+	require.True(t, VerifySecret(secret, hashedSecret))
+	require.False(t, VerifySecret(secret+"x", hashedSecret))
+
+	clientID, err := NewClientID()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(clientID, clientIDPrefix))
+}
+
+func TestScopes(t *testing.T) {
+	scopes := ParseScope(" account:read  transfer:create account:read ")
+	require.Equal(t, []string{"account:read", "transfer:create"}, scopes)
+	require.Equal(t, "account:read transfer:create", FormatScope(scopes))
+	require.Empty(t, ParseScope(""))
+
+	require.True(t, ContainsAll(scopes, []string{"account:read"}))
+	require.False(t, ContainsAll([]string{"account:read"}, scopes))
+}
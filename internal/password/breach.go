@@ -0,0 +1,85 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const prefixLength = 5
+
+// BreachedSet holds the SHA-1 hashes of breached passwords indexed by their
+// first five characters, as the Pwned Passwords range API does, so a lookup
+// only ever compares the suffixes sharing the candidate's prefix.
+type BreachedSet struct {
+	ranges map[string][]string
+}
+
+// Range returns the sorted hash suffixes starting with prefix.
+func (set *BreachedSet) Range(prefix string) []string {
+	if set == nil {
+		return nil
+	}
+
+	return set.ranges[strings.ToUpper(prefix)]
+}
+
+// Contains is always false for a nil set, so the check is disabled when no
+// dataset was loaded.
+func (set *BreachedSet) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes := set.Range(hash[:prefixLength])
+	i := sort.SearchStrings(suffixes, hash[prefixLength:])
+
+	return i < len(suffixes) && suffixes[i] == hash[prefixLength:]
+}
+
+// ReadBreachedSet parses the Pwned Passwords download format: one SHA-1 hash
+// per line, optionally followed by ":" and the number of occurrences.
+func ReadBreachedSet(r io.Reader) (*BreachedSet, error) {
+	set := &BreachedSet{ranges: map[string][]string{}}
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("%w: line %d", ERR_INVALID_BREACHED_SET, n)
+		}
+
+		hash = strings.ToUpper(hash)
+		set.ranges[hash[:prefixLength]] = append(set.ranges[hash[:prefixLength]], hash[prefixLength:])
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, suffixes := range set.ranges {
+		sort.Strings(suffixes)
+	}
+
+	return set, nil
+}
+
+func LoadBreachedSet(path string) (*BreachedSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadBreachedSet(file)
+}
@@ -0,0 +1,35 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBreachedSet(t *testing.T) {
+	hash := strings.ToUpper(sha1Hex("hunter2"))
+	set, err := ReadBreachedSet(strings.NewReader(
+		"# pwned passwords sample\n" +
+			strings.ToLower(hash) + ":17043\n" +
+			hash[:prefixLength] + "00000000000000000000000000000000000:1\n",
+	))
+	require.NoError(t, err)
+
+	require.Len(t, set.Range(hash[:prefixLength]), 2)
+	require.True(t, set.Contains("hunter2"))
+	require.False(t, set.Contains("hunter3"))
+
+	var empty *BreachedSet
+	require.False(t, empty.Contains("hunter2"))
+
+	_, err = ReadBreachedSet(strings.NewReader("not-a-hash:1\n"))
+	require.ErrorIs(t, err, ERR_INVALID_BREACHED_SET)
+}
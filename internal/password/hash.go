@@ -0,0 +1,103 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/thanhpk/randstr"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+)
+
+// pepperedPrefix marks hashes of passwords peppered before hashing, which
+// lets hashes from before the pepper was configured be upgraded.
+const pepperedPrefix = "$peppered"
+
+var ERR_PEPPER_REQUIRED = errors.New("[Err]: The password hash is peppered but no pepper is configured")
+
+type Hasher struct {
+	params    *argon2id.Params
+	dummyHash func() string
+	pepper    []byte
+}
+
+func (hasher *Hasher) Hash(password string) (string, error) {
+	if len(hasher.pepper) == 0 {
+		return argon2id.CreateHash(password, hasher.params)
+	}
+
+	hash, err := argon2id.CreateHash(hasher.peppered(password), hasher.params)
+	if err != nil {
+		return "", err
+	}
+
+	return pepperedPrefix + hash, nil
+}
+
+// Verify compares password with hash. rehash reports whether a matching
+// hash was created with other parameters, or without the pepper, and should
+// be replaced with a new one.
+func (hasher *Hasher) Verify(password, hash string) (match, rehash bool, err error) {
+	var params *argon2id.Params
+
+	if encoded, ok := strings.CutPrefix(hash, pepperedPrefix); ok {
+		if len(hasher.pepper) == 0 {
+			return false, false, ERR_PEPPER_REQUIRED
+		}
+
+		password = hasher.peppered(password)
+		hash = encoded
+	} else {
+		rehash = len(hasher.pepper) > 0
+	}
+
+	if match, err = argon2id.ComparePasswordAndHash(password, hash); err != nil || !match {
+		return false, false, err
+	}
+
+	if params, _, _, err = argon2id.DecodeHash(hash); err != nil {
+		return false, false, err
+	}
+
+	return true, rehash || *params != *hasher.params, nil
+}
+
+// VerifyDummy spends the same time as Verify, so the response timing doesn't
+// reveal whether an account exists.
+func (hasher *Hasher) VerifyDummy(password string) {
+	_, _, _ = hasher.Verify(password, hasher.dummyHash())
+}
+
+func (hasher *Hasher) peppered(password string) string {
+	mac := hmac.New(sha256.New, hasher.pepper)
+	mac.Write([]byte(password))
+
+	return string(mac.Sum(nil))
+}
+
+func NewHasher(params *argon2id.Params, pepper string) *Hasher {
+	hasher := &Hasher{
+		params: params,
+		pepper: []byte(pepper),
+	}
+	hasher.dummyHash = sync.OnceValue(func() string {
+		hash, _ := hasher.Hash(randstr.String(32))
+		return hash
+	})
+
+	return hasher
+}
+
+func NewConfiguredHasher() *Hasher {
+	return NewHasher(&argon2id.Params{
+		Memory:      config.App.ArgonMemory,
+		Iterations:  config.App.ArgonIterations,
+		Parallelism: config.App.ArgonParallelism,
+		SaltLength:  config.App.ArgonSaltLength,
+		KeyLength:   config.App.ArgonKeyLength,
+	}, config.App.PasswordPepper)
+}
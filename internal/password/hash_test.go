@@ -0,0 +1,52 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasherVerify(t *testing.T) {
+	params := &argon2id.Params{
+		Memory:      16 * 1024,
+		Iterations:  2,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+	hasher := NewHasher(params, "pepper")
+
+	hash, err := hasher.Hash("correct-Horse-9")
+	require.NoError(t, err)
+	require.Contains(t, hash, pepperedPrefix)
+
+	match, rehash, err := hasher.Verify("correct-Horse-9", hash)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.False(t, rehash)
+
+	match, rehash, err = hasher.Verify("wrong-Horse-9", hash)
+	require.NoError(t, err)
+	require.False(t, match)
+	require.False(t, rehash)
+
+	legacyHash, err := argon2id.CreateHash("correct-Horse-9", params)
+	require.NoError(t, err)
+
+	match, rehash, err = hasher.Verify("correct-Horse-9", legacyHash)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, rehash, "unpeppered hashes should be upgraded")
+
+	weakHash, err := NewHasher(argon2id.DefaultParams, "pepper").Hash("correct-Horse-9")
+	require.NoError(t, err)
+
+	match, rehash, err = hasher.Verify("correct-Horse-9", weakHash)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, rehash, "hashes with other parameters should be upgraded")
+
+	_, _, err = NewHasher(params, "").Verify("correct-Horse-9", hash)
+	require.ErrorIs(t, err, ERR_PEPPER_REQUIRED)
+}
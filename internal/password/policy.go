@@ -0,0 +1,156 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	"github.com/dharmavagabond/simple-bank/internal/valid"
+)
+
+const maxLength = 100
+
+var (
+	ERR_PASSWORD_REUSED      = errors.New("[Err]: The password was used recently")
+	ERR_INVALID_BREACHED_SET = errors.New("[Err]: Invalid breached passwords file")
+)
+
+type (
+	Config struct {
+		MinLength  int
+		MinClasses int
+		// HistorySize is how many of the latest passwords, the current one
+		// included, can't be reused. Zero disables the check.
+		HistorySize int
+	}
+
+	Policy struct {
+		breached *BreachedSet
+		hasher   *Hasher
+		config   Config
+	}
+)
+
+// Validate checks a new password, the username and email it's compared to
+// being those of its account.
+func (policy *Policy) Validate(password, username, email string) error {
+	if err := valid.ValidateString(password, policy.config.MinLength, maxLength); err != nil {
+		return err
+	}
+
+	if countClasses(password) < policy.config.MinClasses {
+		return fmt.Errorf(
+			"must contain at least %d of lower case letters, upper case letters, digits or symbols",
+			policy.config.MinClasses,
+		)
+	}
+
+	if resembles(password, username, email) {
+		return fmt.Errorf("must not contain the username or email")
+	}
+
+	if policy.breached.Contains(password) {
+		return fmt.Errorf("has appeared in a data breach, choose another one")
+	}
+
+	return nil
+}
+
+// CheckHistory compares password with the account's latest hashes, most
+// recent first, returning ERR_PASSWORD_REUSED on a match.
+func (policy *Policy) CheckHistory(password string, hashes []string) error {
+	if len(hashes) > policy.config.HistorySize {
+		hashes = hashes[:policy.config.HistorySize]
+	}
+
+	for _, hash := range hashes {
+		match, _, err := policy.hasher.Verify(password, hash)
+		if err != nil {
+			return err
+		}
+
+		if match {
+			return ERR_PASSWORD_REUSED
+		}
+	}
+
+	return nil
+}
+
+func (policy *Policy) HistorySize() int {
+	return policy.config.HistorySize
+}
+
+func countClasses(password string) (n int) {
+	var lower, upper, digit, symbol bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// resembles reports whether password contains the username or the local
+// part of the email, ignoring case. Parts shorter than 3 characters are
+// ignored.
+func resembles(password, username, email string) bool {
+	password = strings.ToLower(password)
+	local, _, _ := strings.Cut(email, "@")
+
+	for _, part := range []string{username, local} {
+		if len(part) >= 3 && strings.Contains(password, strings.ToLower(part)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewPolicy takes the hasher that created the password hashes it checks
+// the history against.
+func NewPolicy(cfg Config, breached *BreachedSet, hasher *Hasher) *Policy {
+	return &Policy{
+		breached: breached,
+		hasher:   hasher,
+		config:   cfg,
+	}
+}
+
+// NewConfiguredPolicy loads the breached passwords from
+// APP_PASSWORD_BREACH_FILE when set.
+func NewConfiguredPolicy(hasher *Hasher) (*Policy, error) {
+	var (
+		breached *BreachedSet
+		err      error
+	)
+
+	if len(config.App.PasswordBreachFile) > 0 {
+		if breached, err = LoadBreachedSet(config.App.PasswordBreachFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewPolicy(Config{
+		MinLength:   config.App.PasswordMinLength,
+		MinClasses:  config.App.PasswordMinClasses,
+		HistorySize: config.App.PasswordHistorySize,
+	}, breached, hasher), nil
+}
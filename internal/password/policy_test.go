@@ -0,0 +1,63 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	breached, err := ReadBreachedSet(strings.NewReader(
+		sha1Hex("Tr0ub4dor&3") + ":42\n",
+	))
+	require.NoError(t, err)
+
+	policy := NewPolicy(Config{MinLength: 10, MinClasses: 3}, breached, nil)
+	testCases := []struct {
+		name     string
+		password string
+		ok       bool
+	}{
+		{name: "OK", password: "correct-Horse-battery-9", ok: true},
+		{name: "TooShort", password: "aB1!", ok: false},
+		{name: "TooFewClasses", password: "correcthorsebattery", ok: false},
+		{name: "ContainsUsername", password: "Xx1-Alice_Smith-2024", ok: false},
+		{name: "ContainsEmail", password: "1-Wonderland!x", ok: false},
+		{name: "Breached", password: "Tr0ub4dor&3", ok: false},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Validate(tc.password, "alice_smith", "wonderland@example.com")
+
+			if tc.ok {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckHistory(t *testing.T) {
+	hasher := NewHasher(argon2id.DefaultParams, "pepper")
+	hashes := make([]string, 0, 3)
+
+	for _, password := range []string{"Newest-Pass-1", "Middle-Pass-2", "Oldest-Pass-3"} {
+		hash, err := hasher.Hash(password)
+		require.NoError(t, err)
+
+		hashes = append(hashes, hash)
+	}
+
+	policy := NewPolicy(Config{HistorySize: 2}, nil, hasher)
+
+	require.ErrorIs(t, policy.CheckHistory("Newest-Pass-1", hashes), ERR_PASSWORD_REUSED)
+	require.ErrorIs(t, policy.CheckHistory("Middle-Pass-2", hashes), ERR_PASSWORD_REUSED)
+	require.NoError(t, policy.CheckHistory("Oldest-Pass-3", hashes))
+	require.NoError(t, policy.CheckHistory("Brand-New-Pass-4", hashes))
+}
@@ -19,5 +19,16 @@ var (
 	)
 	ERR_INVALID_PASETO_TOKEN    = errors.New("[Err]: Invalid token")
 	ERR_EXPIRED_TOKEN           = errors.New("[Err]: Token has expired")
+	ERR_REVOKED_TOKEN           = errors.New("[Err]: Token has been revoked")
+	ERR_REFRESH_TOKEN           = errors.New("[Err]: Refresh tokens can't authorize requests")
+	ERR_MISSING_SCOPES          = errors.New("[Err]: Scoped tokens need at least one scope")
 	ERR_CANT_CREATE_TOKEN_MAKER = errors.New("[Err]: Cannot create token maker")
+	ERR_UNKNOWN_KEY             = errors.New("[Err]: Unknown key")
+	ERR_NO_CURRENT_KEY          = errors.New("[Err]: The keyring has no current key")
+	ERR_MULTIPLE_CURRENT_KEYS   = errors.New("[Err]: The keyring has more than one current key")
+	ERR_RETIRED_KEY             = errors.New("[Err]: The key has been retired")
+	ERR_RETIRING_CURRENT_KEY    = errors.New("[Err]: Cannot retire the current key, promote another one first")
+	ERR_UNKNOWN_KEY_PURPOSE     = errors.New("[Err]: Unknown key purpose")
+	ERR_KEYRING_FILE_REQUIRED   = errors.New("[Err]: Public tokens require a keyring file")
+	ERR_UNKNOWN_TOKEN_FORMAT    = errors.New("[Err]: Unknown token format")
 )
@@ -15,8 +15,46 @@ type JWTMaker struct {
 	secretKey string
 }
 
-func (maker *JWTMaker) CreateToken(username string, duration time.Duration) (token string, payload *Payload, err error) {
-	if payload, err = NewPayload(username, duration); err != nil {
+func (maker *JWTMaker) CreateToken(username string, role string, duration time.Duration) (token string, payload *Payload, err error) {
+	if payload, err = NewPayload(username, role, duration); err != nil {
+		return
+	}
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+
+	if token, err = jwtToken.SignedString([]byte(maker.secretKey)); err != nil {
+		return
+	}
+
+	return
+}
+
+func (maker *JWTMaker) CreateScopedToken(
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) (token string, payload *Payload, err error) {
+	if payload, err = NewScopedPayload(username, role, scopes, duration); err != nil {
+		return
+	}
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+
+	if token, err = jwtToken.SignedString([]byte(maker.secretKey)); err != nil {
+		return
+	}
+
+	return
+}
+
+func (maker *JWTMaker) CreateRefreshToken(
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) (token string, payload *Payload, err error) {
+	if payload, err = NewRefreshPayload(username, role, scopes, duration); err != nil {
 		return
 	}
 
@@ -16,10 +16,11 @@ func TestJWTMaker(t *testing.T) {
 	require.NoError(t, err)
 
 	username := strings.ToLower(randomdata.SillyName())
+	role := "banker"
 	duration := time.Minute
 	issuedAt := time.Now()
 	expiredAt := issuedAt.Add(duration)
-	token, payload, err := maker.CreateToken(username, duration)
+	token, payload, err := maker.CreateToken(username, role, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -29,6 +30,7 @@ func TestJWTMaker(t *testing.T) {
 	require.NotEmpty(t, payload)
 	require.NotZero(t, payload.ID)
 	require.Equal(t, username, payload.Username)
+	require.Equal(t, role, payload.Role)
 	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
 	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
 }
@@ -38,8 +40,9 @@ func TestJWTExpiredToken(t *testing.T) {
 	require.NoError(t, err)
 
 	username := strings.ToLower(randomdata.SillyName())
+	role := "banker"
 	duration := -time.Minute
-	token, _, err := maker.CreateToken(username, duration)
+	token, _, err := maker.CreateToken(username, role, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 
@@ -51,7 +54,7 @@ func TestJWTExpiredToken(t *testing.T) {
 
 func TestInvalidJWTSigningMethod(t *testing.T) {
 	username := strings.ToLower(randomdata.SillyName())
-	payload, err := NewPayload(username, time.Minute)
+	payload, err := NewPayload(username, "depositor", time.Minute)
 	require.NoError(t, err)
 
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodNone, payload)
@@ -0,0 +1,216 @@
+package token
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/thanhpk/randstr"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	// PurposeLocal keys are symmetric and encrypt v4.local tokens.
+	PurposeLocal = "local"
+	// PurposePublic keys are Ed25519 key pairs that sign v4.public tokens.
+	PurposePublic = "public"
+
+	// KeyStatusCurrent signs new tokens; there must be exactly one.
+	KeyStatusCurrent = "current"
+	// KeyStatusActive only verifies, either because the key was just
+	// generated and is waiting to be promoted or because it was replaced.
+	KeyStatusActive = "active"
+	// KeyStatusRetired is kept for the record but no longer verifies.
+	KeyStatusRetired = "retired"
+
+	keyIDSize = 8
+)
+
+type (
+	Key struct {
+		CreatedAt time.Time `json:"created_at"`
+		ID        string    `json:"id"`
+		Purpose   string    `json:"purpose,omitempty"`
+		Secret    string    `json:"secret"`
+		Status    string    `json:"status"`
+	}
+
+	Keyring struct {
+		Keys []*Key `json:"keys"`
+	}
+)
+
+// Keys written before purposes existed are symmetric.
+func (key *Key) purpose() string {
+	if len(key.Purpose) == 0 {
+		return PurposeLocal
+	}
+
+	return key.Purpose
+}
+
+func (key *Key) symmetricKey() (paseto.V4SymmetricKey, error) {
+	secret, err := hex.DecodeString(key.Secret)
+	if err != nil {
+		return paseto.V4SymmetricKey{}, err
+	}
+
+	if len(secret) != chacha20.KeySize {
+		return paseto.V4SymmetricKey{}, ERR_INVALID_PASETO_KEY_SIZE
+	}
+
+	return paseto.V4SymmetricKeyFromBytes(secret)
+}
+
+func (key *Key) secretKey() (paseto.V4AsymmetricSecretKey, error) {
+	return paseto.NewV4AsymmetricSecretKeyFromHex(key.Secret)
+}
+
+func (keyring *Keyring) Current(purpose string) (*Key, error) {
+	var current *Key
+
+	for _, key := range keyring.Keys {
+		if key.Status != KeyStatusCurrent || key.purpose() != purpose {
+			continue
+		}
+
+		if current != nil {
+			return nil, ERR_MULTIPLE_CURRENT_KEYS
+		}
+
+		current = key
+	}
+
+	if current == nil {
+		return nil, ERR_NO_CURRENT_KEY
+	}
+
+	return current, nil
+}
+
+func (keyring *Keyring) Find(id string) (*Key, error) {
+	for _, key := range keyring.Keys {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+
+	return nil, ERR_UNKNOWN_KEY
+}
+
+// Generate adds a verification-only key. Roll the keyring out to every
+// instance before promoting it, so tokens signed with it verify everywhere.
+// The first key of each purpose becomes current right away.
+func (keyring *Keyring) Generate(purpose string) (*Key, error) {
+	key := &Key{
+		ID:        randstr.Hex(keyIDSize),
+		Purpose:   purpose,
+		Status:    KeyStatusActive,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	switch purpose {
+	case PurposeLocal:
+		key.Secret = paseto.NewV4SymmetricKey().ExportHex()
+	case PurposePublic:
+		key.Secret = paseto.NewV4AsymmetricSecretKey().ExportHex()
+	default:
+		return nil, ERR_UNKNOWN_KEY_PURPOSE
+	}
+
+	if _, err := keyring.Current(purpose); errors.Is(err, ERR_NO_CURRENT_KEY) {
+		key.Status = KeyStatusCurrent
+	}
+
+	keyring.Keys = append(keyring.Keys, key)
+
+	return key, nil
+}
+
+// Promote makes the key the signing key for its purpose and demotes the
+// previous one to verification only.
+func (keyring *Keyring) Promote(id string) error {
+	key, err := keyring.Find(id)
+	if err != nil {
+		return err
+	}
+
+	if key.Status == KeyStatusRetired {
+		return ERR_RETIRED_KEY
+	}
+
+	for _, other := range keyring.Keys {
+		if other.Status == KeyStatusCurrent && other.purpose() == key.purpose() {
+			other.Status = KeyStatusActive
+		}
+	}
+
+	key.Status = KeyStatusCurrent
+
+	return nil
+}
+
+func (keyring *Keyring) Retire(id string) error {
+	key, err := keyring.Find(id)
+	if err != nil {
+		return err
+	}
+
+	if key.Status == KeyStatusCurrent {
+		return ERR_RETIRING_CURRENT_KEY
+	}
+
+	key.Status = KeyStatusRetired
+
+	return nil
+}
+
+func (keyring *Keyring) Save(path string) error {
+	data, err := json.MarshalIndent(keyring, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+func LoadKeyring(path string) (keyring *Keyring, err error) {
+	var data []byte
+
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, err
+	}
+
+	keyring = &Keyring{}
+
+	if err = json.Unmarshal(data, keyring); err != nil {
+		return nil, err
+	}
+
+	return keyring, nil
+}
+
+// NewStaticKeyring wraps a single symmetric key, as configured before
+// keyrings existed. Its ID is derived from the key so every instance agrees
+// on it.
+func NewStaticKeyring(symmetricKey string) (*Keyring, error) {
+	if len(symmetricKey) != chacha20.KeySize {
+		return nil, ERR_INVALID_PASETO_KEY_SIZE
+	}
+
+	sum := sha256.Sum256([]byte(symmetricKey))
+	keyring := &Keyring{
+		Keys: []*Key{{
+			ID:      hex.EncodeToString(sum[:keyIDSize/2]),
+			Purpose: PurposeLocal,
+			Secret:  hex.EncodeToString([]byte(symmetricKey)),
+			Status:  KeyStatusCurrent,
+		}},
+	}
+
+	return keyring, nil
+}
@@ -0,0 +1,191 @@
+package token
+
+import (
+	"encoding/json"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+)
+
+type (
+	KeyringPasetoMaker struct {
+		keys       map[string]paseto.V4SymmetricKey
+		currentKID string
+	}
+
+	keyringFooter struct {
+		KID string `json:"kid"`
+	}
+)
+
+func (maker *KeyringPasetoMaker) CreateToken(
+	username string,
+	role string,
+	duration time.Duration,
+) (token string, payload *Payload, err error) {
+	var pasetoToken *paseto.Token
+
+	if payload, err = NewPayload(username, role, duration); err != nil {
+		return
+	}
+
+	if pasetoToken, err = newKeyedToken(payload, maker.currentKID); err != nil {
+		return
+	}
+
+	token = pasetoToken.V4Encrypt(maker.keys[maker.currentKID], nil)
+
+	return
+}
+
+func (maker *KeyringPasetoMaker) CreateScopedToken(
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) (token string, payload *Payload, err error) {
+	var pasetoToken *paseto.Token
+
+	if payload, err = NewScopedPayload(username, role, scopes, duration); err != nil {
+		return
+	}
+
+	if pasetoToken, err = newKeyedToken(payload, maker.currentKID); err != nil {
+		return
+	}
+
+	token = pasetoToken.V4Encrypt(maker.keys[maker.currentKID], nil)
+
+	return
+}
+
+func (maker *KeyringPasetoMaker) CreateRefreshToken(
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) (token string, payload *Payload, err error) {
+	var pasetoToken *paseto.Token
+
+	if payload, err = NewRefreshPayload(username, role, scopes, duration); err != nil {
+		return
+	}
+
+	if pasetoToken, err = newKeyedToken(payload, maker.currentKID); err != nil {
+		return
+	}
+
+	token = pasetoToken.V4Encrypt(maker.keys[maker.currentKID], nil)
+
+	return
+}
+
+func (maker *KeyringPasetoMaker) VerifyToken(token string) (payload *Payload, err error) {
+	var (
+		pasetoToken *paseto.Token
+		kid         string
+	)
+
+	parser := paseto.NewParser()
+
+	if kid, err = parseKeyID(paseto.V4Local, token); err != nil {
+		return nil, err
+	}
+
+	if len(kid) > 0 {
+		key, ok := maker.keys[kid]
+		if !ok {
+			return nil, ERR_UNKNOWN_KEY
+		}
+
+		pasetoToken, err = parser.ParseV4Local(key, token, nil)
+	} else {
+		// Tokens issued before key IDs were introduced carry no footer.
+		for _, key := range maker.keys {
+			if pasetoToken, err = parser.ParseV4Local(key, token, nil); err == nil {
+				break
+			}
+		}
+	}
+
+	return payloadFromToken(pasetoToken, err)
+}
+
+func newKeyedToken(payload *Payload, kid string) (*paseto.Token, error) {
+	footer, err := json.Marshal(keyringFooter{KID: kid})
+	if err != nil {
+		return nil, err
+	}
+
+	token := newPasetoToken(payload)
+	token.SetFooter(footer)
+
+	return &token, nil
+}
+
+// parseKeyID reads the key ID from the footer before the token is verified,
+// so it must only be used to pick the verification key.
+func parseKeyID(protocol paseto.Protocol, token string) (string, error) {
+	var footer keyringFooter
+
+	rawFooter, err := paseto.NewParser().UnsafeParseFooter(protocol, token)
+	if err != nil {
+		return "", ERR_INVALID_PASETO_TOKEN
+	}
+
+	if len(rawFooter) == 0 {
+		return "", nil
+	}
+
+	if err = json.Unmarshal(rawFooter, &footer); err != nil {
+		return "", ERR_INVALID_PASETO_TOKEN
+	}
+
+	return footer.KID, nil
+}
+
+func payloadFromToken(pasetoToken *paseto.Token, err error) (payload *Payload, _ error) {
+	if err != nil {
+		if err.Error() == "this token has expired" {
+			return nil, ERR_EXPIRED_TOKEN
+		}
+
+		return nil, err
+	}
+
+	if err = json.Unmarshal(pasetoToken.ClaimsJSON(), &payload); err != nil {
+		return nil, err
+	}
+
+	if err = payload.Valid(); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// NewKeyringPasetoMaker encrypts with the keyring's current local key and
+// decrypts with any local key that hasn't been retired.
+func NewKeyringPasetoMaker(keyring *Keyring) (Maker, error) {
+	current, err := keyring.Current(PurposeLocal)
+	if err != nil {
+		return nil, err
+	}
+
+	maker := &KeyringPasetoMaker{
+		keys:       make(map[string]paseto.V4SymmetricKey, len(keyring.Keys)),
+		currentKID: current.ID,
+	}
+
+	for _, key := range keyring.Keys {
+		if key.Status == KeyStatusRetired || key.purpose() != PurposeLocal {
+			continue
+		}
+
+		if maker.keys[key.ID], err = key.symmetricKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	return maker, nil
+}
@@ -0,0 +1,128 @@
+package token
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"github.com/thanhpk/randstr"
+)
+
+func TestKeyringPasetoMaker(t *testing.T) {
+	keyring := &Keyring{}
+	oldKey, err := keyring.Generate(PurposeLocal)
+	require.NoError(t, err)
+	require.Equal(t, KeyStatusCurrent, oldKey.Status)
+
+	maker, err := NewKeyringPasetoMaker(keyring)
+	require.NoError(t, err)
+
+	username := strings.ToLower(randomdata.SillyName())
+	oldToken, _, err := maker.CreateToken(username, "depositor", time.Minute)
+	require.NoError(t, err)
+
+	newKey, err := keyring.Generate(PurposeLocal)
+	require.NoError(t, err)
+	require.Equal(t, KeyStatusActive, newKey.Status)
+	require.NoError(t, keyring.Promote(newKey.ID))
+	require.Equal(t, KeyStatusActive, oldKey.Status)
+
+	maker, err = NewKeyringPasetoMaker(keyring)
+	require.NoError(t, err)
+
+	newToken, _, err := maker.CreateToken(username, "depositor", time.Minute)
+	require.NoError(t, err)
+
+	for _, token := range []string{oldToken, newToken} {
+		payload, err := maker.VerifyToken(token)
+		require.NoError(t, err)
+		require.Equal(t, username, payload.Username)
+	}
+
+	require.ErrorIs(t, keyring.Retire(newKey.ID), ERR_RETIRING_CURRENT_KEY)
+	require.NoError(t, keyring.Retire(oldKey.ID))
+	require.ErrorIs(t, keyring.Promote(oldKey.ID), ERR_RETIRED_KEY)
+
+	maker, err = NewKeyringPasetoMaker(keyring)
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(oldToken)
+	require.ErrorIs(t, err, ERR_UNKNOWN_KEY)
+
+	_, err = maker.VerifyToken(newToken)
+	require.NoError(t, err)
+}
+
+func TestKeyringPasetoMakerExpiredToken(t *testing.T) {
+	keyring := &Keyring{}
+	_, err := keyring.Generate(PurposeLocal)
+	require.NoError(t, err)
+
+	maker, err := NewKeyringPasetoMaker(keyring)
+	require.NoError(t, err)
+
+	token, _, err := maker.CreateToken(strings.ToLower(randomdata.SillyName()), "depositor", -time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.ErrorIs(t, err, ERR_EXPIRED_TOKEN)
+	require.Nil(t, payload)
+}
+
+func TestStaticKeyringAcceptsLegacyTokens(t *testing.T) {
+	symmetricKey := randstr.String(32)
+	legacyMaker, err := NewPasetoMaker(symmetricKey)
+	require.NoError(t, err)
+
+	username := strings.ToLower(randomdata.SillyName())
+	token, _, err := legacyMaker.CreateToken(username, "banker", time.Minute)
+	require.NoError(t, err)
+
+	keyring, err := NewStaticKeyring(symmetricKey)
+	require.NoError(t, err)
+
+	sameKeyring, err := NewStaticKeyring(symmetricKey)
+	require.NoError(t, err)
+	require.Equal(t, keyring.Keys[0].ID, sameKeyring.Keys[0].ID)
+
+	maker, err := NewKeyringPasetoMaker(keyring)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, "banker", payload.Role)
+}
+
+func TestKeyringSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	keyring := &Keyring{}
+
+	for _, purpose := range []string{PurposeLocal, PurposeLocal, PurposePublic} {
+		_, err := keyring.Generate(purpose)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, keyring.Save(path))
+
+	loaded, err := LoadKeyring(path)
+	require.NoError(t, err)
+	require.Len(t, loaded.Keys, 3)
+
+	_, err = loaded.Generate("shared")
+	require.ErrorIs(t, err, ERR_UNKNOWN_KEY_PURPOSE)
+
+	current, err := loaded.Current(PurposeLocal)
+	require.NoError(t, err)
+	require.Equal(t, keyring.Keys[0].ID, current.ID)
+
+	loaded.Keys[1].Status = KeyStatusCurrent
+	_, err = NewKeyringPasetoMaker(loaded)
+	require.ErrorIs(t, err, ERR_MULTIPLE_CURRENT_KEYS)
+
+	_, err = NewKeyringPasetoMaker(&Keyring{})
+	require.ErrorIs(t, err, ERR_NO_CURRENT_KEY)
+}
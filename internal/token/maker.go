@@ -1,8 +1,49 @@
 package token
 
-import "time"
+import (
+	"time"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+)
 
 type Maker interface {
-	CreateToken(username string, duration time.Duration) (string, *Payload, error)
+	CreateToken(username string, role string, duration time.Duration) (string, *Payload, error)
+	// CreateScopedToken issues a delegated token limited to the given scopes.
+	CreateScopedToken(username string, role string, scopes []string, duration time.Duration) (string, *Payload, error)
+	// CreateRefreshToken issues a session's refresh token, keeping the
+	// scopes of OAuth sessions.
+	CreateRefreshToken(username string, role string, scopes []string, duration time.Duration) (string, *Payload, error)
 	VerifyToken(token string) (*Payload, error)
 }
+
+// NewConfiguredMaker loads the keyring from APP_TOKEN_KEYRING_FILE when set,
+// falling back to the single APP_TOKEN_SYMMETRIC_KEY for local tokens.
+func NewConfiguredMaker() (Maker, error) {
+	var (
+		keyring *Keyring
+		err     error
+	)
+
+	if len(config.App.TokenKeyringFile) > 0 {
+		keyring, err = LoadKeyring(config.App.TokenKeyringFile)
+	} else {
+		keyring, err = NewStaticKeyring(config.App.TokenSymmetricKey)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.App.TokenFormat {
+	case PurposeLocal:
+		return NewKeyringPasetoMaker(keyring)
+	case PurposePublic:
+		if len(config.App.TokenKeyringFile) == 0 {
+			return nil, ERR_KEYRING_FILE_REQUIRED
+		}
+
+		return NewPublicPasetoMaker(keyring)
+	}
+
+	return nil, ERR_UNKNOWN_TOKEN_FORMAT
+}
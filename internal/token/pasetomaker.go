@@ -8,23 +8,52 @@ import (
 )
 
 type PasetoMaker struct {
-	pasetoToken  *paseto.Token
 	symmetricKey paseto.V4SymmetricKey
 }
 
 func (maker *PasetoMaker) CreateToken(
 	username string,
+	role string,
 	duration time.Duration,
 ) (token string, payload *Payload, err error) {
-	if payload, err = NewPayload(username, duration); err != nil {
+	if payload, err = NewPayload(username, role, duration); err != nil {
 		return
 	}
 
-	maker.pasetoToken.SetString("id", payload.ID.String())
-	maker.pasetoToken.SetString("username", payload.Username)
-	maker.pasetoToken.SetIssuedAt(payload.IssuedAt)
-	maker.pasetoToken.SetExpiration(payload.ExpiredAt)
-	token = maker.pasetoToken.V4Encrypt(maker.symmetricKey, nil)
+	pasetoToken := newPasetoToken(payload)
+	token = pasetoToken.V4Encrypt(maker.symmetricKey, nil)
+
+	return
+}
+
+func (maker *PasetoMaker) CreateScopedToken(
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) (token string, payload *Payload, err error) {
+	if payload, err = NewScopedPayload(username, role, scopes, duration); err != nil {
+		return
+	}
+
+	pasetoToken := newPasetoToken(payload)
+	token = pasetoToken.V4Encrypt(maker.symmetricKey, nil)
+
+	return
+}
+
+func (maker *PasetoMaker) CreateRefreshToken(
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) (token string, payload *Payload, err error) {
+	if payload, err = NewRefreshPayload(username, role, scopes, duration); err != nil {
+		return
+	}
+
+	pasetoToken := newPasetoToken(payload)
+	token = pasetoToken.V4Encrypt(maker.symmetricKey, nil)
 
 	return
 }
@@ -52,20 +81,33 @@ func (maker *PasetoMaker) VerifyToken(token string) (payload *Payload, err error
 	return payload, nil
 }
 
+func newPasetoToken(payload *Payload) paseto.Token {
+	token := paseto.NewToken()
+	token.SetString("id", payload.ID.String())
+	token.SetString("username", payload.Username)
+	token.SetString("role", payload.Role)
+	token.SetIssuedAt(payload.IssuedAt)
+	token.SetExpiration(payload.ExpiredAt)
+
+	if payload.Scopes != nil {
+		_ = token.Set("scopes", payload.Scopes)
+	}
+
+	if len(payload.Type) > 0 {
+		token.SetString("type", payload.Type)
+	}
+
+	return token
+}
+
 func NewPasetoMaker(symmetricKey string) (maker Maker, err error) {
-	var (
-		pv4sk paseto.V4SymmetricKey
-		token = paseto.NewToken()
-	)
+	var pv4sk paseto.V4SymmetricKey
 
 	if pv4sk, err = paseto.V4SymmetricKeyFromBytes([]byte(symmetricKey)); err != nil {
 		return
 	}
 
-	maker = &PasetoMaker{
-		pasetoToken:  &token,
-		symmetricKey: pv4sk,
-	}
+	maker = &PasetoMaker{symmetricKey: pv4sk}
 
 	return
 }
@@ -6,10 +6,19 @@ import (
 	"github.com/google/uuid"
 )
 
+// TypeRefresh marks refresh tokens, which only renew a session and are
+// refused as bearer credentials. Access tokens carry no type.
+const TypeRefresh = "refresh"
+
+// Payload.Scopes is only set for delegated credentials, API keys and OAuth
+// tokens, which may then perform just those actions.
 type Payload struct {
 	IssuedAt  time.Time `json:"iat"`
 	ExpiredAt time.Time `json:"exp"`
 	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Scopes    []string  `json:"scopes,omitempty"`
+	Type      string    `json:"type,omitempty"`
 	ID        uuid.UUID `json:"id"`
 }
 
@@ -21,13 +30,56 @@ func (payload *Payload) Valid() error {
 	return nil
 }
 
-func NewPayload(username string, duration time.Duration) (payload *Payload, err error) {
+// IsRefresh reports whether the payload is a refresh token's.
+func (payload *Payload) IsRefresh() bool {
+	return payload.Type == TypeRefresh
+}
+
+func NewPayload(username string, role string, duration time.Duration) (payload *Payload, err error) {
 	payload = &Payload{
 		ID:        uuid.New(),
 		Username:  username,
+		Role:      role,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration),
 	}
 
 	return
 }
+
+func NewScopedPayload(
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) (payload *Payload, err error) {
+	if len(scopes) == 0 {
+		return nil, ERR_MISSING_SCOPES
+	}
+
+	if payload, err = NewPayload(username, role, duration); err != nil {
+		return nil, err
+	}
+
+	payload.Scopes = scopes
+
+	return payload, nil
+}
+
+// NewRefreshPayload is the payload of a session's refresh token. OAuth
+// sessions keep their scopes, which are nil otherwise.
+func NewRefreshPayload(
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) (payload *Payload, err error) {
+	if payload, err = NewPayload(username, role, duration); err != nil {
+		return nil, err
+	}
+
+	payload.Scopes = scopes
+	payload.Type = TypeRefresh
+
+	return payload, nil
+}
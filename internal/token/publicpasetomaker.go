@@ -0,0 +1,168 @@
+package token
+
+import (
+	"sort"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+)
+
+const PublicTokenVersion = "v4.public"
+
+type (
+	// KeyPublisher is implemented by makers whose tokens can be verified by
+	// third parties holding only the published keys.
+	KeyPublisher interface {
+		PublicKeys() []PublicKey
+	}
+
+	PublicKey struct {
+		ID        string
+		Version   string
+		PublicKey string
+		Status    string
+	}
+
+	PublicPasetoMaker struct {
+		secretKey  paseto.V4AsymmetricSecretKey
+		keys       map[string]PublicKey
+		publicKeys map[string]paseto.V4AsymmetricPublicKey
+		currentKID string
+	}
+)
+
+func (maker *PublicPasetoMaker) CreateToken(
+	username string,
+	role string,
+	duration time.Duration,
+) (token string, payload *Payload, err error) {
+	var pasetoToken *paseto.Token
+
+	if payload, err = NewPayload(username, role, duration); err != nil {
+		return
+	}
+
+	if pasetoToken, err = newKeyedToken(payload, maker.currentKID); err != nil {
+		return
+	}
+
+	token = pasetoToken.V4Sign(maker.secretKey, nil)
+
+	return
+}
+
+func (maker *PublicPasetoMaker) CreateScopedToken(
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) (token string, payload *Payload, err error) {
+	var pasetoToken *paseto.Token
+
+	if payload, err = NewScopedPayload(username, role, scopes, duration); err != nil {
+		return
+	}
+
+	if pasetoToken, err = newKeyedToken(payload, maker.currentKID); err != nil {
+		return
+	}
+
+	token = pasetoToken.V4Sign(maker.secretKey, nil)
+
+	return
+}
+
+func (maker *PublicPasetoMaker) CreateRefreshToken(
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) (token string, payload *Payload, err error) {
+	var pasetoToken *paseto.Token
+
+	if payload, err = NewRefreshPayload(username, role, scopes, duration); err != nil {
+		return
+	}
+
+	if pasetoToken, err = newKeyedToken(payload, maker.currentKID); err != nil {
+		return
+	}
+
+	token = pasetoToken.V4Sign(maker.secretKey, nil)
+
+	return
+}
+
+func (maker *PublicPasetoMaker) VerifyToken(token string) (*Payload, error) {
+	kid, err := parseKeyID(paseto.V4Public, token)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := maker.publicKeys[kid]
+	if !ok {
+		return nil, ERR_UNKNOWN_KEY
+	}
+
+	return payloadFromToken(paseto.NewParser().ParseV4Public(key, token, nil))
+}
+
+// PublicKeys returns the verification keys with the current one first.
+func (maker *PublicPasetoMaker) PublicKeys() []PublicKey {
+	keys := make([]PublicKey, 0, len(maker.keys))
+
+	for _, key := range maker.keys {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ID == maker.currentKID || keys[j].ID == maker.currentKID {
+			return keys[i].ID == maker.currentKID
+		}
+
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys
+}
+
+// NewPublicPasetoMaker signs with the keyring's current public key and
+// verifies with any public key that hasn't been retired.
+func NewPublicPasetoMaker(keyring *Keyring) (Maker, error) {
+	current, err := keyring.Current(PurposePublic)
+	if err != nil {
+		return nil, err
+	}
+
+	maker := &PublicPasetoMaker{
+		keys:       make(map[string]PublicKey, len(keyring.Keys)),
+		publicKeys: make(map[string]paseto.V4AsymmetricPublicKey, len(keyring.Keys)),
+		currentKID: current.ID,
+	}
+
+	for _, key := range keyring.Keys {
+		if key.Status == KeyStatusRetired || key.purpose() != PurposePublic {
+			continue
+		}
+
+		secretKey, err := key.secretKey()
+		if err != nil {
+			return nil, err
+		}
+
+		if key.ID == current.ID {
+			maker.secretKey = secretKey
+		}
+
+		publicKey := secretKey.Public()
+		maker.publicKeys[key.ID] = publicKey
+		maker.keys[key.ID] = PublicKey{
+			ID:        key.ID,
+			Version:   PublicTokenVersion,
+			PublicKey: publicKey.ExportHex(),
+			Status:    key.Status,
+		}
+	}
+
+	return maker, nil
+}
@@ -0,0 +1,89 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicPasetoMaker(t *testing.T) {
+	keyring := &Keyring{}
+	_, err := keyring.Generate(PurposeLocal)
+	require.NoError(t, err)
+
+	oldKey, err := keyring.Generate(PurposePublic)
+	require.NoError(t, err)
+	require.Equal(t, KeyStatusCurrent, oldKey.Status)
+
+	maker, err := NewPublicPasetoMaker(keyring)
+	require.NoError(t, err)
+
+	username := strings.ToLower(randomdata.SillyName())
+	token, _, err := maker.CreateToken(username, "auditor", time.Minute)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token, "v4.public."))
+
+	payload, err := maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, "auditor", payload.Role)
+
+	newKey, err := keyring.Generate(PurposePublic)
+	require.NoError(t, err)
+	require.NoError(t, keyring.Promote(newKey.ID))
+
+	maker, err = NewPublicPasetoMaker(keyring)
+	require.NoError(t, err)
+
+	publicKeys := maker.(KeyPublisher).PublicKeys()
+	require.Len(t, publicKeys, 2)
+	require.Equal(t, newKey.ID, publicKeys[0].ID)
+	require.Equal(t, KeyStatusCurrent, publicKeys[0].Status)
+	require.Equal(t, oldKey.ID, publicKeys[1].ID)
+	require.Equal(t, PublicTokenVersion, publicKeys[1].Version)
+
+	// A third party holding only the published key can verify the token.
+	publicKey, err := paseto.NewV4AsymmetricPublicKeyFromHex(publicKeys[1].PublicKey)
+	require.NoError(t, err)
+	_, err = paseto.NewParser().ParseV4Public(publicKey, token, nil)
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(token)
+	require.NoError(t, err)
+
+	require.NoError(t, keyring.Retire(oldKey.ID))
+	maker, err = NewPublicPasetoMaker(keyring)
+	require.NoError(t, err)
+	require.Len(t, maker.(KeyPublisher).PublicKeys(), 1)
+
+	_, err = maker.VerifyToken(token)
+	require.ErrorIs(t, err, ERR_UNKNOWN_KEY)
+}
+
+func TestPublicPasetoMakerRejectsLocalTokens(t *testing.T) {
+	keyring := &Keyring{}
+
+	for _, purpose := range []string{PurposeLocal, PurposePublic} {
+		_, err := keyring.Generate(purpose)
+		require.NoError(t, err)
+	}
+
+	localMaker, err := NewKeyringPasetoMaker(keyring)
+	require.NoError(t, err)
+
+	publicMaker, err := NewPublicPasetoMaker(keyring)
+	require.NoError(t, err)
+
+	token, _, err := localMaker.CreateToken(strings.ToLower(randomdata.SillyName()), "depositor", time.Minute)
+	require.NoError(t, err)
+
+	_, err = publicMaker.VerifyToken(token)
+	require.ErrorIs(t, err, ERR_INVALID_PASETO_TOKEN)
+
+	_, err = NewPublicPasetoMaker(&Keyring{})
+	require.ErrorIs(t, err, ERR_NO_CURRENT_KEY)
+}
@@ -0,0 +1,154 @@
+package token
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+)
+
+const revokedTokenPrefix = "token:revoked:"
+
+// RevocationStore remembers revoked token IDs until the token would have
+// expired anyway.
+type RevocationStore interface {
+	Revoke(ctx context.Context, id uuid.UUID, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+type RedisRevocationStore struct {
+	client redis.UniversalClient
+}
+
+func (store *RedisRevocationStore) Revoke(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+
+	if ttl <= 0 {
+		return nil
+	}
+
+	return store.client.Set(ctx, revokedTokenPrefix+id.String(), 1, ttl).Err()
+}
+
+func (store *RedisRevocationStore) IsRevoked(ctx context.Context, id uuid.UUID) (bool, error) {
+	n, err := store.client.Exists(ctx, revokedTokenPrefix+id.String()).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (store *RedisRevocationStore) Close() error {
+	return store.client.Close()
+}
+
+func NewRedisRevocationStore(client redis.UniversalClient) RevocationStore {
+	return &RedisRevocationStore{client: client}
+}
+
+// MemoryRevocationStore is only visible to the process that owns it, so it's
+// meant for development and tests.
+type MemoryRevocationStore struct {
+	entries map[uuid.UUID]time.Time
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+func (store *MemoryRevocationStore) Revoke(_ context.Context, id uuid.UUID, expiresAt time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.now()
+
+	for entryID, entryExpiresAt := range store.entries {
+		if !now.Before(entryExpiresAt) {
+			delete(store.entries, entryID)
+		}
+	}
+
+	if now.Before(expiresAt) {
+		store.entries[id] = expiresAt
+	}
+
+	return nil
+}
+
+func (store *MemoryRevocationStore) IsRevoked(_ context.Context, id uuid.UUID) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	expiresAt, ok := store.entries[id]
+
+	return ok && store.now().Before(expiresAt), nil
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		entries: make(map[uuid.UUID]time.Time),
+		now:     time.Now,
+	}
+}
+
+// NewConfiguredRevocationStore uses Redis when REDIS_HOST is set and keeps
+// revocations in memory otherwise.
+func NewConfiguredRevocationStore() RevocationStore {
+	if len(config.Redis.Host) == 0 {
+		return NewMemoryRevocationStore()
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr: net.JoinHostPort(config.Redis.Host, config.Redis.Port),
+	})
+
+	return NewRedisRevocationStore(client)
+}
+
+// RevokingMaker rejects tokens whose ID is in the revocation store.
+type RevokingMaker struct {
+	Maker
+	store RevocationStore
+}
+
+func (maker *RevokingMaker) VerifyToken(token string) (payload *Payload, err error) {
+	var revoked bool
+
+	if payload, err = maker.Maker.VerifyToken(token); err != nil {
+		return nil, err
+	}
+
+	if revoked, err = maker.store.IsRevoked(context.Background(), payload.ID); err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, ERR_REVOKED_TOKEN
+	}
+
+	return payload, nil
+}
+
+func (maker *RevokingMaker) Revoke(ctx context.Context, payload *Payload) error {
+	return maker.store.Revoke(ctx, payload.ID, payload.ExpiredAt)
+}
+
+// PublicKeys forwards the wrapped maker's keys, if it publishes any.
+func (maker *RevokingMaker) PublicKeys() []PublicKey {
+	if publisher, ok := maker.Maker.(KeyPublisher); ok {
+		return publisher.PublicKeys()
+	}
+
+	return nil
+}
+
+func NewRevokingMaker(maker Maker, store RevocationStore) *RevokingMaker {
+	return &RevokingMaker{
+		Maker: maker,
+		store: store,
+	}
+}
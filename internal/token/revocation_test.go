@@ -0,0 +1,55 @@
+package token
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/thanhpk/randstr"
+)
+
+func TestMemoryRevocationStoreExpires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryRevocationStore()
+	store.now = func() time.Time { return now }
+	id := uuid.New()
+
+	require.NoError(t, store.Revoke(ctx, id, now.Add(time.Minute)))
+
+	revoked, err := store.IsRevoked(ctx, id)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	now = now.Add(time.Minute)
+	revoked, err = store.IsRevoked(ctx, id)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	require.NoError(t, store.Revoke(ctx, uuid.New(), now.Add(-time.Second)))
+	require.Empty(t, store.entries)
+}
+
+func TestRevokingMaker(t *testing.T) {
+	pasetoMaker, err := NewPasetoMaker(randstr.String(32))
+	require.NoError(t, err)
+
+	maker := NewRevokingMaker(pasetoMaker, NewMemoryRevocationStore())
+	username := strings.ToLower(randomdata.SillyName())
+	token, payload, err := maker.CreateToken(username, "depositor", time.Minute)
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(token)
+	require.NoError(t, err)
+
+	require.NoError(t, maker.Revoke(context.Background(), payload))
+
+	payload, err = maker.VerifyToken(token)
+	require.ErrorIs(t, err, ERR_REVOKED_TOKEN)
+	require.Nil(t, payload)
+	require.Nil(t, maker.PublicKeys())
+}
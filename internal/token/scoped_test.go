@@ -0,0 +1,67 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"github.com/thanhpk/randstr"
+)
+
+func TestScopedToken(t *testing.T) {
+	pasetoMaker, err := NewPasetoMaker(randstr.String(32))
+	require.NoError(t, err)
+
+	jwtMaker, err := NewJWTMaker(randstr.String(32))
+	require.NoError(t, err)
+
+	keyring, err := NewStaticKeyring(randstr.String(32))
+	require.NoError(t, err)
+
+	keyringMaker, err := NewKeyringPasetoMaker(keyring)
+	require.NoError(t, err)
+
+	makers := map[string]Maker{
+		"Paseto":  pasetoMaker,
+		"JWT":     jwtMaker,
+		"Keyring": keyringMaker,
+	}
+
+	for name, maker := range makers {
+		maker := maker
+		t.Run(name, func(t *testing.T) {
+			username := strings.ToLower(randomdata.SillyName())
+			scopes := []string{"account:read"}
+
+			token, _, err := maker.CreateScopedToken(username, "depositor", scopes, time.Minute)
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, scopes, payload.Scopes)
+
+			// Scopes must not leak into tokens created afterwards.
+			token, _, err = maker.CreateToken(username, "depositor", time.Minute)
+			require.NoError(t, err)
+
+			payload, err = maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Nil(t, payload.Scopes)
+
+			require.False(t, payload.IsRefresh())
+
+			_, _, err = maker.CreateScopedToken(username, "depositor", []string{}, time.Minute)
+			require.ErrorIs(t, err, ERR_MISSING_SCOPES)
+
+			token, _, err = maker.CreateRefreshToken(username, "depositor", scopes, time.Minute)
+			require.NoError(t, err)
+
+			payload, err = maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.True(t, payload.IsRefresh())
+			require.Equal(t, scopes, payload.Scopes)
+		})
+	}
+}
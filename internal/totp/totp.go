@@ -0,0 +1,129 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint: gosec // RFC 6238 usa HMAC-SHA1 por defecto
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/thanhpk/randstr"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	Skew   = 1
+
+	secretSize       = 20
+	recoveryCodeSize = 10
+	challengeSize    = 43
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateSecret() string {
+	return encoding.EncodeToString(randstr.Bytes(secretSize))
+}
+
+func URI(issuer, accountName, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func GenerateCode(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return generateCode(key, counter(t), Digits), nil
+}
+
+// Validate returns the time step the code was generated for. A code stays
+// valid for Skew steps either side, so callers must reject steps already used.
+func Validate(code, secret string, t time.Time) (step int64, ok bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+
+	current := int64(counter(t))
+
+	for step = current - Skew; step <= current+Skew; step++ {
+		expected := generateCode(key, uint64(step), Digits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+func GenerateRecoveryCodes(n int) []string {
+	codes := make([]string, n)
+
+	for i := range codes {
+		code := randstr.Hex(recoveryCodeSize)
+		codes[i] = code[:recoveryCodeSize/2] + "-" + code[recoveryCodeSize/2:]
+	}
+
+	return codes
+}
+
+func HashRecoveryCode(code string) string {
+	return hash(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
+
+func NewChallenge() (challenge, hashedChallenge string) {
+	challenge = randstr.Base62(challengeSize)
+	return challenge, HashChallenge(challenge)
+}
+
+func HashChallenge(challenge string) string {
+	return hash(challenge)
+}
+
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	return encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+func counter(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(Period.Seconds())
+}
+
+func generateCode(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
@@ -0,0 +1,89 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCodeRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	testCases := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "94287082"},
+		{unix: 1111111109, code: "07081804"},
+		{unix: 1111111111, code: "14050471"},
+		{unix: 1234567890, code: "89005924"},
+		{unix: 2000000000, code: "69279037"},
+		{unix: 20000000000, code: "65353130"},
+	}
+
+	for _, tc := range testCases {
+		code := generateCode(key, counter(time.Unix(tc.unix, 0)), 8)
+		require.Equal(t, tc.code, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := GenerateSecret()
+	now := time.Now()
+
+	code, err := GenerateCode(secret, now)
+	require.NoError(t, err)
+	require.Len(t, code, Digits)
+
+	step, ok := Validate(code, secret, now)
+	require.True(t, ok)
+	require.Equal(t, int64(counter(now)), step)
+
+	step, ok = Validate(code, secret, now.Add(Period))
+	require.True(t, ok)
+	require.Equal(t, int64(counter(now)), step)
+
+	_, ok = Validate(code, secret, now.Add(3*Period))
+	require.False(t, ok)
+	_, ok = Validate("000000", GenerateSecret(), now)
+	require.False(t, ok)
+	_, ok = Validate(code, "not a secret", now)
+	require.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret := GenerateSecret()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	require.NoError(t, err)
+	require.Len(t, key, secretSize)
+	require.NotEqual(t, secret, GenerateSecret())
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Simple Bank", "erosennin", "JBSWY3DPEHPK3PXP")
+	require.True(t, strings.HasPrefix(uri, "otpauth://totp/Simple%20Bank:erosennin?"))
+	require.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	require.Contains(t, uri, "issuer=Simple+Bank")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := GenerateRecoveryCodes(10)
+	require.Len(t, codes, 10)
+
+	for _, code := range codes {
+		require.Len(t, code, recoveryCodeSize+1)
+		require.Equal(t, HashRecoveryCode(code), HashRecoveryCode(strings.ToUpper(code)))
+		require.Equal(t, HashRecoveryCode(code), HashRecoveryCode(strings.ReplaceAll(code, "-", "")))
+	}
+
+	require.NotEqual(t, HashRecoveryCode(codes[0]), HashRecoveryCode(codes[1]))
+}
+
+func TestChallenge(t *testing.T) {
+	challenge, hashedChallenge := NewChallenge()
+	require.Len(t, challenge, challengeSize)
+	require.Equal(t, hashedChallenge, HashChallenge(challenge))
+	require.NotEqual(t, challenge, hashedChallenge)
+}
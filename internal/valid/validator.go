@@ -32,8 +32,10 @@ func ValidateUsername(value string) (err error) {
 	return nil
 }
 
-func ValidatePassword(value string) error {
-	return ValidateString(value, 10, 100)
+// ValidateLoginPassword only checks a password is given and within the
+// maximum length: the policy minimum may have been raised since it was set.
+func ValidateLoginPassword(value string) error {
+	return ValidateString(value, 1, 100)
 }
 
 func ValidateEmail(value string) (err error) {
@@ -0,0 +1,177 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errCBORMalformed = errors.New("malformed CBOR")
+
+// decodeCBOR decodes the subset of CBOR (RFC 8949) emitted by authenticators
+// and returns the value along with the number of bytes consumed.
+func decodeCBOR(data []byte) (value any, n int, err error) {
+	d := cborDecoder{data: data}
+	if value, err = d.decode(0); err != nil {
+		return nil, 0, err
+	}
+
+	return value, d.pos, nil
+}
+
+const maxCBORDepth = 16
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) decode(depth int) (any, error) {
+	if depth > maxCBORDepth {
+		return nil, errCBORMalformed
+	}
+
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, errCBORMalformed
+		}
+
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, errCBORMalformed
+		}
+
+		return -1 - int64(arg), nil
+	case 2, 3:
+		bs, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if major == 3 {
+			return string(bs), nil
+		}
+
+		return append([]byte(nil), bs...), nil
+	case 4:
+		if arg > uint64(len(d.data)) {
+			return nil, errCBORMalformed
+		}
+
+		items := make([]any, 0, arg)
+
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, item)
+		}
+
+		return items, nil
+	case 5:
+		if arg > uint64(len(d.data)) {
+			return nil, errCBORMalformed
+		}
+
+		items := make(map[any]any, arg)
+
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, errCBORMalformed
+			}
+
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			items[key] = value
+		}
+
+		return items, nil
+	case 7:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		}
+	}
+
+	return nil, errCBORMalformed
+}
+
+func (d *cborDecoder) head() (major byte, arg uint64, err error) {
+	var bs []byte
+
+	if bs, err = d.take(1); err != nil {
+		return 0, 0, err
+	}
+
+	major = bs[0] >> 5
+	info := bs[0] & 0x1f
+
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		bs, err = d.take(1)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		return major, uint64(bs[0]), nil
+	case info == 25:
+		bs, err = d.take(2)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		return major, uint64(binary.BigEndian.Uint16(bs)), nil
+	case info == 26:
+		bs, err = d.take(4)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		return major, uint64(binary.BigEndian.Uint32(bs)), nil
+	case info == 27:
+		bs, err = d.take(8)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		return major, binary.BigEndian.Uint64(bs), nil
+	}
+
+	return 0, 0, errCBORMalformed
+}
+
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCBORMalformed
+	}
+
+	bs := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+
+	return bs, nil
+}
@@ -0,0 +1,17 @@
+package webauthn
+
+import "errors"
+
+var (
+	ERR_INVALID_CLIENT_DATA      = errors.New("[Err]: Invalid client data")
+	ERR_CHALLENGE_MISMATCH       = errors.New("[Err]: Challenge mismatch")
+	ERR_ORIGIN_MISMATCH          = errors.New("[Err]: Origin mismatch")
+	ERR_INVALID_AUTHENTICATOR    = errors.New("[Err]: Invalid authenticator data")
+	ERR_RP_ID_MISMATCH           = errors.New("[Err]: Relying party ID mismatch")
+	ERR_USER_NOT_PRESENT         = errors.New("[Err]: User not present")
+	ERR_USER_NOT_VERIFIED        = errors.New("[Err]: User not verified")
+	ERR_UNSUPPORTED_ATTESTATION  = errors.New("[Err]: Unsupported attestation format")
+	ERR_UNSUPPORTED_PUBLIC_KEY   = errors.New("[Err]: Unsupported public key")
+	ERR_INVALID_SIGNATURE        = errors.New("[Err]: Invalid signature")
+	ERR_SIGN_COUNT_NOT_INCREASED = errors.New("[Err]: Signature counter did not increase")
+)
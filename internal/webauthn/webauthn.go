@@ -0,0 +1,351 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"net/url"
+	"strings"
+
+	"github.com/thanhpk/randstr"
+)
+
+const (
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+
+	challengeSize = 32
+
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1
+	coseX         = -2
+	coseY         = -3
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseAlgES256   = -7
+	coseAlgEdDSA   = -8
+	coseCurveP256  = 1
+	coseCurveEd    = 6
+)
+
+var encoding = base64.RawURLEncoding
+
+type RelyingParty struct {
+	ID   string
+	Name string
+	// Secret keys the decoy credentials offered to users without passkeys.
+	Secret []byte
+}
+
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	credentialID []byte
+	publicKey    []byte
+	flags        byte
+	signCount    uint32
+}
+
+func NewChallenge() string {
+	return encoding.EncodeToString(randstr.Bytes(challengeSize))
+}
+
+// DecoyCredentialID is offered to users without passkeys so that a login
+// doesn't reveal whether they have any. It's stable per user, like a real
+// credential.
+func (rp *RelyingParty) DecoyCredentialID(username string) []byte {
+	mac := hmac.New(sha256.New, rp.Secret)
+	mac.Write([]byte(username))
+
+	return mac.Sum(nil)
+}
+
+func (rp *RelyingParty) VerifyRegistration(
+	challenge string,
+	clientDataJSON []byte,
+	attestationObject []byte,
+) (*Credential, error) {
+	var (
+		authData *authenticatorData
+		err      error
+	)
+
+	if err = rp.verifyClientData(clientDataJSON, ceremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	value, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, ERR_INVALID_AUTHENTICATOR
+	}
+
+	attestation, ok := value.(map[any]any)
+	if !ok {
+		return nil, ERR_INVALID_AUTHENTICATOR
+	}
+
+	if format, _ := attestation["fmt"].(string); format != "none" {
+		return nil, ERR_UNSUPPORTED_ATTESTATION
+	}
+
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ERR_INVALID_AUTHENTICATOR
+	}
+
+	if authData, err = rp.verifyAuthenticatorData(rawAuthData, false); err != nil {
+		return nil, err
+	}
+
+	if authData.flags&flagAttested == 0 {
+		return nil, ERR_INVALID_AUTHENTICATOR
+	}
+
+	if _, err = parsePublicKey(authData.publicKey); err != nil {
+		return nil, err
+	}
+
+	credential := &Credential{
+		ID:        authData.credentialID,
+		PublicKey: authData.publicKey,
+		SignCount: authData.signCount,
+	}
+
+	return credential, nil
+}
+
+// VerifyAssertion returns the new signature counter, which callers must
+// persist for the credential.
+func (rp *RelyingParty) VerifyAssertion(
+	challenge string,
+	credential *Credential,
+	clientDataJSON []byte,
+	rawAuthData []byte,
+	signature []byte,
+) (uint32, error) {
+	var (
+		authData  *authenticatorData
+		publicKey any
+		err       error
+	)
+
+	if err = rp.verifyClientData(clientDataJSON, ceremonyGet, challenge); err != nil {
+		return 0, err
+	}
+
+	if authData, err = rp.verifyAuthenticatorData(rawAuthData, true); err != nil {
+		return 0, err
+	}
+
+	if publicKey, err = parsePublicKey(credential.PublicKey); err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := make([]byte, 0, len(rawAuthData)+len(clientDataHash))
+	signed = append(signed, rawAuthData...)
+	signed = append(signed, clientDataHash[:]...)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return 0, ERR_INVALID_SIGNATURE
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, signed, signature) {
+			return 0, ERR_INVALID_SIGNATURE
+		}
+	default:
+		return 0, ERR_UNSUPPORTED_PUBLIC_KEY
+	}
+
+	if (authData.signCount != 0 || credential.SignCount != 0) &&
+		authData.signCount <= credential.SignCount {
+		return 0, ERR_SIGN_COUNT_NOT_INCREASED
+	}
+
+	return authData.signCount, nil
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony, challenge string) error {
+	var data clientData
+
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return ERR_INVALID_CLIENT_DATA
+	}
+
+	if data.Type != ceremony {
+		return ERR_INVALID_CLIENT_DATA
+	}
+
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return ERR_CHALLENGE_MISMATCH
+	}
+
+	if !rp.isValidOrigin(data.Origin) {
+		return ERR_ORIGIN_MISMATCH
+	}
+
+	return nil
+}
+
+func (rp *RelyingParty) isValidOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	host := u.Hostname()
+
+	if host != rp.ID && !strings.HasSuffix(host, "."+rp.ID) {
+		return false
+	}
+
+	return u.Scheme == "https" || (u.Scheme == "http" && host == "localhost")
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(
+	raw []byte,
+	requireUserVerification bool,
+) (*authenticatorData, error) {
+	authData, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return nil, ERR_RP_ID_MISMATCH
+	}
+
+	if authData.flags&flagUserPresent == 0 {
+		return nil, ERR_USER_NOT_PRESENT
+	}
+
+	if requireUserVerification && authData.flags&flagUserVerified == 0 {
+		return nil, ERR_USER_NOT_VERIFIED
+	}
+
+	return authData, nil
+}
+
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	const (
+		rpIDHashSize  = 32
+		headerSize    = rpIDHashSize + 1 + 4
+		aaguidSize    = 16
+		credIDLenSize = 2
+	)
+
+	if len(raw) < headerSize {
+		return nil, ERR_INVALID_AUTHENTICATOR
+	}
+
+	authData := &authenticatorData{
+		rpIDHash:  raw[:rpIDHashSize],
+		flags:     raw[rpIDHashSize],
+		signCount: binary.BigEndian.Uint32(raw[rpIDHashSize+1 : headerSize]),
+	}
+
+	if authData.flags&flagAttested == 0 {
+		return authData, nil
+	}
+
+	rest := raw[headerSize:]
+
+	if len(rest) < aaguidSize+credIDLenSize {
+		return nil, ERR_INVALID_AUTHENTICATOR
+	}
+
+	rest = rest[aaguidSize:]
+	credIDLen := int(binary.BigEndian.Uint16(rest))
+	rest = rest[credIDLenSize:]
+
+	if credIDLen == 0 || len(rest) < credIDLen {
+		return nil, ERR_INVALID_AUTHENTICATOR
+	}
+
+	authData.credentialID = append([]byte(nil), rest[:credIDLen]...)
+	rest = rest[credIDLen:]
+
+	_, n, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, ERR_INVALID_AUTHENTICATOR
+	}
+
+	authData.publicKey = append([]byte(nil), rest[:n]...)
+
+	return authData, nil
+}
+
+func parsePublicKey(coseKey []byte) (any, error) {
+	value, _, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, ERR_UNSUPPORTED_PUBLIC_KEY
+	}
+
+	key, ok := value.(map[any]any)
+	if !ok {
+		return nil, ERR_UNSUPPORTED_PUBLIC_KEY
+	}
+
+	kty, _ := key[int64(coseKeyType)].(int64)
+	alg, _ := key[int64(coseAlgorithm)].(int64)
+	crv, _ := key[int64(coseCurve)].(int64)
+	x, _ := key[int64(coseX)].([]byte)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == coseAlgES256 && crv == coseCurveP256:
+		y, _ := key[int64(coseY)].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, ERR_UNSUPPORTED_PUBLIC_KEY
+		}
+
+		publicKey := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, ERR_UNSUPPORTED_PUBLIC_KEY
+		}
+
+		return publicKey, nil
+	case kty == coseKeyTypeOKP && alg == coseAlgEdDSA && crv == coseCurveEd:
+		if len(x) != ed25519.PublicKeySize {
+			return nil, ERR_UNSUPPORTED_PUBLIC_KEY
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, ERR_UNSUPPORTED_PUBLIC_KEY
+}
@@ -0,0 +1,255 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thanhpk/randstr"
+
+	"github.com/dharmavagabond/simple-bank/internal/webauthn/webauthntest"
+)
+
+type testAuthenticator struct {
+	signer       func(t *testing.T, msg []byte) []byte
+	coseKey      []byte
+	credentialID []byte
+	signCount    uint32
+}
+
+func newES256Authenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+
+	return &testAuthenticator{
+		credentialID: randstr.Bytes(16),
+		coseKey: webauthntest.EncodeCBOR(map[int64]any{
+			coseKeyType:   int64(coseKeyTypeEC2),
+			coseAlgorithm: int64(coseAlgES256),
+			coseCurve:     int64(coseCurveP256),
+			coseX:         x,
+			coseY:         y,
+		}),
+		signer: func(t *testing.T, msg []byte) []byte {
+			t.Helper()
+			digest := sha256.Sum256(msg)
+			sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+			require.NoError(t, err)
+			return sig
+		},
+	}
+}
+
+func newEd25519Authenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return &testAuthenticator{
+		credentialID: randstr.Bytes(16),
+		coseKey: webauthntest.EncodeCBOR(map[int64]any{
+			coseKeyType:   int64(coseKeyTypeOKP),
+			coseAlgorithm: int64(coseAlgEdDSA),
+			coseCurve:     int64(coseCurveEd),
+			coseX:         []byte(publicKey),
+		}),
+		signer: func(t *testing.T, msg []byte) []byte {
+			t.Helper()
+			return ed25519.Sign(privateKey, msg)
+		},
+	}
+}
+
+func (a *testAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte(nil), rpIDHash[:]...)
+
+	if attested {
+		flags |= flagAttested
+	}
+
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey...)
+	}
+
+	return data
+}
+
+func (a *testAuthenticator) register(t *testing.T, rpID, origin, challenge string) ([]byte, []byte) {
+	t.Helper()
+	clientDataJSON := newClientData(t, ceremonyCreate, challenge, origin)
+	attestationObject := webauthntest.EncodeCBOR(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(rpID, flagUserPresent|flagUserVerified, true),
+	})
+
+	return clientDataJSON, attestationObject
+}
+
+func (a *testAuthenticator) assert(
+	t *testing.T,
+	rpID, origin, challenge string,
+	flags byte,
+) (clientDataJSON, authData, signature []byte) {
+	t.Helper()
+	a.signCount++
+	clientDataJSON = newClientData(t, ceremonyGet, challenge, origin)
+	authData = a.authData(rpID, flags, false)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signature = a.signer(t, append(append([]byte(nil), authData...), clientDataHash[:]...))
+
+	return clientDataJSON, authData, signature
+}
+
+func newClientData(t *testing.T, ceremony, challenge, origin string) []byte {
+	t.Helper()
+	data, err := json.Marshal(clientData{Type: ceremony, Challenge: challenge, Origin: origin})
+	require.NoError(t, err)
+	return data
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := &RelyingParty{ID: "bank.example", Name: "Simple Bank"}
+	origin := "https://bank.example"
+	authenticators := map[string]*testAuthenticator{
+		"ES256": newES256Authenticator(t),
+		"EdDSA": newEd25519Authenticator(t),
+	}
+
+	for name, authenticator := range authenticators {
+		authenticator := authenticator
+		t.Run(name, func(t *testing.T) {
+			challenge := NewChallenge()
+			clientDataJSON, attestationObject := authenticator.register(t, rp.ID, origin, challenge)
+			credential, err := rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+			require.NoError(t, err)
+			require.Equal(t, authenticator.credentialID, credential.ID)
+			require.Equal(t, authenticator.coseKey, credential.PublicKey)
+
+			challenge = NewChallenge()
+			clientDataJSON, authData, signature := authenticator.assert(
+				t,
+				rp.ID,
+				origin,
+				challenge,
+				flagUserPresent|flagUserVerified,
+			)
+			signCount, err := rp.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature)
+			require.NoError(t, err)
+			require.Equal(t, authenticator.signCount, signCount)
+
+			credential.SignCount = signCount
+			_, err = rp.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature)
+			require.ErrorIs(t, err, ERR_SIGN_COUNT_NOT_INCREASED)
+		})
+	}
+}
+
+func TestRegistrationErrors(t *testing.T) {
+	rp := &RelyingParty{ID: "bank.example", Name: "Simple Bank"}
+	authenticator := newES256Authenticator(t)
+	challenge := NewChallenge()
+
+	clientDataJSON, attestationObject := authenticator.register(t, rp.ID, "https://bank.example", NewChallenge())
+	_, err := rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	require.ErrorIs(t, err, ERR_CHALLENGE_MISMATCH)
+
+	clientDataJSON, attestationObject = authenticator.register(t, rp.ID, "https://evil.example", challenge)
+	_, err = rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	require.ErrorIs(t, err, ERR_ORIGIN_MISMATCH)
+
+	clientDataJSON, attestationObject = authenticator.register(t, rp.ID, "http://bank.example", challenge)
+	_, err = rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	require.ErrorIs(t, err, ERR_ORIGIN_MISMATCH)
+
+	clientDataJSON, attestationObject = authenticator.register(t, "evil.example", "https://bank.example", challenge)
+	_, err = rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	require.ErrorIs(t, err, ERR_RP_ID_MISMATCH)
+
+	clientDataJSON = newClientData(t, ceremonyGet, challenge, "https://bank.example")
+	_, err = rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	require.ErrorIs(t, err, ERR_INVALID_CLIENT_DATA)
+
+	clientDataJSON = newClientData(t, ceremonyCreate, challenge, "https://login.bank.example")
+	_, err = rp.VerifyRegistration(challenge, clientDataJSON, webauthntest.EncodeCBOR(map[string]any{
+		"fmt":      "packed",
+		"attStmt":  map[string]any{},
+		"authData": authenticator.authData(rp.ID, flagUserPresent, true),
+	}))
+	require.ErrorIs(t, err, ERR_UNSUPPORTED_ATTESTATION)
+}
+
+func TestAssertionErrors(t *testing.T) {
+	rp := &RelyingParty{ID: "localhost", Name: "Simple Bank"}
+	origin := "http://localhost:8080"
+	authenticator := newES256Authenticator(t)
+	challenge := NewChallenge()
+	clientDataJSON, attestationObject := authenticator.register(t, rp.ID, origin, challenge)
+	credential, err := rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	require.NoError(t, err)
+
+	clientDataJSON, authData, signature := authenticator.assert(t, rp.ID, origin, challenge, flagUserPresent)
+	_, err = rp.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature)
+	require.ErrorIs(t, err, ERR_USER_NOT_VERIFIED)
+
+	clientDataJSON, authData, signature = authenticator.assert(t, rp.ID, origin, challenge, flagUserVerified)
+	_, err = rp.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature)
+	require.ErrorIs(t, err, ERR_USER_NOT_PRESENT)
+
+	clientDataJSON, authData, signature = authenticator.assert(
+		t,
+		rp.ID,
+		origin,
+		challenge,
+		flagUserPresent|flagUserVerified,
+	)
+	signature[len(signature)-1] ^= 0xff
+	_, err = rp.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature)
+	require.ErrorIs(t, err, ERR_INVALID_SIGNATURE)
+
+	other := newES256Authenticator(t)
+	clientDataJSON, authData, signature = other.assert(t, rp.ID, origin, challenge, flagUserPresent|flagUserVerified)
+	_, err = rp.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature)
+	require.ErrorIs(t, err, ERR_INVALID_SIGNATURE)
+}
+
+func TestDecoyCredentialID(t *testing.T) {
+	rp := &RelyingParty{ID: "bank.example", Secret: []byte("secret")}
+	other := &RelyingParty{ID: "bank.example", Secret: []byte("other")}
+
+	require.Equal(t, rp.DecoyCredentialID("alice"), rp.DecoyCredentialID("alice"))
+	require.NotEqual(t, rp.DecoyCredentialID("alice"), rp.DecoyCredentialID("bob"))
+	require.NotEqual(t, rp.DecoyCredentialID("alice"), other.DecoyCredentialID("alice"))
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{0x5a, 0xff, 0xff, 0xff, 0xff},
+		{0xa1, 0x41, 0x00, 0x01},
+		{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		{0x1c},
+	} {
+		_, _, err := decodeCBOR(data)
+		require.Error(t, err)
+	}
+}
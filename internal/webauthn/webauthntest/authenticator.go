@@ -0,0 +1,185 @@
+// Package webauthntest provides a software authenticator to drive passkey
+// ceremonies in tests.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thanhpk/randstr"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+// Authenticator is an ES256 authenticator holding a single credential,
+// attesting with the "none" format.
+type Authenticator struct {
+	key          *ecdsa.PrivateKey
+	CredentialID []byte
+	PublicKey    []byte
+	SignCount    uint32
+}
+
+func NewAuthenticator(t testing.TB) *Authenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+
+	return &Authenticator{
+		key:          key,
+		CredentialID: randstr.Bytes(16),
+		PublicKey: EncodeCBOR(map[int64]any{
+			1:  int64(2),  // kty: EC2
+			3:  int64(-7), // alg: ES256
+			-1: int64(1),  // crv: P-256
+			-2: x,
+			-3: y,
+		}),
+	}
+}
+
+// Register answers a registration challenge from the origin.
+func (a *Authenticator) Register(
+	t testing.TB,
+	rpID, origin, challenge string,
+) (clientDataJSON, attestationObject []byte) {
+	t.Helper()
+	clientDataJSON = ClientData(t, "webauthn.create", challenge, origin)
+	attestationObject = EncodeCBOR(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(rpID, true),
+	})
+
+	return clientDataJSON, attestationObject
+}
+
+// Assert signs a login challenge from the origin, counting the signature.
+func (a *Authenticator) Assert(
+	t testing.TB,
+	rpID, origin, challenge string,
+) (clientDataJSON, authData, signature []byte) {
+	t.Helper()
+	a.SignCount++
+	clientDataJSON = ClientData(t, "webauthn.get", challenge, origin)
+	authData = a.authData(rpID, false)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+
+	return clientDataJSON, authData, signature
+}
+
+func (a *Authenticator) authData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte(nil), rpIDHash[:]...)
+	flags := byte(flagUserPresent | flagUserVerified)
+
+	if attested {
+		flags |= flagAttested
+	}
+
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.SignCount)
+
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.CredentialID)))
+		data = append(data, a.CredentialID...)
+		data = append(data, a.PublicKey...)
+	}
+
+	return data
+}
+
+// ClientData is the client data JSON a browser collects for a ceremony.
+func ClientData(t testing.TB, ceremony, challenge, origin string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	require.NoError(t, err)
+
+	return data
+}
+
+// EncodeCBOR encodes the integers, byte and text strings, and maps that
+// attestations are made of. Map keys are sorted, as CTAP2 requires.
+func EncodeCBOR(value any) []byte {
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case map[int64]any:
+		keys := make([]int64, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		out := cborHead(5, uint64(len(v)))
+
+		for _, k := range keys {
+			out = append(out, EncodeCBOR(k)...)
+			out = append(out, EncodeCBOR(v[k])...)
+		}
+
+		return out
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		out := cborHead(5, uint64(len(v)))
+
+		for _, k := range keys {
+			out = append(out, EncodeCBOR(k)...)
+			out = append(out, EncodeCBOR(v[k])...)
+		}
+
+		return out
+	}
+
+	panic("unsupported CBOR value")
+}
+
+func cborHead(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	default:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	}
+}
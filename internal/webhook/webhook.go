@@ -0,0 +1,272 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/util"
+)
+
+const (
+	EventTransferIn  = "transfer.in"
+	EventTransferOut = "transfer.out"
+)
+
+// Delivery statuses. A delivery is retrying while its task has retries left
+// and failed once it has none.
+const (
+	StatusPending   = "pending"
+	StatusRetrying  = "retrying"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	HeaderDelivery  = "Simple-Bank-Delivery"
+	HeaderEvent     = "Simple-Bank-Event"
+	HeaderSignature = "Simple-Bank-Signature"
+)
+
+var (
+	ERR_INVALID_SIGNATURE = errors.New("[Err]: Invalid webhook signature")
+	ERR_EXPIRED_SIGNATURE = errors.New("[Err]: Expired webhook signature")
+	ERR_UNEXPECTED_STATUS = errors.New("[Err]: Unexpected webhook response status")
+	ERR_FORBIDDEN_ADDRESS = errors.New("[Err]: Webhooks can't be delivered to internal addresses")
+)
+
+// Payload is the JSON body of a callback. The payload is stored with its
+// delivery, so a redelivered callback keeps its ID, which receivers can use
+// to discard duplicates.
+type Payload struct {
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+}
+
+type TransferData struct {
+	CreatedAt     time.Time `json:"created_at"`
+	Currency      string    `json:"currency"`
+	TransferID    int64     `json:"transfer_id"`
+	AccountID     int64     `json:"account_id"`
+	FromAccountID int64     `json:"from_account_id"`
+	ToAccountID   int64     `json:"to_account_id"`
+	Amount        int64     `json:"amount"`
+	Balance       int64     `json:"balance"`
+}
+
+// NewTransferData returns the event type and data of the transfer's callback
+// to the endpoints of accountID, which must be one of its accounts.
+func NewTransferData(result db.TransferTxResult, accountID int64) (eventType string, data TransferData) {
+	data = TransferData{
+		CreatedAt:     result.Transfer.CreatedAt.Time,
+		TransferID:    result.Transfer.ID,
+		AccountID:     accountID,
+		FromAccountID: result.Transfer.FromAccountID,
+		ToAccountID:   result.Transfer.ToAccountID,
+		Amount:        result.Transfer.Amount,
+	}
+
+	if accountID == result.FromAccount.ID {
+		data.Currency = result.FromAccount.Currency
+		data.Balance = result.FromAccount.Balance
+		return EventTransferOut, data
+	}
+
+	data.Currency = result.ToAccount.Currency
+	data.Balance = result.ToAccount.Balance
+	return EventTransferIn, data
+}
+
+func NewPayload(eventType string, data any) ([]byte, error) {
+	bs, err := json.Marshal(Payload{
+		CreatedAt: time.Now(),
+		Data:      data,
+		ID:        uuid.NewString(),
+		Type:      eventType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	return bs, nil
+}
+
+// NewSecret returns a random signing secret for an endpoint.
+func NewSecret() (string, error) {
+	bs := make([]byte, 32)
+
+	if _, err := rand.Read(bs); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	return "whsec_" + hex.EncodeToString(bs), nil
+}
+
+// Sign returns the signature header of body sent at timestamp: the unix
+// timestamp and the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the
+// endpoint's secret.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+// Verify checks a signature header as receivers should: the signature must
+// match and have been made less than tolerance before now.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, sig string
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ERR_INVALID_SIGNATURE
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return ERR_INVALID_SIGNATURE
+	}
+
+	if now.Sub(time.Unix(unix, 0)).Abs() > tolerance {
+		return ERR_EXPIRED_SIGNATURE
+	}
+
+	return nil
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver posts the delivery's payload to the endpoint, signed now. Any
+// response status other than 2xx is an error, returned with the status.
+func Deliver(
+	ctx context.Context,
+	client *http.Client,
+	endpoint db.WebhookEndpoint,
+	delivery db.WebhookDelivery,
+) (statusCode int, err error) {
+	var (
+		req *http.Request
+		res *http.Response
+	)
+
+	if req, err = http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		endpoint.Url,
+		bytes.NewReader(delivery.Payload),
+	); err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "simple-bank-webhooks/1")
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, time.Now(), delivery.Payload))
+
+	if res, err = client.Do(req); err != nil {
+		return 0, fmt.Errorf("failed to post webhook: %w", err)
+	}
+
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("%w: %d", ERR_UNEXPECTED_STATUS, res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// PublicIP reports whether ip may receive webhooks: loopback, private,
+// link-local, multicast and unspecified addresses are internal to the bank's
+// network, or meaningless as a destination.
+func PublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// DialControl is a net.Dialer Control that refuses connections to addresses
+// that aren't public. It runs after DNS resolution, so a hostname resolving
+// to an internal address, or rebinding to one, is refused too.
+func DialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+		return fmt.Errorf("%w: %s", ERR_FORBIDDEN_ADDRESS, host)
+	}
+
+	return nil
+}
+
+// NewTransport is the transport for webhook deliveries. Unless
+// allowInternal, connections to internal addresses are refused. Proxies
+// aren't used, as the checks would apply to the proxy instead.
+func NewTransport(allowInternal bool) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if !allowInternal {
+		dialer.Control = DialControl
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return transport
+}
+
+// RetryDelay is the exponential backoff between delivery attempts: 30s
+// doubling up to 6h, with up to 10% jitter.
+func RetryDelay(n int) time.Duration {
+	delay := 6 * time.Hour
+
+	if n < 10 {
+		delay = min(30*time.Second<<n, delay)
+	}
+
+	return delay + time.Duration(util.RandomInt(0, int64(delay/10)))
+}
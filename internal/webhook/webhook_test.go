@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":"1"}`)
+	header := Sign("secret", now, body)
+
+	require.NoError(t, Verify("secret", header, body, time.Minute, now))
+	require.ErrorIs(t, Verify("other", header, body, time.Minute, now), ERR_INVALID_SIGNATURE)
+	require.ErrorIs(t, Verify("secret", header, []byte(`{"id":"2"}`), time.Minute, now), ERR_INVALID_SIGNATURE)
+	require.ErrorIs(t, Verify("secret", "v1=abc", body, time.Minute, now), ERR_INVALID_SIGNATURE)
+	require.ErrorIs(
+		t,
+		Verify("secret", header, body, time.Minute, now.Add(2*time.Minute)),
+		ERR_EXPIRED_SIGNATURE,
+	)
+}
+
+func TestDeliver(t *testing.T) {
+	endpoint := db.WebhookEndpoint{ID: 1, Secret: "secret"}
+	delivery := db.WebhookDelivery{ID: 2, EventType: EventTransferIn, Payload: []byte(`{"id":"1"}`)}
+	statusCode := http.StatusNoContent
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.Equal(t, delivery.Payload, body)
+		require.Equal(t, "2", req.Header.Get(HeaderDelivery))
+		require.Equal(t, EventTransferIn, req.Header.Get(HeaderEvent))
+		require.NoError(t, Verify("secret", req.Header.Get(HeaderSignature), body, time.Minute, time.Now()))
+		rw.WriteHeader(statusCode)
+	}))
+	defer receiver.Close()
+
+	endpoint.Url = receiver.URL
+	code, err := Deliver(context.TODO(), receiver.Client(), endpoint, delivery)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, code)
+
+	statusCode = http.StatusServiceUnavailable
+	code, err = Deliver(context.TODO(), receiver.Client(), endpoint, delivery)
+	require.ErrorIs(t, err, ERR_UNEXPECTED_STATUS)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+}
+
+func TestRetryDelay(t *testing.T) {
+	for n, base := range map[int]time.Duration{
+		0:  30 * time.Second,
+		3:  4 * time.Minute,
+		10: 6 * time.Hour,
+		40: 6 * time.Hour,
+	} {
+		delay := RetryDelay(n)
+		require.GreaterOrEqual(t, delay, base)
+		require.LessOrEqual(t, delay, base+base/10)
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	for address, allowed := range map[string]bool{
+		"93.184.216.34:443":           true,
+		"[2606:2800:220:1::]:443":     true,
+		"127.0.0.1:443":               false,
+		"10.0.0.1:443":                false,
+		"192.168.1.1:443":             false,
+		"169.254.169.254:80":          false,
+		"0.0.0.0:443":                 false,
+		"[::]:443":                    false,
+		"[::1]:443":                   false,
+		"[fe80::1]:443":               false,
+		"[fd00::1]:443":               false,
+		"[::ffff:127.0.0.1]:443":      false,
+		"[::ffff:169.254.169.254]:80": false,
+	} {
+		err := DialControl("tcp", address, nil)
+		if allowed {
+			require.NoError(t, err, address)
+		} else {
+			require.ErrorIs(t, err, ERR_FORBIDDEN_ADDRESS, address)
+		}
+	}
+}
+
+func TestTransportRefusesInternalAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	endpoint := db.WebhookEndpoint{ID: 1, Secret: "secret", Url: receiver.URL}
+	delivery := db.WebhookDelivery{ID: 2, EventType: EventTransferIn, Payload: []byte(`{"id":"1"}`)}
+
+	_, err := Deliver(context.TODO(), &http.Client{Transport: NewTransport(false)}, endpoint, delivery)
+	require.ErrorIs(t, err, ERR_FORBIDDEN_ADDRESS)
+
+	code, err := Deliver(context.TODO(), &http.Client{Transport: NewTransport(true)}, endpoint, delivery)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, code)
+}
@@ -0,0 +1,358 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+)
+
+// Task states an admin can list. Only retry and archived (dead) tasks can be
+// run or deleted in bulk.
+const (
+	TaskStatePending   = "pending"
+	TaskStateActive    = "active"
+	TaskStateScheduled = "scheduled"
+	TaskStateRetry     = "retry"
+	TaskStateArchived  = "archived"
+)
+
+var (
+	ERR_UNKNOWN_QUEUE       = errors.New("[Err]: Unknown queue")
+	ERR_INVALID_TASK_STATE  = errors.New("[Err]: Invalid task state")
+	ERR_TASK_STATE_CONFLICT = errors.New("[Err]: The task can't be changed in its current state")
+)
+
+// Queues are the queues the task processor serves.
+var Queues = []string{QueueCritical, QueueDefault}
+
+// TaskInspector is the part of *asynq.Inspector the TaskAdmin uses.
+type TaskInspector interface {
+	GetQueueInfo(queue string) (*asynq.QueueInfo, error)
+	GetTaskInfo(queue, id string) (*asynq.TaskInfo, error)
+	ListPendingTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+	ListActiveTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+	ListScheduledTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+	ListRetryTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+	ListArchivedTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+	RunTask(queue, id string) error
+	DeleteTask(queue, id string) error
+	RunAllRetryTasks(queue string) (int, error)
+	RunAllArchivedTasks(queue string) (int, error)
+	DeleteAllRetryTasks(queue string) (int, error)
+	DeleteAllArchivedTasks(queue string) (int, error)
+	Close() error
+}
+
+// QueueStats is a queue's depth by task state. Latency is the age of its
+// oldest pending task, and Processed and Failed count today's tasks.
+type QueueStats struct {
+	Queue          string  `json:"queue"`
+	LatencySeconds float64 `json:"latency_seconds"`
+	Size           int     `json:"size"`
+	Pending        int     `json:"pending"`
+	Active         int     `json:"active"`
+	Scheduled      int     `json:"scheduled"`
+	Retry          int     `json:"retry"`
+	Archived       int     `json:"archived"`
+	Processed      int     `json:"processed"`
+	Failed         int     `json:"failed"`
+	Paused         bool    `json:"paused"`
+}
+
+type TaskSummary struct {
+	NextProcessAt *time.Time      `json:"next_process_at,omitempty"`
+	LastFailedAt  *time.Time      `json:"last_failed_at,omitempty"`
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Queue         string          `json:"queue"`
+	State         string          `json:"state"`
+	LastErr       string          `json:"last_err,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	MaxRetry      int             `json:"max_retry"`
+	Retried       int             `json:"retried"`
+}
+
+// ScheduleStatus is a periodic task's schedule and last run. A task that
+// never ran has no status.
+type ScheduleStatus struct {
+	EnqueuedAt *time.Time `json:"enqueued_at,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Type       string     `json:"type"`
+	Cron       string     `json:"cron"`
+	Queue      string     `json:"queue"`
+	TaskID     string     `json:"task_id,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// TaskAdmin inspects and manages the tasks of the processor's queues.
+type TaskAdmin struct {
+	inspector TaskInspector
+	schedules ScheduleStore
+}
+
+// Queues returns the depth of each queue. A queue without tasks yet has
+// zero stats.
+func (admin *TaskAdmin) Queues() ([]QueueStats, error) {
+	stats := make([]QueueStats, 0, len(Queues))
+
+	for _, queue := range Queues {
+		info, err := admin.inspector.GetQueueInfo(queue)
+		if err != nil {
+			if errors.Is(err, asynq.ErrQueueNotFound) {
+				stats = append(stats, QueueStats{Queue: queue})
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to get queue %s: %w", queue, err)
+		}
+
+		stats = append(stats, QueueStats{
+			Queue:          info.Queue,
+			LatencySeconds: info.Latency.Seconds(),
+			Size:           info.Size,
+			Pending:        info.Pending,
+			Active:         info.Active,
+			Scheduled:      info.Scheduled,
+			Retry:          info.Retry,
+			Archived:       info.Archived,
+			Processed:      info.Processed,
+			Failed:         info.Failed,
+			Paused:         info.Paused,
+		})
+	}
+
+	return stats, nil
+}
+
+// ListTasks returns a page, starting at 1, of the queue's tasks in the state.
+func (admin *TaskAdmin) ListTasks(queue, state string, page, pageSize int) (tasks []TaskSummary, err error) {
+	var (
+		infos []*asynq.TaskInfo
+		list  func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+	)
+
+	if err = validateQueue(queue); err != nil {
+		return nil, err
+	}
+
+	switch state {
+	case TaskStatePending:
+		list = admin.inspector.ListPendingTasks
+	case TaskStateActive:
+		list = admin.inspector.ListActiveTasks
+	case TaskStateScheduled:
+		list = admin.inspector.ListScheduledTasks
+	case TaskStateRetry:
+		list = admin.inspector.ListRetryTasks
+	case TaskStateArchived:
+		list = admin.inspector.ListArchivedTasks
+	default:
+		return nil, fmt.Errorf("%w: %s", ERR_INVALID_TASK_STATE, state)
+	}
+
+	if infos, err = list(queue, asynq.Page(page), asynq.PageSize(pageSize)); err != nil {
+		if errors.Is(err, asynq.ErrQueueNotFound) {
+			return []TaskSummary{}, nil
+		}
+
+		return nil, fmt.Errorf("failed to list %s tasks: %w", state, err)
+	}
+
+	tasks = make([]TaskSummary, 0, len(infos))
+
+	for _, info := range infos {
+		tasks = append(tasks, newTaskSummary(info))
+	}
+
+	return tasks, nil
+}
+
+func (admin *TaskAdmin) GetTask(queue, id string) (TaskSummary, error) {
+	if err := validateQueue(queue); err != nil {
+		return TaskSummary{}, err
+	}
+
+	info, err := admin.inspector.GetTaskInfo(queue, id)
+	if err != nil {
+		return TaskSummary{}, err
+	}
+
+	return newTaskSummary(info), nil
+}
+
+// RunTask requeues a scheduled, retry or archived task to run right away.
+func (admin *TaskAdmin) RunTask(queue, id string) error {
+	if err := admin.checkTaskState(queue, id, asynq.TaskStatePending, asynq.TaskStateActive); err != nil {
+		return err
+	}
+
+	return admin.inspector.RunTask(queue, id)
+}
+
+// DeleteTask deletes a task that isn't being processed.
+func (admin *TaskAdmin) DeleteTask(queue, id string) error {
+	if err := admin.checkTaskState(queue, id, asynq.TaskStateActive); err != nil {
+		return err
+	}
+
+	return admin.inspector.DeleteTask(queue, id)
+}
+
+// checkTaskState fails with ERR_TASK_STATE_CONFLICT if the task is in one of
+// the states.
+func (admin *TaskAdmin) checkTaskState(queue, id string, states ...asynq.TaskState) error {
+	if err := validateQueue(queue); err != nil {
+		return err
+	}
+
+	info, err := admin.inspector.GetTaskInfo(queue, id)
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(states, info.State) {
+		return fmt.Errorf("%w: %s", ERR_TASK_STATE_CONFLICT, info.State)
+	}
+
+	return nil
+}
+
+// RunAllTasks requeues every retry or archived task of the queue, returning
+// how many were.
+func (admin *TaskAdmin) RunAllTasks(queue, state string) (int, error) {
+	if err := validateQueue(queue); err != nil {
+		return 0, err
+	}
+
+	switch state {
+	case TaskStateRetry:
+		return admin.inspector.RunAllRetryTasks(queue)
+	case TaskStateArchived:
+		return admin.inspector.RunAllArchivedTasks(queue)
+	}
+
+	return 0, fmt.Errorf("%w: %s", ERR_INVALID_TASK_STATE, state)
+}
+
+// DeleteAllTasks deletes every retry or archived task of the queue,
+// returning how many were.
+func (admin *TaskAdmin) DeleteAllTasks(queue, state string) (int, error) {
+	if err := validateQueue(queue); err != nil {
+		return 0, err
+	}
+
+	switch state {
+	case TaskStateRetry:
+		return admin.inspector.DeleteAllRetryTasks(queue)
+	case TaskStateArchived:
+		return admin.inspector.DeleteAllArchivedTasks(queue)
+	}
+
+	return 0, fmt.Errorf("%w: %s", ERR_INVALID_TASK_STATE, state)
+}
+
+// Schedules returns the periodic tasks with their last run. A disabled task
+// has an empty cron.
+func (admin *TaskAdmin) Schedules(ctx context.Context) ([]ScheduleStatus, error) {
+	tasks := PeriodicTasks()
+	statuses := make([]ScheduleStatus, 0, len(tasks))
+
+	for _, task := range tasks {
+		run, err := admin.schedules.LastRun(ctx, task.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the last run of %s: %w", task.Type, err)
+		}
+
+		statuses = append(statuses, ScheduleStatus{
+			EnqueuedAt: timeOrNil(run.EnqueuedAt),
+			StartedAt:  timeOrNil(run.StartedAt),
+			FinishedAt: timeOrNil(run.FinishedAt),
+			Type:       task.Type,
+			Cron:       task.Cron,
+			Queue:      task.Queue,
+			TaskID:     run.TaskID,
+			Status:     run.Status,
+			Error:      run.Error,
+		})
+	}
+
+	return statuses, nil
+}
+
+func (admin *TaskAdmin) Close() error {
+	return admin.inspector.Close()
+}
+
+func validateQueue(queue string) error {
+	if !slices.Contains(Queues, queue) {
+		return fmt.Errorf("%w: %s", ERR_UNKNOWN_QUEUE, queue)
+	}
+
+	return nil
+}
+
+func newTaskSummary(info *asynq.TaskInfo) TaskSummary {
+	task := TaskSummary{
+		ID:       info.ID,
+		Type:     info.Type,
+		Queue:    info.Queue,
+		LastErr:  info.LastErr,
+		Payload:  payloadJSON(info.Payload),
+		MaxRetry: info.MaxRetry,
+		Retried:  info.Retried,
+	}
+
+	if info.State != 0 {
+		task.State = info.State.String()
+	}
+
+	task.NextProcessAt = timeOrNil(info.NextProcessAt)
+	task.LastFailedAt = timeOrNil(info.LastFailedAt)
+
+	return task
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// payloadJSON returns JSON payloads, as all of ours are, as they are and any
+// other as a base64 string.
+func payloadJSON(payload []byte) json.RawMessage {
+	if json.Valid(payload) {
+		return payload
+	}
+
+	bs, _ := json.Marshal(payload)
+
+	return bs
+}
+
+func NewTaskAdmin(inspector TaskInspector, schedules ScheduleStore) *TaskAdmin {
+	return &TaskAdmin{
+		inspector: inspector,
+		schedules: schedules,
+	}
+}
+
+func NewRedisTaskAdmin() *TaskAdmin {
+	rcopt := asynq.RedisClientOpt{
+		Addr: net.JoinHostPort(config.Redis.Host, config.Redis.Port),
+	}
+
+	return NewTaskAdmin(asynq.NewInspector(rcopt), NewConfiguredRedisScheduleStore())
+}
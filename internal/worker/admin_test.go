@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeInspector struct {
+	TaskInspector
+	queues map[string]*asynq.QueueInfo
+	tasks  map[string]*asynq.TaskInfo
+	ran    []string
+	opts   []asynq.ListOption
+}
+
+func (inspector *fakeInspector) GetQueueInfo(queue string) (*asynq.QueueInfo, error) {
+	if info, ok := inspector.queues[queue]; ok {
+		return info, nil
+	}
+
+	return nil, asynq.ErrQueueNotFound
+}
+
+func (inspector *fakeInspector) GetTaskInfo(_, id string) (*asynq.TaskInfo, error) {
+	if info, ok := inspector.tasks[id]; ok {
+		return info, nil
+	}
+
+	return nil, asynq.ErrTaskNotFound
+}
+
+func (inspector *fakeInspector) ListArchivedTasks(_ string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+	inspector.opts = opts
+	tasks := []*asynq.TaskInfo{}
+
+	for _, info := range inspector.tasks {
+		if info.State == asynq.TaskStateArchived {
+			tasks = append(tasks, info)
+		}
+	}
+
+	return tasks, nil
+}
+
+func (inspector *fakeInspector) RunTask(_, id string) error {
+	inspector.ran = append(inspector.ran, id)
+	return nil
+}
+
+func TestTaskAdminQueues(t *testing.T) {
+	admin := NewTaskAdmin(&fakeInspector{queues: map[string]*asynq.QueueInfo{
+		QueueCritical: {Queue: QueueCritical, Size: 3, Pending: 1, Archived: 2},
+	}}, nil)
+
+	queues, err := admin.Queues()
+	require.NoError(t, err)
+	require.Equal(t, []QueueStats{
+		{Queue: QueueCritical, Size: 3, Pending: 1, Archived: 2},
+		{Queue: QueueDefault},
+	}, queues)
+}
+
+func TestTaskAdminTasks(t *testing.T) {
+	inspector := &fakeInspector{tasks: map[string]*asynq.TaskInfo{
+		"dead": {
+			ID:       "dead",
+			Type:     TaskSendVerifyEmail,
+			Queue:    QueueCritical,
+			State:    asynq.TaskStateArchived,
+			Payload:  []byte(`{"username":"alice"}`),
+			MaxRetry: 10,
+			Retried:  10,
+			LastErr:  "smtp down",
+		},
+		"busy": {ID: "busy", Queue: QueueCritical, State: asynq.TaskStateActive, Payload: []byte{0xff}},
+	}}
+	admin := NewTaskAdmin(inspector, nil)
+
+	tasks, err := admin.ListTasks(QueueCritical, TaskStateArchived, 2, 20)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "archived", tasks[0].State)
+	require.Equal(t, json.RawMessage(`{"username":"alice"}`), tasks[0].Payload)
+	require.Len(t, inspector.opts, 2)
+
+	task, err := admin.GetTask(QueueCritical, "busy")
+	require.NoError(t, err)
+	require.Equal(t, json.RawMessage(`"/w=="`), task.Payload)
+
+	require.NoError(t, admin.RunTask(QueueCritical, "dead"))
+	require.Equal(t, []string{"dead"}, inspector.ran)
+	require.ErrorIs(t, admin.RunTask(QueueCritical, "busy"), ERR_TASK_STATE_CONFLICT)
+	require.ErrorIs(t, admin.DeleteTask(QueueCritical, "busy"), ERR_TASK_STATE_CONFLICT)
+	require.ErrorIs(t, admin.RunTask(QueueCritical, "missing"), asynq.ErrTaskNotFound)
+
+	_, err = admin.ListTasks("low", TaskStateArchived, 1, 20)
+	require.ErrorIs(t, err, ERR_UNKNOWN_QUEUE)
+
+	_, err = admin.ListTasks(QueueCritical, "completed", 1, 20)
+	require.ErrorIs(t, err, ERR_INVALID_TASK_STATE)
+
+	_, err = admin.RunAllTasks(QueueCritical, TaskStatePending)
+	require.ErrorIs(t, err, ERR_INVALID_TASK_STATE)
+}
+
+func TestTaskAdminSchedules(t *testing.T) {
+	schedules := NewMemoryScheduleStore()
+	finishedAt := time.Now()
+	schedules.runs[TaskCleanupSessions] = PeriodicTaskRun{
+		FinishedAt: finishedAt,
+		TaskID:     "cleanup",
+		Status:     PeriodicTaskSucceeded,
+	}
+
+	statuses, err := NewTaskAdmin(&fakeInspector{}, schedules).Schedules(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, statuses, len(PeriodicTasks()))
+	require.Equal(t, TaskCleanupSessions, statuses[0].Type)
+	require.Equal(t, PeriodicTaskSucceeded, statuses[0].Status)
+	require.Equal(t, &finishedAt, statuses[0].FinishedAt)
+	require.Nil(t, statuses[0].StartedAt)
+	require.Empty(t, statuses[1].Status)
+}
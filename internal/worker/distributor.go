@@ -2,22 +2,63 @@ package worker
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net"
 
 	"github.com/dharmavagabond/simple-bank/internal/config"
 	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
 )
 
 type TaskDistributor interface {
-	DistributeTaskSendVerifyEmail(
-		ctx context.Context,
-		payload *PayloadSendVerifyEmail,
-		opts ...asynq.Option,
-	) error
+	// Enqueue distributes a task of any type, for TaskDefinition.Enqueue.
+	Enqueue(ctx context.Context, task *asynq.Task, opts ...asynq.Option) error
+	Close() error
+}
+
+// taskEnqueuer is implemented by *asynq.Client and by the outbox client,
+// which writes the tasks to the task outbox instead.
+type taskEnqueuer interface {
+	EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
 }
 
 type RedisTaskDistributor struct {
-	client *asynq.Client
+	client taskEnqueuer
+}
+
+func (distr *RedisTaskDistributor) Enqueue(
+	ctx context.Context,
+	task *asynq.Task,
+	opts ...asynq.Option,
+) error {
+	taskInfo, err := distr.client.EnqueueContext(ctx, task, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	log.Info().
+		Str("id", taskInfo.ID).
+		Str("type", taskInfo.Type).
+		Bytes("payload", task.Payload()).
+		Int("retries", taskInfo.MaxRetry).
+		Str("queue", taskInfo.Queue).
+		Msg("enqueue task")
+
+	return nil
+}
+
+func (distr *RedisTaskDistributor) Close() error {
+	return closeClient(distr.client)
+}
+
+// closeClient closes the client if it holds connections.
+func closeClient(client any) error {
+	if closer, ok := client.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
 }
 
 func NewRedisTaskDistributor() TaskDistributor {
@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dharmavagabond/simple-bank/internal/events"
+)
+
+// PublishEvents publishes the domain events the store wrote to the task
+// outbox. A retried task publishes its events again, with the same ids.
+var PublishEvents = RegisterTask(&TaskDefinition[[]events.Event]{
+	Name:    events.TaskPublishEvents,
+	Version: 1,
+	Handle:  (*RedisTaskProcessor).publishEvents,
+})
+
+func (proc *RedisTaskProcessor) publishEvents(ctx context.Context, evts *[]events.Event) error {
+	if err := proc.publisher.Publish(ctx, *evts...); err != nil {
+		return fmt.Errorf("failed to publish events: %w", err)
+	}
+
+	log.Info().
+		Str("type", events.TaskPublishEvents).
+		Int("events", len(*evts)).
+		Msg("processed task")
+
+	return nil
+}
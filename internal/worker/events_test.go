@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/events"
+)
+
+func TestPublishEvents(t *testing.T) {
+	publisher := events.NewMemoryPublisher()
+	proc := &RedisTaskProcessor{publisher: publisher}
+	evts := []events.Event{
+		{ID: "1", Type: events.TypeTransferCompleted, Key: "transfers/1", Data: []byte{0x08, 0x01}},
+		{ID: "2", Type: events.TypeEntryPosted, Key: "accounts/1", Data: []byte{0x08, 0x02}},
+	}
+	payload, err := json.Marshal(evts)
+	require.NoError(t, err)
+
+	handler := proc.handler()
+
+	err = handler.ProcessTask(context.TODO(), asynq.NewTask(PublishEvents.Type(), payload))
+	require.NoError(t, err)
+	require.Equal(t, evts, publisher.Events())
+
+	err = handler.ProcessTask(context.TODO(), asynq.NewTask(PublishEvents.Type(), []byte("{")))
+	require.ErrorIs(t, err, asynq.SkipRetry)
+}
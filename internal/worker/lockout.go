@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+type PayloadSendLockoutEmail struct {
+	LockedUntil time.Time `json:"locked_until"`
+	Username    string    `json:"username"`
+	ClientIP    string    `json:"client_ip"`
+}
+
+const TaskSendLockoutEmail = "task:send_lockout_email"
+
+var SendLockoutEmail = RegisterTask(&TaskDefinition[PayloadSendLockoutEmail]{
+	Name:    TaskSendLockoutEmail,
+	Version: 1,
+	Options: []asynq.Option{asynq.Queue(QueueCritical), asynq.MaxRetry(10)},
+	Handle:  (*RedisTaskProcessor).sendLockoutEmail,
+})
+
+func (proc *RedisTaskProcessor) sendLockoutEmail(
+	ctx context.Context,
+	payload *PayloadSendLockoutEmail,
+) (err error) {
+	var user db.User
+
+	if user, err = proc.store.GetUser(ctx, payload.Username); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("user doesn't exists: %w", asynq.SkipRetry)
+		}
+
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// TODO: send email
+
+	log.Info().
+		Str("type", TaskSendLockoutEmail).
+		Str("email", user.Email).
+		Str("client_ip", payload.ClientIP).
+		Time("locked_until", payload.LockedUntil).
+		Msg("processed task")
+
+	return nil
+}
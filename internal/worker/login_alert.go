@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+type PayloadSendLoginAlert struct {
+	CreatedAt time.Time `json:"created_at"`
+	Username  string    `json:"username"`
+	UserAgent string    `json:"user_agent"`
+	ClientIP  string    `json:"client_ip"`
+	SessionID uuid.UUID `json:"session_id"`
+}
+
+const TaskSendLoginAlert = "task:send_login_alert"
+
+var SendLoginAlert = RegisterTask(&TaskDefinition[PayloadSendLoginAlert]{
+	Name:    TaskSendLoginAlert,
+	Version: 1,
+	Options: []asynq.Option{asynq.Queue(QueueCritical), asynq.MaxRetry(10)},
+	Handle:  (*RedisTaskProcessor).sendLoginAlert,
+})
+
+// sendLoginAlert creates the secret for the "this wasn't me" link here, so
+// it only ever travels in the email.
+func (proc *RedisTaskProcessor) sendLoginAlert(
+	ctx context.Context,
+	payload *PayloadSendLoginAlert,
+) (err error) {
+	var (
+		user   db.User
+		secret string
+	)
+
+	if user, err = proc.store.GetUser(ctx, payload.Username); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("user doesn't exists: %w", asynq.SkipRetry)
+		}
+
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if secret, err = newReportSecret(); err != nil {
+		return fmt.Errorf("failed to create report secret: %w", err)
+	}
+
+	if err = proc.store.SetSessionReportSecret(ctx, db.SetSessionReportSecretParams{
+		ID:               pgtype.UUID{Bytes: payload.SessionID, Valid: true},
+		ReportSecretHash: pgtype.Text{String: HashReportSecret(secret), Valid: true},
+	}); err != nil {
+		return fmt.Errorf("failed to set report secret: %w", err)
+	}
+
+	subject, _ := newLoginAlertEmail(user, *payload, reportSessionLink(payload.SessionID, secret))
+
+	// TODO: send email
+
+	log.Info().
+		Str("type", TaskSendLoginAlert).
+		Str("email", user.Email).
+		Str("subject", subject).
+		Str("client_ip", payload.ClientIP).
+		Msg("processed task")
+
+	return nil
+}
+
+func HashReportSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func newReportSecret() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// reportSessionLink is empty when APP_LOGIN_ALERT_REPORT_URL isn't set.
+func reportSessionLink(sessionID uuid.UUID, secret string) string {
+	if len(config.App.LoginAlertReportURL) == 0 {
+		return ""
+	}
+
+	query := url.Values{
+		"session_id": {sessionID.String()},
+		"secret":     {secret},
+	}
+
+	return config.App.LoginAlertReportURL + "?" + query.Encode()
+}
+
+func newLoginAlertEmail(user db.User, payload PayloadSendLoginAlert, link string) (subject, body string) {
+	subject = "New sign-in to your SimpleBank account"
+	body = fmt.Sprintf(
+		"Hi %s,\n\nYour account was just signed in to from a new device.\n\n"+
+			"Device: %s\nIP address: %s\nTime: %s\n\n"+
+			"If this was you, you can ignore this email.",
+		user.FullName,
+		payload.UserAgent,
+		payload.ClientIP,
+		payload.CreatedAt.UTC().Format(time.RFC1123),
+	)
+
+	if len(link) > 0 {
+		body += fmt.Sprintf(" If it wasn't, sign that device out and change your password:\n\n%s", link)
+	}
+
+	return subject, body
+}
@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	TaskCleanupSessions   = "task:cleanup_sessions"
+	TaskReconcileAccounts = "task:reconcile_accounts"
+)
+
+// unreconciledAccountsLimit bounds how many accounts a reconciliation reports.
+const unreconciledAccountsLimit = 100
+
+var ERR_UNRECONCILED_ACCOUNTS = errors.New("[Err]: Account balances don't match their entries")
+
+// ProcessTaskCleanupSessions deletes the expired sessions and MFA challenges.
+func (proc *RedisTaskProcessor) ProcessTaskCleanupSessions(ctx context.Context, _ *asynq.Task) error {
+	sessions, err := proc.store.DeleteExpiredSessions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	challenges, err := proc.store.DeleteExpiredMFAChallenges(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired MFA challenges: %w", err)
+	}
+
+	log.Info().
+		Int64("sessions", sessions).
+		Int64("mfa_challenges", challenges).
+		Msg("clean up sessions")
+
+	return nil
+}
+
+// ProcessTaskReconcileAccounts checks every account balance against the sum
+// of its entries. Mismatches are logged and fail the task, without retries,
+// so they show in its last run.
+func (proc *RedisTaskProcessor) ProcessTaskReconcileAccounts(ctx context.Context, _ *asynq.Task) error {
+	accounts, err := proc.store.ListUnreconciledAccounts(ctx, unreconciledAccountsLimit)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile accounts: %w", err)
+	}
+
+	for _, account := range accounts {
+		log.Error().
+			Int64("account_id", account.ID).
+			Int64("balance", account.Balance).
+			Int64("entries_balance", account.EntriesBalance).
+			Msg("unreconciled account")
+	}
+
+	if len(accounts) > 0 {
+		return fmt.Errorf("%w: %d accounts: %w", ERR_UNRECONCILED_ACCOUNTS, len(accounts), asynq.SkipRetry)
+	}
+
+	log.Info().Msg("reconcile accounts")
+
+	return nil
+}
+
+// periodic records the runs of a periodic task's handler in the schedule
+// store.
+func (proc *RedisTaskProcessor) periodic(handler asynq.HandlerFunc) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		proc.recordRun(ctx, task.Type(), PeriodicTaskRun{
+			StartedAt: time.Now(),
+			Status:    PeriodicTaskRunning,
+		})
+
+		err := handler(ctx, task)
+		run := PeriodicTaskRun{
+			FinishedAt: time.Now(),
+			Status:     PeriodicTaskSucceeded,
+		}
+
+		if err != nil {
+			run.Status = PeriodicTaskFailed
+			run.Error = err.Error()
+		}
+
+		proc.recordRun(ctx, task.Type(), run)
+
+		return err
+	}
+}
+
+func (proc *RedisTaskProcessor) recordRun(ctx context.Context, taskType string, run PeriodicTaskRun) {
+	if err := proc.schedules.RecordRun(ctx, taskType, run); err != nil {
+		log.Error().Err(err).Str("type", taskType).Msg("failed to record periodic task run")
+	}
+}
@@ -0,0 +1,303 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/events"
+)
+
+// TaskStateCompleted is the state of a MemoryTask its handler succeeded at.
+const TaskStateCompleted = "completed"
+
+// MemoryTask is a task distributed to a MemoryTaskDistributor, with its
+// options. Delay is how long after being distributed it was due to run.
+type MemoryTask struct {
+	ID       string
+	Type     string
+	Queue    string
+	State    string
+	LastErr  string
+	Payload  []byte
+	Options  []asynq.Option
+	Delay    time.Duration
+	MaxRetry int
+	Retried  int
+}
+
+type (
+	// MemoryTaskDistributor keeps the distributed tasks in memory, for tests,
+	// until Drain runs them.
+	MemoryTaskDistributor struct {
+		*RedisTaskDistributor
+		queue *memoryQueue
+	}
+
+	memoryQueue struct {
+		tasks []*MemoryTask
+		next  int
+		mu    sync.Mutex
+	}
+)
+
+// OutboxDistributor stands in for NewOutboxTaskDistributor, ignoring the
+// queries.
+func (distr *MemoryTaskDistributor) OutboxDistributor(db.Querier) TaskDistributor {
+	return distr
+}
+
+// Tasks returns the distributed tasks of the given types, or all of them
+// when none is given, in distribution order.
+func (distr *MemoryTaskDistributor) Tasks(types ...string) []MemoryTask {
+	distr.queue.mu.Lock()
+	defer distr.queue.mu.Unlock()
+
+	tasks := make([]MemoryTask, 0, len(distr.queue.tasks))
+
+	for _, task := range distr.queue.tasks {
+		if len(types) == 0 || slices.Contains(types, task.Type) {
+			tasks = append(tasks, *task)
+		}
+	}
+
+	return tasks
+}
+
+// Drain runs the pending tasks through handler one at a time, in
+// distribution order, until none is left, including those distributed by
+// the handler. Delays are ignored. A failed task is retried right away up to
+// its MaxRetry, unless it fails with asynq.SkipRetry, and is then archived.
+// Unlike asynq, it doesn't put the retry count in the handler's context.
+// Drain returns the errors of the archived tasks.
+func (distr *MemoryTaskDistributor) Drain(ctx context.Context, handler asynq.Handler) error {
+	var errs []error
+
+	for {
+		task := distr.queue.pop()
+		if task == nil {
+			return errors.Join(errs...)
+		}
+
+		if err := distr.process(ctx, handler, task); err != nil {
+			errs = append(errs, fmt.Errorf("task %s (%s): %w", task.ID, task.Type, err))
+		}
+	}
+}
+
+func (distr *MemoryTaskDistributor) process(ctx context.Context, handler asynq.Handler, task *MemoryTask) error {
+	for {
+		err := handler.ProcessTask(ctx, asynq.NewTask(task.Type, task.Payload))
+
+		distr.queue.mu.Lock()
+
+		if err == nil {
+			task.State = TaskStateCompleted
+		} else {
+			task.LastErr = err.Error()
+
+			if !errors.Is(err, asynq.SkipRetry) && task.Retried < task.MaxRetry {
+				task.Retried++
+				err = nil
+			} else {
+				task.State = TaskStateArchived
+			}
+		}
+
+		state := task.State
+		distr.queue.mu.Unlock()
+
+		if state != TaskStatePending {
+			return err
+		}
+	}
+}
+
+func (queue *memoryQueue) EnqueueContext(
+	_ context.Context,
+	task *asynq.Task,
+	opts ...asynq.Option,
+) (*asynq.TaskInfo, error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	now := time.Now()
+	memoryTask := &MemoryTask{
+		ID:       strconv.Itoa(len(queue.tasks) + 1),
+		Type:     task.Type(),
+		Queue:    QueueDefault,
+		State:    TaskStatePending,
+		Payload:  task.Payload(),
+		Options:  opts,
+		MaxRetry: defaultMaxRetry,
+	}
+
+	for _, opt := range opts {
+		switch opt.Type() {
+		case asynq.TaskIDOpt:
+			memoryTask.ID = opt.Value().(string)
+
+			for _, t := range queue.tasks {
+				if t.ID == memoryTask.ID {
+					return nil, asynq.ErrTaskIDConflict
+				}
+			}
+		case asynq.QueueOpt:
+			memoryTask.Queue = opt.Value().(string)
+		case asynq.MaxRetryOpt:
+			memoryTask.MaxRetry = opt.Value().(int)
+		case asynq.ProcessInOpt:
+			memoryTask.Delay = opt.Value().(time.Duration)
+		case asynq.ProcessAtOpt:
+			memoryTask.Delay = opt.Value().(time.Time).Sub(now)
+		}
+	}
+
+	queue.tasks = append(queue.tasks, memoryTask)
+
+	return &asynq.TaskInfo{
+		ID:            memoryTask.ID,
+		Type:          memoryTask.Type,
+		Payload:       memoryTask.Payload,
+		Queue:         memoryTask.Queue,
+		MaxRetry:      memoryTask.MaxRetry,
+		NextProcessAt: now.Add(memoryTask.Delay),
+	}, nil
+}
+
+// pop returns the next pending task, or nil if there's none.
+func (queue *memoryQueue) pop() *MemoryTask {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if queue.next == len(queue.tasks) {
+		return nil
+	}
+
+	task := queue.tasks[queue.next]
+	queue.next++
+
+	return task
+}
+
+func NewMemoryTaskDistributor() *MemoryTaskDistributor {
+	queue := &memoryQueue{}
+
+	return &MemoryTaskDistributor{
+		RedisTaskDistributor: &RedisTaskDistributor{client: queue},
+		queue:                queue,
+	}
+}
+
+// MemoryTaskProcessor runs the tasks of a MemoryTaskDistributor through the
+// same handlers as RedisTaskProcessor, synchronously, for tests. Events are
+// published to a MemoryPublisher.
+type MemoryTaskProcessor struct {
+	*RedisTaskProcessor
+	distributor *MemoryTaskDistributor
+	publisher   *events.MemoryPublisher
+}
+
+// Start drains the distributor's pending tasks.
+func (proc *MemoryTaskProcessor) Start() error {
+	return proc.distributor.Drain(context.Background(), proc.handler())
+}
+
+// Shutdown does nothing: tasks only run while Start drains them.
+func (proc *MemoryTaskProcessor) Shutdown() {}
+
+// Events returns the events published by the tasks, as
+// events.MemoryPublisher.Events.
+func (proc *MemoryTaskProcessor) Events(types ...string) []events.Event {
+	return proc.publisher.Events(types...)
+}
+
+func NewMemoryTaskProcessor(store db.Store, distributor *MemoryTaskDistributor) *MemoryTaskProcessor {
+	publisher := events.NewMemoryPublisher()
+
+	return &MemoryTaskProcessor{
+		RedisTaskProcessor: &RedisTaskProcessor{
+			store:      store,
+			publisher:  publisher,
+			httpClient: newWebhookClient(),
+			schedules:  NewMemoryScheduleStore(),
+		},
+		distributor: distributor,
+		publisher:   publisher,
+	}
+}
+
+// MemoryScheduleStore is a ScheduleStore for tests. Its lock doesn't
+// expire.
+type MemoryScheduleStore struct {
+	runs  map[string]PeriodicTaskRun
+	owner string
+	mu    sync.Mutex
+}
+
+func (store *MemoryScheduleStore) AcquireLock(_ context.Context, owner string, _ time.Duration) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if len(store.owner) == 0 {
+		store.owner = owner
+	}
+
+	return store.owner == owner, nil
+}
+
+func (store *MemoryScheduleStore) ReleaseLock(_ context.Context, owner string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.owner == owner {
+		store.owner = ""
+	}
+
+	return nil
+}
+
+func (store *MemoryScheduleStore) RecordRun(_ context.Context, taskType string, run PeriodicTaskRun) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	last := store.runs[taskType]
+	last.Status = run.Status
+	last.Error = run.Error
+
+	if len(run.TaskID) > 0 {
+		last.TaskID = run.TaskID
+	}
+
+	for _, t := range []struct{ from, to *time.Time }{
+		{&run.EnqueuedAt, &last.EnqueuedAt},
+		{&run.StartedAt, &last.StartedAt},
+		{&run.FinishedAt, &last.FinishedAt},
+	} {
+		if !t.from.IsZero() {
+			*t.to = *t.from
+		}
+	}
+
+	store.runs[taskType] = last
+
+	return nil
+}
+
+func (store *MemoryScheduleStore) LastRun(_ context.Context, taskType string) (PeriodicTaskRun, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.runs[taskType], nil
+}
+
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{runs: make(map[string]PeriodicTaskRun)}
+}
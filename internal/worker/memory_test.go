@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTaskDistributor(t *testing.T) {
+	distributor := NewMemoryTaskDistributor()
+
+	err := SendVerifyEmail.Enqueue(
+		context.TODO(),
+		distributor,
+		&PayloadSendVerifyEmail{Username: "alice"},
+		asynq.Queue(QueueCritical),
+		asynq.MaxRetry(3),
+		asynq.ProcessIn(time.Minute),
+	)
+	require.NoError(t, err)
+
+	err = DeliverWebhook.Enqueue(
+		context.TODO(),
+		distributor,
+		&PayloadDeliverWebhook{DeliveryID: 7},
+		asynq.TaskID("delivery:7"),
+	)
+	require.NoError(t, err)
+
+	err = DeliverWebhook.Enqueue(
+		context.TODO(),
+		distributor,
+		&PayloadDeliverWebhook{DeliveryID: 7},
+		asynq.TaskID("delivery:7"),
+	)
+	require.ErrorIs(t, err, asynq.ErrTaskIDConflict)
+
+	tasks := distributor.Tasks()
+	require.Len(t, tasks, 2)
+	require.Equal(t, TaskSendVerifyEmail, tasks[0].Type)
+	require.Equal(t, QueueCritical, tasks[0].Queue)
+	require.Equal(t, 3, tasks[0].MaxRetry)
+	require.Equal(t, time.Minute, tasks[0].Delay)
+	require.Equal(t, TaskStatePending, tasks[0].State)
+	require.Equal(t, "delivery:7", tasks[1].ID)
+	require.Equal(t, QueueDefault, tasks[1].Queue)
+	require.Equal(t, defaultMaxRetry, tasks[1].MaxRetry)
+	require.Len(t, distributor.Tasks(TaskDeliverWebhook), 1)
+}
+
+func TestMemoryTaskDistributorDrain(t *testing.T) {
+	distributor := NewMemoryTaskDistributor()
+	attempts := map[string]int{}
+
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(TaskSendVerifyEmail, func(ctx context.Context, task *asynq.Task) error {
+		attempts[task.Type()]++
+
+		if attempts[task.Type()] < 3 {
+			return errors.New("smtp down")
+		}
+
+		// Tasks distributed while draining are drained too.
+		return SendLockoutEmail.Enqueue(ctx, distributor, &PayloadSendLockoutEmail{Username: "alice"})
+	})
+	mux.HandleFunc(TaskSendLockoutEmail, func(_ context.Context, task *asynq.Task) error {
+		attempts[task.Type()]++
+		return nil
+	})
+	mux.HandleFunc(TaskDeliverWebhook, func(_ context.Context, task *asynq.Task) error {
+		attempts[task.Type()]++
+		return fmt.Errorf("endpoint disabled: %w", asynq.SkipRetry)
+	})
+
+	require.NoError(t, SendVerifyEmail.Enqueue(
+		context.TODO(),
+		distributor,
+		&PayloadSendVerifyEmail{Username: "alice"},
+		asynq.MaxRetry(5),
+	))
+	require.NoError(t, DeliverWebhook.Enqueue(context.TODO(), distributor, &PayloadDeliverWebhook{DeliveryID: 7}))
+
+	err := distributor.Drain(context.TODO(), mux)
+	require.ErrorIs(t, err, asynq.SkipRetry)
+	require.ErrorContains(t, err, TaskDeliverWebhook)
+	require.Equal(t, map[string]int{
+		TaskSendVerifyEmail:  3,
+		TaskSendLockoutEmail: 1,
+		TaskDeliverWebhook:   1,
+	}, attempts)
+
+	tasks := distributor.Tasks()
+	require.Len(t, tasks, 3)
+	require.Equal(t, TaskStateCompleted, tasks[0].State)
+	require.Equal(t, 2, tasks[0].Retried)
+	require.Equal(t, "smtp down", tasks[0].LastErr)
+	require.Equal(t, TaskStateArchived, tasks[1].State)
+	require.Equal(t, TaskSendLockoutEmail, tasks[2].Type)
+	require.Equal(t, TaskStateCompleted, tasks[2].State)
+
+	require.NoError(t, distributor.Drain(context.TODO(), mux))
+}
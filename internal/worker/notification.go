@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/notification"
+)
+
+// PayloadSendNotification is a notification about an account, sent to its
+// owner through the channels they haven't opted out of.
+type PayloadSendNotification struct {
+	EventType  string `json:"event_type"`
+	Currency   string `json:"currency"`
+	AccountID  int64  `json:"account_id"`
+	TransferID int64  `json:"transfer_id,omitempty"`
+	Amount     int64  `json:"amount,omitempty"`
+	Balance    int64  `json:"balance"`
+	Threshold  int64  `json:"threshold,omitempty"`
+}
+
+var SendNotification = RegisterTask(&TaskDefinition[PayloadSendNotification]{
+	Name:    "task:send_notification",
+	Version: 1,
+	Options: []asynq.Option{asynq.Queue(QueueDefault), asynq.MaxRetry(10)},
+	Handle:  (*RedisTaskProcessor).sendNotification,
+})
+
+func (proc *RedisTaskProcessor) sendNotification(ctx context.Context, payload *PayloadSendNotification) error {
+	account, err := proc.store.GetAccount(ctx, payload.AccountID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("account doesn't exist: %w", asynq.SkipRetry)
+		}
+
+		return fmt.Errorf("failed to get account: %w", err)
+	}
+
+	user, err := proc.store.GetUser(ctx, account.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	prefs, err := proc.store.ListNotificationPreferences(ctx, user.Username)
+	if err != nil {
+		return fmt.Errorf("failed to list notification preferences: %w", err)
+	}
+
+	for _, channel := range notification.Channels {
+		if !notification.Enabled(prefs, payload.EventType, channel) {
+			continue
+		}
+
+		// TODO: send through the channel
+
+		log.Info().
+			Str("event_type", payload.EventType).
+			Str("channel", channel).
+			Str("email", user.Email).
+			Int64("account_id", payload.AccountID).
+			Int64("transfer_id", payload.TransferID).
+			Msg("send notification")
+	}
+
+	return nil
+}
+
+// EnqueueTransferNotifications writes the transfer's notifications to the
+// outbox with q: a receipt for the sender, an incoming-funds notice for the
+// recipient and, if the transfer took the sending account below its alert
+// threshold, a low-balance alert.
+func EnqueueTransferNotifications(ctx context.Context, q db.Querier, result db.TransferTxResult) error {
+	transfer := result.Transfer
+	payloads := []*PayloadSendNotification{
+		{
+			EventType:  notification.EventTransferReceipt,
+			Currency:   result.FromAccount.Currency,
+			AccountID:  result.FromAccount.ID,
+			TransferID: transfer.ID,
+			Amount:     transfer.Amount,
+			Balance:    result.FromAccount.Balance,
+		},
+		{
+			EventType:  notification.EventTransferIncoming,
+			Currency:   result.ToAccount.Currency,
+			AccountID:  result.ToAccount.ID,
+			TransferID: transfer.ID,
+			Amount:     transfer.Amount,
+			Balance:    result.ToAccount.Balance,
+		},
+	}
+
+	alert, err := q.GetLowBalanceAlert(ctx, result.FromAccount.ID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to get low balance alert: %w", err)
+	}
+
+	if err == nil && notification.CrossedBelow(
+		result.FromAccount.Balance+transfer.Amount,
+		result.FromAccount.Balance,
+		alert.Threshold,
+	) {
+		payloads = append(payloads, &PayloadSendNotification{
+			EventType: notification.EventLowBalance,
+			Currency:  result.FromAccount.Currency,
+			AccountID: result.FromAccount.ID,
+			Balance:   result.FromAccount.Balance,
+			Threshold: alert.Threshold,
+		})
+	}
+
+	distributor := NewOutboxTaskDistributor(q)
+
+	for _, payload := range payloads {
+		if err = SendNotification.Enqueue(ctx, distributor, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/notification"
+)
+
+func TestEnqueueTransferNotifications(t *testing.T) {
+	result := db.TransferTxResult{
+		Transfer:    db.Transfer{ID: 1, FromAccountID: 10, ToAccountID: 20, Amount: 50},
+		FromAccount: db.Account{ID: 10, Balance: 80, Currency: "USD"},
+		ToAccount:   db.Account{ID: 20, Balance: 150, Currency: "USD"},
+	}
+	testCases := []struct {
+		name           string
+		alert          db.LowBalanceAlert
+		alertErr       error
+		expectedEvents []string
+	}{
+		{
+			name:     "NoAlert",
+			alertErr: pgx.ErrNoRows,
+			expectedEvents: []string{
+				notification.EventTransferReceipt,
+				notification.EventTransferIncoming,
+			},
+		},
+		{
+			name:  "CrossedBelow",
+			alert: db.LowBalanceAlert{AccountID: 10, Threshold: 100},
+			expectedEvents: []string{
+				notification.EventTransferReceipt,
+				notification.EventTransferIncoming,
+				notification.EventLowBalance,
+			},
+		},
+		{
+			name:  "AlreadyBelow",
+			alert: db.LowBalanceAlert{AccountID: 10, Threshold: 200},
+			expectedEvents: []string{
+				notification.EventTransferReceipt,
+				notification.EventTransferIncoming,
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			var events []string
+
+			store := mocks.NewStore(t)
+			store.
+				EXPECT().
+				GetLowBalanceAlert(mock.AnythingOfType("context.todoCtx"), result.FromAccount.ID).
+				Once().
+				Return(tc.alert, tc.alertErr)
+			store.
+				EXPECT().
+				CreateTaskOutbox(
+					mock.AnythingOfType("context.todoCtx"),
+					mock.MatchedBy(func(arg db.CreateTaskOutboxParams) bool {
+						return arg.TaskType == SendNotification.Type()
+					}),
+				).
+				Times(len(tc.expectedEvents)).
+				Return(db.TaskOutbox{TaskType: SendNotification.Type()}, nil).
+				Run(func(args mock.Arguments) {
+					var payload PayloadSendNotification
+
+					require.NoError(t, json.Unmarshal(args.Get(1).(db.CreateTaskOutboxParams).Payload, &payload))
+					events = append(events, payload.EventType)
+				})
+
+			require.NoError(t, EnqueueTransferNotifications(context.TODO(), store, result))
+			require.Equal(t, tc.expectedEvents, events)
+		})
+	}
+}
+
+func TestSendNotificationMissingAccount(t *testing.T) {
+	store := mocks.NewStore(t)
+	store.
+		EXPECT().
+		GetAccount(mock.AnythingOfType("context.todoCtx"), int64(10)).
+		Once().
+		Return(db.Account{}, pgx.ErrNoRows)
+
+	proc := &RedisTaskProcessor{store: store}
+	err := proc.sendNotification(context.TODO(), &PayloadSendNotification{
+		EventType: notification.EventTransferReceipt,
+		AccountID: 10,
+	})
+	require.ErrorContains(t, err, "account doesn't exist")
+}
@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+)
+
+// defaultMaxRetry is asynq's default, for tasks without asynq.MaxRetry.
+const defaultMaxRetry = 25
+
+var ERR_UNSUPPORTED_OUTBOX_OPTION = errors.New("[Err]: The task option isn't supported by the outbox")
+
+type (
+	outboxClient struct {
+		queries db.Querier
+	}
+
+	// OutboxRelay publishes the tasks written to the outbox to asynq. A task
+	// is marked as sent after it's enqueued, so it's delivered at least once;
+	// the outbox id doubles as the asynq task id so a task published again,
+	// after a failure to mark it, is rejected as a duplicate.
+	OutboxRelay struct {
+		store    db.Store
+		client   taskEnqueuer
+		interval time.Duration
+		limit    int32
+	}
+)
+
+// NewOutboxTaskDistributor distributes tasks by writing them with queries,
+// usually those of a transaction, so they're only published if it commits.
+// The asynq.Queue, asynq.MaxRetry, asynq.ProcessIn and asynq.ProcessAt
+// options are supported.
+func NewOutboxTaskDistributor(queries db.Querier) TaskDistributor {
+	return &RedisTaskDistributor{
+		client: &outboxClient{queries: queries},
+	}
+}
+
+func (client *outboxClient) EnqueueContext(
+	ctx context.Context,
+	task *asynq.Task,
+	opts ...asynq.Option,
+) (*asynq.TaskInfo, error) {
+	arg := db.CreateTaskOutboxParams{
+		TaskType:  task.Type(),
+		Payload:   task.Payload(),
+		Queue:     QueueDefault,
+		MaxRetry:  defaultMaxRetry,
+		ProcessAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+
+	for _, opt := range opts {
+		switch opt.Type() {
+		case asynq.QueueOpt:
+			arg.Queue = opt.Value().(string)
+		case asynq.MaxRetryOpt:
+			arg.MaxRetry = int32(opt.Value().(int))
+		case asynq.ProcessInOpt:
+			arg.ProcessAt.Time = time.Now().Add(opt.Value().(time.Duration))
+		case asynq.ProcessAtOpt:
+			arg.ProcessAt.Time = opt.Value().(time.Time)
+		default:
+			return nil, fmt.Errorf("%w: %s", ERR_UNSUPPORTED_OUTBOX_OPTION, opt.String())
+		}
+	}
+
+	outboxTask, err := client.queries.CreateTaskOutbox(ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &asynq.TaskInfo{
+		ID:            outboxTaskID(outboxTask.ID),
+		Type:          outboxTask.TaskType,
+		Payload:       outboxTask.Payload,
+		Queue:         outboxTask.Queue,
+		MaxRetry:      int(outboxTask.MaxRetry),
+		NextProcessAt: outboxTask.ProcessAt.Time,
+	}, nil
+}
+
+// Start relays the pending tasks every interval, until ctx is done.
+func (relay *OutboxRelay) Start(ctx context.Context) error {
+	ticker := time.NewTicker(relay.interval)
+	defer ticker.Stop()
+
+	for {
+		relay.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Relay publishes a batch of pending tasks and returns how many were sent.
+func (relay *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	result, err := relay.store.RelayTaskOutboxTx(ctx, db.RelayTaskOutboxTxParams{
+		Limit: relay.limit,
+		Publish: func(task db.TaskOutbox) error {
+			return relay.publish(ctx, task)
+		},
+	})
+
+	return result.Sent, err
+}
+
+// drain relays batches until the outbox is empty or relaying fails.
+func (relay *OutboxRelay) drain(ctx context.Context) {
+	for {
+		sent, err := relay.Relay(ctx)
+		if err != nil {
+			log.Error().Err(err).Int("sent", sent).Msg("failed to relay the task outbox")
+			return
+		}
+
+		if sent < int(relay.limit) {
+			return
+		}
+	}
+}
+
+func (relay *OutboxRelay) publish(ctx context.Context, task db.TaskOutbox) error {
+	opts := []asynq.Option{
+		asynq.TaskID(outboxTaskID(task.ID)),
+		asynq.Queue(task.Queue),
+		asynq.MaxRetry(int(task.MaxRetry)),
+	}
+
+	if task.ProcessAt.Time.After(time.Now()) {
+		opts = append(opts, asynq.ProcessAt(task.ProcessAt.Time))
+	}
+
+	taskInfo, err := relay.client.EnqueueContext(ctx, asynq.NewTask(task.TaskType, task.Payload), opts...)
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	log.Info().
+		Str("id", taskInfo.ID).
+		Str("type", taskInfo.Type).
+		Int("retries", taskInfo.MaxRetry).
+		Str("queue", taskInfo.Queue).
+		Msg("relay task")
+
+	return nil
+}
+
+func (relay *OutboxRelay) Close() error {
+	return closeClient(relay.client)
+}
+
+func outboxTaskID(id int64) string {
+	return "outbox:" + strconv.FormatInt(id, 10)
+}
+
+func NewOutboxRelay(store db.Store) *OutboxRelay {
+	rcopt := asynq.RedisClientOpt{
+		Addr: net.JoinHostPort(config.Redis.Host, config.Redis.Port),
+	}
+
+	return &OutboxRelay{
+		store:    store,
+		client:   asynq.NewClient(rcopt),
+		interval: config.App.OutboxRelayInterval,
+		limit:    config.App.OutboxRelayBatchSize,
+	}
+}
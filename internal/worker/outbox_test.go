@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+)
+
+type recordingEnqueuer struct {
+	err   error
+	tasks []*asynq.Task
+	opts  [][]asynq.Option
+}
+
+func (enqueuer *recordingEnqueuer) EnqueueContext(
+	_ context.Context,
+	task *asynq.Task,
+	opts ...asynq.Option,
+) (*asynq.TaskInfo, error) {
+	if enqueuer.err != nil {
+		return nil, enqueuer.err
+	}
+
+	enqueuer.tasks = append(enqueuer.tasks, task)
+	enqueuer.opts = append(enqueuer.opts, opts)
+
+	return &asynq.TaskInfo{Type: task.Type()}, nil
+}
+
+func TestOutboxTaskDistributor(t *testing.T) {
+	store := mocks.NewStore(t)
+	start := time.Now()
+
+	store.
+		EXPECT().
+		CreateTaskOutbox(
+			mock.AnythingOfType("context.todoCtx"),
+			mock.MatchedBy(func(arg db.CreateTaskOutboxParams) bool {
+				return arg.TaskType == TaskSendVerifyEmail &&
+					arg.Queue == QueueCritical &&
+					arg.MaxRetry == 10 &&
+					arg.ProcessAt.Time.Sub(start) >= 10*time.Second
+			}),
+		).
+		Once().
+		Return(db.TaskOutbox{ID: 7, TaskType: TaskSendVerifyEmail, Queue: QueueCritical, MaxRetry: 10}, nil)
+
+	err := SendVerifyEmail.Enqueue(
+		context.TODO(),
+		NewOutboxTaskDistributor(store),
+		&PayloadSendVerifyEmail{Username: "alice"},
+		asynq.ProcessIn(10*time.Second),
+	)
+	require.NoError(t, err)
+
+	err = SendVerifyEmail.Enqueue(
+		context.TODO(),
+		NewOutboxTaskDistributor(store),
+		&PayloadSendVerifyEmail{Username: "alice"},
+		asynq.Timeout(time.Minute),
+	)
+	require.ErrorIs(t, err, ERR_UNSUPPORTED_OUTBOX_OPTION)
+}
+
+func TestOutboxRelayPublish(t *testing.T) {
+	enqueuer := &recordingEnqueuer{}
+	relay := &OutboxRelay{client: enqueuer}
+	task := db.TaskOutbox{
+		ID:        42,
+		TaskType:  TaskSendVerifyEmail,
+		Payload:   []byte(`{"username":"alice"}`),
+		Queue:     QueueCritical,
+		MaxRetry:  10,
+		ProcessAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+
+	require.NoError(t, relay.publish(context.TODO(), task))
+	require.Len(t, enqueuer.tasks, 1)
+	require.Equal(t, task.Payload, enqueuer.tasks[0].Payload())
+
+	optTypes := make(map[asynq.OptionType]interface{})
+
+	for _, opt := range enqueuer.opts[0] {
+		optTypes[opt.Type()] = opt.Value()
+	}
+
+	require.Equal(t, "outbox:42", optTypes[asynq.TaskIDOpt])
+	require.Equal(t, QueueCritical, optTypes[asynq.QueueOpt])
+	require.Equal(t, 10, optTypes[asynq.MaxRetryOpt])
+	require.Contains(t, optTypes, asynq.ProcessAtOpt)
+
+	// A task enqueued before the relay failed to mark it counts as sent.
+	enqueuer.err = asynq.ErrTaskIDConflict
+	require.NoError(t, relay.publish(context.TODO(), task))
+}
@@ -3,9 +3,13 @@ package worker
 import (
 	"context"
 	"net"
+	"net/http"
+	"time"
 
 	"github.com/dharmavagabond/simple-bank/internal/config"
 	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/events"
+	"github.com/dharmavagabond/simple-bank/internal/webhook"
 	"github.com/hibiken/asynq"
 	"github.com/rs/zerolog/log"
 )
@@ -17,18 +21,45 @@ const (
 
 type TaskProcessor interface {
 	Start() error
-	ProcessTaskSendVerifyEmail(ctx context.Context, task *asynq.Task) error
+	Shutdown()
+	ProcessTaskCleanupSessions(ctx context.Context, task *asynq.Task) error
+	ProcessTaskReconcileAccounts(ctx context.Context, task *asynq.Task) error
 }
 
 type RedisTaskProcessor struct {
-	server *asynq.Server
-	store  db.Store
+	server     *asynq.Server
+	store      db.Store
+	publisher  events.Publisher
+	httpClient *http.Client
+	schedules  ScheduleStore
 }
 
 func (proc *RedisTaskProcessor) Start() error {
+	return proc.server.Start(proc.handler())
+}
+
+// Shutdown stops fetching tasks and waits up to the shutdown timeout for
+// those in progress, which are requeued if they don't finish in time.
+func (proc *RedisTaskProcessor) Shutdown() {
+	proc.server.Shutdown()
+
+	for _, client := range []any{proc.publisher, proc.schedules} {
+		if err := closeClient(client); err != nil {
+			log.Error().Err(err).Msg("failed to close the task processor's client")
+		}
+	}
+}
+
+func (proc *RedisTaskProcessor) handler() *asynq.ServeMux {
 	mux := asynq.NewServeMux()
-	mux.HandleFunc(TaskSendVerifyEmail, proc.ProcessTaskSendVerifyEmail)
-	return proc.server.Start(mux)
+	mux.HandleFunc(TaskCleanupSessions, proc.periodic(proc.ProcessTaskCleanupSessions))
+	mux.HandleFunc(TaskReconcileAccounts, proc.periodic(proc.ProcessTaskReconcileAccounts))
+
+	for _, task := range registeredTasks {
+		task.register(mux, proc)
+	}
+
+	return mux
 }
 
 func NewRedisTaskProcessor(store db.Store) TaskProcessor {
@@ -42,7 +73,15 @@ func NewRedisTaskProcessor(store db.Store) TaskProcessor {
 				QueueCritical: 10,
 				QueueDefault:  5,
 			},
-			Logger: NewWorkerLogger(),
+			Logger:          NewWorkerLogger(),
+			ShutdownTimeout: config.App.ShutdownTimeout,
+			RetryDelayFunc: func(n int, err error, task *asynq.Task) time.Duration {
+				if task.Type() == DeliverWebhook.Type() {
+					return webhook.RetryDelay(n)
+				}
+
+				return asynq.DefaultRetryDelayFunc(n, err, task)
+			},
 			ErrorHandler: asynq.ErrorHandlerFunc(
 				func(ctx context.Context, task *asynq.Task, err error) {
 					log.Error().
@@ -55,7 +94,22 @@ func NewRedisTaskProcessor(store db.Store) TaskProcessor {
 		})
 
 	return &RedisTaskProcessor{
-		server: server,
-		store:  store,
+		server:     server,
+		store:      store,
+		publisher:  events.NewConfiguredRedisStream(),
+		httpClient: newWebhookClient(),
+		schedules:  NewConfiguredRedisScheduleStore(),
+	}
+}
+
+func newWebhookClient() *http.Client {
+	return &http.Client{
+		// Endpoints on the bank's network are only reachable in development.
+		Transport: webhook.NewTransport(config.App.IsDev),
+		Timeout:   config.App.WebhookTimeout,
+		// Webhooks aren't redirected: a 3xx response is a failed delivery.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
 	}
 }
@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+)
+
+type (
+	// TaskDefinition declares a task with a typed payload. Registered with
+	// RegisterTask, its handler is served by the task processor, and
+	// Enqueue distributes it with Options as defaults.
+	//
+	// Version is the schema version of P, starting at 1. From version 2 it's
+	// part of the task type, so the tasks of an older version still queued
+	// after a change of schema are told apart and decoded by Upgrades.
+	TaskDefinition[P any] struct {
+		Handle   func(proc *RedisTaskProcessor, ctx context.Context, payload *P) error
+		Upgrades map[int]func(payload []byte) (*P, error)
+		Name     string
+		Options  []asynq.Option
+		Version  int
+	}
+
+	registeredTask interface {
+		register(mux *asynq.ServeMux, proc *RedisTaskProcessor)
+	}
+)
+
+var registeredTasks []registeredTask
+
+// RegisterTask registers the definition with the task processor. It's meant
+// to be called when initialising a package variable, and panics on an
+// invalid definition.
+func RegisterTask[P any](def *TaskDefinition[P]) *TaskDefinition[P] {
+	if len(def.Name) == 0 || def.Handle == nil || def.Version < 1 {
+		panic(fmt.Sprintf("worker: invalid task definition %q", def.Name))
+	}
+
+	for version := range def.Upgrades {
+		if version < 1 || version >= def.Version {
+			panic(fmt.Sprintf("worker: invalid upgrade from version %d of %q", version, def.Name))
+		}
+	}
+
+	registeredTasks = append(registeredTasks, def)
+
+	return def
+}
+
+// Type is the task type of the current version.
+func (def *TaskDefinition[P]) Type() string {
+	return def.typeOf(def.Version)
+}
+
+func (def *TaskDefinition[P]) typeOf(version int) string {
+	if version == 1 {
+		return def.Name
+	}
+
+	return fmt.Sprintf("%s:v%d", def.Name, version)
+}
+
+// Enqueue distributes a task with the payload. The options override the
+// definition's.
+func (def *TaskDefinition[P]) Enqueue(
+	ctx context.Context,
+	distributor TaskDistributor,
+	payload *P,
+	opts ...asynq.Option,
+) error {
+	bs, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	options := make([]asynq.Option, 0, len(def.Options)+len(opts))
+	options = append(options, def.Options...)
+	options = append(options, opts...)
+
+	return distributor.Enqueue(ctx, asynq.NewTask(def.Type(), bs), options...)
+}
+
+func (def *TaskDefinition[P]) register(mux *asynq.ServeMux, proc *RedisTaskProcessor) {
+	mux.HandleFunc(def.Type(), func(ctx context.Context, task *asynq.Task) error {
+		var payload P
+
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+		}
+
+		return def.Handle(proc, ctx, &payload)
+	})
+
+	for version, upgrade := range def.Upgrades {
+		version, upgrade := version, upgrade
+
+		mux.HandleFunc(def.typeOf(version), func(ctx context.Context, task *asynq.Task) error {
+			payload, err := upgrade(task.Payload())
+			if err != nil {
+				return fmt.Errorf("failed to upgrade payload from version %d: %w: %w", version, err, asynq.SkipRetry)
+			}
+
+			return def.Handle(proc, ctx, payload)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/require"
+)
+
+type payloadGreet struct {
+	FullName string `json:"full_name"`
+}
+
+var (
+	greeted   []string
+	taskGreet = RegisterTask(&TaskDefinition[payloadGreet]{
+		Name:    "task:test_greet",
+		Version: 2,
+		Options: []asynq.Option{asynq.Queue(QueueCritical), asynq.MaxRetry(2)},
+		Handle: func(_ *RedisTaskProcessor, _ context.Context, payload *payloadGreet) error {
+			greeted = append(greeted, payload.FullName)
+			return nil
+		},
+		Upgrades: map[int]func([]byte) (*payloadGreet, error){
+			1: func(bs []byte) (*payloadGreet, error) {
+				var v1 struct {
+					Name string `json:"name"`
+				}
+
+				if err := json.Unmarshal(bs, &v1); err != nil {
+					return nil, err
+				}
+
+				return &payloadGreet{FullName: v1.Name}, nil
+			},
+		},
+	})
+)
+
+func TestTaskDefinition(t *testing.T) {
+	distributor := NewMemoryTaskDistributor()
+
+	require.NoError(t, taskGreet.Enqueue(context.TODO(), distributor, &payloadGreet{FullName: "Bob"}, asynq.MaxRetry(5)))
+	require.NoError(t, distributor.Enqueue(context.TODO(), asynq.NewTask("task:test_greet", []byte(`{"name":"Alice"}`))))
+	require.NoError(t, distributor.Enqueue(context.TODO(), asynq.NewTask("task:test_greet", []byte(`"Carol"`))))
+
+	tasks := distributor.Tasks(taskGreet.Type())
+	require.Len(t, tasks, 1)
+	require.Equal(t, "task:test_greet:v2", tasks[0].Type)
+	require.Equal(t, QueueCritical, tasks[0].Queue)
+	require.Equal(t, 5, tasks[0].MaxRetry)
+	require.JSONEq(t, `{"full_name":"Bob"}`, string(tasks[0].Payload))
+
+	err := NewMemoryTaskProcessor(nil, distributor).Start()
+	require.ErrorIs(t, err, asynq.SkipRetry)
+	require.ErrorContains(t, err, "version 1")
+	require.Equal(t, []string{"Bob", "Alice"}, greeted)
+	require.Equal(t, TaskStateArchived, distributor.Tasks()[2].State)
+}
+
+func TestRegisterInvalidTask(t *testing.T) {
+	handle := func(*RedisTaskProcessor, context.Context, *payloadGreet) error { return nil }
+
+	require.Panics(t, func() {
+		RegisterTask(&TaskDefinition[payloadGreet]{Name: "task:test_invalid", Handle: handle})
+	})
+	require.Panics(t, func() {
+		RegisterTask(&TaskDefinition[payloadGreet]{
+			Name:     "task:test_invalid",
+			Version:  1,
+			Handle:   handle,
+			Upgrades: map[int]func([]byte) (*payloadGreet, error){2: nil},
+		})
+	})
+}
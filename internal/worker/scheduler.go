@@ -0,0 +1,322 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+)
+
+const (
+	PeriodicTaskEnqueued  = "enqueued"
+	PeriodicTaskRunning   = "running"
+	PeriodicTaskSucceeded = "succeeded"
+	PeriodicTaskFailed    = "failed"
+)
+
+// periodicMaxRetry is low since a failed periodic task runs again at its
+// next scheduled time anyway.
+const periodicMaxRetry = 3
+
+type (
+	// PeriodicTask is a task the scheduler enqueues on a cron schedule, in
+	// the standard five-field syntax or a descriptor like @hourly. A task
+	// with an empty Cron is disabled.
+	PeriodicTask struct {
+		Type  string `json:"type"`
+		Cron  string `json:"cron"`
+		Queue string `json:"queue"`
+	}
+
+	// PeriodicTaskRun is the last run of a periodic task. Its status is one
+	// of the PeriodicTask* statuses.
+	PeriodicTaskRun struct {
+		EnqueuedAt time.Time `json:"enqueued_at"`
+		StartedAt  time.Time `json:"started_at"`
+		FinishedAt time.Time `json:"finished_at"`
+		TaskID     string    `json:"task_id"`
+		Status     string    `json:"status"`
+		Error      string    `json:"error"`
+	}
+
+	// ScheduleStore holds the lock that makes a single scheduler active and
+	// the last run of each periodic task.
+	ScheduleStore interface {
+		// AcquireLock takes the lock for owner, or extends it if owner
+		// already holds it, and reports whether owner holds it.
+		AcquireLock(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+		ReleaseLock(ctx context.Context, owner string) error
+		// RecordRun updates the status and error of the task's last run,
+		// and its non-zero times and task id.
+		RecordRun(ctx context.Context, taskType string, run PeriodicTaskRun) error
+		LastRun(ctx context.Context, taskType string) (PeriodicTaskRun, error)
+	}
+
+	// Scheduler enqueues the periodic tasks. Every instance campaigns for
+	// the lock, and only the one holding it runs the schedule, so each task
+	// is enqueued once per scheduled time.
+	Scheduler struct {
+		store   ScheduleStore
+		client  taskEnqueuer
+		cron    *cron.Cron
+		id      string
+		lockTTL time.Duration
+		active  bool
+	}
+)
+
+// PeriodicTasks are the periodic tasks, with their schedule from the config.
+func PeriodicTasks() []PeriodicTask {
+	return []PeriodicTask{
+		{Type: TaskCleanupSessions, Cron: config.App.SessionCleanupCron, Queue: QueueDefault},
+		{Type: TaskReconcileAccounts, Cron: config.App.ReconciliationCron, Queue: QueueDefault},
+	}
+}
+
+// Start campaigns for the lock every third of its TTL, running the schedule
+// while it's held, until ctx is done.
+func (scheduler *Scheduler) Start(ctx context.Context) error {
+	ticker := time.NewTicker(scheduler.lockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		scheduler.campaign(ctx)
+
+		select {
+		case <-ctx.Done():
+			scheduler.resign()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign starts the schedule when the lock is acquired and stops it when
+// it's lost. A failure to reach the store counts as losing it, since the
+// lock may expire meanwhile and be acquired by another instance.
+func (scheduler *Scheduler) campaign(ctx context.Context) {
+	held, err := scheduler.store.AcquireLock(ctx, scheduler.id, scheduler.lockTTL)
+	if err != nil && ctx.Err() == nil {
+		log.Error().Err(err).Msg("failed to acquire the scheduler lock")
+	}
+
+	switch {
+	case held && !scheduler.active:
+		scheduler.cron.Start()
+		log.Info().Str("id", scheduler.id).Msg("start scheduler")
+	case !held && scheduler.active:
+		<-scheduler.cron.Stop().Done()
+		log.Info().Str("id", scheduler.id).Msg("stop scheduler")
+	}
+
+	scheduler.active = held
+}
+
+func (scheduler *Scheduler) resign() {
+	if !scheduler.active {
+		return
+	}
+
+	<-scheduler.cron.Stop().Done()
+	scheduler.active = false
+
+	if err := scheduler.store.ReleaseLock(context.Background(), scheduler.id); err != nil {
+		log.Error().Err(err).Msg("failed to release the scheduler lock")
+	}
+}
+
+func (scheduler *Scheduler) Close() error {
+	return errors.Join(closeClient(scheduler.client), closeClient(scheduler.store))
+}
+
+func (scheduler *Scheduler) enqueue(task PeriodicTask) {
+	ctx := context.Background()
+	run := PeriodicTaskRun{
+		EnqueuedAt: time.Now(),
+		Status:     PeriodicTaskEnqueued,
+	}
+
+	taskInfo, err := scheduler.client.EnqueueContext(
+		ctx,
+		asynq.NewTask(task.Type, nil),
+		asynq.Queue(task.Queue),
+		asynq.MaxRetry(periodicMaxRetry),
+	)
+	if err != nil {
+		run.Status = PeriodicTaskFailed
+		run.Error = fmt.Sprintf("failed to enqueue task: %s", err.Error())
+		log.Error().Err(err).Str("type", task.Type).Msg("failed to enqueue periodic task")
+	} else {
+		run.TaskID = taskInfo.ID
+		log.Info().
+			Str("id", taskInfo.ID).
+			Str("type", taskInfo.Type).
+			Str("queue", taskInfo.Queue).
+			Msg("enqueue periodic task")
+	}
+
+	if err = scheduler.store.RecordRun(ctx, task.Type, run); err != nil {
+		log.Error().Err(err).Str("type", task.Type).Msg("failed to record periodic task run")
+	}
+}
+
+// NewScheduler schedules the tasks, failing on an invalid cron expression.
+func NewScheduler(
+	store ScheduleStore,
+	client taskEnqueuer,
+	tasks []PeriodicTask,
+	lockTTL time.Duration,
+) (*Scheduler, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	scheduler := &Scheduler{
+		store:   store,
+		client:  client,
+		cron:    cron.New(cron.WithLocation(time.UTC)),
+		id:      fmt.Sprintf("%s:%d:%s", host, os.Getpid(), uuid.NewString()),
+		lockTTL: lockTTL,
+	}
+
+	for _, task := range tasks {
+		if len(task.Cron) == 0 {
+			continue
+		}
+
+		task := task
+
+		if _, err = scheduler.cron.AddFunc(task.Cron, func() { scheduler.enqueue(task) }); err != nil {
+			return nil, fmt.Errorf("invalid cron expression for %s: %w", task.Type, err)
+		}
+	}
+
+	return scheduler, nil
+}
+
+func NewRedisScheduler() (*Scheduler, error) {
+	rcopt := asynq.RedisClientOpt{
+		Addr: net.JoinHostPort(config.Redis.Host, config.Redis.Port),
+	}
+
+	return NewScheduler(
+		NewConfiguredRedisScheduleStore(),
+		asynq.NewClient(rcopt),
+		PeriodicTasks(),
+		config.App.SchedulerLockTTL,
+	)
+}
+
+const (
+	schedulerLockKey    = "scheduler:lock"
+	schedulerRunsPrefix = "scheduler:runs:"
+)
+
+var (
+	acquireLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+  return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+if redis.call("set", KEYS[1], ARGV[1], "nx", "px", ARGV[2]) then
+  return 1
+end
+return 0
+`)
+	releaseLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+  return redis.call("del", KEYS[1])
+end
+return 0
+`)
+)
+
+type RedisScheduleStore struct {
+	client redis.UniversalClient
+}
+
+func (store *RedisScheduleStore) Close() error {
+	return store.client.Close()
+}
+
+func (store *RedisScheduleStore) AcquireLock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	n, err := acquireLockScript.Run(ctx, store.client, []string{schedulerLockKey}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func (store *RedisScheduleStore) ReleaseLock(ctx context.Context, owner string) error {
+	return releaseLockScript.Run(ctx, store.client, []string{schedulerLockKey}, owner).Err()
+}
+
+func (store *RedisScheduleStore) RecordRun(ctx context.Context, taskType string, run PeriodicTaskRun) error {
+	values := []any{"status", run.Status, "error", run.Error}
+
+	if len(run.TaskID) > 0 {
+		values = append(values, "task_id", run.TaskID)
+	}
+
+	for field, t := range map[string]time.Time{
+		"enqueued_at": run.EnqueuedAt,
+		"started_at":  run.StartedAt,
+		"finished_at": run.FinishedAt,
+	} {
+		if !t.IsZero() {
+			values = append(values, field, t.Format(time.RFC3339Nano))
+		}
+	}
+
+	return store.client.HSet(ctx, schedulerRunsPrefix+taskType, values...).Err()
+}
+
+// LastRun returns a zero run for a task that never ran.
+func (store *RedisScheduleStore) LastRun(ctx context.Context, taskType string) (run PeriodicTaskRun, err error) {
+	var fields map[string]string
+
+	if fields, err = store.client.HGetAll(ctx, schedulerRunsPrefix+taskType).Result(); err != nil {
+		return run, err
+	}
+
+	run.TaskID = fields["task_id"]
+	run.Status = fields["status"]
+	run.Error = fields["error"]
+
+	for field, t := range map[string]*time.Time{
+		"enqueued_at": &run.EnqueuedAt,
+		"started_at":  &run.StartedAt,
+		"finished_at": &run.FinishedAt,
+	} {
+		if value, ok := fields[field]; ok {
+			if *t, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				return run, fmt.Errorf("invalid %s: %w", field, err)
+			}
+		}
+	}
+
+	return run, nil
+}
+
+func NewRedisScheduleStore(client redis.UniversalClient) ScheduleStore {
+	return &RedisScheduleStore{client: client}
+}
+
+func NewConfiguredRedisScheduleStore() ScheduleStore {
+	client := redis.NewClient(&redis.Options{
+		Addr: net.JoinHostPort(config.Redis.Host, config.Redis.Port),
+	})
+
+	return NewRedisScheduleStore(client)
+}
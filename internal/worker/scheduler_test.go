@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+)
+
+func TestSchedulerLock(t *testing.T) {
+	store := NewMemoryScheduleStore()
+	tasks := []PeriodicTask{{Type: TaskCleanupSessions, Cron: "@hourly", Queue: QueueDefault}}
+
+	first, err := NewScheduler(store, &recordingEnqueuer{}, tasks, time.Minute)
+	require.NoError(t, err)
+	second, err := NewScheduler(store, &recordingEnqueuer{}, tasks, time.Minute)
+	require.NoError(t, err)
+
+	first.campaign(context.TODO())
+	second.campaign(context.TODO())
+	require.True(t, first.active)
+	require.False(t, second.active)
+
+	first.resign()
+	require.False(t, first.active)
+
+	second.campaign(context.TODO())
+	require.True(t, second.active)
+	second.resign()
+}
+
+func TestNewSchedulerInvalidCron(t *testing.T) {
+	_, err := NewScheduler(
+		NewMemoryScheduleStore(),
+		&recordingEnqueuer{},
+		[]PeriodicTask{
+			{Type: TaskCleanupSessions, Cron: "", Queue: QueueDefault},
+			{Type: TaskReconcileAccounts, Cron: "every day", Queue: QueueDefault},
+		},
+		time.Minute,
+	)
+	require.ErrorContains(t, err, TaskReconcileAccounts)
+}
+
+func TestSchedulerEnqueue(t *testing.T) {
+	store := NewMemoryScheduleStore()
+	enqueuer := &recordingEnqueuer{}
+	task := PeriodicTask{Type: TaskReconcileAccounts, Cron: "0 3 * * *", Queue: QueueDefault}
+
+	scheduler, err := NewScheduler(store, enqueuer, []PeriodicTask{task}, time.Minute)
+	require.NoError(t, err)
+
+	scheduler.enqueue(task)
+	require.Len(t, enqueuer.tasks, 1)
+	require.Equal(t, TaskReconcileAccounts, enqueuer.tasks[0].Type())
+	require.Equal(t, PeriodicTaskEnqueued, store.runs[TaskReconcileAccounts].Status)
+	require.False(t, store.runs[TaskReconcileAccounts].EnqueuedAt.IsZero())
+
+	enqueuer.err = errors.New("redis down")
+	scheduler.enqueue(task)
+	require.Equal(t, PeriodicTaskFailed, store.runs[TaskReconcileAccounts].Status)
+	require.Contains(t, store.runs[TaskReconcileAccounts].Error, "redis down")
+}
+
+func TestProcessPeriodicTasks(t *testing.T) {
+	store := mocks.NewStore(t)
+	schedules := NewMemoryScheduleStore()
+	proc := &RedisTaskProcessor{store: store, schedules: schedules}
+
+	store.
+		EXPECT().
+		DeleteExpiredSessions(mock.AnythingOfType("context.todoCtx")).
+		Once().
+		Return(int64(3), nil)
+	store.
+		EXPECT().
+		DeleteExpiredMFAChallenges(mock.AnythingOfType("context.todoCtx")).
+		Once().
+		Return(int64(1), nil)
+	store.
+		EXPECT().
+		ListUnreconciledAccounts(mock.AnythingOfType("context.todoCtx"), int32(unreconciledAccountsLimit)).
+		Once().
+		Return([]db.ListUnreconciledAccountsRow{{ID: 7, Balance: 100, EntriesBalance: 90}}, nil)
+
+	handler := proc.periodic(proc.ProcessTaskCleanupSessions)
+	require.NoError(t, handler(context.TODO(), asynq.NewTask(TaskCleanupSessions, nil)))
+
+	run := schedules.runs[TaskCleanupSessions]
+	require.Equal(t, PeriodicTaskSucceeded, run.Status)
+	require.False(t, run.StartedAt.IsZero())
+	require.False(t, run.FinishedAt.Before(run.StartedAt))
+
+	handler = proc.periodic(proc.ProcessTaskReconcileAccounts)
+	err := handler(context.TODO(), asynq.NewTask(TaskReconcileAccounts, nil))
+	require.ErrorIs(t, err, ERR_UNRECONCILED_ACCOUNTS)
+	require.ErrorIs(t, err, asynq.SkipRetry)
+
+	run = schedules.runs[TaskReconcileAccounts]
+	require.Equal(t, PeriodicTaskFailed, run.Status)
+	require.Contains(t, run.Error, "1 accounts")
+}
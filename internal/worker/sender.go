@@ -2,7 +2,6 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -18,48 +17,18 @@ type PayloadSendVerifyEmail struct {
 
 const TaskSendVerifyEmail = "task:send_verify_email"
 
-func (distr *RedisTaskDistributor) DistributeTaskSendVerifyEmail(
-	ctx context.Context,
-	payload *PayloadSendVerifyEmail,
-	opts ...asynq.Option,
-) (err error) {
-	var (
-		bs   []byte
-		task *asynq.Task
-	)
+var SendVerifyEmail = RegisterTask(&TaskDefinition[PayloadSendVerifyEmail]{
+	Name:    TaskSendVerifyEmail,
+	Version: 1,
+	Options: []asynq.Option{asynq.Queue(QueueCritical), asynq.MaxRetry(10)},
+	Handle:  (*RedisTaskProcessor).sendVerifyEmail,
+})
 
-	if bs, err = json.Marshal(payload); err != nil {
-		return fmt.Errorf("failed to marshal task payload: %w", err)
-	}
-
-	task = asynq.NewTask(TaskSendVerifyEmail, bs, opts...)
-
-	if taskInfo, err := distr.client.EnqueueContext(ctx, task); err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
-	} else {
-		log.Info().
-			Str("id", taskInfo.ID).
-			Str("type", taskInfo.Type).
-			Bytes("payload", task.Payload()).
-			Int("retries", taskInfo.MaxRetry).
-			Str("queue", taskInfo.Queue).
-			Msg("enqueue task")
-	}
-
-	return nil
-}
-
-func (proc *RedisTaskProcessor) ProcessTaskSendVerifyEmail(
+func (proc *RedisTaskProcessor) sendVerifyEmail(
 	ctx context.Context,
-	task *asynq.Task,
+	payload *PayloadSendVerifyEmail,
 ) (err error) {
-	var (
-		payload PayloadSendVerifyEmail
-		user    db.User
-	)
-	if err = json.Unmarshal(task.Payload(), &payload); err != nil {
-		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
-	}
+	var user db.User
 
 	if user, err = proc.store.GetUser(ctx, payload.Username); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -72,8 +41,8 @@ func (proc *RedisTaskProcessor) ProcessTaskSendVerifyEmail(
 	// TODO: send email
 
 	log.Info().
-		Str("type", task.Type()).
-		Bytes("payload", task.Payload()).
+		Str("type", TaskSendVerifyEmail).
+		Str("username", payload.Username).
 		Str("email", user.Email).
 		Msg("processed task")
 
@@ -0,0 +1,207 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/webhook"
+)
+
+type PayloadDeliverWebhook struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+const TaskDeliverWebhook = "task:deliver_webhook"
+
+// DeliverWebhook is retried WebhookMaxRetry times, which is passed when it's
+// enqueued as it's only known once the configuration is loaded.
+var DeliverWebhook = RegisterTask(&TaskDefinition[PayloadDeliverWebhook]{
+	Name:    TaskDeliverWebhook,
+	Version: 1,
+	Options: []asynq.Option{asynq.Queue(QueueDefault)},
+	Handle:  (*RedisTaskProcessor).deliverWebhook,
+})
+
+// deliverWebhook posts a delivery to its endpoint and records the attempt.
+// Every failure counts against the endpoint, which is disabled, failing the
+// delivery for good, once it reaches WebhookMaxFailures in a row.
+func (proc *RedisTaskProcessor) deliverWebhook(
+	ctx context.Context,
+	payload *PayloadDeliverWebhook,
+) (err error) {
+	var (
+		delivery   db.WebhookDelivery
+		endpoint   db.WebhookEndpoint
+		statusCode int
+	)
+
+	if delivery, err = proc.store.GetWebhookDelivery(ctx, payload.DeliveryID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("webhook delivery doesn't exists: %w", asynq.SkipRetry)
+		}
+
+		return fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	if delivery.Status == webhook.StatusSucceeded {
+		return nil
+	}
+
+	if endpoint, err = proc.store.GetWebhookEndpoint(ctx, delivery.EndpointID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("webhook endpoint doesn't exists: %w", asynq.SkipRetry)
+		}
+
+		return fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+
+	if endpoint.DisabledAt.Valid {
+		return fmt.Errorf("webhook endpoint is disabled: %w", asynq.SkipRetry)
+	}
+
+	statusCode, err = webhook.Deliver(ctx, proc.httpClient, endpoint, delivery)
+	if err == nil {
+		return proc.recordWebhookSuccess(ctx, endpoint, delivery, statusCode)
+	}
+
+	return proc.recordWebhookFailure(ctx, endpoint, delivery, statusCode, err)
+}
+
+func (proc *RedisTaskProcessor) recordWebhookSuccess(
+	ctx context.Context,
+	endpoint db.WebhookEndpoint,
+	delivery db.WebhookDelivery,
+	statusCode int,
+) (err error) {
+	if _, err = proc.store.RecordWebhookDeliveryAttempt(ctx, db.RecordWebhookDeliveryAttemptParams{
+		ID:             delivery.ID,
+		Status:         webhook.StatusSucceeded,
+		ResponseStatus: pgtype.Int4{Int32: int32(statusCode), Valid: true},
+		DeliveredAt:    pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}); err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+
+	if endpoint.ConsecutiveFailures > 0 {
+		if err = proc.store.RecordWebhookEndpointSuccess(ctx, endpoint.ID); err != nil {
+			return fmt.Errorf("failed to reset webhook endpoint failures: %w", err)
+		}
+	}
+
+	log.Info().
+		Str("type", TaskDeliverWebhook).
+		Int64("delivery_id", delivery.ID).
+		Int64("endpoint_id", endpoint.ID).
+		Int("status", statusCode).
+		Msg("processed task")
+
+	return nil
+}
+
+func (proc *RedisTaskProcessor) recordWebhookFailure(
+	ctx context.Context,
+	endpoint db.WebhookEndpoint,
+	delivery db.WebhookDelivery,
+	statusCode int,
+	deliverErr error,
+) (err error) {
+	arg := db.RecordWebhookDeliveryAttemptParams{
+		ID:        delivery.ID,
+		Status:    webhook.StatusRetrying,
+		LastError: pgtype.Text{String: deliverErr.Error(), Valid: true},
+	}
+
+	if statusCode != 0 {
+		arg.ResponseStatus = pgtype.Int4{Int32: int32(statusCode), Valid: true}
+	}
+
+	if endpoint, err = proc.store.RecordWebhookEndpointFailure(ctx, db.RecordWebhookEndpointFailureParams{
+		ID:          endpoint.ID,
+		MaxFailures: config.App.WebhookMaxFailures,
+	}); err != nil {
+		return fmt.Errorf("failed to record webhook endpoint failure: %w", err)
+	}
+
+	if endpoint.DisabledAt.Valid || isLastRetry(ctx) {
+		arg.Status = webhook.StatusFailed
+	}
+
+	if _, err = proc.store.RecordWebhookDeliveryAttempt(ctx, arg); err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+
+	if endpoint.DisabledAt.Valid {
+		log.Warn().
+			Int64("endpoint_id", endpoint.ID).
+			Int32("failures", endpoint.ConsecutiveFailures).
+			Msg("disabled webhook endpoint")
+
+		return fmt.Errorf("%w: %w", deliverErr, asynq.SkipRetry)
+	}
+
+	return deliverErr
+}
+
+// isLastRetry reports whether the task being processed has no retries left.
+func isLastRetry(ctx context.Context) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	return ok && retried >= maxRetry
+}
+
+// EnqueueTransferWebhooks records a delivery of the transfer to every active
+// endpoint of its accounts and writes their tasks to the outbox with q, so
+// they're only sent if the transfer commits.
+func EnqueueTransferWebhooks(ctx context.Context, q db.Querier, result db.TransferTxResult) (err error) {
+	var endpoints []db.WebhookEndpoint
+
+	if endpoints, err = q.ListActiveWebhookEndpoints(
+		ctx,
+		[]int64{result.Transfer.FromAccountID, result.Transfer.ToAccountID},
+	); err != nil {
+		return fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+
+	distributor := NewOutboxTaskDistributor(q)
+
+	for _, endpoint := range endpoints {
+		var (
+			delivery db.WebhookDelivery
+			payload  []byte
+		)
+
+		eventType, data := webhook.NewTransferData(result, endpoint.AccountID)
+
+		if payload, err = webhook.NewPayload(eventType, data); err != nil {
+			return err
+		}
+
+		if delivery, err = q.CreateWebhookDelivery(ctx, db.CreateWebhookDeliveryParams{
+			EndpointID: endpoint.ID,
+			EventType:  eventType,
+			Payload:    payload,
+		}); err != nil {
+			return fmt.Errorf("failed to create webhook delivery: %w", err)
+		}
+
+		if err = DeliverWebhook.Enqueue(
+			ctx,
+			distributor,
+			&PayloadDeliverWebhook{DeliveryID: delivery.ID},
+			asynq.MaxRetry(config.App.WebhookMaxRetry),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dharmavagabond/simple-bank/internal/config"
+	db "github.com/dharmavagabond/simple-bank/internal/db/sqlc"
+	"github.com/dharmavagabond/simple-bank/internal/mocks"
+	"github.com/dharmavagabond/simple-bank/internal/webhook"
+)
+
+func TestDeliverWebhook(t *testing.T) {
+	var received []string
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		received = append(received, req.Header.Get(webhook.HeaderSignature))
+
+		if req.URL.Path == "/down" {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	delivery := db.WebhookDelivery{
+		ID:         5,
+		EndpointID: 2,
+		EventType:  webhook.EventTransferOut,
+		Payload:    []byte(`{"id":"1"}`),
+		Status:     webhook.StatusPending,
+	}
+	payload, err := json.Marshal(PayloadDeliverWebhook{DeliveryID: delivery.ID})
+	require.NoError(t, err)
+	task := asynq.NewTask(DeliverWebhook.Type(), payload)
+
+	testCases := []struct {
+		name       string
+		endpoint   db.WebhookEndpoint
+		buildStubs func(store *mocks.Store, endpoint db.WebhookEndpoint)
+		checkErr   func(t *testing.T, err error)
+		deliveries int
+	}{
+		{
+			name:     "OK",
+			endpoint: db.WebhookEndpoint{ID: 2, Url: receiver.URL, Secret: "secret", ConsecutiveFailures: 3},
+			buildStubs: func(store *mocks.Store, endpoint db.WebhookEndpoint) {
+				store.
+					EXPECT().
+					RecordWebhookDeliveryAttempt(
+						mock.AnythingOfType("context.todoCtx"),
+						mock.MatchedBy(func(arg db.RecordWebhookDeliveryAttemptParams) bool {
+							return arg.ID == delivery.ID &&
+								arg.Status == webhook.StatusSucceeded &&
+								arg.ResponseStatus.Int32 == http.StatusOK &&
+								arg.DeliveredAt.Valid
+						}),
+					).
+					Once().
+					Return(db.WebhookDelivery{}, nil)
+				store.
+					EXPECT().
+					RecordWebhookEndpointSuccess(mock.AnythingOfType("context.todoCtx"), endpoint.ID).
+					Once().
+					Return(nil)
+			},
+			checkErr: func(t *testing.T, err error) {
+				t.Helper()
+				require.NoError(t, err)
+			},
+			deliveries: 1,
+		},
+		{
+			name:     "ReceiverDown",
+			endpoint: db.WebhookEndpoint{ID: 2, Url: receiver.URL + "/down", Secret: "secret"},
+			buildStubs: func(store *mocks.Store, endpoint db.WebhookEndpoint) {
+				store.
+					EXPECT().
+					RecordWebhookEndpointFailure(
+						mock.AnythingOfType("context.todoCtx"),
+						db.RecordWebhookEndpointFailureParams{
+							ID:          endpoint.ID,
+							MaxFailures: config.App.WebhookMaxFailures,
+						},
+					).
+					Once().
+					Return(db.WebhookEndpoint{ID: endpoint.ID, ConsecutiveFailures: 1}, nil)
+				store.
+					EXPECT().
+					RecordWebhookDeliveryAttempt(
+						mock.AnythingOfType("context.todoCtx"),
+						mock.MatchedBy(func(arg db.RecordWebhookDeliveryAttemptParams) bool {
+							return arg.Status == webhook.StatusRetrying &&
+								arg.ResponseStatus.Int32 == http.StatusInternalServerError &&
+								arg.LastError.Valid
+						}),
+					).
+					Once().
+					Return(db.WebhookDelivery{}, nil)
+			},
+			checkErr: func(t *testing.T, err error) {
+				t.Helper()
+				require.ErrorIs(t, err, webhook.ERR_UNEXPECTED_STATUS)
+				require.NotErrorIs(t, err, asynq.SkipRetry)
+			},
+			deliveries: 1,
+		},
+		{
+			name:     "DisablesEndpoint",
+			endpoint: db.WebhookEndpoint{ID: 2, Url: receiver.URL + "/down", Secret: "secret"},
+			buildStubs: func(store *mocks.Store, endpoint db.WebhookEndpoint) {
+				store.
+					EXPECT().
+					RecordWebhookEndpointFailure(mock.AnythingOfType("context.todoCtx"), mock.Anything).
+					Once().
+					Return(db.WebhookEndpoint{
+						ID:         endpoint.ID,
+						DisabledAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+					}, nil)
+				store.
+					EXPECT().
+					RecordWebhookDeliveryAttempt(
+						mock.AnythingOfType("context.todoCtx"),
+						mock.MatchedBy(func(arg db.RecordWebhookDeliveryAttemptParams) bool {
+							return arg.Status == webhook.StatusFailed
+						}),
+					).
+					Once().
+					Return(db.WebhookDelivery{}, nil)
+			},
+			checkErr: func(t *testing.T, err error) {
+				t.Helper()
+				require.ErrorIs(t, err, asynq.SkipRetry)
+			},
+			deliveries: 1,
+		},
+		{
+			name: "EndpointDisabled",
+			endpoint: db.WebhookEndpoint{
+				ID:         2,
+				Url:        receiver.URL,
+				DisabledAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			},
+			buildStubs: func(store *mocks.Store, endpoint db.WebhookEndpoint) {},
+			checkErr: func(t *testing.T, err error) {
+				t.Helper()
+				require.ErrorIs(t, err, asynq.SkipRetry)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			received = nil
+			store := mocks.NewStore(t)
+			store.
+				EXPECT().
+				GetWebhookDelivery(mock.AnythingOfType("context.todoCtx"), delivery.ID).
+				Once().
+				Return(delivery, nil)
+			store.
+				EXPECT().
+				GetWebhookEndpoint(mock.AnythingOfType("context.todoCtx"), delivery.EndpointID).
+				Once().
+				Return(tc.endpoint, nil)
+			tc.buildStubs(store, tc.endpoint)
+
+			proc := &RedisTaskProcessor{store: store, httpClient: receiver.Client()}
+			tc.checkErr(t, proc.handler().ProcessTask(context.TODO(), task))
+			require.Len(t, received, tc.deliveries)
+		})
+	}
+}
+
+func TestEnqueueTransferWebhooks(t *testing.T) {
+	store := mocks.NewStore(t)
+	result := db.TransferTxResult{
+		Transfer:    db.Transfer{ID: 1, FromAccountID: 10, ToAccountID: 20, Amount: 5},
+		FromAccount: db.Account{ID: 10, Balance: 95, Currency: "USD"},
+		ToAccount:   db.Account{ID: 20, Balance: 105, Currency: "USD"},
+	}
+
+	store.
+		EXPECT().
+		ListActiveWebhookEndpoints(mock.AnythingOfType("context.todoCtx"), []int64{10, 20}).
+		Once().
+		Return([]db.WebhookEndpoint{{ID: 1, AccountID: 20}}, nil)
+	store.
+		EXPECT().
+		CreateWebhookDelivery(
+			mock.AnythingOfType("context.todoCtx"),
+			mock.MatchedBy(func(arg db.CreateWebhookDeliveryParams) bool {
+				var payload struct {
+					Type string               `json:"type"`
+					Data webhook.TransferData `json:"data"`
+				}
+
+				return json.Unmarshal(arg.Payload, &payload) == nil &&
+					arg.EndpointID == 1 &&
+					arg.EventType == webhook.EventTransferIn &&
+					payload.Type == webhook.EventTransferIn &&
+					payload.Data.AccountID == 20 &&
+					payload.Data.Balance == 105
+			}),
+		).
+		Once().
+		Return(db.WebhookDelivery{ID: 8}, nil)
+	store.
+		EXPECT().
+		CreateTaskOutbox(
+			mock.AnythingOfType("context.todoCtx"),
+			mock.MatchedBy(func(arg db.CreateTaskOutboxParams) bool {
+				return arg.TaskType == TaskDeliverWebhook &&
+					string(arg.Payload) == `{"delivery_id":8}`
+			}),
+		).
+		Once().
+		Return(db.TaskOutbox{ID: 1, TaskType: TaskDeliverWebhook}, nil)
+
+	require.NoError(t, EnqueueTransferWebhooks(context.TODO(), store, result))
+}
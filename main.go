@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
@@ -13,6 +16,8 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/encoding/protojson"
 
 	_ "github.com/dharmavagabond/simple-bank/doc/statik"
@@ -27,40 +32,71 @@ func init() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 }
 
+// main runs the servers until SIGINT or SIGTERM, or until one of them fails,
+// then shuts them all down, letting requests and tasks in progress finish
+// within the shutdown timeout.
 func main() {
-	var eg errgroup.Group
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
+	eg, ctx := errgroup.WithContext(ctx)
 	store := db.NewStore()
 	taskDistributor := worker.NewRedisTaskDistributor()
 
 	eg.Go(func() (err error) {
-		if err = runGatewayServer(store, taskDistributor); err != nil {
+		if err = runGatewayServer(ctx); err != nil {
 			err = fmt.Errorf("gateway server: %w", err)
 		}
 
 		return err
 	})
 	eg.Go(func() (err error) {
-		if err = runGrpcServer(store, taskDistributor); err != nil {
+		if err = runGrpcServer(ctx, store, taskDistributor); err != nil {
 			err = fmt.Errorf("gRPC server: %w", err)
 		}
 
 		return err
 	})
 	eg.Go(func() (err error) {
-		if err = runTaskProcessor(store); err != nil {
+		if err = runTaskProcessor(ctx, store); err != nil {
 			err = fmt.Errorf("failed to run task processor: %w", err)
 		}
 
 		return err
 	})
+	eg.Go(func() (err error) {
+		if err = runOutboxRelay(ctx, store); err != nil {
+			err = fmt.Errorf("failed to run outbox relay: %w", err)
+		}
+
+		return err
+	})
+
+	eg.Go(func() (err error) {
+		if err = runScheduler(ctx); err != nil {
+			err = fmt.Errorf("failed to run scheduler: %w", err)
+		}
+
+		return err
+	})
+
+	err := eg.Wait()
+
+	if cerr := taskDistributor.Close(); cerr != nil {
+		log.Error().Err(cerr).Msg("failed to close task distributor")
+	}
+
+	store.Close()
 
-	if err := eg.Wait(); err != nil {
+	if err != nil {
 		log.Fatal().Err(err).Msg("Err")
 	}
+
+	log.Info().Msg("shut down")
 }
 
 func runGrpcServer(
+	ctx context.Context,
 	store db.Store,
 	taskDistributor worker.TaskDistributor,
 ) error {
@@ -73,20 +109,24 @@ func runGrpcServer(
 		return err
 	}
 
-	return server.Start()
+	defer server.Close()
+
+	return server.Start(ctx)
 }
 
-func runGatewayServer(
-	store db.Store,
-	taskDistributor worker.TaskDistributor,
-) error {
+// runGatewayServer proxies to the gRPC server, rather than calling it in
+// process, so that its interceptors also apply to gateway requests.
+func runGatewayServer(ctx context.Context) error {
 	var (
-		server   *grpc.Server
 		statikFs http.FileSystem
 		err      error
 	)
 
 	addr := net.JoinHostPort(config.App.Host, strconv.Itoa(config.App.HTTPPort))
+	grpcAddr := net.JoinHostPort(config.App.Host, strconv.Itoa(config.App.GrpcPort))
+	dialOptions := []ggrpc.DialOption{
+		ggrpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
 	jsonOption := runtime.WithMarshalerOption(
 		runtime.MIMEWildcard,
 		&runtime.JSONPb{
@@ -99,17 +139,21 @@ func runGatewayServer(
 		},
 	)
 	grpcMux := runtime.NewServeMux(jsonOption)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// The connection outlives ctx so requests in progress at shutdown finish.
+	connCtx, cancelConn := context.WithCancel(context.Background())
+	defer cancelConn()
 
-	if server, err = grpc.NewServer(store, taskDistributor); err != nil {
+	if err = pb.RegisterSimpleBankServiceHandlerFromEndpoint(connCtx, grpcMux, grpcAddr, dialOptions); err != nil {
 		return err
 	}
 
-	if err = pb.RegisterSimpleBankServiceHandlerServer(ctx, grpcMux, server); err != nil {
+	conn, err := ggrpc.Dial(grpcAddr, dialOptions...)
+	if err != nil {
 		return err
 	}
 
+	defer conn.Close()
+
 	mux := http.NewServeMux()
 
 	if statikFs, err = fs.New(); err != nil {
@@ -120,6 +164,12 @@ func runGatewayServer(
 
 	mux.Handle("/", grpcMux)
 	mux.Handle("/swagger/", swaggerHandler)
+	// Shutdown doesn't cancel the requests in progress, so the SSE streams
+	// are ended through sseShutdown.
+	sseShutdown := make(chan struct{})
+	sseHandler := grpc.NewWatchAccountSSEHandler(pb.NewSimpleBankServiceClient(conn), sseShutdown)
+
+	mux.Handle(grpc.WatchAccountSSEPath, sseHandler)
 
 	srv := &http.Server{
 		Handler:      grpc.HTTPLogger(mux),
@@ -127,14 +177,79 @@ func runGatewayServer(
 		ReadTimeout:  time.Second * 5,
 		WriteTimeout: time.Second * 10,
 	}
+	srv.RegisterOnShutdown(func() { close(sseShutdown) })
 
 	log.Info().Msgf("Listening HTTP gateway at %s", srv.Addr)
 
-	return srv.ListenAndServe()
+	served := make(chan error, 1)
+
+	go func() {
+		served <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info().Msg("shutting down HTTP gateway")
+
+	// The shutdown gets its own deadline, as ctx is already done.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.App.ShutdownTimeout)
+	defer cancel()
+
+	if err = srv.Shutdown(shutdownCtx); errors.Is(err, context.DeadlineExceeded) {
+		log.Warn().Msg("HTTP gateway shutdown timed out, closing connections in progress")
+		err = srv.Close()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
 }
 
-func runTaskProcessor(store db.Store) error {
+// runTaskProcessor runs the task processor until ctx is done. Its server
+// runs in the background, so it's shut down here.
+func runTaskProcessor(ctx context.Context, store db.Store) error {
 	proc := worker.NewRedisTaskProcessor(store)
 	log.Info().Msg("start task processor")
-	return proc.Start()
+
+	if err := proc.Start(); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	log.Info().Msg("shutting down task processor")
+	proc.Shutdown()
+
+	return nil
+}
+
+func runOutboxRelay(ctx context.Context, store db.Store) error {
+	relay := worker.NewOutboxRelay(store)
+	defer relay.Close()
+
+	log.Info().Msg("start outbox relay")
+
+	return relay.Start(ctx)
+}
+
+func runScheduler(ctx context.Context) error {
+	scheduler, err := worker.NewRedisScheduler()
+	if err != nil {
+		return err
+	}
+
+	defer scheduler.Close()
+
+	log.Info().Msg("start scheduler")
+
+	return scheduler.Start(ctx)
 }